@@ -40,6 +40,14 @@ func (h *ApiHandler) AdminToggleFavoriteEventDate(gc *gin.Context) {
 			return txErr
 		}
 
+		ok, err := h.isOrgFavoriteListTx(ctx, tx, payload.OrgUuid, payload.FavoriteListUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return ApiErrNotFound("favorite list not found in organization")
+		}
+
 		// First try deleting existing favorite
 		deleteQuery := fmt.Sprintf(`
 		DELETE FROM %s.favorite
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminCancelEventRegistration cancels a registration for an event date on
+// behalf of the organization. The visitor is notified by email and seats
+// which become free are given to the waitlist.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminCancelEventRegistration(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-cancel-event-registration")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateUuid")
+	registrationUuid := gc.Param("registrationUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+	apiRequest.SetMeta("registration_uuid", registrationUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var dateFound bool
+		query := fmt.Sprintf(
+			`SELECT EXISTS (SELECT 1 FROM %s.event_date WHERE uuid::text = $1 AND event_uuid::text = $2)`,
+			h.DbSchema)
+		if err := tx.QueryRow(ctx, query, eventDateUuid, eventUuid).Scan(&dateFound); err != nil {
+			return TxInternalError(err)
+		}
+		if !dateFound {
+			return ApiErrNotFound("event date not found")
+		}
+
+		if err := lockEventDateSeatsTx(ctx, tx, eventDateUuid); err != nil {
+			return TxInternalError(err)
+		}
+
+		r := registrationRow{EventDateUuid: eventDateUuid}
+		query = fmt.Sprintf(`
+			SELECT uuid, name, email, seats, lang, status, confirm_expires_at
+			FROM %s.event_registration
+			WHERE uuid::text = $1 AND event_date_uuid = $2::uuid
+			FOR UPDATE`,
+			h.DbSchema)
+		err := tx.QueryRow(ctx, query, registrationUuid, eventDateUuid).Scan(
+			&r.Uuid, &r.Name, &r.Email, &r.Seats, &r.Lang, &r.Status, &r.ConfirmExpiresAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("registration not found")
+			}
+			return TxInternalError(err)
+		}
+		if r.Status == registrationCancelled {
+			return NewApiTxError(http.StatusConflict, "registration is already cancelled")
+		}
+
+		if txErr := h.cancelEventRegistrationTx(ctx, tx, r, "organizer"); txErr != nil {
+			return txErr
+		}
+
+		orgUuid, err := h.GetOrgUuidByEventUuidTx(gc, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(
+			gc, tx, orgUuid, auditEntityEvent, eventUuid, "cancel-registration",
+			map[string]any{"registration_uuid": r.Uuid, "status": r.Status, "seats": r.Seats},
+			map[string]any{"registration_uuid": r.Uuid, "status": registrationCancelled})
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "registration cancelled")
+}
@@ -50,6 +50,14 @@ func (h *ApiHandler) AdminCheckFavoriteListEventDate(gc *gin.Context) {
 			return txErr
 		}
 
+		ok, err := h.isOrgFavoriteListTx(ctx, tx, payload.OrgUuid, payload.FavoriteListUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return ApiErrNotFound("favorite list not found in organization")
+		}
+
 		query := fmt.Sprintf(`
 			SELECT EXISTS (
 				SELECT 1
@@ -60,7 +68,7 @@ func (h *ApiHandler) AdminCheckFavoriteListEventDate(gc *gin.Context) {
 			)
 		`, h.DbSchema)
 
-		err := tx.QueryRow(
+		err = tx.QueryRow(
 			ctx,
 			query,
 			payload.FavoriteListUuid,
@@ -53,6 +53,7 @@ type eventPayload struct {
 	Custom            *string                           `json:"custom"`
 	Style             *string                           `json:"style"`
 	Dates             []model.EventDatePayload          `json:"dates" binding:"required"`
+	Recurrence        *model.EventRecurrencePayload     `json:"recurrence"`
 	TypeGenrePairs    []model.EventTypeGenrePairPayload `json:"types"`
 	ImageUrl          *string                           `json:"image_url"`
 	ImageTitle        *string                           `json:"image_title"`
@@ -186,7 +187,7 @@ func (h *ApiHandler) AdminCreateEvent(gc *gin.Context) {
 		if err != nil {
 			return &ApiTxError{
 				Code: http.StatusInternalServerError,
-				Err:  fmt.Errorf("failed to insert event: %v, userUuid: %d", err, userUuid),
+				Err:  fmt.Errorf("failed to insert event: %v, userUuid: %s", err, userUuid),
 			}
 		}
 
@@ -253,6 +254,22 @@ func (h *ApiHandler) AdminCreateEvent(gc *gin.Context) {
 			}
 		}
 
+		// Recurrence, generates the dates up to the rolling horizon
+		if payload.Recurrence != nil {
+			txErr := h.saveEventRecurrenceTx(gc, tx, newEventUuid, userUuid, *payload.Recurrence)
+			if txErr != nil {
+				return txErr
+			}
+
+			_, err = h.materializeEventRecurrenceTx(ctx, tx, newEventUuid, userUuid)
+			if err != nil {
+				return &ApiTxError{
+					Code: http.StatusInternalServerError,
+					Err:  fmt.Errorf("failed to generate recurring event dates: %v", err),
+				}
+			}
+		}
+
 		// Insert Type + Genre pairs
 		queryTemplate := `
 		INSERT INTO {{schema}}.event_type_link (event_id, type_id, genre_id)
@@ -272,6 +289,18 @@ func (h *ApiHandler) AdminCreateEvent(gc *gin.Context) {
 		// TODO: Insert languages
 		// TODO: Insert tags
 
+		err = h.recordEventRevisionTx(ctx, tx, newEventUuid, userUuid, "create", nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = h.writeAuditLogTx(
+			gc, tx, *payload.OrgUuid, auditEntityEvent, newEventUuid, "create",
+			nil, map[string]any{"title": payload.Title})
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{newEventUuid})
 		if err != nil {
 			debugf("Error: %v", err)
@@ -437,11 +466,24 @@ func (e *eventPayload) Validate() error {
 	// Validate ReleaseDate (optional)
 	if err := app.ValidateOptionalDate("release_date", e.ReleaseDate); err != nil {
 		errs = append(errs, err.Error())
+	} else if e.ReleaseStatus != nil {
+		// Released with a future release date means scheduled
+		status := releaseStatusForDate(*e.ReleaseStatus, e.ReleaseDate)
+		e.ReleaseStatus = &status
+	}
+
+	// Validate Recurrence (optional)
+	if e.Recurrence != nil {
+		if _, err := parseEventRecurrence(*e.Recurrence); err != nil {
+			errs = append(errs, fmt.Sprintf("recurrence: %v", err))
+		}
 	}
 
 	// Validate Dates
 	if len(e.Dates) == 0 {
-		errs = append(errs, "at least one date is required")
+		if e.Recurrence == nil {
+			errs = append(errs, "at least one date or a recurrence is required")
+		}
 	} else {
 		for i, date := range e.Dates {
 			// start_date
@@ -62,7 +62,7 @@ func (h *ApiHandler) AdminCreateOrg(gc *gin.Context) {
 
 		// Insert user_organization_link
 		insertLinkQuery := fmt.Sprintf(
-			`INSERT INTO %s.user_organization_link (user_uuid, org_uuid, permissions) VALUES ($1::uuid, $2::uuid, $3)`,
+			`INSERT INTO %s.user_organization_link (user_uuid, org_uuid, permissions, extra_permissions) VALUES ($1::uuid, $2::uuid, $3, $3)`,
 			h.DbSchema)
 		_, err = tx.Exec(gc, insertLinkQuery, userUuid, orgUuid, app.UserPermCombinationAdmin)
 		if err != nil {
@@ -84,6 +84,15 @@ func (h *ApiHandler) AdminCreateOrg(gc *gin.Context) {
 			}
 		}
 
+		after, err := h.loadAuditStateTx(ctx, tx, "organization", orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityOrg, orgUuid, "create", nil, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminCreateOrgApiKey creates an API key for an organization. permissions
+// are permission bits as used for team members, they must not exceed the
+// permissions of the creating user and must not include managing permissions
+// or the team. The key is only returned once.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminCreateOrgApiKey(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-create-org-api-key")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	type Payload struct {
+		Name        string     `json:"name" binding:"required"`
+		Permissions int64      `json:"permissions" binding:"required"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		apiRequest.Required("name is required")
+		return
+	}
+
+	permissions := app.Permissions(payload.Permissions)
+	if permissions == 0 {
+		apiRequest.Error(http.StatusBadRequest, "permissions are required")
+		return
+	}
+	if permissions.HasAny(apiKeyForbiddenPermissions) {
+		apiRequest.Error(http.StatusBadRequest, "api keys cannot manage permissions or the team")
+		return
+	}
+	orgPermissions, _ := gc.MustGet("org-permissions").(app.Permissions)
+	if !orgPermissions.HasAll(permissions) {
+		apiRequest.Error(http.StatusForbidden, "api keys cannot have permissions the user does not have")
+		return
+	}
+
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		apiRequest.Error(http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+
+	key, keyHash, keyPrefix, err := generateApiKey()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiKeyUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	var apiKey model.OrgApiKey
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			INSERT INTO %s.org_api_key
+				(uuid, org_uuid, name, key_prefix, key_hash, permissions, expires_at, created_by)
+			VALUES ($1::uuid, $2::uuid, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid)
+			RETURNING %s`,
+			h.DbSchema, orgApiKeyColumns)
+		apiKey, err = scanOrgApiKey(tx.QueryRow(ctx, query,
+			apiKeyUuid, orgUuid, payload.Name, keyPrefix, keyHash,
+			int64(permissions), payload.ExpiresAt, userUuid))
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "org_api_key", apiKeyUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityApiKey, apiKeyUuid, "create", nil, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusCreated, gin.H{
+		"api_key": apiKey,
+		"key":     key,
+	}, "store the key safely, it cannot be shown again")
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminCreateOrgOidcGroupMapping maps a group of an identity provider onto
+// the membership of an organization. Users in the group become members with
+// the given permissions when they log in with the provider, see
+// syncOidcMembershipsTx. The permissions must not exceed those of the
+// creating user.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminCreateOrgOidcGroupMapping(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-create-org-oidc-group-mapping")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	type Payload struct {
+		Provider    string `json:"provider" binding:"required"`
+		GroupName   string `json:"group_name" binding:"required"`
+		Permissions int64  `json:"permissions"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	provider, ok := h.Config.OidcProvider(payload.Provider)
+	if !ok {
+		apiRequest.Error(http.StatusBadRequest, "unknown identity provider")
+		return
+	}
+
+	payload.GroupName = strings.TrimSpace(payload.GroupName)
+	if payload.GroupName == "" {
+		apiRequest.Required("group_name is required")
+		return
+	}
+
+	permissions := app.Permissions(payload.Permissions)
+	orgPermissions, _ := gc.MustGet("org-permissions").(app.Permissions)
+	if !orgPermissions.HasAll(permissions) {
+		apiRequest.Error(http.StatusForbidden, "group mappings cannot grant permissions the user does not have")
+		return
+	}
+
+	mappingUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	var mapping model.OrgOidcGroupMapping
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			INSERT INTO %s.org_oidc_group_mapping (uuid, org_uuid, provider, group_name, permissions, created_by)
+			VALUES ($1::uuid, $2::uuid, $3, $4, $5, NULLIF($6, '')::uuid)
+			RETURNING %s`,
+			h.DbSchema, orgOidcGroupMappingColumns)
+		mapping, err = scanOrgOidcGroupMapping(tx.QueryRow(ctx, query,
+			mappingUuid, orgUuid, provider.Name, payload.GroupName, int64(permissions), userUuid))
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return NewApiTxError(http.StatusConflict, "group is already mapped")
+			}
+			return TxInternalError(err)
+		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "org_oidc_group_mapping", mappingUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityOidcGroupMapping, mappingUuid, "create", nil, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusCreated, mapping, "group mapping created successfully")
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminCreateOrgRole creates a member role of an organization bundling
+// permission bits. The permissions must not exceed those of the creating
+// user.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminCreateOrgRole(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-create-org-role")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	type Payload struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Permissions int64  `json:"permissions"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		apiRequest.Required("name is required")
+		return
+	}
+
+	orgPermissions, _ := gc.MustGet("org-permissions").(app.Permissions)
+	if !orgPermissions.HasAll(app.Permissions(payload.Permissions)) {
+		apiRequest.Error(http.StatusForbidden, "roles cannot have permissions the user does not have")
+		return
+	}
+
+	roleUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	var role model.OrgMemberRole
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			INSERT INTO %s.org_member_role (uuid, org_uuid, name, description, permissions, created_by)
+			VALUES ($1::uuid, $2::uuid, $3, $4, $5, NULLIF($6, '')::uuid)`,
+			h.DbSchema)
+		_, err := tx.Exec(ctx, query, roleUuid, orgUuid, payload.Name, payload.Description, payload.Permissions, userUuid)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return NewApiTxError(http.StatusConflict, "a role with this name already exists")
+			}
+			return TxInternalError(err)
+		}
+
+		role, err = scanOrgMemberRole(tx.QueryRow(ctx, h.orgMemberRoleQuery()+` WHERE r.uuid = $1::uuid`, roleUuid))
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "org_member_role", roleUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityRole, roleUuid, "create", nil, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusCreated, role, "role created successfully")
+}
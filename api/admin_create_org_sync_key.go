@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+
+// AdminCreateOrgSyncKey generates a new key for the event sync API of an
+// organization. A previous key stops working. The key is only returned once.
+func (h *ApiHandler) AdminCreateOrgSyncKey(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-create-org-sync-key")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	key, keyHash, err := generateOrgKey()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(
+			`UPDATE %s.organization SET sync_key_hash = $2, sync_key_created_at = NOW() WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		_, err := tx.Exec(ctx, query, orgUuid, keyHash)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntitySyncKey, orgUuid, "create", nil, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusCreated, gin.H{"org_key": key}, "store the key safely, it cannot be shown again")
+}
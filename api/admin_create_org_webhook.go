@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminCreateOrgWebhook registers a webhook endpoint for an organization.
+// With portal_uuid the webhook receives changes of the events shown in that
+// portal instead of the organization's own changes. The signing secret is
+// only returned once.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminCreateOrgWebhook(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-create-org-webhook")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	type Payload struct {
+		Url         string   `json:"url" binding:"required"`
+		PortalUuid  *string  `json:"portal_uuid"`
+		EventTypes  []string `json:"event_types"`
+		Description *string  `json:"description"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	payload.Url = strings.TrimSpace(payload.Url)
+	if err := validateWebhookUrl(payload.Url, h.Config.DevMode); err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+	if payload.EventTypes == nil {
+		payload.EventTypes = []string{}
+	}
+	if err := validateWebhookEventTypes(payload.EventTypes); err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	webhookUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	var webhook model.WebhookSubscription
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if payload.PortalUuid != nil {
+			ok, err := h.isOrgPortalTx(ctx, tx, orgUuid, *payload.PortalUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if !ok {
+				return ApiErrNotFound("portal not found in organization")
+			}
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s.webhook_subscription
+				(uuid, org_uuid, portal_uuid, url, secret, event_types, description, created_by)
+			VALUES ($1::uuid, $2::uuid, $3::uuid, $4, $5, $6, $7, $8::uuid)
+			RETURNING %s`,
+			h.DbSchema, webhookSubscriptionColumns)
+		webhook, err = scanWebhookSubscription(tx.QueryRow(ctx, query,
+			webhookUuid, orgUuid, payload.PortalUuid, payload.Url, secret,
+			payload.EventTypes, payload.Description, userUuid))
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "webhook_subscription", webhookUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityWebhook, webhookUuid, "create", nil, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusCreated, gin.H{
+		"webhook": webhook,
+		"secret":  secret,
+	}, "store the secret safely, it cannot be shown again")
+}
@@ -56,6 +56,16 @@ func (h *ApiHandler) AdminCreatePortal(gc *gin.Context) {
 				Err:  err,
 			}
 		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "portal", portalUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, payload.OrgUuid, auditEntityPortal, portalUuid, "create", nil, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
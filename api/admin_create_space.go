@@ -50,7 +50,7 @@ func (h *ApiHandler) AdminCreateSpace(gc *gin.Context) {
 		apiRequest.Metadata["space_uuid"] = spaceUuid
 		query := fmt.Sprintf(`
 			INSERT INTO %s.space (created_by, uuid, venue_uuid, name)
-			VALUES ($1::uuid, $2::uuid, $3::uuid, $4)`,
+			VALUES (NULLIF($1, '')::uuid, $2::uuid, $3::uuid, $4)`,
 			h.DbSchema)
 		_, err = tx.Exec(ctx, query, userUuid, spaceUuid, payload.VenueUuid, spaceName)
 		if err != nil {
@@ -59,6 +59,16 @@ func (h *ApiHandler) AdminCreateSpace(gc *gin.Context) {
 				Err:  err,
 			}
 		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "space", spaceUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, payload.OrgUuid, auditEntitySpace, spaceUuid, "create", nil, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
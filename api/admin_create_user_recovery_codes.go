@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminCreateUserRecoveryCodes replaces the recovery codes of the user after
+// checking a code of the authenticator app. The new codes are only shown
+// once.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminCreateUserRecoveryCodes(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-create-user-recovery-codes")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	var payload struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	var recoveryCodes []string
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		ok, err := h.verifyTotpTx(ctx, tx, userUuid, payload.Code, false)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return NewApiTxError(http.StatusUnauthorized, "invalid code")
+		}
+
+		recoveryCodes, err = h.replaceRecoveryCodesTx(ctx, tx, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"recovery_codes": recoveryCodes,
+	}, "recovery codes replaced, store them safely")
+}
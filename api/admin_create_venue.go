@@ -62,7 +62,7 @@ func (h *ApiHandler) AdminCreateVenue(gc *gin.Context) {
 
 		query := fmt.Sprintf(`
 			INSERT INTO %s.venue (uuid, created_by, org_uuid, name, scope)
-			VALUES ($1::uuid, $2::uuid, $3::uuid, $4, $5)`,
+			VALUES ($1::uuid, NULLIF($2, '')::uuid, $3::uuid, $4, $5)`,
 			h.DbSchema)
 		_, err = tx.Exec(ctx, query, venueUuid, userUuid, payload.OrgUuid, venueName, scope)
 		if err != nil {
@@ -72,6 +72,16 @@ func (h *ApiHandler) AdminCreateVenue(gc *gin.Context) {
 			}
 		}
 		apiRequest.Metadata["venue_uuid"] = venueUuid
+
+		after, err := h.loadAuditStateTx(ctx, tx, "venue", venueUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, payload.OrgUuid, auditEntityVenue, venueUuid, "create", nil, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
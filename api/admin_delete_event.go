@@ -41,6 +41,13 @@ func (h *ApiHandler) AdminDeleteEvent(gc *gin.Context) {
 			return txErr
 		}
 
+		// Revisions are deleted with the event, the audit log keeps the
+		// last state
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		query := fmt.Sprintf(
 			`DELETE FROM %s.event WHERE uuid = $1::uuid`,
 			h.DbSchema,
@@ -58,6 +65,11 @@ func (h *ApiHandler) AdminDeleteEvent(gc *gin.Context) {
 			}
 		}
 
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityEvent, eventUuid, "delete", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
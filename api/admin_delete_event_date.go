@@ -1,8 +1,10 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -48,22 +50,48 @@ func (h *ApiHandler) AdminDeleteEventDate(gc *gin.Context) {
 			return txErr
 		}
 
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		query := fmt.Sprintf(
-			`DELETE FROM %s.event_date WHERE uuid = $1::uuid AND event_uuid = $2::uuid`,
+			`DELETE FROM %s.event_date WHERE uuid = $1::uuid AND event_uuid = $2::uuid
+			RETURNING recurrence_generated, start_date`,
 			h.DbSchema,
 		)
-		cmdTag, err := tx.Exec(ctx, query, eventDateUuid, eventUuid)
+		var recurrenceGenerated bool
+		var startDate time.Time
+		err = tx.QueryRow(ctx, query, eventDateUuid, eventUuid).Scan(&recurrenceGenerated, &startDate)
 		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return &ApiTxError{
+					Code: http.StatusNotFound,
+					Err:  fmt.Errorf("event date not found"),
+				}
+			}
 			return TxInternalError(err)
 		}
 
-		if cmdTag.RowsAffected() == 0 {
-			return &ApiTxError{
-				Code: http.StatusNotFound,
-				Err:  fmt.Errorf("event date not found"),
+		// A deleted generated date becomes an exception of the recurrence rule,
+		// otherwise it would be generated again.
+		if recurrenceGenerated {
+			query = fmt.Sprintf(`
+				UPDATE %s.event_recurrence
+				SET exdates = array_append(exdates, $2::date), modified_at = NOW()
+				WHERE event_uuid = $1::uuid AND NOT ($2::date = ANY(exdates))`,
+				h.DbSchema)
+			_, err = tx.Exec(ctx, query, eventUuid, startDate)
+			if err != nil {
+				return TxInternalError(err)
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "delete-date", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
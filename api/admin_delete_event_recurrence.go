@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+
+// AdminDeleteEventRecurrence removes the recurrence rule of an event and
+// cancels all future dates generated from it. Past dates are kept.
+func (h *ApiHandler) AdminDeleteEventRecurrence(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-event-recurrence")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		deleted, err := h.deleteEventRecurrenceTx(ctx, tx, eventUuid, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !deleted {
+			return ApiErrNotFound("event has no recurrence")
+		}
+
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "delete-recurrence", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "event recurrence deleted successfully")
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/sndcds/grains/grains_api"
 )
 
@@ -26,17 +27,36 @@ func (h *ApiHandler) AdminDeleteOrg(gc *gin.Context) {
 	}
 	apiRequest.SetMeta("org_uuid", orgUuid)
 
-	query := fmt.Sprintf(`DELETE FROM %s.organization WHERE uuid = $1::uuid`, h.DbSchema)
-	cmdTag, err := h.DbPool.Exec(ctx, query, orgUuid)
-	if err != nil {
-		debugf(err.Error())
-		apiRequest.SetMeta("error", err.Error())
-		apiRequest.Error(http.StatusInternalServerError, "failed to delete organization")
-		return
-	}
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "organization", orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`DELETE FROM %s.organization WHERE uuid = $1::uuid`, h.DbSchema)
+		cmdTag, err := tx.Exec(ctx, query, orgUuid)
+		if err != nil {
+			apiRequest.SetMeta("error", err.Error())
+			return ApiErrInternal("failed to delete organization")
+		}
+
+		if cmdTag.RowsAffected() == 0 {
+			return ApiErrNotFound("organization not found")
+		}
+
+		// The audit log has no reference to the organization and keeps the
+		// entries of deleted organizations until they expire
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityOrg, orgUuid, "delete", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
 
-	if cmdTag.RowsAffected() == 0 {
-		apiRequest.Error(http.StatusNotFound, "organization not found")
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
 		return
 	}
 
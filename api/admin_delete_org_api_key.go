@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminDeleteOrgApiKey revokes an API key of an organization. The key is
+// kept, so that it still shows up as actor in the audit log.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminDeleteOrgApiKey(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-org-api-key")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	apiKeyUuid := gc.Param("apiKeyUuid")
+	if apiKeyUuid == "" {
+		apiRequest.Required("apiKeyUuid is required")
+		return
+	}
+	apiRequest.SetMeta("api_key_uuid", apiKeyUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "org_api_key", apiKeyUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s.org_api_key
+			SET revoked_at = NOW()
+			WHERE uuid = $1::uuid AND org_uuid = $2::uuid AND revoked_at IS NULL`,
+			h.DbSchema)
+		res, err := tx.Exec(ctx, query, apiKeyUuid, orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if res.RowsAffected() == 0 {
+			return ApiErrNotFound("api key not found")
+		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "org_api_key", apiKeyUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityApiKey, apiKeyUuid, "revoke", before, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "api key revoked successfully")
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminDeleteOrgOidcGroupMapping deletes a group mapping of an organization.
+// Members who joined by the mapping keep their membership until their next
+// login with the provider.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminDeleteOrgOidcGroupMapping(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-org-oidc-group-mapping")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	mappingUuid := gc.Param("mappingUuid")
+	if mappingUuid == "" {
+		apiRequest.Required("mappingUuid is required")
+		return
+	}
+	apiRequest.SetMeta("mapping_uuid", mappingUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "org_oidc_group_mapping", mappingUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(
+			`DELETE FROM %s.org_oidc_group_mapping WHERE uuid = $1::uuid AND org_uuid = $2::uuid`,
+			h.DbSchema)
+		res, err := tx.Exec(ctx, query, mappingUuid, orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if res.RowsAffected() == 0 {
+			return ApiErrNotFound("group mapping not found")
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityOidcGroupMapping, mappingUuid, "delete", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "group mapping deleted successfully")
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminDeleteOrgRole deletes a member role which no member holds anymore.
+// Invitations preassigning the role are kept without role.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminDeleteOrgRole(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-org-role")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	roleUuid := gc.Param("roleUuid")
+	if roleUuid == "" {
+		apiRequest.Required("roleUuid is required")
+		return
+	}
+	apiRequest.SetMeta("role_uuid", roleUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "org_member_role", roleUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(
+			`SELECT COUNT(*) FROM %s.user_organization_link WHERE role_uuid = $1::uuid`,
+			h.DbSchema)
+		var memberCount int64
+		err = tx.QueryRow(ctx, query, roleUuid).Scan(&memberCount)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if memberCount > 0 {
+			return NewApiTxError(http.StatusConflict, "role is held by %d members", memberCount)
+		}
+
+		query = fmt.Sprintf(
+			`DELETE FROM %s.org_member_role WHERE uuid = $1::uuid AND org_uuid = $2::uuid`,
+			h.DbSchema)
+		res, err := tx.Exec(ctx, query, roleUuid, orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if res.RowsAffected() == 0 {
+			return ApiErrNotFound("role not found")
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityRole, roleUuid, "delete", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "role deleted successfully")
+}
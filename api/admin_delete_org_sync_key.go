@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+
+// AdminDeleteOrgSyncKey revokes the event sync key of an organization.
+func (h *ApiHandler) AdminDeleteOrgSyncKey(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-org-sync-key")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(
+			`UPDATE %s.organization SET sync_key_hash = NULL, sync_key_created_at = NULL WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		_, err := tx.Exec(ctx, query, orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntitySyncKey, orgUuid, "revoke", nil, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "org sync key revoked")
+}
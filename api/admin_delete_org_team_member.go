@@ -44,6 +44,11 @@ func (h *ApiHandler) AdminDeleteOrgTeamMember(gc *gin.Context) {
 			return txErr
 		}
 
+		before, err := h.loadAuditMemberStateTx(ctx, tx, orgUuid, memberUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		query := fmt.Sprintf(
 			`DELETE FROM %s.organization_member_link
 			 WHERE org_uuid = $1::uuid
@@ -73,6 +78,11 @@ func (h *ApiHandler) AdminDeleteOrgTeamMember(gc *gin.Context) {
 			return TxInternalError(err)
 		}
 
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityMember, memberUuid, "delete", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminDeleteOrgWebhook deletes a webhook subscription including its
+// delivery log.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminDeleteOrgWebhook(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-org-webhook")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	webhookUuid := gc.Param("webhookUuid")
+	if webhookUuid == "" {
+		apiRequest.Required("webhookUuid is required")
+		return
+	}
+	apiRequest.SetMeta("webhook_uuid", webhookUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "webhook_subscription", webhookUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(
+			`DELETE FROM %s.webhook_subscription WHERE uuid = $1::uuid AND org_uuid = $2::uuid`,
+			h.DbSchema)
+		res, err := tx.Exec(ctx, query, webhookUuid, orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if res.RowsAffected() == 0 {
+			return ApiErrNotFound("webhook not found")
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityWebhook, webhookUuid, "delete", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "webhook deleted successfully")
+}
@@ -43,6 +43,11 @@ func (h *ApiHandler) AdminDeleteSpace(gc *gin.Context) {
 			return txErr
 		}
 
+		before, err := h.loadAuditStateTx(ctx, tx, "space", spaceUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		cmdTag, err := tx.Exec(ctx, query, spaceUuid)
 		if err != nil {
 			return &ApiTxError{
@@ -58,6 +63,11 @@ func (h *ApiHandler) AdminDeleteSpace(gc *gin.Context) {
 			}
 		}
 
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntitySpace, spaceUuid, "delete", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
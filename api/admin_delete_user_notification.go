@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminDeleteUserNotification deletes a notification of the user.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminDeleteUserNotification(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-user-notification")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	notificationUuid := gc.Param("notificationUuid")
+	if notificationUuid == "" {
+		apiRequest.Required("notificationUuid is required")
+		return
+	}
+	apiRequest.SetMeta("notification_uuid", notificationUuid)
+
+	query := fmt.Sprintf(
+		`DELETE FROM %s.notification WHERE uuid = $1::uuid AND user_uuid = $2::uuid`,
+		h.DbSchema)
+	res, err := h.DbPool.Exec(ctx, query, notificationUuid, userUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if res.RowsAffected() == 0 {
+		apiRequest.NotFound("notification not found")
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "notification deleted")
+}
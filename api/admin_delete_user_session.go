@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminDeleteUserSession revokes one of the sessions of the user, e.g. of a
+// lost device.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminDeleteUserSession(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-user-session")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	sessionUuid := gc.Param("sessionUuid")
+	if sessionUuid == "" {
+		apiRequest.Required("sessionUuid is required")
+		return
+	}
+	apiRequest.SetMeta("session_uuid", sessionUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		revoked, err := h.revokeSessionTx(ctx, tx, userUuid, sessionUuid, "revoked-by-user")
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !revoked {
+			return ApiErrNotFound("session not found")
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "session revoked")
+}
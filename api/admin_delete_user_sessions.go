@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminDeleteUserSessions signs the user out of all other devices by
+// revoking all sessions except the one of the request.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminDeleteUserSessions(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-user-sessions")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+	sessionUuid := h.sessionUuid(gc)
+
+	var revokedCount int64
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var err error
+		revokedCount, err = h.revokeUserSessionsTx(ctx, tx, userUuid, sessionUuid, "revoked-by-user")
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("revoked_count", revokedCount)
+	apiRequest.SuccessNoData(http.StatusOK, "other sessions revoked")
+}
@@ -1,10 +1,12 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/sndcds/grains/grains_api"
 )
 
@@ -26,16 +28,41 @@ func (h *ApiHandler) AdminDeleteVenue(gc *gin.Context) {
 	}
 	apiRequest.SetMeta("venue_uuid", venueUuid)
 
-	query := fmt.Sprintf(`DELETE FROM %s.venue WHERE uuid = $1::uuid`, h.DbSchema)
-	cmdTag, err := h.DbPool.Exec(ctx, query, venueUuid)
-	if err != nil {
-		debugf(err.Error())
-		apiRequest.InternalServerError()
-		return
-	}
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		orgUuid, err := h.GetOrgUuidByVenueUuidTx(gc, tx, venueUuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("venue not found")
+			}
+			return TxInternalError(err)
+		}
+
+		before, err := h.loadAuditStateTx(ctx, tx, "venue", venueUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`DELETE FROM %s.venue WHERE uuid = $1::uuid`, h.DbSchema)
+		cmdTag, err := tx.Exec(ctx, query, venueUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		if cmdTag.RowsAffected() == 0 {
+			return ApiErrNotFound("venue not found")
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityVenue, venueUuid, "delete", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
 
-	if cmdTag.RowsAffected() == 0 {
-		apiRequest.Error(http.StatusNotFound, "venue not found")
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
 		return
 	}
 
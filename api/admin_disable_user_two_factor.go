@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+)
+
+// AdminDisableUserTwoFactor turns two-factor authentication off. It requires
+// the password and a code or recovery code. Users holding permissions for
+// which an organization requires two-factor authentication cannot turn it
+// off.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminDisableUserTwoFactor(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-disable-user-two-factor")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	var payload struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+	if payload.Code == "" && payload.RecoveryCode == "" {
+		apiRequest.Required("code or recovery_code is required")
+		return
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`SELECT password_hash FROM %s.user WHERE uuid = $1::uuid`, h.DbSchema)
+		var passwordHash *string
+		err := tx.QueryRow(ctx, query, userUuid).Scan(&passwordHash)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("user not found")
+			}
+			return TxInternalError(err)
+		}
+		if passwordHash == nil || app.ComparePasswords(*passwordHash, payload.Password) != nil {
+			return NewApiTxError(http.StatusUnauthorized, "invalid password")
+		}
+
+		ok, err := h.verifySecondFactorTx(ctx, tx, userUuid, payload.Code, payload.RecoveryCode)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return NewApiTxError(http.StatusUnauthorized, "invalid code")
+		}
+
+		orgNames, err := h.twoFactorRequiringOrgsTx(ctx, tx, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if len(orgNames) > 0 {
+			return NewApiTxError(http.StatusConflict,
+				"two-factor authentication is required by: %s", strings.Join(orgNames, ", "))
+		}
+
+		query = fmt.Sprintf(`DELETE FROM %s.user_totp WHERE user_uuid = $1::uuid`, h.DbSchema)
+		_, err = tx.Exec(ctx, query, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query = fmt.Sprintf(`DELETE FROM %s.user_recovery_code WHERE user_uuid = $1::uuid`, h.DbSchema)
+		_, err = tx.Exec(ctx, query, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "two-factor authentication disabled")
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminEnableUserTwoFactor completes the enrollment started by
+// AdminSetupUserTwoFactor with a code of the authenticator app. It returns
+// the recovery codes, which are only shown once. Other sessions of the user
+// are revoked, they have not passed the second factor.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminEnableUserTwoFactor(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-enable-user-two-factor")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	var payload struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	var recoveryCodes []string
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		ok, err := h.verifyTotpTx(ctx, tx, userUuid, payload.Code, true)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return NewApiTxError(http.StatusBadRequest, "invalid code or no pending setup")
+		}
+
+		query := fmt.Sprintf(`UPDATE %s.user_totp SET enabled_at = NOW() WHERE user_uuid = $1::uuid`, h.DbSchema)
+		_, err = tx.Exec(ctx, query, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		recoveryCodes, err = h.replaceRecoveryCodesTx(ctx, tx, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		_, err = h.revokeUserSessionsTx(ctx, tx, userUuid, h.sessionUuid(gc), "two-factor-enabled")
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"recovery_codes": recoveryCodes,
+	}, "two-factor authentication enabled, store the recovery codes safely")
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+const ticketCountMaxSource = 100
+
+// AdminGetEventDateTicketCount returns the latest ticket count of an event
+// date pushed by an external ticketing system with the derived availability.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent or UserPermViewEventInsights, enforced
+// by RequireAnyOrgPermission middleware.
+func (h *ApiHandler) AdminGetEventDateTicketCount(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-date-ticket-count")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateIdentifier") // Shares the wildcard of GetEventByDate, only uuids
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	query := fmt.Sprintf(`
+		SELECT tc.event_date_uuid, tc.available, tc.capacity, tc.waitlist, tc.source, tc.reported_at,
+			ed.availability_status_id
+		FROM %[1]s.event_date_ticket_count tc
+		JOIN %[1]s.event_date ed ON ed.uuid = tc.event_date_uuid
+		WHERE tc.event_date_uuid::text = $1 AND ed.event_uuid::text = $2`,
+		h.DbSchema)
+	var c model.EventDateTicketCount
+	err := h.DbPool.QueryRow(ctx, query, eventDateUuid, eventUuid).Scan(
+		&c.EventDateUuid, &c.Available, &c.Capacity, &c.Waitlist, &c.Source, &c.ReportedAt,
+		&c.AvailabilityStatusId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("no ticket count reported for this event date")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, c, "")
+}
+
+// AdminPushEventDateTicketCount stores the ticket count of an event date sold
+// by an external ticketing system. The count replaces the previous one and
+// takes precedence over tickets and registrations when the availability of
+// the date is derived.
+//
+// PermissionNote: User must be authenticated, ticketing systems use an API
+// key of the organization.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminPushEventDateTicketCount(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-push-event-date-ticket-count")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	var payload model.EventDateTicketCountPayload
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+	if *payload.Available < 0 {
+		apiRequest.Error(http.StatusBadRequest, "available must not be negative")
+		return
+	}
+	if payload.Capacity != nil && *payload.Capacity < *payload.Available {
+		apiRequest.Error(http.StatusBadRequest, "capacity must not be lower than available")
+		return
+	}
+	var source *string
+	if payload.Source != nil {
+		if s := strings.TrimSpace(*payload.Source); s != "" {
+			if len(s) > ticketCountMaxSource {
+				apiRequest.Error(http.StatusBadRequest,
+					fmt.Sprintf("source must not be longer than %d characters", ticketCountMaxSource))
+				return
+			}
+			source = &s
+		}
+	}
+
+	var statusId *int
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if err := lockEventDateSeatsTx(ctx, tx, eventDateUuid); err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %[1]s.event_date_ticket_count
+				(event_date_uuid, available, capacity, waitlist, source, reported_at, reported_by)
+			SELECT ed.uuid, $3, $4, $5, $6, NOW(), NULLIF($7, '')::uuid
+			FROM %[1]s.event_date ed
+			WHERE ed.uuid::text = $1 AND ed.event_uuid::text = $2
+			ON CONFLICT (event_date_uuid) DO UPDATE SET
+				available = EXCLUDED.available,
+				capacity = EXCLUDED.capacity,
+				waitlist = EXCLUDED.waitlist,
+				source = EXCLUDED.source,
+				reported_at = EXCLUDED.reported_at,
+				reported_by = EXCLUDED.reported_by`,
+			h.DbSchema)
+		tag, err := tx.Exec(ctx, query,
+			eventDateUuid, eventUuid, *payload.Available, payload.Capacity, payload.Waitlist, source, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ApiErrNotFound("event date not found")
+		}
+
+		// Ticketing systems push after every sale, so there is no audit log
+		if err := h.updateAvailabilityTx(ctx, tx, []string{eventDateUuid}); err != nil {
+			return TxInternalError(err)
+		}
+
+		query = fmt.Sprintf(`SELECT availability_status_id FROM %s.event_date WHERE uuid = $1::uuid`, h.DbSchema)
+		if err := tx.QueryRow(ctx, query, eventDateUuid).Scan(&statusId); err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"event_date_uuid":        eventDateUuid,
+		"availability_status_id": statusId,
+	}, "ticket count stored")
+}
+
+// AdminDeleteEventDateTicketCount removes the ticket count of an event date,
+// its availability is derived from tickets and registrations again.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminDeleteEventDateTicketCount(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-event-date-ticket-count")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if err := lockEventDateSeatsTx(ctx, tx, eventDateUuid); err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`
+			DELETE FROM %[1]s.event_date_ticket_count tc
+			USING %[1]s.event_date ed
+			WHERE ed.uuid = tc.event_date_uuid AND tc.event_date_uuid::text = $1 AND ed.event_uuid::text = $2`,
+			h.DbSchema)
+		tag, err := tx.Exec(ctx, query, eventDateUuid, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ApiErrNotFound("no ticket count reported for this event date")
+		}
+
+		if err := h.updateAvailabilityTx(ctx, tx, []string{eventDateUuid}); err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "ticket count removed")
+}
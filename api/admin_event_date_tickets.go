@@ -0,0 +1,404 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetEventDateTickets returns the tickets of an event date, optionally
+// filtered by status, with the counts of issued and checked in tickets.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent or UserPermViewEventInsights, enforced
+// by RequireAnyOrgPermission middleware.
+func (h *ApiHandler) AdminGetEventDateTickets(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-date-tickets")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateIdentifier") // Shares the wildcard of GetEventByDate, only uuids
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	status := gc.Query("status")
+	if status != "" && status != ticketValid && status != ticketUsed && status != ticketCancelled {
+		apiRequest.Error(http.StatusBadRequest, "status must be valid, used or cancelled")
+		return
+	}
+
+	query := fmt.Sprintf(`
+		SELECT t.uuid, t.order_uuid, t.event_date_uuid, t.price_tier_uuid, pt.name, t.holder_name, t.holder_email,
+			t.amount::float8, t.currency, t.payment, t.status, t.issued_at, t.checked_in_at, t.checked_in_device,
+			t.cancelled_at
+		FROM %[1]s.event_ticket t
+		JOIN %[1]s.event_date ed ON ed.uuid = t.event_date_uuid
+		JOIN %[1]s.event_price_tier pt ON pt.uuid = t.price_tier_uuid
+		WHERE t.event_date_uuid::text = $1 AND ed.event_uuid::text = $2 AND ($3 = '' OR t.status = $3)
+		ORDER BY t.issued_at, t.uuid`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, eventDateUuid, eventUuid, status)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	tickets := make([]model.EventTicket, 0)
+	counts := map[string]int{ticketValid: 0, ticketUsed: 0, ticketCancelled: 0}
+	for rows.Next() {
+		var t model.EventTicket
+		err := rows.Scan(
+			&t.Uuid, &t.OrderUuid, &t.EventDateUuid, &t.PriceTierUuid, &t.PriceTierName, &t.HolderName,
+			&t.HolderEmail, &t.Amount, &t.Currency, &t.Payment, &t.Status, &t.IssuedAt, &t.CheckedInAt,
+			&t.CheckedInDevice, &t.CancelledAt)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		counts[t.Status]++
+		tickets = append(tickets, t)
+	}
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("ticket_count", len(tickets))
+	apiRequest.SetMeta("status_counts", counts)
+	apiRequest.Success(http.StatusOK, tickets, "tickets loaded successfully")
+}
+
+// AdminIssueEventDateTickets issues tickets of a price tier at the box
+// office. The tickets are mailed if an email address is given.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminIssueEventDateTickets(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-issue-event-date-tickets")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	type Payload struct {
+		PriceTierUuid string  `json:"price_tier_uuid" binding:"required"`
+		Quantity      *int    `json:"quantity"`
+		Name          string  `json:"name" binding:"required"`
+		Email         *string `json:"email"`
+		Lang          string  `json:"lang"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	name := strings.TrimSpace(payload.Name)
+	if name == "" || len(name) > ticketMaxHolderLen {
+		apiRequest.Error(http.StatusBadRequest,
+			fmt.Sprintf("name is required and must not be longer than %d characters", ticketMaxHolderLen))
+		return
+	}
+	if payload.Email != nil {
+		email := strings.TrimSpace(*payload.Email)
+		payload.Email = &email
+		if email == "" {
+			payload.Email = nil
+		} else if !app.IsValidEmail(email) {
+			apiRequest.Error(http.StatusBadRequest, "invalid email address")
+			return
+		}
+	}
+	quantity := 1
+	if payload.Quantity != nil {
+		quantity = *payload.Quantity
+	}
+	if quantity < 1 || quantity > ticketMaxBoxOffice {
+		apiRequest.Error(http.StatusBadRequest, fmt.Sprintf("quantity must be between 1 and %d", ticketMaxBoxOffice))
+		return
+	}
+	lang := payload.Lang
+	if lang == "" {
+		lang = "en"
+	}
+
+	var tickets []model.EventTicket
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if err := lockEventDateSeatsTx(ctx, tx, eventDateUuid); err != nil {
+			return TxInternalError(err)
+		}
+		d, err := h.loadRegistrationDateTx(ctx, tx, eventDateUuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("event date not found")
+			}
+			return TxInternalError(err)
+		}
+		if d.EventUuid != eventUuid {
+			return ApiErrNotFound("event date not found")
+		}
+		if d.ReleaseStatus == "cancelled" || d.ReleaseStatus == "deferred" || d.ReleaseStatus == "rescheduled" {
+			return NewApiTxError(http.StatusConflict, "no tickets for %s event dates", d.ReleaseStatus)
+		}
+
+		var txErr *ApiTxError
+		tickets, txErr = h.issueTicketsTx(ctx, tx, d, ticketOrder{
+			TierUuid:    payload.PriceTierUuid,
+			Quantity:    quantity,
+			HolderName:  name,
+			HolderEmail: payload.Email,
+			IssuedBy:    &userUuid,
+		})
+		if txErr != nil {
+			return txErr
+		}
+
+		if err := h.enqueueTicketsEmailTx(ctx, tx, d, tickets, lang); err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("ticket_count", len(tickets))
+	apiRequest.Success(http.StatusCreated, tickets, "tickets issued successfully")
+}
+
+// AdminCancelEventTicket cancels an unused ticket of an event date, its seat
+// is offered again.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminCancelEventTicket(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-cancel-event-ticket")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateUuid")
+	ticketUuid := gc.Param("ticketUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+	apiRequest.SetMeta("ticket_uuid", ticketUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if err := lockEventDateSeatsTx(ctx, tx, eventDateUuid); err != nil {
+			return TxInternalError(err)
+		}
+
+		var status string
+		query := fmt.Sprintf(`
+			SELECT t.status
+			FROM %[1]s.event_ticket t
+			JOIN %[1]s.event_date ed ON ed.uuid = t.event_date_uuid
+			WHERE t.uuid::text = $1 AND t.event_date_uuid::text = $2 AND ed.event_uuid::text = $3
+			FOR UPDATE OF t`,
+			h.DbSchema)
+		err := tx.QueryRow(ctx, query, ticketUuid, eventDateUuid, eventUuid).Scan(&status)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("ticket not found")
+			}
+			return TxInternalError(err)
+		}
+		if status != ticketValid {
+			return NewApiTxError(http.StatusConflict, "%s tickets can not be cancelled", status)
+		}
+
+		query = fmt.Sprintf(
+			`UPDATE %s.event_ticket SET status = 'cancelled', cancelled_at = NOW() WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		if _, err := tx.Exec(ctx, query, ticketUuid); err != nil {
+			return TxInternalError(err)
+		}
+		if err := h.updateAvailabilityTx(ctx, tx, []string{eventDateUuid}); err != nil {
+			return TxInternalError(err)
+		}
+
+		orgUuid, err := h.GetOrgUuidByEventUuidTx(gc, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityEvent, eventUuid, "cancel-ticket",
+			map[string]any{"ticket_uuid": ticketUuid, "status": status},
+			map[string]any{"ticket_uuid": ticketUuid, "status": ticketCancelled})
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "ticket cancelled")
+}
+
+// AdminCheckInTickets checks in the scanned tickets of an event date. Scan
+// devices working offline send their scans later with the time of the scan
+// and their device id. A scan sent again returns its first result, a ticket
+// scanned again is reported as conflict with its first check-in.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminCheckInTickets(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-check-in-tickets")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	var payload struct {
+		Scans []model.TicketScan `json:"scans" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+	if len(payload.Scans) == 0 || len(payload.Scans) > ticketMaxScans {
+		apiRequest.Error(http.StatusBadRequest, fmt.Sprintf("between 1 and %d scans are required", ticketMaxScans))
+		return
+	}
+
+	results := make([]model.TicketScanResult, 0, len(payload.Scans))
+	conflictCount := 0
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var dateFound bool
+		query := fmt.Sprintf(
+			`SELECT EXISTS (SELECT 1 FROM %s.event_date WHERE uuid::text = $1 AND event_uuid::text = $2)`,
+			h.DbSchema)
+		if err := tx.QueryRow(ctx, query, eventDateUuid, eventUuid).Scan(&dateFound); err != nil {
+			return TxInternalError(err)
+		}
+		if !dateFound {
+			return ApiErrNotFound("event date not found")
+		}
+
+		now := time.Now()
+		for _, scan := range payload.Scans {
+			result := model.TicketScanResult{Code: scan.Code, Result: "invalid"}
+
+			ticketUuid, ok := h.verifyTicketCode(scan.Code)
+			if !ok {
+				results = append(results, result)
+				continue
+			}
+
+			var ticketDateUuid, status string
+			query := fmt.Sprintf(`
+				SELECT t.event_date_uuid, t.status, t.holder_name, pt.name, t.payment, t.amount::float8, t.currency,
+					t.checked_in_at, t.checked_in_device
+				FROM %[1]s.event_ticket t
+				JOIN %[1]s.event_price_tier pt ON pt.uuid = t.price_tier_uuid
+				WHERE t.uuid = $1::uuid
+				FOR UPDATE OF t`,
+				h.DbSchema)
+			err := tx.QueryRow(ctx, query, ticketUuid).Scan(
+				&ticketDateUuid, &status, &result.HolderName, &result.PriceTierName, &result.Payment,
+				&result.Amount, &result.Currency, &result.CheckedInAt, &result.CheckedInDevice)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					results = append(results, result)
+					continue
+				}
+				return TxInternalError(err)
+			}
+			result.TicketUuid = &ticketUuid
+			if ticketDateUuid != eventDateUuid {
+				result.Result = "wrong_date"
+				results = append(results, result)
+				continue
+			}
+
+			scannedAt := ticketScanTime(scan, now)
+
+			// Scans are synced again by devices which missed the response
+			var previous string
+			query = fmt.Sprintf(`
+				SELECT result FROM %s.event_ticket_scan
+				WHERE ticket_uuid = $1::uuid AND device_id = $2 AND scanned_at = $3`,
+				h.DbSchema)
+			err = tx.QueryRow(ctx, query, ticketUuid, scan.DeviceId, scannedAt).Scan(&previous)
+			if err == nil {
+				result.Result = previous
+				result.Replayed = true
+				result.Conflict = previous == "already_used"
+				if result.Conflict {
+					conflictCount++
+				}
+				results = append(results, result)
+				continue
+			} else if !errors.Is(err, pgx.ErrNoRows) {
+				return TxInternalError(err)
+			}
+
+			switch status {
+			case ticketCancelled:
+				result.Result = "cancelled"
+			case ticketUsed:
+				result.Result = "already_used"
+				result.Conflict = true
+				conflictCount++
+			default:
+				query = fmt.Sprintf(`
+					UPDATE %s.event_ticket
+					SET status = 'used', checked_in_at = $2, checked_in_by = NULLIF($3, '')::uuid,
+						checked_in_device = NULLIF($4, '')
+					WHERE uuid = $1::uuid`,
+					h.DbSchema)
+				if _, err := tx.Exec(ctx, query, ticketUuid, scannedAt, userUuid, scan.DeviceId); err != nil {
+					return TxInternalError(err)
+				}
+				result.Result = "checked_in"
+				result.CheckedInAt = &scannedAt
+				if scan.DeviceId != "" {
+					result.CheckedInDevice = &scan.DeviceId
+				}
+			}
+
+			query = fmt.Sprintf(`
+				INSERT INTO %s.event_ticket_scan (ticket_uuid, device_id, scanned_at, user_uuid, result)
+				VALUES ($1::uuid, $2, $3, NULLIF($4, '')::uuid, $5)
+				ON CONFLICT (ticket_uuid, device_id, scanned_at) DO NOTHING`,
+				h.DbSchema)
+			if _, err := tx.Exec(ctx, query, ticketUuid, scan.DeviceId, scannedAt, userUuid, result.Result); err != nil {
+				return TxInternalError(err)
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("scan_count", len(results))
+	apiRequest.SetMeta("conflict_count", conflictCount)
+	apiRequest.Success(http.StatusOK, results, "tickets checked")
+}
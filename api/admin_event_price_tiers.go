@@ -0,0 +1,334 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/model"
+)
+
+// validateEventPriceTierTx checks a price tier of an event and applies the
+// default currency EUR.
+func (h *ApiHandler) validateEventPriceTierTx(ctx context.Context, tx pgx.Tx, eventUuid string, payload *model.EventPriceTierPayload) *ApiTxError {
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" || len(payload.Name) > ticketMaxTierName {
+		return NewApiTxError(http.StatusBadRequest, "name is required and must not be longer than %d characters", ticketMaxTierName)
+	}
+	if payload.Amount < 0 || payload.Amount >= 100000000 {
+		return NewApiTxError(http.StatusBadRequest, "amount must be between 0 and 99999999.99")
+	}
+	if payload.Quota != nil && *payload.Quota < 0 {
+		return NewApiTxError(http.StatusBadRequest, "quota must not be negative")
+	}
+	if payload.Currency == nil || *payload.Currency == "" {
+		currency := "EUR"
+		payload.Currency = &currency
+	}
+
+	var currencyExists bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s.currency WHERE code = $1)`, h.DbSchema)
+	if err := tx.QueryRow(ctx, query, *payload.Currency).Scan(&currencyExists); err != nil {
+		return TxInternalError(err)
+	}
+	if !currencyExists {
+		return NewApiTxError(http.StatusBadRequest, "unknown currency %q", *payload.Currency)
+	}
+
+	if payload.EventDateUuid != nil {
+		var dateExists bool
+		query = fmt.Sprintf(
+			`SELECT EXISTS (SELECT 1 FROM %s.event_date WHERE uuid::text = $1 AND event_uuid = $2::uuid)`,
+			h.DbSchema)
+		if err := tx.QueryRow(ctx, query, *payload.EventDateUuid, eventUuid).Scan(&dateExists); err != nil {
+			return TxInternalError(err)
+		}
+		if !dateExists {
+			return NewApiTxError(http.StatusBadRequest, "event_date_uuid is not a date of this event")
+		}
+	}
+	return nil
+}
+
+// loadEventPriceTierTx returns a price tier of an event, nil if there is
+// none.
+func (h *ApiHandler) loadEventPriceTierTx(ctx context.Context, q rowQuerier, eventUuid string, tierUuid string) (*model.EventPriceTier, error) {
+	query := fmt.Sprintf(`
+		SELECT uuid, event_uuid, event_date_uuid, name, amount::float8, currency, quota, sort_order, created_at, modified_at
+		FROM %s.event_price_tier
+		WHERE uuid::text = $1 AND event_uuid::text = $2`,
+		h.DbSchema)
+	var t model.EventPriceTier
+	err := q.QueryRow(ctx, query, tierUuid, eventUuid).Scan(
+		&t.Uuid, &t.EventUuid, &t.EventDateUuid, &t.Name, &t.Amount, &t.Currency, &t.Quota, &t.SortOrder,
+		&t.CreatedAt, &t.ModifiedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// afterEventPriceTierChangeTx updates the prices and ticket availability of
+// an event after its price tiers changed and records the change.
+func (h *ApiHandler) afterEventPriceTierChangeTx(gc *gin.Context, tx pgx.Tx, eventUuid string, tierUuid string, before *model.EventPriceTier, after *model.EventPriceTier) *ApiTxError {
+	ctx := gc.Request.Context()
+
+	if err := h.syncEventPricesTx(ctx, tx, eventUuid, h.userUuid(gc)); err != nil {
+		return TxInternalError(err)
+	}
+	if err := h.updateEventAvailabilityTx(ctx, tx, eventUuid); err != nil {
+		return TxInternalError(err)
+	}
+
+	orgUuid, err := h.GetOrgUuidByEventUuidTx(gc, tx, eventUuid)
+	if err != nil {
+		return TxInternalError(err)
+	}
+	err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityEvent, eventUuid, "price-tier",
+		map[string]any{"price_tier_uuid": tierUuid, "price_tier": before},
+		map[string]any{"price_tier_uuid": tierUuid, "price_tier": after})
+	if err != nil {
+		return TxInternalError(err)
+	}
+	return nil
+}
+
+// AdminGetEventPriceTiers returns the price tiers of an event.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent or UserPermViewEventInsights, enforced
+// by RequireAnyOrgPermission middleware.
+func (h *ApiHandler) AdminGetEventPriceTiers(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-price-tiers")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	query := fmt.Sprintf(`
+		SELECT uuid, event_uuid, event_date_uuid, name, amount::float8, currency, quota, sort_order, created_at, modified_at
+		FROM %s.event_price_tier
+		WHERE event_uuid::text = $1
+		ORDER BY sort_order, amount, uuid`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, eventUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	tiers := make([]model.EventPriceTier, 0)
+	for rows.Next() {
+		var t model.EventPriceTier
+		err := rows.Scan(
+			&t.Uuid, &t.EventUuid, &t.EventDateUuid, &t.Name, &t.Amount, &t.Currency, &t.Quota, &t.SortOrder,
+			&t.CreatedAt, &t.ModifiedAt)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		tiers = append(tiers, t)
+	}
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("price_tier_count", len(tiers))
+	apiRequest.Success(http.StatusOK, tiers, "price tiers loaded successfully")
+}
+
+// AdminCreateEventPriceTier adds a price tier to an event, for all of its
+// dates or for a single date with event_date_uuid. The price fields of the
+// event are set from its tiers.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminCreateEventPriceTier(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-create-event-price-tier")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	var payload model.EventPriceTierPayload
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	var tier *model.EventPriceTier
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if txErr := h.validateEventPriceTierTx(ctx, tx, eventUuid, &payload); txErr != nil {
+			return txErr
+		}
+
+		tierUuid, err := grains_uuid.Uuidv7String()
+		if err != nil {
+			return TxInternalError(err)
+		}
+		query := fmt.Sprintf(`
+			INSERT INTO %s.event_price_tier (uuid, event_uuid, event_date_uuid, name, amount, currency, quota, sort_order)
+			VALUES ($1::uuid, $2::uuid, $3::uuid, $4, $5, $6, $7, $8)`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, query,
+			tierUuid, eventUuid, payload.EventDateUuid, payload.Name, payload.Amount, *payload.Currency,
+			payload.Quota, payload.SortOrder)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		tier, err = h.loadEventPriceTierTx(ctx, tx, eventUuid, tierUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return h.afterEventPriceTierChangeTx(gc, tx, eventUuid, tierUuid, nil, tier)
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusCreated, tier, "price tier created successfully")
+}
+
+// AdminUpdateEventPriceTier changes a price tier of an event. Issued
+// tickets keep their price.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminUpdateEventPriceTier(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-event-price-tier")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	tierUuid := gc.Param("tierUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("price_tier_uuid", tierUuid)
+
+	var payload model.EventPriceTierPayload
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	var tier *model.EventPriceTier
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventPriceTierTx(ctx, tx, eventUuid, tierUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if before == nil {
+			return ApiErrNotFound("price tier not found")
+		}
+		if txErr := h.validateEventPriceTierTx(ctx, tx, eventUuid, &payload); txErr != nil {
+			return txErr
+		}
+
+		// Tickets issued for other dates would lose their tier
+		if payload.EventDateUuid != nil {
+			var otherDates bool
+			query := fmt.Sprintf(`
+				SELECT EXISTS (
+					SELECT 1 FROM %s.event_ticket
+					WHERE price_tier_uuid = $1::uuid AND event_date_uuid <> $2::uuid
+				)`,
+				h.DbSchema)
+			if err := tx.QueryRow(ctx, query, tierUuid, *payload.EventDateUuid).Scan(&otherDates); err != nil {
+				return TxInternalError(err)
+			}
+			if otherDates {
+				return NewApiTxError(http.StatusConflict, "price tier has tickets for other dates")
+			}
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s.event_price_tier
+			SET event_date_uuid = $2::uuid, name = $3, amount = $4, currency = $5, quota = $6, sort_order = $7,
+				modified_at = NOW()
+			WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, query,
+			tierUuid, payload.EventDateUuid, payload.Name, payload.Amount, *payload.Currency, payload.Quota,
+			payload.SortOrder)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		tier, err = h.loadEventPriceTierTx(ctx, tx, eventUuid, tierUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return h.afterEventPriceTierChangeTx(gc, tx, eventUuid, tierUuid, before, tier)
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, tier, "price tier updated successfully")
+}
+
+// AdminDeleteEventPriceTier removes a price tier without tickets from an
+// event. Tiers with tickets are closed by setting their quota to 0.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminDeleteEventPriceTier(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-delete-event-price-tier")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	tierUuid := gc.Param("tierUuid")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("price_tier_uuid", tierUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventPriceTierTx(ctx, tx, eventUuid, tierUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if before == nil {
+			return ApiErrNotFound("price tier not found")
+		}
+
+		var hasTickets bool
+		query := fmt.Sprintf(
+			`SELECT EXISTS (SELECT 1 FROM %s.event_ticket WHERE price_tier_uuid = $1::uuid)`,
+			h.DbSchema)
+		if err := tx.QueryRow(ctx, query, tierUuid).Scan(&hasTickets); err != nil {
+			return TxInternalError(err)
+		}
+		if hasTickets {
+			return NewApiTxError(http.StatusConflict, "price tier has tickets, set its quota to 0 to stop sales")
+		}
+
+		query = fmt.Sprintf(`DELETE FROM %s.event_price_tier WHERE uuid = $1::uuid`, h.DbSchema)
+		if _, err := tx.Exec(ctx, query, tierUuid); err != nil {
+			return TxInternalError(err)
+		}
+		return h.afterEventPriceTierChangeTx(gc, tx, eventUuid, tierUuid, before, nil)
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "price tier deleted successfully")
+}
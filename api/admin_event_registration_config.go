@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// loadEventRegistrationConfigTx returns the registration settings of an
+// event, the defaults if they were never saved.
+func (h *ApiHandler) loadEventRegistrationConfigTx(ctx context.Context, q rowQuerier, eventUuid string) (model.EventRegistrationConfig, error) {
+	config := model.EventRegistrationConfig{
+		EventUuid:               eventUuid,
+		Questions:               []model.RegistrationQuestion{},
+		WaitlistEnabled:         true,
+		MaxSeatsPerRegistration: 1,
+	}
+	query := fmt.Sprintf(`
+		SELECT enabled, questions, waitlist_enabled, max_seats_per_registration
+		FROM %s.event_registration_config
+		WHERE event_uuid = $1::uuid`,
+		h.DbSchema)
+	err := q.QueryRow(ctx, query, eventUuid).Scan(
+		&config.Enabled, &config.Questions, &config.WaitlistEnabled, &config.MaxSeatsPerRegistration)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return config, err
+	}
+	if config.Questions == nil {
+		config.Questions = []model.RegistrationQuestion{}
+	}
+	return config, nil
+}
+
+// AdminGetEventRegistrationConfig returns the registration settings of an
+// event.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetEventRegistrationConfig(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-registration-config")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	config, err := h.loadEventRegistrationConfigTx(ctx, h.DbPool, eventUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, config, "")
+}
+
+// AdminUpdateEventRegistrationConfig saves the registration settings of an
+// event: whether visitors can register for its dates, the custom questions
+// of the form, whether a waitlist is kept when dates are fully booked and
+// how many seats a single registration may book. Existing registrations are
+// kept when registration is disabled.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminUpdateEventRegistrationConfig(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-event-registration-config")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	type Payload struct {
+		Enabled                 bool                         `json:"enabled"`
+		Questions               []model.RegistrationQuestion `json:"questions"`
+		WaitlistEnabled         *bool                        `json:"waitlist_enabled"`
+		MaxSeatsPerRegistration *int                         `json:"max_seats_per_registration"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	config := model.EventRegistrationConfig{
+		EventUuid:               eventUuid,
+		Enabled:                 payload.Enabled,
+		Questions:               payload.Questions,
+		WaitlistEnabled:         true,
+		MaxSeatsPerRegistration: 1,
+	}
+	if config.Questions == nil {
+		config.Questions = []model.RegistrationQuestion{}
+	}
+	if payload.WaitlistEnabled != nil {
+		config.WaitlistEnabled = *payload.WaitlistEnabled
+	}
+	if payload.MaxSeatsPerRegistration != nil {
+		config.MaxSeatsPerRegistration = *payload.MaxSeatsPerRegistration
+	}
+	if config.MaxSeatsPerRegistration < 1 || config.MaxSeatsPerRegistration > 20 {
+		apiRequest.Error(http.StatusBadRequest, "max_seats_per_registration must be between 1 and 20")
+		return
+	}
+	if err := validateRegistrationQuestions(config.Questions); err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventRegistrationConfigTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s.event_registration_config AS c
+				(event_uuid, enabled, questions, waitlist_enabled, max_seats_per_registration)
+			VALUES ($1::uuid, $2, $3, $4, $5)
+			ON CONFLICT (event_uuid) DO UPDATE SET
+				enabled = EXCLUDED.enabled,
+				questions = EXCLUDED.questions,
+				waitlist_enabled = EXCLUDED.waitlist_enabled,
+				max_seats_per_registration = EXCLUDED.max_seats_per_registration,
+				modified_at = NOW()`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, query,
+			eventUuid, config.Enabled, config.Questions, config.WaitlistEnabled, config.MaxSeatsPerRegistration)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if err := h.updateEventAvailabilityTx(ctx, tx, eventUuid); err != nil {
+			return TxInternalError(err)
+		}
+
+		orgUuid, err := h.GetOrgUuidByEventUuidTx(gc, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityEvent, eventUuid, "registration", before, config)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, config, "event registration updated successfully")
+}
@@ -1,14 +1,11 @@
 package api
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"net/http"
-	"net/smtp"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,7 +13,6 @@ import (
 	"github.com/sndcds/grains/grains_api"
 	"github.com/sndcds/grains/grains_validation"
 	"github.com/sndcds/uranus/app"
-	"golang.org/x/net/idna"
 )
 
 func (h *ApiHandler) ForgotPassword(gc *gin.Context) {
@@ -68,29 +64,13 @@ func (h *ApiHandler) ForgotPassword(gc *gin.Context) {
 
 	resetUrl := payload.Referer + "/app/reset-password?token=" + token
 
-	messageQuery := fmt.Sprintf(`SELECT subject, template FROM %s.system_email_template WHERE context = 'reset-user-password' AND iso_639_1 = $1`, h.DbSchema)
-	_, err = h.DbPool.Exec(gc, messageQuery, lang)
-	if err != nil {
-		debugf(err.Error())
-		apiRequest.InternalServerError()
-		return
-	}
-	var subject string
-	var template string
-	err = h.DbPool.QueryRow(gc, messageQuery, lang).Scan(&subject, &template)
-	if err != nil {
-		debugf(err.Error())
-		apiRequest.InternalServerError()
-		return
-	}
-	emailContent := strings.Replace(template, "{{link}}", resetUrl, -1)
-	emailContent = strings.Replace(emailContent, "{{expiry_hours}}", strconv.Itoa(expiryHour), -1)
-
-	// Create a context with timeout for sending email
-	emailCtx, cancel := context.WithTimeout(ctx, 10*time.Second) // 10s timeout
-	defer cancel()
-
-	err = sendEmailWithContext(emailCtx, payload.Email, subject, emailContent)
+	_, err = h.enqueueTemplateEmail(
+		ctx, h.DbPool, payload.Email, "reset-user-password", lang,
+		map[string]string{
+			"link":         resetUrl,
+			"expiry_hours": strconv.Itoa(expiryHour),
+		},
+		userUuid, "")
 	if err != nil {
 		debugf(err.Error())
 		apiRequest.InternalServerError()
@@ -160,6 +140,12 @@ func (h *ApiHandler) ResetPassword(gc *gin.Context) {
 			return TxInternalError(nil)
 		}
 
+		// Sign out everywhere, the old password may be known to others
+		_, err = h.revokeUserSessionsTx(ctx, tx, userUuid, "", "password-reset")
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
@@ -179,82 +165,3 @@ func generateResetToken() (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(b), nil
 }
-
-func sendEmailWithTimeout(to, subject, htmlContent string, timeout time.Duration) error {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	errCh := make(chan error, 1)
-
-	go func() {
-		errCh <- sendEmail(to, subject, htmlContent)
-	}()
-
-	select {
-	case <-ctx.Done():
-		return fmt.Errorf("send email timeout: %w", ctx.Err())
-
-	case err := <-errCh:
-		return err
-	}
-}
-
-func sendEmail(to, subject string, htmlContent string) error {
-	from := app.UranusInstance.Config.AuthReplyEmail
-	userName := app.UranusInstance.Config.AuthSmtpLogin
-	password := app.UranusInstance.Config.AuthSmtpPassword
-	smtpHost := app.UranusInstance.Config.AuthSmtpHost
-	smtpPort := app.UranusInstance.Config.AuthSmtpPort // int
-
-	debugf("sendEmail from: %s", from)
-	asciiFrom, err := encodeEmailAddress(from)
-	if err != nil {
-		return fmt.Errorf("unable to send email 1: %s", err.Error())
-	}
-
-	debugf("sendEmail to: %s", to)
-	asciiTo, err := encodeEmailAddress(to)
-	if err != nil {
-		return fmt.Errorf("unable to send email 2: %s", err.Error())
-	}
-
-	// Encode subject in Base64 for UTF-8
-	encodedSubject := fmt.Sprintf("=?UTF-8?B?%s?=", base64.StdEncoding.EncodeToString([]byte(subject)))
-
-	message := []byte(
-		"Subject: " + encodedSubject + "\r\n" +
-			"MIME-Version: 1.0\r\n" +
-			"To: " + asciiTo + "\r\n" +
-			"From: " + asciiFrom + "\r\n" +
-			"Content-Type: text/html; charset=\"UTF-8\"\r\n" +
-			"\r\n" +
-			htmlContent + "\r\n")
-
-	auth := smtp.PlainAuth("", userName, password, smtpHost)
-	addr := fmt.Sprintf("%s:%d", smtpHost, smtpPort)
-
-	err = smtp.SendMail(addr, auth, userName, []string{to}, message)
-	if err != nil {
-		return fmt.Errorf("unable to send email 3: %s", err.Error())
-	}
-
-	return nil
-}
-
-// Encode an email address for SMTP
-func encodeEmailAddress(email string) (string, error) {
-	parts := strings.Split(email, "@")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid email: %s", email)
-	}
-
-	local := parts[0]  // user
-	domain := parts[1] // domain
-
-	asciiDomain, err := idna.ToASCII(domain)
-	if err != nil {
-		return "", err
-	}
-
-	return local + "@" + asciiDomain, nil
-}
@@ -157,6 +157,8 @@ func (h *ApiHandler) AdminGetEvent(gc *gin.Context) {
 			&date.Uuid,
 			&date.EventUuid,
 			&date.ReleaseStatus,
+			&date.StatusReason,
+			&date.RescheduledToUuid,
 			&date.StartDate,
 			&date.StartTime,
 			&date.EndDate,
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// loadEventDateRegistrations returns the registrations of an event date of
+// an event in order of registration, filtered by status unless empty. ok is
+// false if the date does not belong to the event.
+func (h *ApiHandler) loadEventDateRegistrations(ctx context.Context, eventUuid string, eventDateUuid string, status string) ([]model.EventRegistration, []model.RegistrationQuestion, bool, error) {
+	var questions []model.RegistrationQuestion
+	query := fmt.Sprintf(`
+		SELECT COALESCE(c.questions, '[]'::jsonb)
+		FROM %[1]s.event_date ed
+		LEFT JOIN %[1]s.event_registration_config c ON c.event_uuid = ed.event_uuid
+		WHERE ed.uuid::text = $1 AND ed.event_uuid::text = $2`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, eventDateUuid, eventUuid)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	found := false
+	for rows.Next() {
+		found = true
+		if err := rows.Scan(&questions); err != nil {
+			rows.Close()
+			return nil, nil, false, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, false, err
+	}
+	if !found {
+		return nil, nil, false, nil
+	}
+
+	query = fmt.Sprintf(`
+		SELECT uuid, name, email, seats, answers, lang, status, created_at,
+			confirmed_at, waitlisted_at, promoted_at, cancelled_at, cancelled_by
+		FROM %s.event_registration
+		WHERE event_date_uuid = $1::uuid AND ($2 = '' OR status = $2)
+		ORDER BY created_at, uuid`,
+		h.DbSchema)
+	rows, err = h.DbPool.Query(ctx, query, eventDateUuid, status)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+
+	registrations := make([]model.EventRegistration, 0)
+	for rows.Next() {
+		var r model.EventRegistration
+		err := rows.Scan(
+			&r.Uuid, &r.Name, &r.Email, &r.Seats, &r.Answers, &r.Lang, &r.Status, &r.CreatedAt,
+			&r.ConfirmedAt, &r.WaitlistedAt, &r.PromotedAt, &r.CancelledAt, &r.CancelledBy)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		registrations = append(registrations, r)
+	}
+	return registrations, questions, true, rows.Err()
+}
+
+// csvCell keeps cells entered by visitors from being run as formulas by
+// spreadsheet applications.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func registrationStatusFilter(gc *gin.Context, apiRequest *grains_api.Request) (string, bool) {
+	status := gc.Query("status")
+	if status != "" && !slices.Contains(
+		[]string{registrationPending, registrationConfirmed, registrationWaitlisted, registrationCancelled}, status) {
+		apiRequest.Error(http.StatusBadRequest, "status must be pending, confirmed, waitlisted or cancelled")
+		return "", false
+	}
+	return status, true
+}
+
+// AdminGetEventDateRegistrations returns the attendee list of an event date,
+// optionally filtered by status, with the number of booked and waiting
+// seats.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent or UserPermViewEventInsights, enforced
+// by RequireAnyOrgPermission middleware.
+func (h *ApiHandler) AdminGetEventDateRegistrations(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-date-registrations")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateIdentifier") // Shares the wildcard of GetEventByDate, only uuids
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	status, ok := registrationStatusFilter(gc, apiRequest)
+	if !ok {
+		return
+	}
+
+	registrations, _, found, err := h.loadEventDateRegistrations(ctx, eventUuid, eventDateUuid, status)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if !found {
+		apiRequest.NotFound("event date not found")
+		return
+	}
+
+	confirmedSeats, waitlistedSeats := 0, 0
+	for _, r := range registrations {
+		switch r.Status {
+		case registrationConfirmed:
+			confirmedSeats += r.Seats
+		case registrationWaitlisted:
+			waitlistedSeats += r.Seats
+		}
+	}
+
+	apiRequest.SetMeta("registration_count", len(registrations))
+	apiRequest.SetMeta("confirmed_seats", confirmedSeats)
+	apiRequest.SetMeta("waitlisted_seats", waitlistedSeats)
+	apiRequest.Success(http.StatusOK, registrations, "registrations loaded successfully")
+}
+
+// AdminExportEventDateRegistrations returns the attendee list of an event
+// date as CSV, with a column per question of the registration form.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent or UserPermViewEventInsights, enforced
+// by RequireAnyOrgPermission middleware.
+func (h *ApiHandler) AdminExportEventDateRegistrations(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-export-event-date-registrations")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	eventDateUuid := gc.Param("dateIdentifier")
+	apiRequest.SetMeta("event_uuid", eventUuid)
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	status, ok := registrationStatusFilter(gc, apiRequest)
+	if !ok {
+		return
+	}
+
+	registrations, questions, found, err := h.loadEventDateRegistrations(ctx, eventUuid, eventDateUuid, status)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if !found {
+		apiRequest.NotFound("event date not found")
+		return
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	gc.Header("Content-Type", "text/csv; charset=utf-8")
+	gc.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="registrations-%s.csv"`, eventDateUuid))
+	gc.Header("Cache-Control", "private, no-store")
+	gc.Status(http.StatusOK)
+
+	w := csv.NewWriter(gc.Writer)
+	header := []string{"name", "email", "seats", "status", "lang", "registered_at", "confirmed_at", "promoted_at", "cancelled_at"}
+	for _, q := range questions {
+		header = append(header, csvCell(q.Label))
+	}
+	_ = w.Write(header)
+
+	for _, r := range registrations {
+		var answers map[string]any
+		_ = json.Unmarshal(r.Answers, &answers)
+
+		record := []string{
+			csvCell(r.Name), csvCell(r.Email), strconv.Itoa(r.Seats), r.Status, r.Lang, formatTime(&r.CreatedAt),
+			formatTime(r.ConfirmedAt), formatTime(r.PromotedAt), formatTime(r.CancelledAt),
+		}
+		for _, q := range questions {
+			value := ""
+			switch a := answers[q.Key].(type) {
+			case string:
+				value = a
+			case bool:
+				value = strconv.FormatBool(a)
+			}
+			record = append(record, csvCell(value))
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+}
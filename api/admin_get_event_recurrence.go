@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+
+func (h *ApiHandler) AdminGetEventRecurrence(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-recurrence")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	var recurrence *model.EventRecurrence
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var err error
+		recurrence, err = loadEventRecurrence(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if recurrence == nil {
+			return ApiErrNotFound("event has no recurrence")
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, recurrence)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetEventRevision returns a single revision of an event including the
+// diff to the previous state and the snapshot of the event.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetEventRevision(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-revision")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	revision, err := strconv.Atoi(gc.Param("revision"))
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, "revision must be a number")
+		return
+	}
+	apiRequest.SetMeta("revision", revision)
+
+	query := fmt.Sprintf(`
+		SELECT
+			r.revision,
+			r.action,
+			r.user_uuid,
+			COALESCE(u.display_name, NULLIF(CONCAT_WS(' ', u.first_name, u.last_name), ''), u.email),
+			r.restored_from,
+			r.created_at,
+			r.diff,
+			r.snapshot
+		FROM %[1]s.event_revision r
+		LEFT JOIN %[1]s.user u ON u.uuid = r.user_uuid
+		WHERE r.event_uuid = $1::uuid AND r.revision = $2`,
+		h.DbSchema)
+
+	var r model.EventRevision
+	err = h.DbPool.QueryRow(ctx, query, eventUuid, revision).Scan(
+		&r.Revision, &r.Action, &r.UserUuid, &r.UserName, &r.RestoredFrom, &r.CreatedAt, &r.Diff, &r.Snapshot)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("revision not found")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	r.ChangedFields = sortedDiffFields(r.Diff)
+
+	apiRequest.Success(http.StatusOK, r, "event revision loaded successfully")
+}
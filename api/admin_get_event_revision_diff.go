@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetEventRevisionDiff compares two revisions of an event, given as
+// parameters from and to. Without to, revision from is compared with the
+// current state of the event.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetEventRevisionDiff(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-revision-diff")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	from, err := strconv.Atoi(gc.Query("from"))
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, "from must be a revision number")
+		return
+	}
+	apiRequest.SetMeta("from", from)
+
+	var to *int
+	if v := gc.Query("to"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			apiRequest.Error(http.StatusBadRequest, "to must be a revision number")
+			return
+		}
+		to = &n
+		apiRequest.SetMeta("to", n)
+	}
+
+	var diff map[string]model.EventRevisionChange
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		fromSnapshot, err := h.loadEventRevisionSnapshotTx(ctx, tx, eventUuid, from)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if fromSnapshot == nil {
+			return ApiErrNotFound("revision %d not found", from)
+		}
+
+		var toSnapshot map[string]any
+		if to != nil {
+			toSnapshot, err = h.loadEventRevisionSnapshotTx(ctx, tx, eventUuid, *to)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if toSnapshot == nil {
+				return ApiErrNotFound("revision %d not found", *to)
+			}
+		} else {
+			toSnapshot, err = h.loadEventSnapshotTx(ctx, tx, eventUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if toSnapshot == nil {
+				return ApiErrNotFound("event not found")
+			}
+		}
+
+		diff = diffEventSnapshots(fromSnapshot, toSnapshot)
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("changed_fields", sortedDiffFields(diff))
+	apiRequest.Success(http.StatusOK, diff, "event revision diff created successfully")
+}
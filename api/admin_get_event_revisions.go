@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetEventRevisions lists the revisions of an event, newest first, with
+// the user who made the change and the names of the changed fields.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetEventRevisions(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-event-revisions")
+	ctx := gc.Request.Context()
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	query := fmt.Sprintf(`
+		SELECT
+			r.revision,
+			r.action,
+			r.user_uuid,
+			COALESCE(u.display_name, NULLIF(CONCAT_WS(' ', u.first_name, u.last_name), ''), u.email),
+			r.restored_from,
+			r.created_at,
+			r.diff
+		FROM %[1]s.event_revision r
+		LEFT JOIN %[1]s.user u ON u.uuid = r.user_uuid
+		WHERE r.event_uuid = $1::uuid
+		ORDER BY r.revision DESC`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, eventUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	revisions := make([]model.EventRevision, 0)
+	for rows.Next() {
+		var r model.EventRevision
+		var diff map[string]model.EventRevisionChange
+		err := rows.Scan(&r.Revision, &r.Action, &r.UserUuid, &r.UserName, &r.RestoredFrom, &r.CreatedAt, &diff)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		r.ChangedFields = sortedDiffFields(diff)
+		revisions = append(revisions, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("revision_count", len(revisions))
+	apiRequest.Success(http.StatusOK, revisions, "event revisions loaded successfully")
+}
@@ -6,62 +6,60 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
 )
 
-// TODO: Code review
-
+// AdminGetMessages returns the messages sent to the user in the shape of
+// the former message table, read from the notifications of type "message".
+//
+// Deprecated: Use AdminGetUserNotifications with type=message.
+//
+// PermissionNote: User must be authenticated.
 func (h *ApiHandler) AdminGetMessages(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-messages")
 	ctx := gc.Request.Context()
 	userUuid := h.userUuid(gc)
 
 	query := fmt.Sprintf(`
-		SELECT id, from_user_id, created_at, is_read, subject, message
-		FROM %s.message
-		WHERE to_user_id = $1
-		ORDER BY created_at DESC
-	`, h.DbSchema)
-
-	rows, err := h.DbPool.Query(ctx, query, userUuid)
+		SELECT uuid, created_by, created_at, read_at IS NOT NULL, title, body
+		FROM %s.notification
+		WHERE user_uuid = $1::uuid AND type = $2
+		ORDER BY created_at DESC, uuid DESC`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, userUuid, notificationMessage)
 	if err != nil {
-		gc.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("query failed: %v", err)})
+		debugf(err.Error())
+		apiRequest.DatabaseError()
 		return
 	}
 	defer rows.Close()
 
 	type Message struct {
-		Id         int       `json:"id"`
-		FromUserId int       `json:"from_user_id"`
+		Id         string    `json:"id"`
+		FromUserId *string   `json:"from_user_id"`
 		CreatedAt  time.Time `json:"created_at"`
 		IsRead     bool      `json:"is_read"`
 		Subject    string    `json:"subject"`
 		Message    string    `json:"message"`
 	}
 
-	var messages []Message
-
+	messages := make([]Message, 0)
 	for rows.Next() {
 		var msg Message
-		if err := rows.Scan(
-			&msg.Id,
-			&msg.FromUserId,
-			&msg.CreatedAt,
-			&msg.IsRead,
-			&msg.Subject,
-			&msg.Message,
-		); err != nil {
-			gc.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("scan failed: %v", err)})
+		err := rows.Scan(&msg.Id, &msg.FromUserId, &msg.CreatedAt, &msg.IsRead, &msg.Subject, &msg.Message)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
 			return
 		}
 		messages = append(messages, msg)
 	}
-
-	if rows.Err() != nil {
-		gc.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("rows error: %v", rows.Err())})
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
 		return
 	}
 
-	fmt.Println(messages)
-
 	gc.JSON(http.StatusOK, gin.H{
 		"messages": messages,
 	})
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetOrgApiKeys lists the API keys of an organization including revoked
+// and expired ones. Keys are not included, only their prefixes.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetOrgApiKeys(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-org-api-keys")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.org_api_key
+		WHERE org_uuid = $1::uuid
+		ORDER BY created_at`,
+		orgApiKeyColumns, h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, orgUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	apiKeys := make([]model.OrgApiKey, 0)
+	for rows.Next() {
+		apiKey, err := scanOrgApiKey(rows)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("api_key_count", len(apiKeys))
+	apiRequest.Success(http.StatusOK, apiKeys, "api keys loaded successfully")
+}
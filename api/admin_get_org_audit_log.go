@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetOrgAuditLog returns the audit log of an organization, newest first.
+// Optional filters: entity_type, entity_uuid, action, actor_uuid (user or API
+// key), from and to (YYYY-MM-DD, inclusive). Paginated by limit (default 50,
+// max 500) and offset, the number of matching entries is returned as
+// total_count.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetOrgAuditLog(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-org-audit-log")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	entityType := gc.Query("entity_type")
+	if entityType != "" && !slices.Contains(auditEntityTypes, entityType) {
+		apiRequest.Error(http.StatusBadRequest, fmt.Sprintf("unknown entity_type: %s", entityType))
+		return
+	}
+	entityUuid := gc.Query("entity_uuid")
+	action := gc.Query("action")
+	actorUuid := gc.Query("actor_uuid")
+
+	from, err := parseOptionalDate(gc.Query("from"))
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, "from must be a date in the format YYYY-MM-DD")
+		return
+	}
+	to, err := parseOptionalDate(gc.Query("to"))
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, "to must be a date in the format YYYY-MM-DD")
+		return
+	}
+
+	limit := GetContextParamIntDefault(gc, "limit", 50)
+	offset := GetContextParamIntDefault(gc, "offset", 0)
+	if limit < 1 || limit > 500 || offset < 0 {
+		apiRequest.Error(http.StatusBadRequest, "limit must be between 1 and 500, offset must not be negative")
+		return
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			a.id,
+			a.actor_user_uuid,
+			a.actor_api_key_uuid,
+			COALESCE(u.display_name, NULLIF(CONCAT_WS(' ', u.first_name, u.last_name), ''), u.email, k.name),
+			a.entity_type,
+			a.entity_uuid,
+			a.action,
+			a.before,
+			a.after,
+			a.client_ip,
+			a.created_at,
+			COUNT(*) OVER ()
+		FROM %[1]s.audit_log a
+		LEFT JOIN %[1]s.user u ON u.uuid = a.actor_user_uuid
+		LEFT JOIN %[1]s.org_api_key k ON k.uuid = a.actor_api_key_uuid
+		WHERE a.org_uuid = $1::uuid
+			AND ($2 = '' OR a.entity_type = $2)
+			AND ($3 = '' OR a.entity_uuid = NULLIF($3, '')::uuid)
+			AND ($4 = '' OR a.action = $4)
+			AND ($5 = '' OR NULLIF($5, '')::uuid IN (a.actor_user_uuid, a.actor_api_key_uuid))
+			AND ($6::date IS NULL OR a.created_at >= $6::date)
+			AND ($7::date IS NULL OR a.created_at < $7::date + 1)
+		ORDER BY a.id DESC
+		LIMIT $8 OFFSET $9`,
+		h.DbSchema)
+
+	rows, err := h.DbPool.Query(
+		ctx, query,
+		orgUuid, entityType, entityUuid, action, actorUuid, from, to, limit, offset)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	entries := make([]model.AuditLogEntry, 0)
+	totalCount := 0
+	for rows.Next() {
+		var e model.AuditLogEntry
+		err := rows.Scan(
+			&e.Id, &e.ActorUserUuid, &e.ActorApiKeyUuid, &e.ActorName, &e.EntityType, &e.EntityUuid, &e.Action,
+			&e.Before, &e.After, &e.ClientIp, &e.CreatedAt, &totalCount)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("entry_count", len(entries))
+	apiRequest.SetMeta("total_count", totalCount)
+	apiRequest.SetMeta("limit", limit)
+	apiRequest.SetMeta("offset", offset)
+	apiRequest.Success(http.StatusOK, entries, "audit log loaded successfully")
+}
+
+func parseOptionalDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -10,6 +10,10 @@ import (
 )
 
 // AdminGetOrgChoosableVenues returns all venues that can be chosen for events of an organization.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermAddEvent or UserPermEditEvent, enforced by
+// RequireAnyOrgPermission middleware.
 func (h *ApiHandler) AdminGetOrgChoosableVenues(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-get-org-choosable-venues")
 	ctx := gc.Request.Context()
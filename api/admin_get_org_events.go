@@ -17,7 +17,8 @@ import (
 // the authenticated user is linked to via `user_organization_link` are returned.
 // No additional permission checks are required in Go for access to the event list.
 // Purpose: Returns the dashboard list of events for a given organization.
-// PermissionChecks: Handled entirely by the SQL query.
+// PermissionChecks: Any event permission, enforced by RequireAnyOrgPermission
+// middleware, and in the SQL query.
 // Verified: 2026-01-12, Roald
 
 func (h *ApiHandler) AdminGetOrgEvents(gc *gin.Context) {
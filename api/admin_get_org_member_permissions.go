@@ -40,6 +40,8 @@ func (h *ApiHandler) AdminGetOrgMemberPermissions(gc *gin.Context) {
 	var memberUserUuid string
 	var memberUserDisplayName *string
 	var permissions int64
+	var roleUuid *string
+	var extraPermissions int64
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
 		txErr := h.CheckOrgPermissionTx(gc, tx, userUuid, orgUuid, app.UserPermManagePermissions)
@@ -58,7 +60,7 @@ func (h *ApiHandler) AdminGetOrgMemberPermissions(gc *gin.Context) {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &ApiTxError{
 					Code: http.StatusNotFound,
-					Err:  fmt.Errorf("No member with id %d found in organization %d", memberUuid, orgUuid),
+					Err:  fmt.Errorf("No member with id %s found in organization %s", memberUuid, orgUuid),
 				}
 			}
 			return &ApiTxError{
@@ -68,15 +70,15 @@ func (h *ApiHandler) AdminGetOrgMemberPermissions(gc *gin.Context) {
 		}
 
 		query := fmt.Sprintf(
-			`SELECT permissions FROM %s.user_organization_link WHERE user_uuid = $1::uuid AND org_uuid = $2::uuid`,
+			`SELECT permissions, role_uuid::text, extra_permissions FROM %s.user_organization_link WHERE user_uuid = $1::uuid AND org_uuid = $2::uuid`,
 			h.DbSchema)
 
-		err = tx.QueryRow(ctx, query, memberUserUuid, orgUuid).Scan(&permissions)
+		err = tx.QueryRow(ctx, query, memberUserUuid, orgUuid).Scan(&permissions, &roleUuid, &extraPermissions)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &ApiTxError{
 					Code: http.StatusNotFound,
-					Err:  fmt.Errorf("No permissions found for user %d in organization %d", memberUserUuid, orgUuid),
+					Err:  fmt.Errorf("No permissions found for user %s in organization %s", memberUserUuid, orgUuid),
 				}
 			}
 			return &ApiTxError{
@@ -98,5 +100,7 @@ func (h *ApiHandler) AdminGetOrgMemberPermissions(gc *gin.Context) {
 			"user_uuid":         memberUserUuid,
 			"user_display_name": memberUserDisplayName,
 			"permissions":       permissions,
+			"role_uuid":         roleUuid,
+			"extra_permissions": extraPermissions,
 		})
 }
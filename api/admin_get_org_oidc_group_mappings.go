@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetOrgOidcGroupMappings lists the groups of identity providers which
+// are mapped onto the membership of an organization.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetOrgOidcGroupMappings(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-org-oidc-group-mappings")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.org_oidc_group_mapping
+		WHERE org_uuid = $1::uuid
+		ORDER BY provider, group_name`,
+		orgOidcGroupMappingColumns, h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, orgUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	mappings := make([]model.OrgOidcGroupMapping, 0)
+	for rows.Next() {
+		mapping, err := scanOrgOidcGroupMapping(rows)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("mapping_count", len(mappings))
+	apiRequest.Success(http.StatusOK, mappings, "group mappings loaded successfully")
+}
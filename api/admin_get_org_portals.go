@@ -12,7 +12,7 @@ import (
 )
 
 // PermissionNote: User must be authenticated.
-// PermissionChecks: TODO
+// PermissionChecks: Any portal permission, enforced by RequireAnyOrgPermission middleware.
 // Verified: TODO
 
 func (h *ApiHandler) AdminGetOrgPortals(gc *gin.Context) {
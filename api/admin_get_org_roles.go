@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetOrgRoles lists the member roles of an organization with the number
+// of members holding each.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions or UserPermManageTeam, enforced by RequireAnyOrgPermission middleware.
+func (h *ApiHandler) AdminGetOrgRoles(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-org-roles")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	query := h.orgMemberRoleQuery() + ` WHERE r.org_uuid = $1::uuid ORDER BY r.name`
+	rows, err := h.DbPool.Query(ctx, query, orgUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	roles := make([]model.OrgMemberRole, 0)
+	for rows.Next() {
+		role, err := scanOrgMemberRole(rows)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("role_count", len(roles))
+	apiRequest.Success(http.StatusOK, roles, "roles loaded successfully")
+}
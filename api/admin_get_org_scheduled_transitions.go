@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+)
+
+type scheduledTransition struct {
+	EventUuid     string `json:"event_uuid"`
+	Title         string `json:"title"`
+	ReleaseStatus string `json:"release_status"`
+	Transition    string `json:"transition"`
+	TargetStatus  string `json:"target_status"`
+	DueDate       string `json:"due_date"`
+	Overdue       bool   `json:"overdue"`
+}
+
+// AdminGetOrgScheduledTransitions lists the pending transitions of the
+// release schedule worker for an organization: scheduled events to be
+// released and released events to be archived within the next days
+// (parameter days, default 30).
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent or UserPermReleaseEvent, enforced by
+// RequireAnyOrgPermission middleware.
+func (h *ApiHandler) AdminGetOrgScheduledTransitions(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-org-scheduled-transitions")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	days := GetContextParamIntDefault(gc, "days", 30)
+	if days < 0 || days > 3650 {
+		apiRequest.Error(http.StatusBadRequest, "days must be between 0 and 3650")
+		return
+	}
+	apiRequest.SetMeta("days", days)
+
+	rows, err := h.DbPool.Query(ctx, app.UranusInstance.SqlAdminGetOrgScheduledTransitions, orgUuid, days)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+	defer rows.Close()
+
+	transitions := make([]scheduledTransition, 0)
+	for rows.Next() {
+		var t scheduledTransition
+		if err := rows.Scan(
+			&t.EventUuid,
+			&t.Title,
+			&t.ReleaseStatus,
+			&t.Transition,
+			&t.TargetStatus,
+			&t.DueDate,
+			&t.Overdue,
+		); err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		transitions = append(transitions, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("transition_count", len(transitions))
+	apiRequest.Success(http.StatusOK, transitions, "scheduled transitions loaded successfully")
+}
@@ -36,7 +36,7 @@ func (h *ApiHandler) AdminGetOrgTeam(gc *gin.Context) {
 
 		memberRows, err := tx.Query(ctx, app.UranusInstance.SqlAdminGetOrgMembers, orgUuid)
 		if err != nil {
-			return ApiErrInternal(err.Error())
+			return ApiErrInternal("%v", err)
 		}
 		defer memberRows.Close()
 
@@ -49,9 +49,12 @@ func (h *ApiHandler) AdminGetOrgTeam(gc *gin.Context) {
 				&m.DisplayName,
 				&m.LastActiveAt,
 				&m.JoinedAt,
+				&m.TwoFactorEnabled,
+				&m.RoleUuid,
+				&m.RoleName,
 			)
 			if err != nil {
-				return ApiErrInternal(err.Error())
+				return ApiErrInternal("%v", err)
 			}
 
 			m.AvatarUrl = h.getAvatarURL(m.UserUuid)
@@ -77,7 +80,7 @@ func (h *ApiHandler) AdminGetOrgTeam(gc *gin.Context) {
 
 		rows, err := tx.Query(ctx, invitedMemberQuery, orgUuid)
 		if err != nil {
-			return ApiErrInternal(err.Error())
+			return ApiErrInternal("%v", err)
 		}
 		defer rows.Close()
 
@@ -85,7 +88,7 @@ func (h *ApiHandler) AdminGetOrgTeam(gc *gin.Context) {
 			var m model.InvitedOrgMember
 			err = rows.Scan(&m.UserUuid, &m.InvitedBy, &m.InvitedAt, &m.Email, &m.DisplayName)
 			if err != nil {
-				return ApiErrInternal(err.Error())
+				return ApiErrInternal("%v", err)
 			}
 
 			m.AvatarUrl = h.getAvatarURL(m.UserUuid)
@@ -15,7 +15,8 @@ import (
 // PermissionNote: User must be authenticated.
 // Only returns venues for the organization if the authenticated user is linked via `user_organization_link`.
 // If the user is not linked, returns HTTP 403 Forbidden.
-// PermissionChecks: Already enforced in SQL.
+// PermissionChecks: Any venue, space or org edit permission, enforced by
+// RequireAnyOrgPermission middleware, and in SQL.
 // Verified: 2026-01-12, Roald
 
 func (h *ApiHandler) AdminGetOrgVenues(gc *gin.Context) {
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetOrgWebhookDeliveries returns the delivery log of a webhook, newest
+// first. Optional parameters: status (pending, delivered, failed), limit
+// (default 50, max 500) and offset.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetOrgWebhookDeliveries(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-org-webhook-deliveries")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	webhookUuid := gc.Param("webhookUuid")
+	if webhookUuid == "" {
+		apiRequest.Required("webhookUuid is required")
+		return
+	}
+	apiRequest.SetMeta("webhook_uuid", webhookUuid)
+
+	status := gc.Query("status")
+	if status != "" && status != "pending" && status != "delivered" && status != "failed" {
+		apiRequest.Error(http.StatusBadRequest, "status must be pending, delivered or failed")
+		return
+	}
+
+	limit := GetContextParamIntDefault(gc, "limit", 50)
+	offset := GetContextParamIntDefault(gc, "offset", 0)
+	if limit < 1 || limit > 500 || offset < 0 {
+		apiRequest.Error(http.StatusBadRequest, "limit must be between 1 and 500, offset must not be negative")
+		return
+	}
+
+	var exists bool
+	query := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s.webhook_subscription WHERE uuid = $1::uuid AND org_uuid = $2::uuid)`,
+		h.DbSchema)
+	err := h.DbPool.QueryRow(ctx, query, webhookUuid, orgUuid).Scan(&exists)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if !exists {
+		apiRequest.NotFound("webhook not found")
+		return
+	}
+
+	query = fmt.Sprintf(`
+		SELECT
+			id, event_type, entity_uuid, payload, status, attempt_count,
+			CASE WHEN status = 'pending' THEN next_attempt_at END,
+			last_attempt_at, response_status, response_body, error, created_at, delivered_at
+		FROM %s.webhook_delivery
+		WHERE subscription_uuid = $1::uuid
+			AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, webhookUuid, status, limit, offset)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	deliveries := make([]model.WebhookDelivery, 0)
+	for rows.Next() {
+		var d model.WebhookDelivery
+		err := rows.Scan(
+			&d.Id, &d.EventType, &d.EntityUuid, &d.Payload, &d.Status, &d.AttemptCount,
+			&d.NextAttemptAt, &d.LastAttemptAt, &d.ResponseStatus, &d.ResponseBody, &d.Error,
+			&d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("delivery_count", len(deliveries))
+	apiRequest.SetMeta("limit", limit)
+	apiRequest.SetMeta("offset", offset)
+	apiRequest.Success(http.StatusOK, deliveries, "webhook deliveries loaded successfully")
+}
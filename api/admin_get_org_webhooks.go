@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetOrgWebhooks lists the webhook subscriptions of an organization.
+// Secrets are not included.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetOrgWebhooks(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-org-webhooks")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.webhook_subscription
+		WHERE org_uuid = $1::uuid
+		ORDER BY created_at`,
+		webhookSubscriptionColumns, h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, orgUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	webhooks := make([]model.WebhookSubscription, 0)
+	for rows.Next() {
+		webhook, err := scanWebhookSubscription(rows)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("webhook_count", len(webhooks))
+	apiRequest.SetMeta("event_types", webhookEventTypes)
+	apiRequest.Success(http.StatusOK, webhooks, "webhooks loaded successfully")
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetUserEventNotifications returns the unreleased events of an
+// organization which start soon or miss data, in the shape of the former
+// dashboard endpoint. Read from the event notifications of the user.
+//
+// Deprecated: Use AdminGetUserNotifications with org_uuid.
+//
+// PermissionNote: Returns notifications about events for the authenticated user.
+// PermissionChecks: Done in PSQL, notifications are written for permitted members only.
+func (h *ApiHandler) AdminGetUserEventNotifications(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-user-event-notifications")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+
+	eventLookaheadDays, err := strconv.Atoi(gc.DefaultQuery("event-lookahead-days", "14"))
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, "event-lookahead-days must be a number")
+		return
+	}
+	apiRequest.SetMeta("event-lookahead-days", eventLookaheadDays)
+
+	rows, err := h.DbPool.Query(ctx, app.UranusInstance.SqlAdminGetUserEventNotifications,
+		userUuid, orgUuid, eventLookaheadDays)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+	defer rows.Close()
+
+	notifications, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.UserEventNotification])
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	type Response struct {
+		Notifications []model.UserEventNotification `json:"notifications"`
+		TotalCount    int                           `json:"total_count"`
+	}
+
+	apiRequest.Success(http.StatusOK, Response{
+		Notifications: notifications,
+		TotalCount:    len(notifications),
+	})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetUserNotificationPreferences returns the channel of each
+// notification type for the user: in-app, immediate, daily or weekly.
+// Mandatory types always use their default channel.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminGetUserNotificationPreferences(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-user-notification-preferences")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	query := fmt.Sprintf(
+		`SELECT type, channel FROM %s.notification_preference WHERE user_uuid = $1::uuid`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, userUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	channels := map[string]string{}
+	for rows.Next() {
+		var notificationType, channel string
+		if err := rows.Scan(&notificationType, &channel); err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		channels[notificationType] = channel
+	}
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	preferences := make([]model.NotificationPreference, 0, len(notificationTypeNames))
+	for _, name := range notificationTypeNames {
+		typ := notificationTypes[name]
+		channel, ok := channels[name]
+		if !ok || typ.Mandatory {
+			channel = typ.DefaultChannel
+		}
+		preferences = append(preferences, model.NotificationPreference{
+			Type:           name,
+			Channel:        channel,
+			DefaultChannel: typ.DefaultChannel,
+			Mandatory:      typ.Mandatory,
+		})
+	}
+
+	apiRequest.Success(http.StatusOK, preferences, "")
+}
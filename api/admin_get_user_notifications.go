@@ -1,68 +1,97 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
-	"strconv"
+	"slices"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5"
 	"github.com/sndcds/grains/grains_api"
-	"github.com/sndcds/uranus/app"
 	"github.com/sndcds/uranus/model"
 )
 
-// PermissionNote: Returns notifications about events for the authenticated user.
-// PermissionChecks: Done in PSQL.
-// Verified: 2026-01-12, Roald
-
-func (h *ApiHandler) AdminGetUserEventNotifications(gc *gin.Context) {
-	apiRequest := grains_api.NewRequest(gc, "admin-get-user-event-notifications")
+// AdminGetUserNotifications returns the notifications of the user, newest
+// first. Optional parameters: unread (true for unread ones only), type,
+// org_uuid, limit (default 50, max 500) and offset. The meta data holds the
+// total number of unread notifications.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminGetUserNotifications(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-user-notifications")
 	ctx := gc.Request.Context()
 	userUuid := h.userUuid(gc)
 
-	orgUuid := gc.Param("orgUuid")
-	if orgUuid == "" {
-		apiRequest.Required("orgUuid is required")
+	unreadOnly := gc.Query("unread") == "true"
+
+	notificationType := gc.Query("type")
+	if notificationType != "" && !slices.Contains(notificationTypeNames, notificationType) {
+		apiRequest.Error(http.StatusBadRequest, "unknown notification type")
 		return
 	}
 
-	eventLookaheadDays, err := strconv.Atoi(gc.DefaultQuery("event-lookahead-days", "14"))
-	if err != nil {
-		debugf(err.Error())
-		apiRequest.InternalServerError()
+	orgUuid := gc.Query("org_uuid")
+
+	limit := GetContextParamIntDefault(gc, "limit", 50)
+	offset := GetContextParamIntDefault(gc, "offset", 0)
+	if limit < 1 || limit > 500 || offset < 0 {
+		apiRequest.Error(http.StatusBadRequest, "limit must be between 1 and 500, offset must not be negative")
 		return
 	}
-	apiRequest.SetMeta("event-lookahead-days", eventLookaheadDays)
-
-	query := app.UranusInstance.SqlAdminGetUserEventNotifications
 
-	rows, err := h.DbPool.Query(ctx, query, userUuid, orgUuid, eventLookaheadDays)
+	query := fmt.Sprintf(`
+		SELECT
+			uuid, type, org_uuid, entity_type, entity_uuid, title, body, link, data, channel,
+			created_by, created_at, read_at, emailed_at
+		FROM %s.notification
+		WHERE user_uuid = $1::uuid
+			AND (NOT $2 OR read_at IS NULL)
+			AND ($3 = '' OR type = $3)
+			AND ($4 = '' OR org_uuid = NULLIF($4, '')::uuid)
+		ORDER BY created_at DESC, uuid DESC
+		LIMIT $5 OFFSET $6`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, userUuid, unreadOnly, notificationType, orgUuid, limit, offset)
 	if err != nil {
 		debugf(err.Error())
-		apiRequest.InternalServerError()
+		apiRequest.DatabaseError()
 		return
-
 	}
-
 	defer rows.Close()
 
-	notifications, err := pgx.CollectRows(rows, pgx.RowToStructByName[model.UserEventNotification])
-	if err != nil {
+	notifications := make([]model.Notification, 0)
+	for rows.Next() {
+		var n model.Notification
+		err := rows.Scan(
+			&n.Uuid, &n.Type, &n.OrgUuid, &n.EntityType, &n.EntityUuid, &n.Title, &n.Body, &n.Link, &n.Data, &n.Channel,
+			&n.CreatedBy, &n.CreatedAt, &n.ReadAt, &n.EmailedAt)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
 		debugf(err.Error())
 		apiRequest.InternalServerError()
 		return
-
 	}
 
-	type Response struct {
-		Notifications []model.UserEventNotification `json:"notifications"`
-		TotalCount    int                           `json:"total_count"`
-	}
-
-	result := Response{
-		Notifications: notifications,
-		TotalCount:    len(notifications),
+	var unreadCount int
+	query = fmt.Sprintf(
+		`SELECT COUNT(*) FROM %s.notification WHERE user_uuid = $1::uuid AND read_at IS NULL`,
+		h.DbSchema)
+	err = h.DbPool.QueryRow(ctx, query, userUuid).Scan(&unreadCount)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
 	}
 
-	apiRequest.Success(http.StatusOK, result)
+	apiRequest.SetMeta("notification_count", len(notifications))
+	apiRequest.SetMeta("unread_count", unreadCount)
+	apiRequest.SetMeta("limit", limit)
+	apiRequest.SetMeta("offset", offset)
+	apiRequest.Success(http.StatusOK, notifications, "notifications loaded successfully")
 }
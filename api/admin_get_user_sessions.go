@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetUserSessions lists the active sessions of the user, most recently
+// used first. The session of the request is marked as current.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminGetUserSessions(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-user-sessions")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+	sessionUuid := h.sessionUuid(gc)
+
+	query := fmt.Sprintf(`
+		SELECT uuid, user_agent, client_ip, created_at, last_seen_at, expires_at
+		FROM %s.user_session
+		WHERE user_uuid = $1::uuid AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, userUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	sessions := make([]model.UserSession, 0)
+	for rows.Next() {
+		var s model.UserSession
+		err := rows.Scan(&s.Uuid, &s.UserAgent, &s.ClientIp, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		userAgent := ""
+		if s.UserAgent != nil {
+			userAgent = *s.UserAgent
+		}
+		s.Device = sessionDeviceLabel(userAgent)
+		s.Current = s.Uuid == sessionUuid
+		sessions = append(sessions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("session_count", len(sessions))
+	apiRequest.Success(http.StatusOK, sessions, "sessions loaded successfully")
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetUserTwoFactor returns the two-factor authentication status of the
+// user, including the organizations which require it.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminGetUserTwoFactor(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-user-two-factor")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	var status model.UserTwoFactorStatus
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			SELECT t.enabled_at,
+				(SELECT COUNT(*) FROM %[1]s.user_recovery_code c WHERE c.user_uuid = t.user_uuid AND c.used_at IS NULL)
+			FROM %[1]s.user_totp t
+			WHERE t.user_uuid = $1::uuid`,
+			h.DbSchema)
+		err := tx.QueryRow(ctx, query, userUuid).Scan(&status.EnabledAt, &status.RecoveryCodesLeft)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return TxInternalError(err)
+		}
+		if err == nil {
+			status.Enabled = status.EnabledAt != nil
+			status.SetupPending = status.EnabledAt == nil
+		}
+
+		status.RequiredByOrgs, err = h.twoFactorRequiringOrgsTx(ctx, tx, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, status, "two-factor status loaded successfully")
+}
@@ -0,0 +1,419 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+)
+
+type eventImportResult struct {
+	Row        int      `json:"row"`
+	Title      string   `json:"title"`
+	ExternalId *string  `json:"external_id,omitempty"`
+	DateCount  int      `json:"date_count"`
+	Rule       *string  `json:"rrule,omitempty"`
+	VenueUuid  *string  `json:"venue_uuid,omitempty"`
+	EventUuid  *string  `json:"event_uuid,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+type eventImportReport struct {
+	Mode       string              `json:"mode"`
+	Format     string              `json:"format"`
+	EventCount int                 `json:"event_count"`
+	ErrorCount int                 `json:"error_count"`
+	Imported   bool                `json:"imported"`
+	Events     []eventImportResult `json:"events"`
+}
+
+// AdminImportOrgEvents imports events from an ICS or CSV file into an
+// organization. The file is sent as multipart field "file" or as request body.
+// With mode "dry_run" (default) the file is only validated, with mode
+// "commit" all events are inserted in one transaction, provided no row has
+// errors. Each imported event gets an entry in the audit log of the org.
+//
+// Optional parameters applied to events without own values:
+// categories (e.g. "1,3"), release_status (default "draft"), venue (name or uuid).
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermChooseAsEventOrg and UserPermAddEvent, enforced by
+// RequireOrgPermissions middleware. UserPermChooseVenue is checked per venue.
+func (h *ApiHandler) AdminImportOrgEvents(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-import-org-events")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+
+	mode, _ := GetContextParamWithDefault(gc, "mode", "dry_run")
+	if mode != "dry_run" && mode != "commit" {
+		apiRequest.Error(http.StatusBadRequest, "mode must be dry_run or commit")
+		return
+	}
+
+	data, filename, err := h.readEventImportFile(gc)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format, _ := GetContextParam(gc, "format")
+	format = strings.ToLower(format)
+	if format == "" {
+		switch {
+		case strings.EqualFold(filepath.Ext(filename), ".ics"),
+			strings.Contains(string(data[:min(len(data), 512)]), "BEGIN:VCALENDAR"):
+			format = "ics"
+		default:
+			format = "csv"
+		}
+	}
+
+	var rows []*eventImportRow
+	switch format {
+	case "ics":
+		location, err := time.LoadLocation(h.Config.IcsTimezone)
+		if err != nil {
+			location = time.UTC
+		}
+		rows, err = parseEventImportICS(data, location)
+		if err != nil {
+			apiRequest.Error(http.StatusBadRequest, err.Error())
+			return
+		}
+	case "csv":
+		rows, err = parseEventImportCsv(data)
+		if err != nil {
+			apiRequest.Error(http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		apiRequest.Error(http.StatusBadRequest, "format must be ics or csv")
+		return
+	}
+
+	if len(rows) == 0 {
+		apiRequest.Error(http.StatusBadRequest, "no events found")
+		return
+	}
+
+	// Defaults
+	var defaultCategories []int
+	if v, _ := GetContextParam(gc, "categories"); v != "" {
+		defaultCategories, err = app.ParseIntSliceCsv(v)
+		if err != nil {
+			apiRequest.Error(http.StatusBadRequest, "invalid categories")
+			return
+		}
+	}
+	defaultReleaseStatus, _ := GetContextParamWithDefault(gc, "release_status", "draft")
+	defaultVenue, _ := GetContextParam(gc, "venue")
+
+	for _, row := range rows {
+		p := &row.Payload
+		p.OrgUuid = &orgUuid
+		if len(p.Categories) == 0 {
+			p.Categories = defaultCategories
+		}
+		if p.ReleaseStatus == nil {
+			status := defaultReleaseStatus
+			p.ReleaseStatus = &status
+		}
+		if row.Venue == "" {
+			row.Venue = defaultVenue
+		}
+		if p.PriceType == "" {
+			p.PriceType = "not_specified"
+		}
+
+		if err := p.Validate(); err != nil {
+			row.Errors = append(row.Errors, strings.Split(err.Error(), "; ")...)
+		}
+	}
+
+	errorCount := 0
+	eventUuids := make([]string, len(rows))
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		venueCache := map[string]*string{}
+		venuePermitted := map[string]bool{}
+
+		for _, row := range rows {
+			p := &row.Payload
+
+			if row.Venue != "" {
+				venueUuid, ok := venueCache[row.Venue]
+				if !ok {
+					uuid, venueErr := h.resolveImportVenueTx(gc, tx, orgUuid, row.Venue)
+					if venueErr != nil {
+						if venueErr.Code == http.StatusInternalServerError {
+							return venueErr
+						}
+						row.Errors = append(row.Errors, venueErr.Error())
+					} else {
+						venueUuid = &uuid
+					}
+					venueCache[row.Venue] = venueUuid
+				}
+				p.VenueUuid = venueUuid
+			}
+
+			// Venues the user is not allowed to choose
+			checkVenue := func(venueUuid *string) error {
+				if venueUuid == nil {
+					return nil
+				}
+				permitted, ok := venuePermitted[*venueUuid]
+				if !ok {
+					perms, err := h.GetUserEffectiveVenuePermissionsTx(gc, tx, userUuid, *venueUuid)
+					if err != nil {
+						return err
+					}
+					permitted = perms.Has(app.UserPermChooseVenue)
+					venuePermitted[*venueUuid] = permitted
+				}
+				if !permitted {
+					row.Errors = append(row.Errors, fmt.Sprintf("venue %s may not be chosen", *venueUuid))
+				}
+				return nil
+			}
+
+			if err := checkVenue(p.VenueUuid); err != nil {
+				return TxInternalError(err)
+			}
+
+			for i, d := range p.Dates {
+				if d.SpaceUuid == nil {
+					continue
+				}
+				venueUuid := d.VenueUuid
+				if venueUuid == nil {
+					venueUuid = p.VenueUuid
+				}
+				if venueUuid == nil {
+					row.Errors = append(row.Errors, fmt.Sprintf("dates[%d].space requires a venue", i))
+					continue
+				}
+				if err := checkVenue(venueUuid); err != nil {
+					return TxInternalError(err)
+				}
+				spaceOk, err := h.IsSpaceInVenueTx(gc, tx, *d.SpaceUuid, *venueUuid)
+				if err != nil {
+					return TxInternalError(err)
+				}
+				if !spaceOk {
+					row.Errors = append(row.Errors, fmt.Sprintf("dates[%d].space does not belong to the venue", i))
+				}
+			}
+
+			errorCount += len(row.Errors)
+		}
+
+		if mode != "commit" || errorCount > 0 {
+			return nil
+		}
+
+		for i, row := range rows {
+			eventUuid, txErr := h.insertEventPayloadTx(gc, tx, &userUuid, &row.Payload)
+			if txErr != nil {
+				txErr.Err = fmt.Errorf("row %d: %w", row.Row, txErr.Err)
+				return txErr
+			}
+			eventUuids[i] = eventUuid
+
+			err := h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "import", nil)
+			if err != nil {
+				return TxInternalError(err)
+			}
+
+			err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityEvent, eventUuid, "import", nil,
+				map[string]any{
+					"format":      format,
+					"row":         row.Row,
+					"title":       row.Payload.Title,
+					"external_id": row.Payload.ExternalId,
+					"date_count":  len(row.Payload.Dates),
+				})
+			if err != nil {
+				return TxInternalError(err)
+			}
+		}
+
+		err := RefreshEventProjections(ctx, tx, "event", eventUuids)
+		if err != nil {
+			debugf("Error: %v", err)
+			return &ApiTxError{
+				Code: http.StatusInternalServerError,
+				Err:  fmt.Errorf("refresh projection tables failed: %v", err),
+			}
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	report := eventImportReport{
+		Mode:       mode,
+		Format:     format,
+		EventCount: len(rows),
+		ErrorCount: errorCount,
+		Imported:   mode == "commit" && errorCount == 0,
+		Events:     make([]eventImportResult, 0, len(rows)),
+	}
+
+	for i, row := range rows {
+		result := eventImportResult{
+			Row:        row.Row,
+			Title:      row.Payload.Title,
+			ExternalId: row.Payload.ExternalId,
+			DateCount:  len(row.Payload.Dates),
+			VenueUuid:  row.Payload.VenueUuid,
+			Errors:     row.Errors,
+		}
+		if row.Payload.Recurrence != nil {
+			result.Rule = &row.Payload.Recurrence.Rule
+		}
+		if eventUuids[i] != "" {
+			result.EventUuid = &eventUuids[i]
+		}
+		report.Events = append(report.Events, result)
+	}
+
+	if mode == "commit" && errorCount > 0 {
+		apiRequest.Success(http.StatusUnprocessableEntity, report, "import contains invalid rows, nothing was imported")
+		return
+	}
+
+	if report.Imported {
+		apiRequest.Success(http.StatusCreated, report, fmt.Sprintf("%d events imported", len(rows)))
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, report)
+}
+
+// readEventImportFile returns the uploaded file (multipart field "file") or
+// the raw request body.
+func (h *ApiHandler) readEventImportFile(gc *gin.Context) ([]byte, string, error) {
+	maxSize := int64(h.Config.ImportMaxFileSize)
+
+	var reader io.Reader
+	filename := ""
+
+	if strings.HasPrefix(gc.ContentType(), "multipart/form-data") {
+		fileHeader, err := gc.FormFile("file")
+		if err != nil {
+			return nil, "", errors.New("file is required")
+		}
+		if maxSize > 0 && fileHeader.Size > maxSize {
+			return nil, "", fmt.Errorf("file exceeds the maximum size of %d bytes", maxSize)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, "", errors.New("failed to open file")
+		}
+		defer file.Close()
+		reader = file
+		filename = fileHeader.Filename
+	} else {
+		reader = gc.Request.Body
+	}
+
+	if maxSize > 0 {
+		reader = io.LimitReader(reader, maxSize+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", errors.New("failed to read file")
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return nil, "", fmt.Errorf("file exceeds the maximum size of %d bytes", maxSize)
+	}
+	if len(data) == 0 {
+		return nil, "", errors.New("file is empty")
+	}
+
+	return data, filename, nil
+}
+
+// resolveImportVenueTx resolves a venue given by uuid or name. Names are
+// matched case-insensitively, venues of the importing organization win if
+// the name is ambiguous. Unknown and ambiguous venues are returned as 400,
+// errors of the database as 500, which abort the import.
+func (h *ApiHandler) resolveImportVenueTx(
+	gc *gin.Context,
+	tx pgx.Tx,
+	orgUuid string,
+	venue string,
+) (string, *ApiTxError) {
+	ctx := gc.Request.Context()
+
+	if grains_uuid.IsValidUuidv7(venue) {
+		query := fmt.Sprintf(`SELECT uuid FROM %s.venue WHERE uuid = $1::uuid`, h.DbSchema)
+		var venueUuid string
+		err := tx.QueryRow(ctx, query, venue).Scan(&venueUuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return "", NewApiTxError(http.StatusBadRequest, "venue %s not found", venue)
+			}
+			return "", TxInternalError(err)
+		}
+		return venueUuid, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT uuid, org_uuid = $2::uuid
+		FROM %s.venue
+		WHERE lower(trim(name)) = lower(trim($1))`,
+		h.DbSchema)
+	rows, err := tx.Query(ctx, query, venue, orgUuid)
+	if err != nil {
+		return "", TxInternalError(err)
+	}
+	defer rows.Close()
+
+	var all, own []string
+	for rows.Next() {
+		var venueUuid string
+		var isOwn bool
+		if err := rows.Scan(&venueUuid, &isOwn); err != nil {
+			return "", TxInternalError(err)
+		}
+		all = append(all, venueUuid)
+		if isOwn {
+			own = append(own, venueUuid)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", TxInternalError(err)
+	}
+
+	switch {
+	case len(all) == 1:
+		return all[0], nil
+	case len(own) == 1:
+		return own[0], nil
+	case len(all) == 0:
+		return "", NewApiTxError(http.StatusBadRequest, "venue %q not found", venue)
+	default:
+		return "", NewApiTxError(http.StatusBadRequest, "venue %q is ambiguous, use the venue uuid", venue)
+	}
+}
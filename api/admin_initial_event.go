@@ -44,7 +44,7 @@ func (h *ApiHandler) AdminInitialEvent(gc *gin.Context) {
 		}
 
 		query := fmt.Sprintf(
-			`INSERT INTO %s.event (uuid, org_uuid, title, created_by) VALUES ($1::uuid, $2::uuid, $3, $4::uuid)`,
+			`INSERT INTO %s.event (uuid, org_uuid, title, created_by) VALUES ($1::uuid, $2::uuid, $3, NULLIF($4, '')::uuid)`,
 			h.DbSchema)
 		eventUuid, err := grains_uuid.Uuidv7String()
 		_, err = tx.Exec(ctx, query, eventUuid, payload.OrgUuid, eventTitle, userUuid)
@@ -18,8 +18,6 @@ func (h *ApiHandler) AdminInsertOrgPartnerRequest(gc *gin.Context) {
 	ctx := gc.Request.Context()
 	userUuid := h.userUuid(gc)
 
-	// TODO: Permission canRequestPartner beachten
-
 	fromOrgUuid := gc.Param("orgUuid")
 	if fromOrgUuid == "" {
 		apiRequest.Required("missing or invalid orgUuid")
@@ -117,6 +115,37 @@ func (h *ApiHandler) AdminInsertOrgPartnerRequest(gc *gin.Context) {
 			return nil
 		}
 
+		err = h.writeAuditLogTx(
+			gc, tx, fromOrgUuid, auditEntityPartner, body.ToOrgUuid, "request",
+			nil, map[string]any{"message": message})
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		var fromOrgName string
+		query := fmt.Sprintf(`SELECT name FROM %s.organization WHERE uuid = $1::uuid`, h.DbSchema)
+		err = tx.QueryRow(ctx, query, fromOrgUuid).Scan(&fromOrgName)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		n := notification{
+			Type:       notificationPartnerRequest,
+			OrgUuid:    body.ToOrgUuid,
+			EntityType: notificationEntityOrg,
+			EntityUuid: fromOrgUuid,
+			Title:      fromOrgName + " requests a partnership",
+			Data:       map[string]any{"from_org_uuid": fromOrgUuid, "to_org_uuid": body.ToOrgUuid},
+			CreatedBy:  userUuid,
+		}
+		if body.Message != nil {
+			n.Body = strings.TrimSpace(*body.Message)
+		}
+		_, err = h.notifyOrgMembersTx(ctx, tx, n, body.ToOrgUuid, app.UserPermAnswerPartnerRequest)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		apiStatus = http.StatusCreated
 		apiMessage = "request created successfully"
 		return nil
@@ -135,8 +164,6 @@ func (h *ApiHandler) AdminInsertOrgPartnerAccept(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-insert-org-partner-request")
 	ctx := gc.Request.Context()
 
-	// TODO: Permission canAnswerPartnerRequests beachten
-
 	orgUuid := gc.Param("orgUuid")
 	if orgUuid == "" {
 		apiRequest.Required("missing or invalid orgUuid")
@@ -199,6 +226,37 @@ func (h *ApiHandler) AdminInsertOrgPartnerAccept(gc *gin.Context) {
 		return
 	}
 
+	err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityPartner, partnerUuid, "accept", nil, nil)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	var orgName string
+	query := fmt.Sprintf(`SELECT name FROM %s.organization WHERE uuid = $1::uuid`, h.DbSchema)
+	err = tx.QueryRow(ctx, query, orgUuid).Scan(&orgName)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	_, err = h.notifyOrgMembersTx(ctx, tx, notification{
+		Type:       notificationPartnerAccepted,
+		OrgUuid:    partnerUuid,
+		EntityType: notificationEntityOrg,
+		EntityUuid: orgUuid,
+		Title:      orgName + " accepted the partnership request",
+		Data:       map[string]any{"from_org_uuid": partnerUuid, "to_org_uuid": orgUuid},
+		CreatedBy:  h.userUuid(gc),
+	}, partnerUuid, app.UserPermRequestPartner)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit(ctx); err != nil {
 		debugf(err.Error())
@@ -213,8 +271,6 @@ func (h *ApiHandler) AdminOrgPartnerReject(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-org-partner-reject")
 	ctx := gc.Request.Context()
 
-	// TODO: Permission canDeletePartnership beachten
-
 	orgUuid := gc.Param("orgUuid")
 	if orgUuid == "" {
 		apiRequest.Required("missing or invalid orgUuid")
@@ -229,15 +285,33 @@ func (h *ApiHandler) AdminOrgPartnerReject(gc *gin.Context) {
 	}
 	apiRequest.SetMeta("partner_uuid", partnerUuid)
 
-	query := fmt.Sprintf(`
-		DELETE FROM %s.organization_partner_request
-		WHERE from_org_uuid = $1::uuid
-		AND to_org_uuid = $2::uuid
-		`, h.DbSchema)
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			DELETE FROM %s.organization_partner_request
+			WHERE from_org_uuid = $1::uuid
+			AND to_org_uuid = $2::uuid
+			RETURNING to_jsonb(organization_partner_request)`,
+			h.DbSchema)
 
-	_, err := h.DbPool.Query(ctx, query, partnerUuid, orgUuid)
-	if err != nil {
-		debugf(err.Error())
+		var before map[string]any
+		err := tx.QueryRow(ctx, query, partnerUuid, orgUuid).Scan(&before)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return TxInternalError(err)
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityPartner, partnerUuid, "reject", before, nil)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+
+	if txErr != nil {
+		debugf(txErr.Error())
 		apiRequest.InternalServerError()
 		return
 	}
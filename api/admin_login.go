@@ -1,13 +1,14 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/sndcds/grains/grains_api"
 	"github.com/sndcds/uranus/app"
 	"github.com/sndcds/uranus/model"
@@ -15,6 +16,7 @@ import (
 
 func (h *ApiHandler) Login(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "login")
+	ctx := gc.Request.Context()
 
 	var userCredentials model.UserCredentials
 
@@ -27,17 +29,18 @@ func (h *ApiHandler) Login(gc *gin.Context) {
 	}
 
 	if userCredentials.Email == "" || userCredentials.Password == "" {
-		debugf(err.Error())
 		apiRequest.Error(http.StatusUnauthorized, "invalid email or password")
 		return
 	}
 
 	var user model.User
+	var throttle loginThrottle
 	query := fmt.Sprintf(
-		`SELECT uuid, email, password_hash, first_name, last_name, display_name, locale, theme, is_active
+		`SELECT uuid, email, password_hash, first_name, last_name, display_name, locale, theme, is_active, account_type,
+			failed_login_count, last_failed_login_at, login_locked_until
 		FROM %s.user WHERE email = $1`,
 		h.DbSchema)
-	err = h.DbPool.QueryRow(gc, query, userCredentials.Email).Scan(
+	err = h.DbPool.QueryRow(ctx, query, userCredentials.Email).Scan(
 		&user.Uuid,
 		&user.Email,
 		&user.PasswordHash,
@@ -47,45 +50,199 @@ func (h *ApiHandler) Login(gc *gin.Context) {
 		&user.Locale,
 		&user.Theme,
 		&user.IsActive,
+		&user.AccountType,
+		&throttle.FailedCount,
+		&throttle.LastFailedAt,
+		&throttle.LockedUntil,
 	)
 	if err != nil {
-		debugf(err.Error())
-		apiRequest.Error(http.StatusUnauthorized, "login error")
+		if !errors.Is(err, pgx.ErrNoRows) {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+
+		// Unknown email addresses are throttled and answered like wrong
+		// passwords, so the response does not tell whether an account exists
+		throttle, err := h.loadUnknownLoginThrottle(ctx, userCredentials.Email)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		if h.loginThrottled(gc, throttle) {
+			return
+		}
+		_ = app.ComparePasswords(loginDummyPasswordHash(), userCredentials.Password)
+		if err := h.recordUnknownLoginFailure(ctx, userCredentials.Email); err != nil {
+			debugf(err.Error())
+		}
+		apiRequest.Error(http.StatusUnauthorized, "login failed")
 		return
 	}
 
-	if !user.IsActive || app.ComparePasswords(*user.PasswordHash, userCredentials.Password) != nil {
-		debugf(err.Error())
+	// Failed logins delay further attempts and finally lock the login
+	if h.loginThrottled(gc, throttle) {
+		return
+	}
+
+	if !user.IsActive || user.PasswordHash == nil ||
+		app.ComparePasswords(*user.PasswordHash, userCredentials.Password) != nil {
+		if _, err := h.recordLoginFailure(ctx, user.Uuid); err != nil {
+			debugf(err.Error())
+		}
 		apiRequest.Error(http.StatusUnauthorized, "login failed")
 		return
 	}
 
-	// Create access token
-	accessExp := time.Now().Add(time.Duration(h.Config.AuthTokenExpirationTime) * time.Second)
-	accessClaims := &app.Claims{
-		UserUuid: user.Uuid,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(accessExp),
-		},
+	h.completeLogin(gc, apiRequest, user)
+}
+
+// LoginTwoFactor completes the login of an account with two-factor
+// authentication. It takes the interim token returned by Login and either a
+// TOTP code or one of the recovery codes, and responds like Login.
+func (h *ApiHandler) LoginTwoFactor(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "login-two-factor")
+	ctx := gc.Request.Context()
+
+	var payload struct {
+		TwoFactorToken string `json:"two_factor_token" binding:"required"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+	if payload.Code == "" && payload.RecoveryCode == "" {
+		apiRequest.Required("code or recovery_code is required")
+		return
+	}
+
+	userUuid, err := parseTwoFactorToken(payload.TwoFactorToken)
+	if err != nil {
+		apiRequest.Error(http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Wrong codes count as failed logins, so codes cannot be guessed
+	throttle, err := h.loadLoginThrottle(ctx, userUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+	if h.loginThrottled(gc, throttle) {
+		return
+	}
+
+	var user model.User
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		ok, err := h.verifySecondFactorTx(ctx, tx, userUuid, payload.Code, payload.RecoveryCode)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return NewApiTxError(http.StatusUnauthorized, "invalid code")
+		}
+
+		query := fmt.Sprintf(
+			`SELECT uuid, email, first_name, last_name, display_name, locale, theme, is_active, account_type
+			FROM %s.user WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		err = tx.QueryRow(ctx, query, userUuid).Scan(
+			&user.Uuid,
+			&user.Email,
+			&user.FirstName,
+			&user.LastName,
+			&user.DisplayName,
+			&user.Locale,
+			&user.Theme,
+			&user.IsActive,
+			&user.AccountType,
+		)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !user.IsActive {
+			return NewApiTxError(http.StatusUnauthorized, "login failed")
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		if txErr.Code == http.StatusUnauthorized {
+			if _, err := h.recordLoginFailure(ctx, userUuid); err != nil {
+				debugf(err.Error())
+			}
+		}
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenStr, err := accessToken.SignedString(app.UranusInstance.JwtKey)
+
+	h.startLoginSession(gc, apiRequest, user)
+}
+
+// completeLogin responds to a user who has been authenticated by Login or
+// LoginOidcCallback. Accounts with two-factor authentication get an interim
+// token, their session starts once LoginTwoFactor has verified the second
+// factor.
+func (h *ApiHandler) completeLogin(gc *gin.Context, apiRequest *grains_api.Request, user model.User) {
+	ctx := gc.Request.Context()
+
+	twoFactor, err := h.userTwoFactorEnabled(ctx, h.DbPool, user.Uuid)
 	if err != nil {
 		debugf(err.Error())
 		apiRequest.InternalServerError()
 		return
 	}
+	if twoFactor {
+		token, err := h.issueTwoFactorToken(user.Uuid)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		apiRequest.Success(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"two_factor_token":    token,
+			"expires_in":          int(twoFactorTokenLifetime.Seconds()),
+		}, "second factor required")
+		return
+	}
+
+	h.startLoginSession(gc, apiRequest, user)
+}
+
+// startLoginSession starts a session for a user who has passed all login
+// steps and responds with the tokens and the user's profile. Failed logins
+// are only reset here, so knowing the password does not allow to guess
+// second factor codes endlessly.
+func (h *ApiHandler) startLoginSession(gc *gin.Context, apiRequest *grains_api.Request, user model.User) {
+	ctx := gc.Request.Context()
+
+	if err := h.resetLoginFailures(ctx, user.Uuid); err != nil {
+		debugf(err.Error())
+	}
 
-	// Create refresh token
-	refreshExp := time.Now().Add(7 * 24 * time.Hour)
-	refreshClaims := &app.Claims{
-		UserUuid: user.Uuid,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(refreshExp),
-		},
+	// Start a session, refresh tokens are rotated by Refresh
+	var sessionUuid, refreshTokenStr string
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var err error
+		sessionUuid, refreshTokenStr, err = h.createUserSessionTx(gc, tx, user.Uuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.InternalServerError()
+		return
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenStr, err := refreshToken.SignedString(app.UranusInstance.JwtKey)
+
+	accessTokenStr, accessExp, err := h.issueAccessToken(user.Uuid, sessionUuid)
 	if err != nil {
 		debugf(err.Error())
 		apiRequest.InternalServerError()
@@ -99,61 +256,69 @@ func (h *ApiHandler) Login(gc *gin.Context) {
 		"last_name":     user.LastName,
 		"locale":        user.Locale,
 		"theme":         user.Theme,
+		"account_type":  user.AccountType,
 		"access_token":  accessTokenStr,
 		"refresh_token": refreshTokenStr,
+		"expires_in":    int(time.Until(accessExp).Seconds()),
+		"session_uuid":  sessionUuid,
 		"avatar_url":    app.GetAvatarURL(h.Config.BaseApiUrl, h.Config.ProfileImageDir, user.Uuid, 64),
 	}, "login successful")
 }
 
+// Refresh exchanges a refresh token for a new access token and a new
+// refresh token. The refresh token is read from the JSON body
+// ({"refresh_token": "..."}) or the Authorization header. Each refresh token
+// can be used once, presenting it again revokes the session.
 func (h *ApiHandler) Refresh(gc *gin.Context) {
-	apiRequest := grains_api.NewRequest(gc, "refresh access token")
-	const refreshErrorMsg = "invalid refresh token"
+	apiRequest := grains_api.NewRequest(gc, "refresh-access-token")
+	ctx := gc.Request.Context()
 
-	// Get token from Authorization header
-	authHeader := gc.GetHeader("Authorization")
-	parts := strings.SplitN(authHeader, " ", 2)
-	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-		debugf("Invalid refresh header: %s", authHeader)
-		apiRequest.Error(http.StatusUnauthorized, "failed")
-		return
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
 	}
-	refreshToken := parts[1]
+	_ = gc.ShouldBindJSON(&body)
+	refreshToken := strings.TrimSpace(body.RefreshToken)
 
-	// Parse token
-	claims := &app.Claims{}
-	tkn, err := jwt.ParseWithClaims(refreshToken, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	if refreshToken == "" {
+		authHeader := gc.GetHeader("Authorization")
+		parts := strings.SplitN(authHeader, " ", 2)
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+			refreshToken = strings.TrimSpace(parts[1])
 		}
-		return app.UranusInstance.JwtKey, nil
+	}
+	if refreshToken == "" {
+		apiRequest.Error(http.StatusUnauthorized, "missing refresh token")
+		return
+	}
+
+	var session refreshedSession
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var txErr *ApiTxError
+		session, txErr = h.rotateRefreshTokenTx(gc, tx, refreshToken)
+		return txErr
 	})
-	if err != nil || !tkn.Valid {
-		debugf("Invalid refresh token: %v", err)
-		apiRequest.Error(http.StatusUnauthorized, "failed")
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
 		return
 	}
 
-	// Issue new access token
-	accessExp := time.Now().Add(time.Duration(h.Config.AuthTokenExpirationTime) * time.Second)
-	newClaims := &app.Claims{
-		UserUuid: claims.UserUuid,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(accessExp),
-		},
+	if session.Reused {
+		debugf("refresh token reuse, session %s revoked", session.SessionUuid)
+		apiRequest.Error(http.StatusUnauthorized, "refresh token already used, session revoked")
+		return
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
-	accessTokenStr, err := accessToken.SignedString(app.UranusInstance.JwtKey)
+
+	accessTokenStr, accessExp, err := h.issueAccessToken(session.UserUuid, session.SessionUuid)
 	if err != nil {
-		debugf("failed to sign new access token for user_uuid=%s: %v", claims.UserUuid, err)
+		debugf("failed to sign new access token for user_uuid=%s: %v", session.UserUuid, err)
 		apiRequest.InternalServerError()
 		return
 	}
 
-	// Return new access token
-	gc.Header("Authorization", "Bearer "+accessTokenStr)
-	gc.JSON(http.StatusOK, gin.H{
-		"message":      "token refreshed",
-		"access_token": accessTokenStr,
-		"expires_in":   int(time.Until(accessExp).Seconds()),
-	})
+	apiRequest.Success(http.StatusOK, gin.H{
+		"access_token":  accessTokenStr,
+		"refresh_token": session.RefreshToken,
+		"expires_in":    int(time.Until(accessExp).Seconds()),
+	}, "token refreshed")
 }
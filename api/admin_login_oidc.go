@@ -0,0 +1,182 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// GetOidcProviders lists the OpenID Connect providers users can log in with,
+// e.g. to show a button for each on the login page.
+func (h *ApiHandler) GetOidcProviders(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-oidc-providers")
+
+	providers := make([]gin.H, 0, len(h.Config.OidcProviders))
+	for _, provider := range h.Config.OidcProviders {
+		displayName := provider.DisplayName
+		if displayName == "" {
+			displayName = provider.Name
+		}
+		providers = append(providers, gin.H{
+			"name":         provider.Name,
+			"display_name": displayName,
+		})
+	}
+
+	apiRequest.Success(http.StatusOK, providers, "")
+}
+
+// LoginOidcStart starts a login with an OpenID Connect provider using the
+// authorization code flow with PKCE. The client sends the browser to the
+// returned authorization_url. The provider redirects back to its configured
+// redirect URL with code and state, which the client passes on to
+// LoginOidcCallback.
+func (h *ApiHandler) LoginOidcStart(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "login-oidc-start")
+	ctx := gc.Request.Context()
+
+	provider, ok := h.Config.OidcProvider(gc.Param("provider"))
+	if !ok {
+		apiRequest.NotFound("unknown identity provider")
+		return
+	}
+	apiRequest.SetMeta("provider", provider.Name)
+
+	discovery, err := oidcDiscover(ctx, provider)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.Error(http.StatusBadGateway, "identity provider not available")
+		return
+	}
+
+	state, codeVerifier, nonce, err := h.createOidcLoginState(ctx, provider.Name)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"authorization_url": oidcAuthorizationUrl(discovery, provider, state, nonce, codeVerifier),
+		"state":             state,
+		"expires_in":        int(oidcLoginStateLifetime.Seconds()),
+	}, "")
+}
+
+// LoginOidcCallback completes a login started by LoginOidcStart. It
+// exchanges the authorization code, verifies the ID token and logs in the
+// user linked to the identity, see resolveOidcUserTx. Memberships are
+// updated from the group mappings of the organizations. The response is the
+// same as of Login, including the interim token for accounts with two-factor
+// authentication.
+func (h *ApiHandler) LoginOidcCallback(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "login-oidc-callback")
+	ctx := gc.Request.Context()
+
+	provider, ok := h.Config.OidcProvider(gc.Param("provider"))
+	if !ok {
+		apiRequest.NotFound("unknown identity provider")
+		return
+	}
+	apiRequest.SetMeta("provider", provider.Name)
+
+	var payload struct {
+		Code  string `json:"code" binding:"required"`
+		State string `json:"state" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	codeVerifier, nonce, ok, err := h.consumeOidcLoginState(ctx, provider.Name, payload.State)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+	if !ok {
+		apiRequest.Error(http.StatusUnauthorized, "invalid or expired state")
+		return
+	}
+
+	discovery, err := oidcDiscover(ctx, provider)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.Error(http.StatusBadGateway, "identity provider not available")
+		return
+	}
+
+	tokens, err := oidcExchangeCode(ctx, discovery, provider, payload.Code, codeVerifier)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.Error(http.StatusUnauthorized, "authorization code rejected by identity provider")
+		return
+	}
+
+	claims, err := oidcVerifyIdToken(ctx, discovery, provider, tokens.IdToken, nonce)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.Error(http.StatusUnauthorized, "invalid id token")
+		return
+	}
+
+	if err := oidcMergeUserinfo(ctx, discovery, provider, tokens.AccessToken, claims); err != nil {
+		debugf(err.Error())
+	}
+
+	identity := oidcIdentityFromClaims(provider, claims)
+	if identity.Subject == "" {
+		apiRequest.Error(http.StatusUnauthorized, "invalid id token")
+		return
+	}
+
+	var user model.User
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		userUuid, txErr := h.resolveOidcUserTx(ctx, tx, provider, identity)
+		if txErr != nil {
+			return txErr
+		}
+
+		query := fmt.Sprintf(
+			`SELECT uuid, email, first_name, last_name, display_name, locale, theme, is_active, account_type
+			FROM %s.user WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		err := tx.QueryRow(ctx, query, userUuid).Scan(
+			&user.Uuid,
+			&user.Email,
+			&user.FirstName,
+			&user.LastName,
+			&user.DisplayName,
+			&user.Locale,
+			&user.Theme,
+			&user.IsActive,
+			&user.AccountType,
+		)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !user.IsActive {
+			return NewApiTxError(http.StatusUnauthorized, "login failed")
+		}
+
+		err = h.syncOidcMembershipsTx(gc, tx, provider.Name, user.Uuid, identity.Groups)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	// The second factor is required with single sign-on as well
+	h.completeLogin(gc, apiRequest, user)
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminLogout revokes the session of the access token. Its refresh token
+// can no longer be used and access tokens of the session are rejected.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminLogout(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-logout")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+	sessionUuid := h.sessionUuid(gc)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		_, err := h.revokeSessionTx(ctx, tx, userUuid, sessionUuid, "logout")
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "logged out")
+}
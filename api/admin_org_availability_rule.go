@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminGetOrgAvailabilityRule returns the thresholds from which few seats of
+// the event dates of an organization are left, the defaults if it has none.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminGetOrgAvailabilityRule(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-get-org-availability-rule")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	rule, err := h.loadAvailabilityRuleTx(ctx, h.DbPool, orgUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, rule, "")
+}
+
+// AdminUpdateOrgAvailabilityRule sets the thresholds from which few seats of
+// the event dates of an organization are left and updates the availability
+// of its upcoming dates.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminUpdateOrgAvailabilityRule(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-org-availability-rule")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	var rule model.AvailabilityRule
+	if err := gc.ShouldBindJSON(&rule); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+	if rule.FewLeftPercent < 0 || rule.FewLeftPercent > 100 {
+		apiRequest.Error(http.StatusBadRequest, "few_left_percent must be between 0 and 100")
+		return
+	}
+	if rule.FewLeftSeats < 0 {
+		apiRequest.Error(http.StatusBadRequest, "few_left_seats must not be negative")
+		return
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAvailabilityRuleTx(ctx, tx, orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if before == rule {
+			return nil
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s.org_availability_rule (org_uuid, few_left_percent, few_left_seats)
+			VALUES ($1::uuid, $2, $3)
+			ON CONFLICT (org_uuid) DO UPDATE SET
+				few_left_percent = EXCLUDED.few_left_percent,
+				few_left_seats = EXCLUDED.few_left_seats,
+				modified_at = NOW()`,
+			h.DbSchema)
+		if _, err := tx.Exec(ctx, query, orgUuid, rule.FewLeftPercent, rule.FewLeftSeats); err != nil {
+			return TxInternalError(err)
+		}
+		if err := h.updateOrgAvailabilityTx(ctx, tx, orgUuid); err != nil {
+			return TxInternalError(err)
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityOrg, orgUuid, "update-availability-rule", before, rule)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, rule, "availability rule updated")
+}
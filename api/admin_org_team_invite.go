@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -44,8 +43,9 @@ func (h *ApiHandler) AdminOrgTeamInvite(gc *gin.Context) {
 	}
 
 	var payload struct {
-		Email   string `json:"email" binding:"required,email"`
-		Referer string `json:"referer" binding:"required"`
+		Email    string `json:"email" binding:"required,email"`
+		Referer  string `json:"referer" binding:"required"`
+		RoleUuid string `json:"role_uuid"`
 	}
 
 	if err := gc.ShouldBindJSON(&payload); err != nil {
@@ -64,6 +64,28 @@ func (h *ApiHandler) AdminOrgTeamInvite(gc *gin.Context) {
 			return txErr
 		}
 
+		// Preassigning a role grants its permissions when the invitation is
+		// accepted, which requires managing permissions
+		if payload.RoleUuid != "" {
+			orgPermissions, err := h.GetUserOrgPermissionsTx(gc, tx, userUuid, orgUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if !orgPermissions.Has(app.UserPermManagePermissions) {
+				return ApiErrForbidden("assigning a role requires managing permissions")
+			}
+			rolePermissions, ok, err := h.orgMemberRolePermissionsTx(ctx, tx, orgUuid, payload.RoleUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if !ok {
+				return NewApiTxError(http.StatusBadRequest, "unknown role")
+			}
+			if !orgPermissions.HasAll(app.Permissions(rolePermissions)) {
+				return ApiErrForbidden("you cannot grant permissions you do not have")
+			}
+		}
+
 		// Fetch invited user + org info
 		var invitedUserUuid string
 		var invitedUserDisplayName *string
@@ -111,21 +133,6 @@ func (h *ApiHandler) AdminOrgTeamInvite(gc *gin.Context) {
 			}
 		}
 
-		var subject string
-		var template string
-		err = tx.QueryRow(
-			ctx,
-			app.UranusInstance.SqlGetSystemEmailTemplate,
-			"team-invite",
-			lang).
-			Scan(&subject, &template)
-		if err != nil {
-			return &ApiTxError{
-				Code: http.StatusInternalServerError,
-				Err:  errors.New("failed to get message template"),
-			}
-		}
-
 		res, err := tx.Exec(
 			ctx,
 			app.UranusInstance.SqlAdminUpsertInvitedOrgTeamMember,
@@ -133,6 +140,7 @@ func (h *ApiHandler) AdminOrgTeamInvite(gc *gin.Context) {
 			invitedUserUuid,
 			tokenString,
 			userUuid,
+			payload.RoleUuid,
 		)
 		if err != nil {
 			return &ApiTxError{
@@ -146,15 +154,36 @@ func (h *ApiHandler) AdminOrgTeamInvite(gc *gin.Context) {
 			return nil
 		}
 
+		err = h.writeAuditLogTx(
+			gc, tx, orgUuid, auditEntityMember, invitedUserUuid, "invite",
+			nil, map[string]any{"email": payload.Email, "role_uuid": payload.RoleUuid})
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		displayName := BuildUserLabel(payload.Email, invitedUserDisplayName, invitedUserFirstName, invitedUserLastName)
 
 		inviteAcceptUrl := payload.Referer + "/app/activate/team-invitation?token=" + tokenString
-		emailMessage := strings.Replace(template, "{{invite_link}}", inviteAcceptUrl, -1)
-		emailMessage = strings.Replace(emailMessage, "{{expiry_minutes}}", strconv.Itoa(expiryMinutes), -1)
-		emailMessage = strings.Replace(emailMessage, "{{display_name}}", displayName, -1)
-		emailMessage = strings.Replace(emailMessage, "{{organization_name}}", orgName, -1)
-
-		err = sendEmailWithTimeout(payload.Email, subject, emailMessage, 20*time.Second)
+		// The invitation is mailed with its own template, whatever the
+		// preferences of the user
+		_, err = h.notifyUsersTx(ctx, tx, notification{
+			Type:          notificationTeamInvite,
+			OrgUuid:       orgUuid,
+			EntityType:    notificationEntityOrg,
+			EntityUuid:    orgUuid,
+			Title:         "Invitation to join " + orgName,
+			Link:          inviteAcceptUrl,
+			Data:          map[string]any{"expires_at": tokenExp},
+			CreatedBy:     userUuid,
+			EmailTemplate: "team-invite",
+			EmailVars: map[string]string{
+				"invite_link":       inviteAcceptUrl,
+				"expiry_minutes":    strconv.Itoa(expiryMinutes),
+				"display_name":      displayName,
+				"organization_name": orgName,
+			},
+			Lang: lang,
+		}, []string{invitedUserUuid})
 		if err != nil {
 			debugf(err.Error())
 			return &ApiTxError{
@@ -172,6 +201,10 @@ func (h *ApiHandler) AdminOrgTeamInvite(gc *gin.Context) {
 			return
 		}
 		debugf(txErr.Error())
+		if txErr.Code == http.StatusBadRequest || txErr.Code == http.StatusForbidden {
+			apiRequest.Error(txErr.Code, txErr.Error())
+			return
+		}
 		apiRequest.InternalServerError()
 		return
 	}
@@ -226,8 +259,9 @@ func (h *ApiHandler) OrgTeamInviteAccept(gc *gin.Context) {
 
 		// Query stored activation token
 		var storedToken *string
-		query := fmt.Sprintf(`SELECT accept_token FROM %s.organization_member_link WHERE user_uuid = $1::uuid AND org_uuid = $2::uuid FOR UPDATE`, h.DbSchema)
-		err = tx.QueryRow(ctx, query, userUuid, orgUuid).Scan(&storedToken)
+		var roleUuid *string
+		query := fmt.Sprintf(`SELECT accept_token, role_uuid::text FROM %s.organization_member_link WHERE user_uuid = $1::uuid AND org_uuid = $2::uuid FOR UPDATE`, h.DbSchema)
+		err = tx.QueryRow(ctx, query, userUuid, orgUuid).Scan(&storedToken, &roleUuid)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return &ApiTxError{
@@ -269,12 +303,24 @@ func (h *ApiHandler) OrgTeamInviteAccept(gc *gin.Context) {
 			}
 		}
 
-		// Create user organization link
+		// Create user organization link, with the role preassigned by the
+		// invitation if there is one
 		uolQuery := fmt.Sprintf(`
-			INSERT INTO %s.user_organization_link (user_uuid, org_uuid, permissions)
-			VALUES ($1::uuid, $2::uuid, $3)`,
+			INSERT INTO %[1]s.user_organization_link (user_uuid, org_uuid, role_uuid, permissions)
+			VALUES ($1::uuid, $2::uuid, $3::uuid, COALESCE(
+				(SELECT permissions FROM %[1]s.org_member_role WHERE uuid = $3::uuid AND org_uuid = $2::uuid), 0))`,
 			h.DbSchema)
-		_, err = tx.Exec(ctx, uolQuery, userUuid, orgUuid, 0)
+		_, err = tx.Exec(ctx, uolQuery, userUuid, orgUuid, roleUuid)
+		if err != nil {
+			return &ApiTxError{
+				Code: http.StatusInternalServerError,
+				Err:  fmt.Errorf("failed to accept invite"),
+			}
+		}
+
+		// Visitors joining an organization become members
+		accountQuery := fmt.Sprintf(`UPDATE %s.user SET account_type = $2 WHERE uuid = $1::uuid`, h.DbSchema)
+		_, err = tx.Exec(ctx, accountQuery, userUuid, app.AccountTypeMember)
 		if err != nil {
 			return &ApiTxError{
 				Code: http.StatusInternalServerError,
@@ -56,9 +56,8 @@ func (h *ApiHandler) AdminDeletePlutoImage(gc *gin.Context) {
 
 func (h *ApiHandler) AdminUpsertPlutoImage(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-upsert-pluto-image")
-	userUuid := h.userUuid(gc)
-
-	// TODO: Check user permissions for different contexts
+	// Pluto requires a creator, images uploaded with an API key are owned by the key
+	userUuid := h.actorUuid(gc)
 
 	plutoContext := gc.Param("context")
 	apiRequest.SetMeta("pluto_context", plutoContext)
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminReadUserNotifications marks all unread notifications of the user as
+// read, optionally only those of a type or organization.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminReadUserNotifications(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-read-user-notifications")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	var payload struct {
+		Type    string `json:"type"`
+		OrgUuid string `json:"org_uuid"`
+	}
+	if gc.Request.ContentLength > 0 {
+		if err := gc.ShouldBindJSON(&payload); err != nil {
+			apiRequest.PayloadError()
+			return
+		}
+	}
+	if payload.Type != "" && !slices.Contains(notificationTypeNames, payload.Type) {
+		apiRequest.Error(http.StatusBadRequest, "unknown notification type")
+		return
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.notification
+		SET read_at = NOW()
+		WHERE user_uuid = $1::uuid
+			AND read_at IS NULL
+			AND ($2 = '' OR type = $2)
+			AND ($3 = '' OR org_uuid = NULLIF($3, '')::uuid)`,
+		h.DbSchema)
+	res, err := h.DbPool.Exec(ctx, query, userUuid, payload.Type, payload.OrgUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+
+	apiRequest.SetMeta("read_count", res.RowsAffected())
+	apiRequest.SuccessNoData(http.StatusOK, "notifications marked as read")
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminRestoreEventRevision restores the content of an older revision. The
+// restore is recorded as a new revision, so it can be undone itself. Release
+// status, dates and recurrence of the event are not changed.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminRestoreEventRevision(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-restore-event-revision")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	revision, err := strconv.Atoi(gc.Param("revision"))
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, "revision must be a number")
+		return
+	}
+	apiRequest.SetMeta("revision", revision)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if before == nil {
+			return ApiErrNotFound("event not found")
+		}
+
+		snapshot, err := h.loadEventRevisionSnapshotTx(ctx, tx, eventUuid, revision)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if snapshot == nil {
+			return ApiErrNotFound("revision not found")
+		}
+
+		err = h.restoreEventSnapshotTx(ctx, tx, eventUuid, snapshot, before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = h.insertEventRevisionTx(ctx, tx, eventUuid, userUuid, "restore", before, &revision)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
+		if err != nil {
+			return ApiErrInternal("refresh projection tables failed: %v", err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "event revision restored successfully")
+}
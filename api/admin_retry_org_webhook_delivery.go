@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminRetryOrgWebhookDelivery queues a delivery of the webhook for sending
+// again, e.g. after a failed delivery when the endpoint is fixed. The
+// attempt count starts over.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminRetryOrgWebhookDelivery(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-retry-org-webhook-delivery")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	webhookUuid := gc.Param("webhookUuid")
+	if webhookUuid == "" {
+		apiRequest.Required("webhookUuid is required")
+		return
+	}
+	apiRequest.SetMeta("webhook_uuid", webhookUuid)
+
+	deliveryId, err := strconv.ParseInt(gc.Param("deliveryId"), 10, 64)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, "deliveryId must be a number")
+		return
+	}
+	apiRequest.SetMeta("delivery_id", deliveryId)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			UPDATE %[1]s.webhook_delivery d
+			SET status = 'pending', attempt_count = 0, next_attempt_at = NOW(), delivered_at = NULL
+			FROM %[1]s.webhook_subscription s
+			WHERE d.id = $1
+				AND d.subscription_uuid = $2::uuid
+				AND s.uuid = d.subscription_uuid
+				AND s.org_uuid = $3::uuid`,
+			h.DbSchema)
+		res, err := tx.Exec(ctx, query, deliveryId, webhookUuid, orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if res.RowsAffected() == 0 {
+			return ApiErrNotFound("webhook delivery not found")
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "webhook delivery queued")
+}
@@ -6,102 +6,79 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
 	"github.com/sndcds/uranus/app"
 )
 
-// TODO: Code review
-
+// AdminSendMessage sends a message as notification to a user or to the
+// members of an organization receiving its messages (context "user" or
+// "organization", context_uuid the user or organization).
+//
+// PermissionNote: User must be authenticated.
 func (h *ApiHandler) AdminSendMessage(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-send-message")
 	ctx := gc.Request.Context()
 	fromUserUuid := h.userUuid(gc)
 
-	if fromUserUuid == "" {
-		gc.JSON(http.StatusUnauthorized, gin.H{"error": "invalid fromUserUuid"})
-		return
-	}
-
-	type MessageRequest struct {
+	var payload struct {
 		Context     string `json:"context" binding:"required"`
-		ContextUuid int    `json:"context_uuid" binding:"required"`
+		ContextUuid string `json:"context_uuid" binding:"required"`
 		Subject     string `json:"subject" binding:"required"`
 		Message     string `json:"message" binding:"required"`
 	}
-
-	var req MessageRequest
-	if err := gc.ShouldBindJSON(&req); err != nil {
-		gc.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
 		return
 	}
-
-	tx, err := h.DbPool.Begin(ctx)
-	if err != nil {
-		gc.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+	if payload.Context != "organization" && payload.Context != "user" {
+		apiRequest.Error(http.StatusBadRequest, "context must be organization or user")
 		return
 	}
-	defer func() { _ = tx.Rollback(ctx) }()
-
-	if req.Context == "organization" {
-		orgUuid := req.ContextUuid
-		query := strings.Replace(
-			`SELECT u.uuid, u.display_name
-			FROM {{schema}}.user_organization_link ol
-			JOIN {{schema}}.user u ON u.uuid = ol.user_uuid
-			WHERE ol.org_id = $1 AND (ol.permissions & $2) != 0`,
-			"{{schema}}", h.DbSchema, -1)
-
-		rows, err := tx.Query(ctx, query, orgUuid, app.UserPermReceiveOrgMsgs)
-		if err != nil {
-			gc.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("database query failed: %v", err)})
-			return
-		}
-		defer rows.Close()
+	apiRequest.SetMeta("context", payload.Context)
+	apiRequest.SetMeta("context_uuid", payload.ContextUuid)
+
+	n := notification{
+		Type:       notificationMessage,
+		EntityType: notificationEntityUser,
+		EntityUuid: fromUserUuid,
+		Title:      strings.TrimSpace(payload.Subject),
+		Body:       strings.TrimSpace(payload.Message),
+		Data:       map[string]any{"from_user_uuid": fromUserUuid},
+		CreatedBy:  fromUserUuid,
+	}
 
-		var userIds []int
-		for rows.Next() {
-			var id int
-			var displayName *string
-			if err := rows.Scan(&id, &displayName); err != nil {
-				gc.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to scan row: %v", err)})
-				return
+	notifiedCount := 0
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var err error
+		if payload.Context == "organization" {
+			var exists bool
+			query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s.organization WHERE uuid = $1::uuid)`, h.DbSchema)
+			if err := tx.QueryRow(ctx, query, payload.ContextUuid).Scan(&exists); err != nil {
+				return TxInternalError(err)
 			}
-			fmt.Println(id, displayName)
-			userIds = append(userIds, id)
-
-			// TODO: Duplikate verhindern, DISTINCT!
+			if !exists {
+				return ApiErrNotFound("organization not found")
+			}
+			n.OrgUuid = payload.ContextUuid
+			notifiedCount, err = h.notifyOrgMembersTx(ctx, tx, n, payload.ContextUuid, app.UserPermReceiveOrgMsgs)
+		} else {
+			notifiedCount, err = h.notifyUsersTx(ctx, tx, n, []string{payload.ContextUuid})
 		}
-
-		if err := rows.Err(); err != nil {
-			gc.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("rows iteration error: %v", err)})
-			return
+		if err != nil {
+			return TxInternalError(err)
 		}
-
-		fmt.Println(fromUserUuid)
-
-		for _, toUserId := range userIds {
-			insertQuery := fmt.Sprintf(
-				`INSERT INTO %s.message (to_user_id, from_user_id, subject, message)
-             VALUES ($1, $2, $3, $4)`,
-				h.DbSchema,
-			)
-			_, err := tx.Exec(ctx, insertQuery, toUserId, fromUserUuid, req.Subject, req.Message)
-			if err != nil {
-				gc.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to insert message: %v", err)})
-				return
-			}
+		if notifiedCount == 0 {
+			return ApiErrNotFound("no recipients found")
 		}
-	} else if req.Context == "user" {
-		// TODO: Implement!
-	} else {
-		gc.JSON(http.StatusBadRequest, gin.H{"error": "Unknwon context"})
-		return
-	}
-
-	if err = tx.Commit(gc); err != nil {
-		gc.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to commit transaction: %v", err)})
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
 		return
 	}
 
-	gc.JSON(http.StatusOK, gin.H{
-		"message": "Message sent successfully",
-	})
+	apiRequest.SetMeta("notified_count", notifiedCount)
+	apiRequest.SuccessNoData(http.StatusOK, "message sent successfully")
 }
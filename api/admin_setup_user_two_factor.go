@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+)
+
+// AdminSetupUserTwoFactor starts the enrollment of an authenticator app. It
+// returns a new secret and its otpauth URI, which becomes active once a code
+// has been confirmed with AdminEnableUserTwoFactor. Starting over replaces a
+// pending secret, an enabled one must be disabled first.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminSetupUserTwoFactor(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-setup-user-two-factor")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	secret, err := app.GenerateTotpSecret()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	var email string
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`SELECT email FROM %s.user WHERE uuid = $1::uuid`, h.DbSchema)
+		err := tx.QueryRow(ctx, query, userUuid).Scan(&email)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query = fmt.Sprintf(`
+			INSERT INTO %s.user_totp (user_uuid, secret)
+			VALUES ($1::uuid, $2)
+			ON CONFLICT (user_uuid) DO UPDATE
+			SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+			WHERE user_totp.enabled_at IS NULL`,
+			h.DbSchema)
+		res, err := tx.Exec(ctx, query, userUuid, secret)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if res.RowsAffected() == 0 {
+			return NewApiTxError(http.StatusConflict, "two-factor authentication is already enabled")
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": app.TotpUri(h.Config.TotpIssuer, email, secret),
+	}, "confirm a code to enable two-factor authentication")
+}
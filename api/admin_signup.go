@@ -1,12 +1,10 @@
 package api
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -26,9 +24,10 @@ func (h *ApiHandler) Signup(gc *gin.Context) {
 	lang := gc.DefaultQuery("lang", "en")
 
 	var payload struct {
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required"`
-		Referer  string `json:"referer" binding:"required"`
+		Email       string `json:"email" binding:"required,email"`
+		Password    string `json:"password" binding:"required"`
+		Referer     string `json:"referer" binding:"required"`
+		AccountType string `json:"account_type"` // member (default) or visitor
 	}
 
 	if err := gc.ShouldBindJSON(&payload); err != nil {
@@ -52,6 +51,14 @@ func (h *ApiHandler) Signup(gc *gin.Context) {
 		return
 	}
 
+	if payload.AccountType == "" {
+		payload.AccountType = app.AccountTypeMember
+	}
+	if payload.AccountType != app.AccountTypeMember && payload.AccountType != app.AccountTypeVisitor {
+		apiRequest.Error(http.StatusBadRequest, "(#4) account_type must be member or visitor")
+		return
+	}
+
 	passwordHash, err := app.EncryptPassword(payload.Password)
 	if err != nil {
 		debugf(err.Error())
@@ -76,8 +83,10 @@ func (h *ApiHandler) Signup(gc *gin.Context) {
 			return TxInternalError(nil)
 		}
 
-		insertQuery := fmt.Sprintf(`INSERT INTO %s.user (uuid, email, password_hash) VALUES ($1::uuid, $2, $3)`, h.DbSchema)
-		_, err = tx.Exec(ctx, insertQuery, userUuid, payload.Email, passwordHash)
+		insertQuery := fmt.Sprintf(
+			`INSERT INTO %s.user (uuid, email, password_hash, account_type) VALUES ($1::uuid, $2, $3, $4)`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, insertQuery, userUuid, payload.Email, passwordHash, payload.AccountType)
 		if err != nil {
 			return TxInternalError(nil)
 		}
@@ -103,26 +112,21 @@ func (h *ApiHandler) Signup(gc *gin.Context) {
 			return TxInternalError(nil)
 		}
 
-		messageQuery := fmt.Sprintf(`SELECT subject, template FROM %s.system_email_template WHERE context = 'user-email-verification' AND iso_639_1 = $1`, h.DbSchema)
-		var subject string
-		var template string
-		err = tx.QueryRow(ctx, messageQuery, lang).Scan(&subject, &template)
-		if err != nil {
-			return TxInternalError(nil)
-		}
-
-		expiryHour = 1
+		// Queued with the user, so no mail is sent if the signup fails
 		signupUrl := payload.Referer + "/app/activate/account?token=" + signupTokenString
-
-		emailMessage := strings.Replace(template, "{{link}}", signupUrl, -1)
-		emailMessage = strings.Replace(emailMessage, "{{expiry_hours}}", strconv.Itoa(expiryHour), -1)
-
-		err = sendEmailWithTimeout(payload.Email, subject, emailMessage, 20*time.Second)
+		_, err = h.enqueueTemplateEmail(
+			ctx, tx, payload.Email, "user-email-verification", lang,
+			map[string]string{
+				"link":         signupUrl,
+				"expiry_hours": strconv.Itoa(expiryHour),
+			},
+			userUuid, "")
 		if err != nil {
-			return TxInternalError(nil)
+			return TxInternalError(err)
 		}
 
 		apiRequest.SetMeta("user_uuid", userUuid)
+		apiRequest.SetMeta("account_type", payload.AccountType)
 		return nil
 	})
 
@@ -135,20 +139,6 @@ func (h *ApiHandler) Signup(gc *gin.Context) {
 	apiRequest.SuccessNoData(http.StatusCreated, "user registered successfully")
 }
 
-func sendEmailWithContext(ctx context.Context, to, subject, body string) error {
-	done := make(chan error, 1)
-	go func() {
-		done <- sendEmail(to, subject, body) // your existing sendEmail
-	}()
-
-	select {
-	case err := <-done:
-		return err
-	case <-ctx.Done():
-		return ctx.Err()
-	}
-}
-
 func (h *ApiHandler) Activate(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "signup")
 
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+)
+
+const liveEventHeartbeat = 25 * time.Second
+
+// AdminStreamUserEvents streams live events of the user as Server-Sent
+// Events: "notification" for new notifications, including messages and
+// partner requests, and "event-modified" when another editor changed an
+// event of an organization of the user. The id of each event can be passed
+// as Last-Event-ID header or last_event_id parameter on reconnect to get the
+// events missed in between. A comment line is sent as heartbeat.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminStreamUserEvents(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-stream-user-events")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	if _, ok := requestApiKey(gc); ok {
+		apiRequest.Error(http.StatusForbidden, "not available for API keys")
+		return
+	}
+
+	lastEventIdStr := gc.GetHeader("Last-Event-ID")
+	if lastEventIdStr == "" {
+		lastEventIdStr = gc.Query("last_event_id")
+	}
+	var lastEventId int64
+	if lastEventIdStr != "" {
+		id, err := strconv.ParseInt(lastEventIdStr, 10, 64)
+		if err != nil || id < 0 {
+			apiRequest.Error(http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		lastEventId = id
+	}
+
+	query := fmt.Sprintf(
+		`SELECT DISTINCT org_uuid::text FROM %s.user_organization_link WHERE user_uuid = $1::uuid`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, userUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	var orgUuids []string
+	for rows.Next() {
+		var orgUuid string
+		if err := rows.Scan(&orgUuid); err != nil {
+			rows.Close()
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		orgUuids = append(orgUuids, orgUuid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	// Subscribe before loading missed events, so none gets lost in between
+	subscriber := h.LiveEvents.Subscribe(userUuid, orgUuids)
+	defer h.LiveEvents.Unsubscribe(subscriber)
+
+	var missed []liveEvent
+	if lastEventId > 0 {
+		missed, err = h.LiveEvents.loadLiveEvents(ctx, lastEventId, userUuid, orgUuids)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.DatabaseError()
+			return
+		}
+	}
+
+	gc.Header("Content-Type", "text/event-stream")
+	gc.Header("Cache-Control", "no-cache")
+	gc.Header("Connection", "keep-alive")
+	gc.Header("X-Accel-Buffering", "no")
+	gc.Status(http.StatusOK)
+
+	w := gc.Writer
+	send := func(e liveEvent) bool {
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, e.Payload)
+		w.Flush()
+		return err == nil
+	}
+
+	fmt.Fprintf(w, "retry: %d\n\n", (5 * time.Second).Milliseconds())
+	w.Flush()
+
+	sent := map[int64]bool{}
+	for _, e := range missed {
+		if !subscriber.wants(e) {
+			continue
+		}
+		if !send(e) {
+			return
+		}
+		sent[e.Id] = true
+	}
+
+	heartbeat := time.NewTicker(liveEventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-subscriber.events:
+			if !ok {
+				// Too slow, the client reconnects with Last-Event-ID
+				return
+			}
+			if sent[e.Id] {
+				continue
+			}
+			if !send(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		}
+	}
+}
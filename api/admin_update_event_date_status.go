@@ -0,0 +1,274 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminUpdateEventDateStatus cancels, postpones (status "deferred") or
+// reschedules a single event date, or reinstates it (status "inherited").
+// A rescheduled date links to its new date, given either as
+// rescheduled_to_uuid of an existing date of the event or as new_date, which
+// is created. Users who saved the date in a favorite list are notified,
+// unless notify is false.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent and UserPermReleaseEvent, enforced by
+// RequireOrgPermissions middleware. UserPermChooseVenue for the venue of a
+// new date.
+func (h *ApiHandler) AdminUpdateEventDateStatus(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-event-date-status")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	eventDateUuid := gc.Param("dateUuid")
+	if eventDateUuid == "" {
+		apiRequest.Required("dateUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	type Payload struct {
+		Status            string                  `json:"status" binding:"required"`
+		Reason            *string                 `json:"reason"`
+		RescheduledToUuid *string                 `json:"rescheduled_to_uuid"`
+		NewDate           *model.EventDatePayload `json:"new_date"`
+		Notify            *bool                   `json:"notify"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	if _, ok := eventDateStatusTransitions[payload.Status]; !ok {
+		apiRequest.Error(http.StatusBadRequest, "status must be inherited, cancelled, deferred or rescheduled")
+		return
+	}
+
+	if payload.Reason != nil {
+		reason := strings.TrimSpace(*payload.Reason)
+		payload.Reason = &reason
+		if reason == "" {
+			payload.Reason = nil
+		}
+	}
+
+	if payload.Status == "rescheduled" {
+		if (payload.RescheduledToUuid == nil) == (payload.NewDate == nil) {
+			apiRequest.Error(http.StatusBadRequest, "rescheduled requires either rescheduled_to_uuid or new_date")
+			return
+		}
+	} else if payload.RescheduledToUuid != nil || payload.NewDate != nil {
+		apiRequest.Error(http.StatusBadRequest, "rescheduled_to_uuid and new_date are only allowed with status rescheduled")
+		return
+	}
+
+	var rescheduledToUuid *string
+	notifiedCount := 0
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`
+			SELECT release_status, venue_uuid, space_uuid, start_date,
+				start_date < CURRENT_DATE, recurrence_generated
+			FROM %s.event_date
+			WHERE uuid = $1::uuid AND event_uuid = $2::uuid
+			FOR UPDATE`,
+			h.DbSchema)
+		var releaseStatus, venueUuid, spaceUuid *string
+		var startDate time.Time
+		var isPast, recurrenceGenerated bool
+		err = tx.QueryRow(ctx, query, eventDateUuid, eventUuid).Scan(
+			&releaseStatus, &venueUuid, &spaceUuid, &startDate, &isPast, &recurrenceGenerated)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("event date not found")
+			}
+			return TxInternalError(err)
+		}
+
+		if isPast {
+			return NewApiTxError(http.StatusConflict, "status of past event dates can not be changed")
+		}
+
+		from := eventDateStatusState(releaseStatus)
+		if !canChangeEventDateStatus(from, payload.Status) {
+			return NewApiTxError(http.StatusConflict, "status change from %s to %s is not allowed", from, payload.Status)
+		}
+
+		if payload.RescheduledToUuid != nil {
+			if *payload.RescheduledToUuid == eventDateUuid {
+				return NewApiTxError(http.StatusBadRequest, "an event date can not be rescheduled to itself")
+			}
+
+			query = fmt.Sprintf(`
+				SELECT release_status
+				FROM %s.event_date
+				WHERE uuid = $1::uuid AND event_uuid = $2::uuid`,
+				h.DbSchema)
+			var targetStatus *string
+			err = tx.QueryRow(ctx, query, *payload.RescheduledToUuid, eventUuid).Scan(&targetStatus)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return ApiErrNotFound("rescheduled_to_uuid is not a date of this event")
+				}
+				return TxInternalError(err)
+			}
+			if state := eventDateStatusState(targetStatus); state == "cancelled" || state == "rescheduled" {
+				return NewApiTxError(http.StatusConflict, "event date can not be rescheduled to a %s date", state)
+			}
+			rescheduledToUuid = payload.RescheduledToUuid
+		}
+
+		if payload.NewDate != nil {
+			d := payload.NewDate
+
+			// The new date takes place at the same venue unless given
+			if d.VenueUuid == nil {
+				d.VenueUuid = venueUuid
+				if d.SpaceUuid == nil {
+					d.SpaceUuid = spaceUuid
+				}
+			}
+
+			if d.VenueUuid != nil && (venueUuid == nil || *d.VenueUuid != *venueUuid) {
+				perms, err := h.GetUserEffectiveVenuePermissionsTx(gc, tx, userUuid, *d.VenueUuid)
+				if err != nil {
+					return TxInternalError(err)
+				}
+				if !perms.Has(app.UserPermChooseVenue) {
+					return ApiErrForbidden("venue may not be chosen")
+				}
+			}
+
+			if d.SpaceUuid != nil {
+				if d.VenueUuid == nil {
+					return NewApiTxError(http.StatusBadRequest, "new_date.space_uuid requires a venue")
+				}
+				spaceOk, err := h.IsSpaceInVenueTx(gc, tx, *d.SpaceUuid, *d.VenueUuid)
+				if err != nil {
+					return TxInternalError(err)
+				}
+				if !spaceOk {
+					return NewApiTxError(http.StatusBadRequest, "new_date.space_uuid does not belong to the venue")
+				}
+			}
+
+			newDateUuid, err := grains_uuid.Uuidv7String()
+			if err != nil {
+				return TxInternalError(err)
+			}
+			_, err = tx.Exec(ctx, app.UranusInstance.SqlAdminInsertEventDate,
+				newDateUuid,
+				eventUuid,
+				"inherited",
+				d.VenueUuid,
+				d.SpaceUuid,
+				d.StartDate,
+				d.StartTime,
+				d.EndDate,
+				d.EndTime,
+				d.EntryTime,
+				d.Duration,
+				d.AllDay,
+				userUuid,
+			)
+			if err != nil {
+				return &ApiTxError{
+					Code: http.StatusInternalServerError,
+					Err:  fmt.Errorf("failed to insert event date: %v", err),
+				}
+			}
+			rescheduledToUuid = &newDateUuid
+		}
+
+		// A generated date with own status is detached from its recurrence,
+		// so that the recurrence worker neither changes nor removes it
+		query = fmt.Sprintf(`
+			UPDATE %s.event_date
+			SET release_status = $3,
+				status_reason = $4,
+				rescheduled_to_uuid = $5::uuid,
+				status_changed_at = NOW(),
+				status_changed_by = NULLIF($6, '')::uuid,
+				modified_by = NULLIF($6, '')::uuid,
+				recurrence_generated = false
+			WHERE uuid = $1::uuid AND event_uuid = $2::uuid`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, query, eventDateUuid, eventUuid, payload.Status, payload.Reason, rescheduledToUuid, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		if recurrenceGenerated {
+			query = fmt.Sprintf(`
+				UPDATE %s.event_recurrence
+				SET exdates = array_append(exdates, $2::date), modified_at = NOW()
+				WHERE event_uuid = $1::uuid AND NOT ($2::date = ANY(exdates))`,
+				h.DbSchema)
+			_, err = tx.Exec(ctx, query, eventUuid, startDate)
+			if err != nil {
+				return TxInternalError(err)
+			}
+		}
+
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "date-status", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		dateUuids := []string{eventDateUuid}
+		if rescheduledToUuid != nil {
+			dateUuids = append(dateUuids, *rescheduledToUuid)
+		}
+		err = RefreshEventProjections(ctx, tx, "event_date", dateUuids)
+		if err != nil {
+			return ApiErrInternal("refresh projection tables failed: %v", err)
+		}
+
+		if payload.Notify == nil || *payload.Notify {
+			notifiedCount, err = h.notifyFavoriteEventDateTx(
+				ctx, tx, userUuid, eventUuid, eventDateUuid, payload.Status, payload.Reason, rescheduledToUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("notified_count", notifiedCount)
+	apiRequest.Success(http.StatusOK, gin.H{
+		"event_date_uuid":     eventDateUuid,
+		"status":              payload.Status,
+		"status_reason":       payload.Reason,
+		"rescheduled_to_uuid": rescheduledToUuid,
+	}, "event date status updated successfully")
+}
@@ -67,6 +67,11 @@ func (h *ApiHandler) AdminUpdateEventDates(gc *gin.Context) {
 	}
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		uuidsInPayload := []string{}
 		for _, d := range payload {
 			if d.DateUuid != nil {
@@ -74,10 +79,11 @@ func (h *ApiHandler) AdminUpdateEventDates(gc *gin.Context) {
 			}
 		}
 
+		// Dates generated from a recurrence rule are managed via the rule
 		query := fmt.Sprintf(
-			`DELETE FROM %s.event_date WHERE event_uuid = $1::uuid AND NOT (uuid = ANY($2::uuid[]))`,
+			`DELETE FROM %s.event_date WHERE event_uuid = $1::uuid AND NOT (uuid = ANY($2::uuid[])) AND NOT recurrence_generated`,
 			h.DbSchema)
-		_, err := tx.Exec(ctx, query, eventUuid, uuidsInPayload)
+		_, err = tx.Exec(ctx, query, eventUuid, uuidsInPayload)
 		if err != nil {
 			return &ApiTxError{
 				Code: http.StatusInternalServerError,
@@ -143,6 +149,11 @@ func (h *ApiHandler) AdminUpdateEventDates(gc *gin.Context) {
 		}
 
 		// Refresh projections
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "dates", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
@@ -151,6 +162,11 @@ func (h *ApiHandler) AdminUpdateEventDates(gc *gin.Context) {
 			}
 		}
 
+		// The capacity of a date depends on its space
+		if err := h.updateEventAvailabilityTx(ctx, tx, eventUuid); err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
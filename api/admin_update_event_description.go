@@ -15,6 +15,7 @@ type eventDescriptionRequest struct {
 
 func (h *ApiHandler) AdminUpdateEventDescription(gc *gin.Context) {
 	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
 
 	eventUuid := gc.Param("eventUuid")
 	if eventUuid == "" {
@@ -29,9 +30,14 @@ func (h *ApiHandler) AdminUpdateEventDescription(gc *gin.Context) {
 	}
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
-		query := fmt.Sprintf(`UPDATE %s.event SET description = $2 WHERE id = $1`, h.DbSchema)
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`UPDATE %s.event SET description = $2 WHERE uuid = $1::uuid`, h.DbSchema)
 
-		cmdTag, err := h.DbPool.Exec(ctx, query, eventUuid, req.Description)
+		cmdTag, err := tx.Exec(ctx, query, eventUuid, req.Description)
 		if err != nil {
 			return &ApiTxError{
 				Code: http.StatusInternalServerError,
@@ -47,6 +53,11 @@ func (h *ApiHandler) AdminUpdateEventDescription(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "description", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
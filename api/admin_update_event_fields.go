@@ -126,6 +126,11 @@ func (h *ApiHandler) AdminUpdateEventFields(gc *gin.Context) {
 			}
 		}
 
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		res, err := tx.Exec(ctx, query, args...)
 		if err != nil {
 			return &ApiTxError{
@@ -141,6 +146,11 @@ func (h *ApiHandler) AdminUpdateEventFields(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "fields", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
@@ -17,6 +17,7 @@ type eventReq struct {
 
 func (h *ApiHandler) AdminUpdateEventHeader(gc *gin.Context) {
 	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
 
 	eventUuid := gc.Param("eventUuid")
 	if eventUuid == "" {
@@ -35,14 +36,19 @@ func (h *ApiHandler) AdminUpdateEventHeader(gc *gin.Context) {
 	var args []interface{}
 
 	if req.Subtitle != nil {
-		query = fmt.Sprintf(`UPDATE %s.event SET title = $2, subtitle = $3 WHERE id = $1`, h.DbSchema)
+		query = fmt.Sprintf(`UPDATE %s.event SET title = $2, subtitle = $3 WHERE uuid = $1::uuid`, h.DbSchema)
 		args = []interface{}{eventUuid, req.Title, req.Subtitle}
 	} else {
-		query = fmt.Sprintf(`UPDATE %s.event SET title = $2 WHERE id = $1`, h.DbSchema)
+		query = fmt.Sprintf(`UPDATE %s.event SET title = $2 WHERE uuid = $1::uuid`, h.DbSchema)
 		args = []interface{}{eventUuid, req.Title}
 	}
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		res, err := tx.Exec(ctx, query, args...)
 		if err != nil {
 			return &ApiTxError{
@@ -58,6 +64,11 @@ func (h *ApiHandler) AdminUpdateEventHeader(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "header", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
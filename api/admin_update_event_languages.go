@@ -13,6 +13,7 @@ import (
 func (h *ApiHandler) AdminUpdateEventLanguages(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-update-event-languages")
 	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
 
 	eventUuid := gc.Param("eventUuid")
 	if eventUuid == "" {
@@ -31,6 +32,11 @@ func (h *ApiHandler) AdminUpdateEventLanguages(gc *gin.Context) {
 	}
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		query := fmt.Sprintf(`UPDATE %s.event SET languages = $2 WHERE uuid = $1::uuid`, h.DbSchema)
 
 		res, err := tx.Exec(ctx, query, eventUuid, req.Languages)
@@ -49,6 +55,11 @@ func (h *ApiHandler) AdminUpdateEventLanguages(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "languages", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
@@ -38,7 +38,7 @@ func (h *ApiHandler) AdminUpdateEventLinks(gc *gin.Context) {
 	}
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
-		permissions, err := h.GetUserEventOrgPermissionsTx(ctx, tx, userUuid, eventUuid)
+		permissions, err := h.GetUserEventOrgPermissionsTx(gc, tx, userUuid, eventUuid)
 		if err != nil {
 			return TxInternalError(nil)
 		}
@@ -49,6 +49,11 @@ func (h *ApiHandler) AdminUpdateEventLinks(gc *gin.Context) {
 			}
 		}
 
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		// Update source link
 		query := fmt.Sprintf(`UPDATE %s.event SET source_link = $1 WHERE uuid = $2::uuid`, h.DbSchema)
 		_, err = tx.Exec(ctx, query, payload.SourceLink, eventUuid)
@@ -72,6 +77,11 @@ func (h *ApiHandler) AdminUpdateEventLinks(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "links", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return TxInternalError(nil)
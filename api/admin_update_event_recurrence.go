@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditEvent, enforced by RequireOrgPermissions middleware.
+// Venue usage is checked with UserPermChooseVenue.
+
+// AdminUpdateEventRecurrence creates or replaces the recurrence rule of an
+// event and regenerates its future dates. Past dates are kept unchanged.
+func (h *ApiHandler) AdminUpdateEventRecurrence(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-event-recurrence")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventUuid := gc.Param("eventUuid")
+	if eventUuid == "" {
+		apiRequest.Required("eventUuid is required")
+		return
+	}
+	apiRequest.SetMeta("event_uuid", eventUuid)
+
+	var payload model.EventRecurrencePayload
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		txErr := h.saveEventRecurrenceTx(gc, tx, eventUuid, userUuid, payload)
+		if txErr != nil {
+			return txErr
+		}
+
+		_, err = h.materializeEventRecurrenceTx(ctx, tx, eventUuid, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "recurrence", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "event recurrence updated successfully")
+}
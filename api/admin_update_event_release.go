@@ -4,13 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/app"
 )
 
 func (h *ApiHandler) AdminUpdateEventReleaseStatus(gc *gin.Context) {
 	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
 
 	eventUuid := gc.Param("eventUuid")
 	if eventUuid == "" {
@@ -29,8 +32,41 @@ func (h *ApiHandler) AdminUpdateEventReleaseStatus(gc *gin.Context) {
 		return
 	}
 
+	if _, err := IsEventReleaseStatus("release_status", &req.ReleaseStatus); err != nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := app.ValidateOptionalDate("release_date", req.ReleaseDate); err != nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ReleaseDate != nil && strings.TrimSpace(*req.ReleaseDate) == "" {
+		req.ReleaseDate = nil
+	}
+
+	// Releasing with a future release date schedules the event, the release
+	// schedule worker releases it at that date
+	req.ReleaseStatus = releaseStatusForDate(req.ReleaseStatus, req.ReleaseDate)
+	if req.ReleaseStatus == "scheduled" && req.ReleaseDate == nil {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": "release_date is required for scheduled events"})
+		return
+	}
+
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
-		query := fmt.Sprintf(`UPDATE %s.event SET release_status = $2, release_date = $3 WHERE id = $1`, h.DbSchema)
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s.event
+			SET release_status = $2,
+				release_date = $3,
+				released_at = CASE WHEN $2 = 'released' THEN COALESCE(released_at, NOW()) ELSE released_at END,
+				archived_at = CASE WHEN $2 = 'archived' THEN COALESCE(archived_at, NOW()) ELSE NULL END
+			WHERE uuid = $1::uuid`,
+			h.DbSchema)
 		res, err := tx.Exec(ctx, query, eventUuid, req.ReleaseStatus, req.ReleaseDate)
 		if err != nil {
 			return &ApiTxError{
@@ -47,6 +83,23 @@ func (h *ApiHandler) AdminUpdateEventReleaseStatus(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "release", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		orgUuid, err := h.GetOrgUuidByEventUuidTx(gc, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(
+			gc, tx, orgUuid, auditEntityEvent, eventUuid, "release",
+			map[string]any{"release_status": before["release_status"], "release_date": before["release_date"]},
+			map[string]any{"release_status": req.ReleaseStatus, "release_date": req.ReleaseDate})
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
@@ -64,7 +117,8 @@ func (h *ApiHandler) AdminUpdateEventReleaseStatus(gc *gin.Context) {
 	}
 
 	gc.JSON(http.StatusOK, gin.H{
-		"message":    "event release status updated successfully",
-		"event_uuid": eventUuid,
+		"message":        "event release status updated successfully",
+		"event_uuid":     eventUuid,
+		"release_status": req.ReleaseStatus,
 	})
 }
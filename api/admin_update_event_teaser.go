@@ -15,6 +15,7 @@ type summaryRequest struct {
 
 func (h *ApiHandler) AdminUpdateEventSummary(gc *gin.Context) {
 	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
 
 	eventUuid := gc.Param("eventUuid")
 	if eventUuid == "" {
@@ -29,7 +30,12 @@ func (h *ApiHandler) AdminUpdateEventSummary(gc *gin.Context) {
 	}
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
-		query := fmt.Sprintf(`UPDATE %s.event SET summary = $2 WHERE id = $1`, h.DbSchema)
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`UPDATE %s.event SET summary = $2 WHERE uuid = $1::uuid`, h.DbSchema)
 
 		res, err := tx.Exec(ctx, query, eventUuid, req.Summary)
 		if err != nil {
@@ -47,6 +53,11 @@ func (h *ApiHandler) AdminUpdateEventSummary(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "summary", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
@@ -12,6 +12,7 @@ import (
 func (h *ApiHandler) AdminUpdateEventTypes(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-update-event-types")
 	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
 
 	eventUuid := gc.Param("eventUuid")
 	if eventUuid == "" {
@@ -34,11 +35,16 @@ func (h *ApiHandler) AdminUpdateEventTypes(gc *gin.Context) {
 	}
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		// Delete existing type-genre links
 		deleteQuery := fmt.Sprintf(`DELETE FROM %s.event_type_link WHERE event_uuid = $1::uuid`, h.DbSchema)
 		debugf(deleteQuery)
 		debugf(eventUuid)
-		_, err := tx.Exec(ctx, deleteQuery, eventUuid)
+		_, err = tx.Exec(ctx, deleteQuery, eventUuid)
 		if err != nil {
 			debugf(err.Error())
 			return &ApiTxError{
@@ -64,6 +70,11 @@ func (h *ApiHandler) AdminUpdateEventTypes(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "types", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
@@ -14,6 +14,7 @@ import (
 func (h *ApiHandler) AdminUpdateEventVenue(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-update-event-venue")
 	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
 
 	eventUuid := gc.Param("eventUuid")
 	if eventUuid == "" {
@@ -96,6 +97,11 @@ func (h *ApiHandler) AdminUpdateEventVenue(gc *gin.Context) {
 		query += fmt.Sprintf(" WHERE uuid = $%d::uuid", len(args)+1)
 		args = append(args, eventUuid)
 
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		res, err := tx.Exec(ctx, query, args...)
 		if err != nil {
 			return &ApiTxError{
@@ -111,6 +117,11 @@ func (h *ApiHandler) AdminUpdateEventVenue(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "venue", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		if err := RefreshEventProjections(ctx, tx, "event", []string{eventUuid}); err != nil {
 			return &ApiTxError{
 				Code: http.StatusInternalServerError,
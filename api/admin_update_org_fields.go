@@ -85,6 +85,11 @@ func (h *ApiHandler) UpdateOrgFields(gc *gin.Context) {
 	args = append(args, orgUuid) // eventId is the last parameter
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "organization", orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		res, err := tx.Exec(ctx, query, args...)
 		if err != nil {
 			return &ApiTxError{
@@ -108,6 +113,15 @@ func (h *ApiHandler) UpdateOrgFields(gc *gin.Context) {
 			}
 		}
 
+		after, err := h.loadAuditStateTx(ctx, tx, "organization", orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityOrg, orgUuid, "update", before, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
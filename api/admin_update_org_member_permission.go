@@ -51,7 +51,9 @@ import (
 //   - Validates input parameters and JSON payload.
 //   - Starts a database transaction.
 //   - Verifies the caller’s organization permissions.
-//   - Sets or clears the specified permission bit using a bitwise operation.
+//   - Sets or clears the specified bit in the member's extra permissions,
+//     bits granted by the member's role cannot be cleared (see
+//     AdminUpdateOrgMemberRole).
 //   - Commits the transaction on success.
 //
 // Responses:
@@ -62,6 +64,7 @@ import (
 //   - 400 Bad Request: Missing or invalid parameters or payload.
 //   - 403 Forbidden: Caller lacks sufficient permissions.
 //   - 404 Not Found: Target member does not exist in the organization.
+//   - 409 Conflict: The bit is granted by the member's role.
 //   - 500 Internal Server Error: Database or transaction failure.
 func (h *ApiHandler) AdminUpdateOrgMemberPermissions(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-update-org-member-permissions")
@@ -146,30 +149,45 @@ func (h *ApiHandler) AdminUpdateOrgMemberPermissions(gc *gin.Context) {
 			}
 		}
 
-		// Perform the bitwise update
-		bitUpdateQuery := fmt.Sprintf(`
-			UPDATE %s.user_organization_link
-			SET permissions = CASE
-				WHEN $1 THEN permissions | (1::bigint << $2)
-				ELSE permissions & ~(1::bigint << $2)
-			END
-			WHERE user_uuid = $3::uuid AND org_uuid = $4::uuid
-			RETURNING permissions`,
-			h.DbSchema)
-
-		err = tx.QueryRow(
-			ctx, bitUpdateQuery, inputReq.Enabled, inputReq.Bit, memberUserUuid, orgUuid).
-			Scan(&updatedPermissions)
+		previous, ok, err := h.loadMemberPermissionsTx(ctx, tx, orgUuid, memberUserUuid)
 		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return &ApiTxError{
-					Code: http.StatusNotFound,
-					Err:  fmt.Errorf("Target member does not exist in the organization"),
-				}
-			}
+			return TxInternalError(err)
+		}
+		if !ok {
 			return &ApiTxError{
-				Code: http.StatusInternalServerError,
-				Err:  fmt.Errorf("Transaction failed: %s", err.Error()),
+				Code: http.StatusNotFound,
+				Err:  fmt.Errorf("Target member does not exist in the organization"),
+			}
+		}
+		previousPermissions := previous.Permissions
+
+		// The bit is toggled in the extra permissions, bits of the member's
+		// role can only be removed by changing the role
+		bit := int64(1) << inputReq.Bit
+		if !inputReq.Enabled && previous.RolePermissions&bit != 0 {
+			return NewApiTxError(http.StatusConflict, "permission is granted by the member's role")
+		}
+		extraPermissions := previous.ExtraPermissions | bit
+		if !inputReq.Enabled {
+			extraPermissions = previous.ExtraPermissions &^ bit
+		}
+
+		roleUuid := ""
+		if previous.RoleUuid != nil {
+			roleUuid = *previous.RoleUuid
+		}
+		updatedPermissions, err = h.setMemberPermissionsTx(ctx, tx, orgUuid, memberUserUuid, roleUuid, extraPermissions)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		if updatedPermissions != previousPermissions {
+			err = h.writeAuditLogTx(
+				gc, tx, orgUuid, auditEntityMember, memberUserUuid, "update-permissions",
+				map[string]any{"permissions": previousPermissions},
+				map[string]any{"permissions": updatedPermissions})
+			if err != nil {
+				return TxInternalError(err)
 			}
 		}
 
@@ -178,6 +196,10 @@ func (h *ApiHandler) AdminUpdateOrgMemberPermissions(gc *gin.Context) {
 
 	if txErr != nil {
 		debugf(txErr.Error())
+		if txErr.Code == http.StatusConflict {
+			apiRequest.Error(txErr.Code, txErr.Error())
+			return
+		}
 		apiRequest.InternalServerError()
 		return
 	}
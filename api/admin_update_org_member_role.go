@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+)
+
+// AdminUpdateOrgMemberRole assigns a role and extra permission bits to a
+// member of an organization. The member's permissions become the role's
+// permissions combined with the extra bits. Without role_uuid the member
+// holds the extra bits only.
+//
+// Request Body (JSON):
+//
+//	{
+//	  "role_uuid": "<uuid>",     // optional, role of the organization
+//	  "extra_permissions": <int> // optional, bits in addition to the role
+//	}
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminUpdateOrgMemberRole(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-org-member-role")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	memberUuid := gc.Param("memberUuid")
+	if memberUuid == "" {
+		apiRequest.Required("memberUuid is required")
+		return
+	}
+	apiRequest.SetMeta("member_uuid", memberUuid)
+
+	type Payload struct {
+		RoleUuid         string `json:"role_uuid"`
+		ExtraPermissions int64  `json:"extra_permissions"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	orgPermissions, _ := gc.MustGet("org-permissions").(app.Permissions)
+
+	var permissions int64
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		previous, ok, err := h.loadMemberPermissionsTx(ctx, tx, orgUuid, memberUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return ApiErrNotFound("member not found")
+		}
+
+		var rolePermissions int64
+		if payload.RoleUuid != "" {
+			rolePermissions, ok, err = h.orgMemberRolePermissionsTx(ctx, tx, orgUuid, payload.RoleUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if !ok {
+				return NewApiTxError(http.StatusBadRequest, "unknown role")
+			}
+		}
+
+		txErr := checkPermissionChange(
+			userUuid, orgPermissions, memberUuid,
+			previous.Permissions, rolePermissions|payload.ExtraPermissions)
+		if txErr != nil {
+			return txErr
+		}
+
+		before, err := h.loadAuditMemberStateTx(ctx, tx, orgUuid, memberUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		permissions, err = h.setMemberPermissionsTx(ctx, tx, orgUuid, memberUuid, payload.RoleUuid, payload.ExtraPermissions)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		after, err := h.loadAuditMemberStateTx(ctx, tx, orgUuid, memberUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityMember, memberUuid, "update-role", before, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"role_uuid":         payload.RoleUuid,
+		"extra_permissions": payload.ExtraPermissions,
+		"permissions":       permissions,
+	}, "member role updated successfully")
+}
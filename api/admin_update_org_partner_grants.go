@@ -1,10 +1,12 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/sndcds/grains/grains_api"
 	"github.com/sndcds/uranus/app"
 )
@@ -13,8 +15,6 @@ func (h *ApiHandler) AdminUpdateOrgPartnerGrants(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-update-org-partner-list")
 	ctx := gc.Request.Context()
 
-	// TODO: Check permissions: canEditPartnerRights
-
 	orgUuid := gc.Param("orgUuid")
 	if orgUuid == "" {
 		apiRequest.Required("orgUuid is required")
@@ -54,14 +54,48 @@ func (h *ApiHandler) AdminUpdateOrgPartnerGrants(gc *gin.Context) {
 		permissions |= app.OrgPermSeeInsights
 	}
 
-	query := fmt.Sprintf(`
-		UPDATE %s.organization_access_grants
-		SET permissions = $3
-		WHERE src_org_uuid = $1 AND dst_org_uuid = $2`,
-		h.DbSchema)
-	_, err := h.DbPool.Exec(ctx, query, orgUuid, partnerUuid, permissions)
-	if err != nil {
-		apiRequest.InternalServerError()
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			SELECT permissions
+			FROM %s.organization_access_grants
+			WHERE src_org_uuid = $1 AND dst_org_uuid = $2
+			FOR UPDATE`,
+			h.DbSchema)
+		var previousPermissions app.Permissions
+		err := tx.QueryRow(ctx, query, orgUuid, partnerUuid).Scan(&previousPermissions)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("partnership not found")
+			}
+			return TxInternalError(err)
+		}
+
+		query = fmt.Sprintf(`
+			UPDATE %s.organization_access_grants
+			SET permissions = $3
+			WHERE src_org_uuid = $1 AND dst_org_uuid = $2`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, query, orgUuid, partnerUuid, permissions)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		if permissions != previousPermissions {
+			err = h.writeAuditLogTx(
+				gc, tx, orgUuid, auditEntityPartner, partnerUuid, "update-grants",
+				map[string]any{"permissions": previousPermissions},
+				map[string]any{"permissions": permissions})
+			if err != nil {
+				return TxInternalError(err)
+			}
+		}
+
+		return nil
+	})
+
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
 		return
 	}
 
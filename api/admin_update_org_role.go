@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminUpdateOrgRole updates name, description and permissions of a member
+// role. The permissions of all members holding the role are updated with
+// it. Added permissions must be held by the user, and users cannot change
+// the protected permissions of a role they hold themselves.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminUpdateOrgRole(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-org-role")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	roleUuid := gc.Param("roleUuid")
+	if roleUuid == "" {
+		apiRequest.Required("roleUuid is required")
+		return
+	}
+	apiRequest.SetMeta("role_uuid", roleUuid)
+
+	type Payload struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Permissions int64  `json:"permissions"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		apiRequest.Required("name is required")
+		return
+	}
+
+	orgPermissions, _ := gc.MustGet("org-permissions").(app.Permissions)
+
+	var role model.OrgMemberRole
+	var memberCount int64
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "org_member_role", roleUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		previousPermissions, ok, err := h.orgMemberRolePermissionsTx(ctx, tx, orgUuid, roleUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return ApiErrNotFound("role not found")
+		}
+
+		// Users holding the role are affected like any other holder, so their
+		// own protected permissions are guarded
+		caller, isMember, err := h.loadMemberPermissionsTx(ctx, tx, orgUuid, userUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		holderUuid := ""
+		if isMember && caller.RoleUuid != nil && *caller.RoleUuid == roleUuid {
+			holderUuid = userUuid
+		}
+		txErr := checkPermissionChange(userUuid, orgPermissions, holderUuid, previousPermissions, payload.Permissions)
+		if txErr != nil {
+			return txErr
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s.org_member_role
+			SET name = $3, description = $4, permissions = $5, modified_at = NOW()
+			WHERE uuid = $1::uuid AND org_uuid = $2::uuid`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, query, roleUuid, orgUuid, payload.Name, payload.Description, payload.Permissions)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return NewApiTxError(http.StatusConflict, "a role with this name already exists")
+			}
+			return TxInternalError(err)
+		}
+
+		memberCount, err = h.refreshRoleMembersTx(ctx, tx, roleUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		role, err = scanOrgMemberRole(tx.QueryRow(ctx, h.orgMemberRoleQuery()+` WHERE r.uuid = $1::uuid`, roleUuid))
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "org_member_role", roleUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityRole, roleUuid, "update", before, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("updated_member_count", memberCount)
+	apiRequest.Success(http.StatusOK, role, "role updated successfully")
+}
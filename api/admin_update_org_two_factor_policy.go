@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+)
+
+// AdminUpdateOrgTwoFactorPolicy sets the permission bits which members of an
+// organization only hold while they have two-factor authentication enabled.
+// Members without it keep their other permissions. 0 turns the requirement
+// off. To require it, the user must have two-factor authentication enabled.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermManagePermissions, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminUpdateOrgTwoFactorPolicy(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-org-two-factor-policy")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	var payload struct {
+		Permissions *int64 `json:"permissions" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+	permissions := app.Permissions(*payload.Permissions)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if permissions != 0 {
+			enabled, err := h.userTwoFactorEnabled(ctx, tx, userUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if !enabled {
+				return NewApiTxError(http.StatusConflict, "enable two-factor authentication first")
+			}
+		}
+
+		query := fmt.Sprintf(
+			`SELECT two_factor_permissions FROM %s.organization WHERE uuid = $1::uuid FOR UPDATE`,
+			h.DbSchema)
+		var previous int64
+		err := tx.QueryRow(ctx, query, orgUuid).Scan(&previous)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if app.Permissions(previous) == permissions {
+			return nil
+		}
+
+		query = fmt.Sprintf(
+			`UPDATE %s.organization SET two_factor_permissions = $2 WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, query, orgUuid, int64(permissions))
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityOrg, orgUuid, "update-two-factor-policy",
+			gin.H{"two_factor_permissions": previous},
+			gin.H{"two_factor_permissions": int64(permissions)})
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "two-factor policy updated")
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// AdminUpdateOrgWebhook changes the fields of a webhook subscription. With
+// rotate_secret a new signing secret is generated and returned once.
+// Reactivating a webhook does not resend deliveries which failed meanwhile.
+//
+// PermissionNote: User must be authenticated.
+// PermissionChecks: UserPermEditOrg, enforced by RequireOrgPermissions middleware.
+func (h *ApiHandler) AdminUpdateOrgWebhook(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-org-webhook")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	webhookUuid := gc.Param("webhookUuid")
+	if webhookUuid == "" {
+		apiRequest.Required("webhookUuid is required")
+		return
+	}
+	apiRequest.SetMeta("webhook_uuid", webhookUuid)
+
+	var payload struct {
+		Url          *string               `json:"url"`
+		PortalUuid   NullableField[string] `json:"portal_uuid"`
+		EventTypes   *[]string             `json:"event_types"`
+		Description  NullableField[string] `json:"description"`
+		Active       *bool                 `json:"active"`
+		RotateSecret bool                  `json:"rotate_secret"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	setClauses := []string{}
+	args := []interface{}{}
+	argPos := 1
+
+	if payload.Url != nil {
+		u := strings.TrimSpace(*payload.Url)
+		if err := validateWebhookUrl(u, h.Config.DevMode); err != nil {
+			apiRequest.Error(http.StatusBadRequest, err.Error())
+			return
+		}
+		argPos = addUpdateClauseString("url", &u, &setClauses, &args, argPos)
+	}
+	if payload.EventTypes != nil {
+		if err := validateWebhookEventTypes(*payload.EventTypes); err != nil {
+			apiRequest.Error(http.StatusBadRequest, err.Error())
+			return
+		}
+		argPos = addUpdateClauseStringSliceField("event_types", payload.EventTypes, &setClauses, &args, argPos)
+	}
+	TrimNullableString(&payload.Description)
+	argPos = addUpdateClauseNullable("description", payload.Description, &setClauses, &args, argPos)
+	if payload.PortalUuid.Set {
+		setClauses = append(setClauses, fmt.Sprintf("portal_uuid = $%d::uuid", argPos))
+		args = append(args, payload.PortalUuid.Value)
+		argPos++
+	}
+	if payload.Active != nil {
+		setClauses = append(setClauses, fmt.Sprintf("active = $%d", argPos))
+		args = append(args, *payload.Active)
+		argPos++
+	}
+
+	var secret string
+	if payload.RotateSecret {
+		var err error
+		secret, err = generateWebhookSecret()
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		setClauses = append(setClauses, fmt.Sprintf("secret = $%d", argPos))
+		args = append(args, secret)
+		argPos++
+	}
+
+	if len(setClauses) == 0 {
+		apiRequest.SuccessNoData(http.StatusOK, "no fields updated")
+		return
+	}
+	apiRequest.SetMeta("field_count", len(setClauses))
+	setClauses = append(setClauses, "modified_at = NOW()")
+
+	query := fmt.Sprintf(`
+		UPDATE %s.webhook_subscription
+		SET %s
+		WHERE uuid = $%d::uuid AND org_uuid = $%d::uuid
+		RETURNING %s`,
+		h.DbSchema, strings.Join(setClauses, ", "), argPos, argPos+1, webhookSubscriptionColumns)
+	args = append(args, webhookUuid, orgUuid)
+
+	var webhook model.WebhookSubscription
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if payload.PortalUuid.Value != nil {
+			ok, err := h.isOrgPortalTx(ctx, tx, orgUuid, *payload.PortalUuid.Value)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if !ok {
+				return ApiErrNotFound("portal not found in organization")
+			}
+		}
+
+		before, err := h.loadAuditStateTx(ctx, tx, "webhook_subscription", webhookUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		webhook, err = scanWebhookSubscription(tx.QueryRow(ctx, query, args...))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("webhook not found")
+			}
+			return TxInternalError(err)
+		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "webhook_subscription", webhookUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		action := "update"
+		if payload.RotateSecret {
+			action = "rotate-secret"
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityWebhook, webhookUuid, action, before, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	if secret != "" {
+		apiRequest.Success(http.StatusOK, gin.H{
+			"webhook": webhook,
+			"secret":  secret,
+		}, "store the secret safely, it cannot be shown again")
+		return
+	}
+	apiRequest.Success(http.StatusOK, gin.H{"webhook": webhook}, "webhook updated successfully")
+}
@@ -27,6 +27,7 @@ type participationInfoReq struct {
 
 func (h *ApiHandler) AdminUpdateEventParticipationInfos(gc *gin.Context) {
 	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
 
 	eventUuid := gc.Param("eventUuid")
 	if eventUuid == "" {
@@ -95,7 +96,12 @@ func (h *ApiHandler) AdminUpdateEventParticipationInfos(gc *gin.Context) {
 	}
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
-		query := fmt.Sprintf(`UPDATE %s.event SET %s WHERE id = $1`,
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(`UPDATE %s.event SET %s WHERE uuid = $1::uuid`,
 			h.DbSchema,
 			strings.Join(setClauses, ", "),
 		)
@@ -115,6 +121,11 @@ func (h *ApiHandler) AdminUpdateEventParticipationInfos(gc *gin.Context) {
 			}
 		}
 
+		err = h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "participation", before)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
 		if err != nil {
 			return &ApiTxError{
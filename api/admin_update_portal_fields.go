@@ -53,6 +53,11 @@ func (h *ApiHandler) AdminUpdatePortalFields(gc *gin.Context) {
 	args = append(args, portalUuid)
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "portal", portalUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		res, err := tx.Exec(ctx, query, args...)
 		if err != nil {
 			return &ApiTxError{
@@ -68,6 +73,19 @@ func (h *ApiHandler) AdminUpdatePortalFields(gc *gin.Context) {
 			}
 		}
 
+		after, err := h.loadAuditStateTx(ctx, tx, "portal", portalUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		orgUuid, err := h.GetOrgUuidByPortalUuidTx(gc, tx, portalUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityPortal, portalUuid, "update", before, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
@@ -2,7 +2,6 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,7 +10,6 @@ import (
 
 func (h *ApiHandler) AdminUpdatePortalFooter(gc *gin.Context) {
 	apiRequest := grains_api.NewRequest(gc, "admin-update-portal-footer")
-	ctx := gc.Request.Context()
 
 	portalUuid := gc.Param("portalUuid")
 	if portalUuid == "" {
@@ -33,8 +31,7 @@ func (h *ApiHandler) AdminUpdatePortalFooter(gc *gin.Context) {
 		return
 	}
 
-	query := fmt.Sprintf(`UPDATE %s.portal SET footer = $1::jsonb WHERE uuid = $2::uuid`, h.DbSchema)
-	_, err = h.DbPool.Exec(ctx, query, styleJSON, portalUuid)
+	err = h.updatePortalJsonColumn(gc, portalUuid, "footer", styleJSON)
 	if err != nil {
 		apiRequest.InternalServerError()
 		return
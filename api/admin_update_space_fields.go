@@ -83,6 +83,11 @@ func (h *ApiHandler) AdminUpdateSpaceFields(gc *gin.Context) {
 			return txErr
 		}
 
+		before, err := h.loadAuditStateTx(ctx, tx, "space", spaceUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		res, err := tx.Exec(ctx, query, args...)
 		if err != nil {
 			return &ApiTxError{
@@ -106,6 +111,15 @@ func (h *ApiHandler) AdminUpdateSpaceFields(gc *gin.Context) {
 			}
 		}
 
+		after, err := h.loadAuditStateTx(ctx, tx, "space", spaceUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntitySpace, spaceUuid, "update", before, after)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		return nil
 	})
 
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminUpdateUserNotification marks a notification of the user as read or
+// unread.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminUpdateUserNotification(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-user-notification")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	notificationUuid := gc.Param("notificationUuid")
+	if notificationUuid == "" {
+		apiRequest.Required("notificationUuid is required")
+		return
+	}
+	apiRequest.SetMeta("notification_uuid", notificationUuid)
+
+	var payload struct {
+		Read *bool `json:"read" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.notification
+		SET read_at = CASE WHEN $3 THEN COALESCE(read_at, NOW()) END
+		WHERE uuid = $1::uuid AND user_uuid = $2::uuid`,
+		h.DbSchema)
+	res, err := h.DbPool.Exec(ctx, query, notificationUuid, userUuid, *payload.Read)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if res.RowsAffected() == 0 {
+		apiRequest.NotFound("notification not found")
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "notification updated")
+}
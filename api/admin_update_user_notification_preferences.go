@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AdminUpdateUserNotificationPreferences sets the channels of notification
+// types for the user. An empty channel resets a type to its default.
+// Notifications already created keep their channel.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) AdminUpdateUserNotificationPreferences(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "admin-update-user-notification-preferences")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	var payload struct {
+		Preferences []struct {
+			Type    string `json:"type" binding:"required"`
+			Channel string `json:"channel"`
+		} `json:"preferences" binding:"required,dive"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	for _, p := range payload.Preferences {
+		typ, ok := notificationTypes[p.Type]
+		if !ok {
+			apiRequest.Error(http.StatusBadRequest, "unknown notification type: "+p.Type)
+			return
+		}
+		if p.Channel != "" && !slices.Contains(notificationChannels, p.Channel) {
+			apiRequest.Error(http.StatusBadRequest, "channel must be in-app, immediate, daily or weekly")
+			return
+		}
+		if typ.Mandatory && p.Channel != "" && p.Channel != typ.DefaultChannel {
+			apiRequest.Error(http.StatusBadRequest, "channel of "+p.Type+" can not be changed")
+			return
+		}
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		for _, p := range payload.Preferences {
+			if p.Channel == "" {
+				query := fmt.Sprintf(
+					`DELETE FROM %s.notification_preference WHERE user_uuid = $1::uuid AND type = $2`,
+					h.DbSchema)
+				if _, err := tx.Exec(ctx, query, userUuid, p.Type); err != nil {
+					return TxInternalError(err)
+				}
+				continue
+			}
+
+			query := fmt.Sprintf(`
+				INSERT INTO %s.notification_preference (user_uuid, type, channel)
+				VALUES ($1::uuid, $2, $3)
+				ON CONFLICT (user_uuid, type) DO UPDATE SET channel = EXCLUDED.channel, modified_at = NOW()`,
+				h.DbSchema)
+			if _, err := tx.Exec(ctx, query, userUuid, p.Type, p.Channel); err != nil {
+				return TxInternalError(err)
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "notification preferences updated")
+}
@@ -113,9 +113,14 @@ func (h *ApiHandler) AdminUpdateVenueFields(gc *gin.Context) {
 	args = append(args, venueUuid) // eventUuid is the last parameter
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "venue", venueUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
 		res, err := tx.Exec(ctx, query, args...)
 		if err != nil {
-			return TxInternalError(nil)
+			return TxInternalError(err)
 		}
 
 		if res.RowsAffected() == 0 {
@@ -127,7 +132,20 @@ func (h *ApiHandler) AdminUpdateVenueFields(gc *gin.Context) {
 
 		err = RefreshEventProjections(ctx, tx, "venue", []string{venueUuid})
 		if err != nil {
-			return TxInternalError(nil)
+			return TxInternalError(err)
+		}
+
+		after, err := h.loadAuditStateTx(ctx, tx, "venue", venueUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		orgUuid, err := h.GetOrgUuidByVenueUuidTx(gc, tx, venueUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(gc, tx, orgUuid, auditEntityVenue, venueUuid, "update", before, after)
+		if err != nil {
+			return TxInternalError(err)
 		}
 
 		return nil
@@ -25,11 +25,20 @@ func (h *ApiHandler) AdminUpsertEventDate(gc *gin.Context) {
 		gc.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.AvailabilityStatusId != nil &&
+		(*req.AvailabilityStatusId < availabilityStatusAvailable || *req.AvailabilityStatusId > availabilityStatusWaitlist) {
+		gc.JSON(http.StatusBadRequest, gin.H{"error": "availability_status_id must be between 1 and 4"})
+		return
+	}
 
 	eventDateUuid := gc.Param("dateUuid")
 	newEventDateUuid := ""
 
 	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
 
 		if eventDateUuid == "" {
 			// Insert
@@ -125,11 +134,20 @@ RETURNING id`, h.DbSchema)
 			}
 		}
 
+		if err := h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "date", before); err != nil {
+			return TxInternalError(err)
+		}
+
 		// Refresh projections
 		if err := RefreshEventProjections(ctx, tx, "event_date", []string{newEventDateUuid}); err != nil {
 			return ApiErrInternal("refresh projection tables failed: %v", err)
 		}
 
+		// A status set by hand only stays for dates without counts
+		if err := h.updateAvailabilityTx(ctx, tx, []string{newEventDateUuid}); err != nil {
+			return ApiErrInternal("update availability failed: %v", err)
+		}
+
 		return nil
 	})
 
@@ -18,7 +18,7 @@ func (h *ApiHandler) AdminGetUserProfile(gc *gin.Context) {
 	userUuid := h.userUuid(gc)
 
 	query := fmt.Sprintf(`
-        SELECT email, username, display_name, first_name, last_name, locale, theme
+        SELECT email, username, display_name, first_name, last_name, locale, theme, account_type
         FROM %s.user
         WHERE uuid = $1
         `,
@@ -34,7 +34,8 @@ func (h *ApiHandler) AdminGetUserProfile(gc *gin.Context) {
 		&profile.FirstName,
 		&profile.LastName,
 		&profile.Locale,
-		&profile.Theme)
+		&profile.Theme,
+		&profile.AccountType)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			apiRequest.NotFound("user not found")
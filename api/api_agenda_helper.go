@@ -0,0 +1,358 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/app"
+)
+
+// Changes of saved event dates visitors are notified about, see
+// user_agenda.pending_change
+const (
+	agendaChangeCancelled   = "cancelled"
+	agendaChangeDeferred    = "deferred"
+	agendaChangeRescheduled = "rescheduled"
+	agendaChangeMoved       = "moved"
+)
+
+const (
+	agendaBatchSize                = 100
+	agendaMaxItems                 = 1000
+	agendaMaxRemindBeforeHours     = 336
+	agendaDefaultRemindBeforeHours = 24
+)
+
+func hasAgendaItemsTx(ctx context.Context, tx pgx.Tx) (bool, error) {
+	query := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s.user_agenda)`,
+		app.UranusInstance.Config.DbSchema)
+	var exists bool
+	err := tx.QueryRow(ctx, query).Scan(&exists)
+	return exists, err
+}
+
+// eventDateAgendaChanges compares the event date states before and after a
+// refresh and returns the dates which were cancelled, postponed or
+// rescheduled, or moved to another time or place while released, with the
+// change.
+func eventDateAgendaChanges(before, after map[string]webhookState) map[string]string {
+	changes := map[string]string{}
+	for uuid, a := range after {
+		b, existed := before[uuid]
+		if !existed {
+			continue
+		}
+		status := publicEventDateStatus(a)
+		previous := publicEventDateStatus(b)
+
+		switch {
+		case status != previous &&
+			(status == agendaChangeCancelled || status == agendaChangeDeferred || status == agendaChangeRescheduled):
+			changes[uuid] = status
+		case status == "released" && previous == "released" && a.Schedule != b.Schedule:
+			changes[uuid] = agendaChangeMoved
+		}
+	}
+	return changes
+}
+
+// markAgendaChangesTx marks the agenda items of the changed upcoming event
+// dates for the agenda worker. Moved dates are reminded again.
+func markAgendaChangesTx(ctx context.Context, tx pgx.Tx, changes map[string]string) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	eventDateUuids := make([]string, 0, len(changes))
+	types := make([]string, 0, len(changes))
+	for uuid, change := range changes {
+		eventDateUuids = append(eventDateUuids, uuid)
+		types = append(types, change)
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %[1]s.user_agenda a
+		SET pending_change = c.change,
+			changed_at = NOW(),
+			reminded_at = CASE WHEN c.change = $3 THEN NULL ELSE a.reminded_at END
+		FROM unnest($1::uuid[], $2::text[]) AS c (event_date_uuid, change)
+		JOIN %[1]s.event_date_projection edp ON edp.event_date_uuid = c.event_date_uuid
+		WHERE a.event_date_uuid = c.event_date_uuid
+			AND edp.start_date >= CURRENT_DATE`,
+		app.UranusInstance.Config.DbSchema)
+	_, err := tx.Exec(ctx, query, eventDateUuids, types, agendaChangeMoved)
+	return err
+}
+
+// agendaEventDate is an event date of an agenda item as used in
+// notifications.
+type agendaEventDate struct {
+	UserUuid      string
+	EventDateUuid string
+	EventUuid     string
+	Title         string
+	StartDate     string
+	StartTime     string
+	VenueName     string
+}
+
+func (d agendaEventDate) when() string {
+	return strings.TrimSpace(d.StartDate + " " + d.StartTime)
+}
+
+func (h *ApiHandler) agendaEventDateLink(eventUuid string, eventDateUuid string) string {
+	if h.Config.Frontend == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/event/%s/date/%s", h.Config.Frontend, eventUuid, eventDateUuid)
+}
+
+// NotifyAgendaChanges notifies visitors about changes of the event dates in
+// their agenda marked by the projection refresh. Returns the number of
+// handled agenda items.
+func (h *ApiHandler) NotifyAgendaChanges(ctx context.Context) (int, error) {
+	count := 0
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			SELECT
+				a.user_uuid,
+				a.event_date_uuid,
+				a.pending_change,
+				a.changed_at,
+				edp.event_uuid,
+				COALESCE(ep.title, ''),
+				TO_CHAR(edp.start_date, 'DD.MM.YYYY'),
+				COALESCE(TO_CHAR(edp.start_time, 'HH24:MI'), ''),
+				COALESCE(edp.venue_name, ep.venue_name, ''),
+				edp.status_reason,
+				edp.rescheduled_to_uuid,
+				COALESCE(TO_CHAR(nd.start_date, 'DD.MM.YYYY'), ''),
+				COALESCE(TO_CHAR(nd.start_time, 'HH24:MI'), '')
+			FROM %[1]s.user_agenda a
+			JOIN %[1]s.event_date_projection edp ON edp.event_date_uuid = a.event_date_uuid
+			LEFT JOIN %[1]s.event_projection ep ON ep.event_uuid = edp.event_uuid
+			LEFT JOIN %[1]s.event_date_projection nd ON nd.event_date_uuid = edp.rescheduled_to_uuid
+			WHERE a.pending_change IS NOT NULL
+			ORDER BY a.changed_at
+			LIMIT $1
+			FOR UPDATE OF a SKIP LOCKED`,
+			h.DbSchema)
+		rows, err := tx.Query(ctx, query, agendaBatchSize)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		type agendaChange struct {
+			agendaEventDate
+			Change            string
+			ChangedAt         time.Time
+			StatusReason      *string
+			RescheduledToUuid *string
+			NewDate           string
+			NewTime           string
+		}
+		var changes []agendaChange
+		for rows.Next() {
+			var c agendaChange
+			err := rows.Scan(
+				&c.UserUuid, &c.EventDateUuid, &c.Change, &c.ChangedAt, &c.EventUuid, &c.Title,
+				&c.StartDate, &c.StartTime, &c.VenueName, &c.StatusReason, &c.RescheduledToUuid,
+				&c.NewDate, &c.NewTime)
+			if err != nil {
+				rows.Close()
+				return TxInternalError(err)
+			}
+			changes = append(changes, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return TxInternalError(err)
+		}
+
+		for _, c := range changes {
+			var subject string
+			var lines []string
+			switch c.Change {
+			case agendaChangeCancelled:
+				subject = fmt.Sprintf("Cancelled: %s (%s)", c.Title, c.when())
+			case agendaChangeDeferred:
+				subject = fmt.Sprintf("Postponed: %s (%s)", c.Title, c.when())
+			case agendaChangeRescheduled:
+				subject = fmt.Sprintf("Rescheduled: %s (%s)", c.Title, c.when())
+			default:
+				subject = fmt.Sprintf("Changed: %s (%s)", c.Title, c.when())
+				line := "Now on " + c.when()
+				if c.VenueName != "" {
+					line += " at " + c.VenueName
+				}
+				lines = append(lines, line+".")
+			}
+			if c.Change != agendaChangeMoved && c.StatusReason != nil && *c.StatusReason != "" {
+				lines = append(lines, *c.StatusReason)
+			}
+
+			data := map[string]any{"event_uuid": c.EventUuid, "change": c.Change}
+			linkDateUuid := c.EventDateUuid
+			if c.Change == agendaChangeRescheduled && c.RescheduledToUuid != nil {
+				lines = append(lines, "New date: "+strings.TrimSpace(c.NewDate+" "+c.NewTime))
+				data["rescheduled_to_uuid"] = *c.RescheduledToUuid
+				linkDateUuid = *c.RescheduledToUuid
+			}
+
+			_, err := h.notifyUsersTx(ctx, tx, notification{
+				Type:       notificationAgendaChange,
+				EntityType: notificationEntityEventDate,
+				EntityUuid: c.EventDateUuid,
+				Title:      subject,
+				Body:       strings.Join(lines, "\n\n"),
+				Link:       h.agendaEventDateLink(c.EventUuid, linkDateUuid),
+				Data:       data,
+				DedupKey: fmt.Sprintf("%s:%s:%s:%d",
+					notificationAgendaChange, c.EventDateUuid, c.Change, c.ChangedAt.Unix()),
+			}, []string{c.UserUuid})
+			if err != nil {
+				return TxInternalError(err)
+			}
+
+			query := fmt.Sprintf(`
+				UPDATE %s.user_agenda SET pending_change = NULL
+				WHERE user_uuid = $1::uuid AND event_date_uuid = $2::uuid`,
+				h.DbSchema)
+			if _, err := tx.Exec(ctx, query, c.UserUuid, c.EventDateUuid); err != nil {
+				return TxInternalError(err)
+			}
+			count++
+		}
+		return nil
+	})
+	if txErr != nil {
+		return count, txErr
+	}
+	return count, nil
+}
+
+// SendAgendaReminders notifies visitors about released event dates in their
+// agenda starting within the hours they chose. Each date is reminded once,
+// dates with a pending change are reminded after the change was notified.
+// Returns the number of reminded agenda items.
+func (h *ApiHandler) SendAgendaReminders(ctx context.Context) (int, error) {
+	count := 0
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			SELECT
+				a.user_uuid,
+				a.event_date_uuid,
+				edp.event_uuid,
+				COALESCE(ep.title, ''),
+				TO_CHAR(edp.start_date, 'DD.MM.YYYY'),
+				COALESCE(TO_CHAR(edp.start_time, 'HH24:MI'), ''),
+				COALESCE(edp.venue_name, ep.venue_name, '')
+			FROM %[1]s.user_agenda a
+			JOIN %[1]s.event_date_projection edp ON edp.event_date_uuid = a.event_date_uuid
+			JOIN %[1]s.event_projection ep ON ep.event_uuid = edp.event_uuid
+			WHERE a.reminded_at IS NULL
+				AND a.remind_before_hours > 0
+				AND a.pending_change IS NULL
+				AND edp.event_start_at > NOW()
+				AND edp.event_start_at - make_interval(hours => a.remind_before_hours) <= NOW()
+				AND CASE
+					WHEN edp.release_status IN ('cancelled', 'deferred', 'rescheduled') THEN edp.release_status
+					ELSE ep.release_status
+				END = 'released'
+			ORDER BY edp.event_start_at
+			LIMIT $1
+			FOR UPDATE OF a SKIP LOCKED`,
+			h.DbSchema)
+		rows, err := tx.Query(ctx, query, agendaBatchSize)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		var dates []agendaEventDate
+		for rows.Next() {
+			var d agendaEventDate
+			err := rows.Scan(&d.UserUuid, &d.EventDateUuid, &d.EventUuid, &d.Title, &d.StartDate, &d.StartTime, &d.VenueName)
+			if err != nil {
+				rows.Close()
+				return TxInternalError(err)
+			}
+			dates = append(dates, d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return TxInternalError(err)
+		}
+
+		for _, d := range dates {
+			body := "Starts on " + d.when()
+			if d.VenueName != "" {
+				body += " at " + d.VenueName
+			}
+
+			_, err := h.notifyUsersTx(ctx, tx, notification{
+				Type:       notificationAgendaReminder,
+				EntityType: notificationEntityEventDate,
+				EntityUuid: d.EventDateUuid,
+				Title:      fmt.Sprintf("Reminder: %s (%s)", d.Title, d.when()),
+				Body:       body + ".",
+				Link:       h.agendaEventDateLink(d.EventUuid, d.EventDateUuid),
+				Data:       map[string]any{"event_uuid": d.EventUuid},
+				DedupKey:   notificationAgendaReminder + ":" + d.EventDateUuid + ":" + d.when(),
+			}, []string{d.UserUuid})
+			if err != nil {
+				return TxInternalError(err)
+			}
+
+			query := fmt.Sprintf(`
+				UPDATE %s.user_agenda SET reminded_at = NOW()
+				WHERE user_uuid = $1::uuid AND event_date_uuid = $2::uuid`,
+				h.DbSchema)
+			if _, err := tx.Exec(ctx, query, d.UserUuid, d.EventDateUuid); err != nil {
+				return TxInternalError(err)
+			}
+			count++
+		}
+		return nil
+	})
+	if txErr != nil {
+		return count, txErr
+	}
+	return count, nil
+}
+
+// RunAgendaWorker notifies visitors about changes of saved event dates and
+// sends due reminders once at start and then in the given interval until
+// ctx is cancelled.
+func (h *ApiHandler) RunAgendaWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := h.NotifyAgendaChanges(ctx)
+			if err != nil {
+				debugf("notify agenda changes failed: %v", err)
+			}
+			if err != nil || n < agendaBatchSize {
+				break
+			}
+		}
+		for {
+			n, err := h.SendAgendaReminders(ctx)
+			if err != nil {
+				debugf("send agenda reminders failed: %v", err)
+			}
+			if err != nil || n < agendaBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
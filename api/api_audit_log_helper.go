@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Entity types of audit log entries
+const (
+	auditEntityOrg     = "org"
+	auditEntityMember  = "member"
+	auditEntityPartner = "partner"
+	auditEntityVenue   = "venue"
+	auditEntitySpace   = "space"
+	auditEntityPortal  = "portal"
+	auditEntityEvent   = "event"
+	auditEntitySyncKey = "sync_key"
+	auditEntityWebhook = "webhook"
+	auditEntityApiKey  = "api_key"
+	auditEntityRole    = "role"
+
+	auditEntityOidcGroupMapping = "oidc_group_mapping"
+)
+
+var auditEntityTypes = []string{
+	auditEntityOrg,
+	auditEntityMember,
+	auditEntityPartner,
+	auditEntityVenue,
+	auditEntitySpace,
+	auditEntityPortal,
+	auditEntityEvent,
+	auditEntitySyncKey,
+	auditEntityWebhook,
+	auditEntityApiKey,
+	auditEntityRole,
+	auditEntityOidcGroupMapping,
+}
+
+// Columns which never go into the audit log
+var auditRedactedFields = []string{
+	"secret",
+	"sync_key_hash",
+	"password_hash",
+	"accept_token",
+	"api_import_token",
+	"key_hash",
+	"search_vector",
+}
+
+// loadAuditStateTx returns a row of a table with a uuid primary key as
+// stored in the before or after payload of an audit log entry, nil if the
+// row does not exist.
+func (h *ApiHandler) loadAuditStateTx(ctx context.Context, tx pgx.Tx, table string, uuid string) (map[string]any, error) {
+	query := fmt.Sprintf(
+		`SELECT to_jsonb(t) - $2::text[] FROM %s.%s t WHERE t.uuid = $1::uuid`,
+		h.DbSchema, pgx.Identifier{table}.Sanitize())
+	var state map[string]any
+	err := tx.QueryRow(ctx, query, uuid, auditRedactedFields).Scan(&state)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return state, nil
+}
+
+// loadAuditMemberStateTx returns the membership of a user in an organization
+// including the permissions, nil if the user is no member.
+func (h *ApiHandler) loadAuditMemberStateTx(ctx context.Context, tx pgx.Tx, orgUuid string, userUuid string) (map[string]any, error) {
+	query := fmt.Sprintf(`
+		SELECT (to_jsonb(m) - $3::text[]) || jsonb_build_object('permissions', l.permissions)
+		FROM %[1]s.organization_member_link m
+		LEFT JOIN %[1]s.user_organization_link l ON l.org_uuid = m.org_uuid AND l.user_uuid = m.user_uuid
+		WHERE m.org_uuid = $1::uuid AND m.user_uuid = $2::uuid`,
+		h.DbSchema)
+	var state map[string]any
+	err := tx.QueryRow(ctx, query, orgUuid, userUuid, auditRedactedFields).Scan(&state)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return state, nil
+}
+
+// writeAuditLogTx appends an entry to the audit log of an organization. The
+// actor (user or API key) and client IP are taken from the request. before and after are
+// stored as JSON, nil values as NULL.
+func (h *ApiHandler) writeAuditLogTx(
+	gc *gin.Context,
+	tx pgx.Tx,
+	orgUuid string,
+	entityType string,
+	entityUuid string,
+	action string,
+	before any,
+	after any,
+) error {
+	beforeJson, err := auditJson(before)
+	if err != nil {
+		return err
+	}
+	afterJson, err := auditJson(after)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s.audit_log (org_uuid, actor_user_uuid, actor_api_key_uuid, entity_type, entity_uuid, action, before, after, client_ip)
+		VALUES ($1::uuid, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, NULLIF($5, '')::uuid, $6, $7::jsonb, $8::jsonb, NULLIF($9, ''))`,
+		h.DbSchema)
+	_, err = tx.Exec(
+		gc.Request.Context(), query,
+		orgUuid, h.userUuid(gc), h.apiKeyUuid(gc), entityType, entityUuid, action, beforeJson, afterJson, gc.ClientIP())
+	return err
+}
+
+func auditJson(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return nil, err
+	}
+	return b, nil
+}
+
+// PurgeAuditLog deletes audit log entries older than the configured
+// retention. A retention of 0 days keeps all entries.
+func (h *ApiHandler) PurgeAuditLog(ctx context.Context) error {
+	days := h.Config.AuditLogRetentionDays
+	if days <= 0 {
+		return nil
+	}
+
+	var deleted int64
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		_, err := tx.Exec(ctx, `SET LOCAL uranus.audit_log_retention = 'on'`)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query := fmt.Sprintf(
+			`DELETE FROM %s.audit_log WHERE created_at < NOW() - make_interval(days => $1)`,
+			h.DbSchema)
+		res, err := tx.Exec(ctx, query, days)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		deleted = res.RowsAffected()
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	if deleted > 0 {
+		debugf("audit log retention: %d entries deleted", deleted)
+	}
+
+	return nil
+}
+
+// RunAuditLogRetentionWorker calls PurgeAuditLog once at start and then in
+// the given interval until ctx is cancelled.
+func (h *ApiHandler) RunAuditLogRetentionWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.PurgeAuditLog(ctx); err != nil {
+			debugf("purge audit log failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/model"
+)
+
+// Values of availability_status_id of event dates
+const (
+	availabilityStatusAvailable = 1
+	availabilityStatusFewLeft   = 2
+	availabilityStatusSoldOut   = 3
+	availabilityStatusWaitlist  = 4
+)
+
+// defaultAvailabilityRule applies to organizations without a rule of their
+// own
+var defaultAvailabilityRule = model.AvailabilityRule{FewLeftPercent: 10, FewLeftSeats: 1}
+
+// availabilityCounts are the figures the availability of an event date is
+// derived from. Capacity is nil if unknown. Waitlist tells whether visitors
+// can join a waiting list, Waiting whether visitors are waiting already.
+type availabilityCounts struct {
+	Capacity  *int
+	Available int
+	Waitlist  bool
+	Waiting   bool
+}
+
+// availabilityStatus derives the availability of an event date from its
+// counts. Few seats are left from the higher of both thresholds of the rule
+// on.
+func availabilityStatus(rule model.AvailabilityRule, c availabilityCounts) int {
+	fewLeft := rule.FewLeftSeats
+	if c.Capacity != nil {
+		fewLeft = max(fewLeft, *c.Capacity*rule.FewLeftPercent/100)
+	}
+
+	switch {
+	case c.Available <= 0 || c.Waiting:
+		if c.Waitlist {
+			return availabilityStatusWaitlist
+		}
+		return availabilityStatusSoldOut
+	case c.Available <= fewLeft:
+		return availabilityStatusFewLeft
+	default:
+		return availabilityStatusAvailable
+	}
+}
+
+// loadAvailabilityRuleTx returns the availability rule of an organization,
+// the default rule if it has none.
+func (h *ApiHandler) loadAvailabilityRuleTx(ctx context.Context, q rowQuerier, orgUuid string) (model.AvailabilityRule, error) {
+	query := fmt.Sprintf(
+		`SELECT few_left_percent, few_left_seats FROM %s.org_availability_rule WHERE org_uuid::text = $1`,
+		h.DbSchema)
+	var rule model.AvailabilityRule
+	err := q.QueryRow(ctx, query, orgUuid).Scan(&rule.FewLeftPercent, &rule.FewLeftSeats)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return defaultAvailabilityRule, nil
+	}
+	return rule, err
+}
+
+// loadAvailabilityCountsTx returns the counts of an event date from the
+// first source which applies: ticket counts pushed by an external ticketing
+// system, tickets of limited price tiers or registrations with a capacity.
+// Returns false if none applies.
+func (h *ApiHandler) loadAvailabilityCountsTx(ctx context.Context, tx pgx.Tx, d registrationDate) (availabilityCounts, bool, error) {
+	var c availabilityCounts
+
+	query := fmt.Sprintf(
+		`SELECT available, capacity, waitlist FROM %s.event_date_ticket_count WHERE event_date_uuid = $1::uuid`,
+		h.DbSchema)
+	err := tx.QueryRow(ctx, query, d.EventDateUuid).Scan(&c.Available, &c.Capacity, &c.Waitlist)
+	if err == nil {
+		return c, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return c, false, err
+	}
+
+	tiers, err := h.loadTicketTiersTx(ctx, tx, d.EventDateUuid)
+	if err != nil {
+		return c, false, err
+	}
+	if len(tiers) > 0 {
+		if limit, sold := ticketLimit(tiers, d.ticketCapacity()); limit != nil {
+			c.Capacity = limit
+			c.Available = *limit - sold
+			return c, true, nil
+		}
+	}
+
+	if d.Enabled && d.Capacity != nil {
+		c.Capacity = d.Capacity
+		c.Available = *d.Capacity - d.ConfirmedSeats - d.IssuedTickets
+		c.Waitlist = d.WaitlistEnabled
+		c.Waiting = d.WaitlistCount > 0
+		return c, true, nil
+	}
+
+	return c, false, nil
+}
+
+// updateAvailabilityTx derives the availability status of event dates from
+// their counts and refreshes the projections of the changed dates. Dates
+// without a source keep their status.
+func (h *ApiHandler) updateAvailabilityTx(ctx context.Context, tx pgx.Tx, eventDateUuids []string) error {
+	rules := map[string]model.AvailabilityRule{}
+	var changed []string
+	for _, eventDateUuid := range eventDateUuids {
+		d, err := h.loadRegistrationDateTx(ctx, tx, eventDateUuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return err
+		}
+		counts, ok, err := h.loadAvailabilityCountsTx(ctx, tx, d)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		rule, ok := rules[d.OrgUuid]
+		if !ok {
+			rule, err = h.loadAvailabilityRuleTx(ctx, tx, d.OrgUuid)
+			if err != nil {
+				return err
+			}
+			rules[d.OrgUuid] = rule
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s.event_date
+			SET availability_status_id = $2
+			WHERE uuid = $1::uuid AND availability_status_id IS DISTINCT FROM $2`,
+			h.DbSchema)
+		tag, err := tx.Exec(ctx, query, eventDateUuid, availabilityStatus(rule, counts))
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			changed = append(changed, eventDateUuid)
+		}
+	}
+	return RefreshEventProjections(ctx, tx, "event_date", changed)
+}
+
+// updateEventAvailabilityTx updates the availability of the upcoming dates
+// of an event, e.g. after its price tiers or registration settings changed.
+func (h *ApiHandler) updateEventAvailabilityTx(ctx context.Context, tx pgx.Tx, eventUuid string) error {
+	query := fmt.Sprintf(`
+		SELECT uuid::text FROM %s.event_date
+		WHERE event_uuid = $1::uuid AND start_date >= CURRENT_DATE`,
+		h.DbSchema)
+	rows, err := tx.Query(ctx, query, eventUuid)
+	if err != nil {
+		return err
+	}
+	eventDateUuids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	return h.updateAvailabilityTx(ctx, tx, eventDateUuids)
+}
+
+// updateOrgAvailabilityTx updates the availability of the upcoming dates of
+// an organization which have a source, after its rule changed.
+func (h *ApiHandler) updateOrgAvailabilityTx(ctx context.Context, tx pgx.Tx, orgUuid string) error {
+	query := fmt.Sprintf(`
+		SELECT ed.uuid::text
+		FROM %[1]s.event_date ed
+		JOIN %[1]s.event e ON e.uuid = ed.event_uuid
+		WHERE e.org_uuid = $1::uuid AND ed.start_date >= CURRENT_DATE
+			AND (
+				EXISTS (SELECT 1 FROM %[1]s.event_date_ticket_count tc WHERE tc.event_date_uuid = ed.uuid)
+				OR EXISTS (SELECT 1 FROM %[1]s.event_price_tier pt WHERE pt.event_uuid = ed.event_uuid)
+				OR EXISTS (SELECT 1 FROM %[1]s.event_registration_config c WHERE c.event_uuid = ed.event_uuid AND c.enabled)
+			)`,
+		h.DbSchema)
+	rows, err := tx.Query(ctx, query, orgUuid)
+	if err != nil {
+		return err
+	}
+	eventDateUuids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	return h.updateAvailabilityTx(ctx, tx, eventDateUuids)
+}
+
+// availabilitySchemaOrg returns the schema.org ItemAvailability of an
+// availability status, "" if unknown.
+func availabilitySchemaOrg(statusId *int) string {
+	if statusId == nil {
+		return ""
+	}
+	switch *statusId {
+	case availabilityStatusAvailable:
+		return "https://schema.org/InStock"
+	case availabilityStatusFewLeft:
+		return "https://schema.org/LimitedAvailability"
+	case availabilityStatusSoldOut, availabilityStatusWaitlist:
+		return "https://schema.org/SoldOut"
+	}
+	return ""
+}
@@ -0,0 +1,388 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"mime/quotedprintable"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/app"
+)
+
+const (
+	emailBatchSize     = 50
+	emailMaxAttempts   = 8
+	emailSendTimeout   = 30 * time.Second
+	emailLease         = 5 * time.Minute
+	emailMaxRetryWait  = 6 * time.Hour
+	emailRetentionDays = 90
+)
+
+// errNoEmailTemplate is returned by renderEmailTemplate if there is no
+// template for the context in any language.
+var errNoEmailTemplate = errors.New("no email template")
+
+// emailMessage is a mail to be queued by enqueueEmail.
+type emailMessage struct {
+	To              string
+	Subject         string
+	Html            string
+	Text            string
+	TemplateContext string
+	Lang            string
+	UserUuid        string
+	OrgUuid         string
+}
+
+// emailTemplateLanguages returns the languages to look for a template in:
+// the requested one if supported, then English and the other supported
+// languages.
+func emailTemplateLanguages(lang string, supported []string) []string {
+	langs := []string{}
+	for _, l := range supported {
+		if l == lang {
+			langs = append(langs, lang)
+		}
+	}
+	langs = append(langs, "en")
+	return append(langs, supported...)
+}
+
+// renderEmailTemplate returns a mail from the system email template of a
+// context in the language closest to lang. Placeholders like {{link}} are
+// replaced by vars, escaped in the HTML part except for keys ending in
+// "_html", which are only used there. Templates without text version get one
+// derived from the HTML.
+func (h *ApiHandler) renderEmailTemplate(ctx context.Context, q rowQuerier, templateContext string, lang string, vars map[string]string) (emailMessage, error) {
+	query := fmt.Sprintf(`
+		SELECT subject, template, text_template, iso_639_1
+		FROM %s.system_email_template
+		WHERE context = $1 AND iso_639_1 = ANY($2::text[])
+		ORDER BY array_position($2::text[], iso_639_1)
+		LIMIT 1`,
+		h.DbSchema)
+	var subject, htmlTemplate, templateLang string
+	var textTemplate *string
+	err := q.QueryRow(ctx, query, templateContext, emailTemplateLanguages(lang, h.Config.SupportedLanguages)).
+		Scan(&subject, &htmlTemplate, &textTemplate, &templateLang)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return emailMessage{}, fmt.Errorf("%w: %s", errNoEmailTemplate, templateContext)
+		}
+		return emailMessage{}, err
+	}
+
+	htmlPairs := make([]string, 0, len(vars)*2)
+	textPairs := make([]string, 0, len(vars)*2)
+	for key, value := range vars {
+		if strings.HasSuffix(key, "_html") {
+			htmlPairs = append(htmlPairs, "{{"+key+"}}", value)
+			continue
+		}
+		htmlPairs = append(htmlPairs, "{{"+key+"}}", html.EscapeString(value))
+		textPairs = append(textPairs, "{{"+key+"}}", value)
+	}
+
+	m := emailMessage{
+		Subject:         strings.NewReplacer(textPairs...).Replace(subject),
+		Html:            strings.NewReplacer(htmlPairs...).Replace(htmlTemplate),
+		TemplateContext: templateContext,
+		Lang:            templateLang,
+	}
+	if textTemplate != nil && *textTemplate != "" {
+		m.Text = strings.NewReplacer(textPairs...).Replace(*textTemplate)
+	} else {
+		m.Text = htmlToText(m.Html)
+	}
+	return m, nil
+}
+
+var (
+	htmlLinkRegexp      = regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	htmlBreakRegexp     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr)>`)
+	htmlTagRegexp       = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlStyleRegexp     = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+	blankLinesRegexp    = regexp.MustCompile(`\n{3,}`)
+	spaceRunRegexp      = regexp.MustCompile(`[ \t]+`)
+	lineSpaceTrimRegexp = regexp.MustCompile(`(?m)^[ \t]+|[ \t]+$`)
+)
+
+// htmlToText returns a plain text version of an HTML mail, links are kept
+// as "text (url)".
+func htmlToText(s string) string {
+	s = htmlStyleRegexp.ReplaceAllString(s, "")
+	s = htmlLinkRegexp.ReplaceAllStringFunc(s, func(a string) string {
+		parts := htmlLinkRegexp.FindStringSubmatch(a)
+		text := strings.TrimSpace(htmlTagRegexp.ReplaceAllString(parts[2], ""))
+		if text == "" || text == parts[1] {
+			return parts[1]
+		}
+		return text + " (" + parts[1] + ")"
+	})
+	s = htmlBreakRegexp.ReplaceAllString(s, "\n")
+	s = htmlTagRegexp.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = spaceRunRegexp.ReplaceAllString(s, " ")
+	s = lineSpaceTrimRegexp.ReplaceAllString(s, "")
+	s = blankLinesRegexp.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+// enqueueEmail queues a mail for the email worker and returns its id. Called
+// with a transaction, the mail is only sent if the transaction commits.
+func (h *ApiHandler) enqueueEmail(ctx context.Context, q rowQuerier, m emailMessage) (int64, error) {
+	if !app.IsValidEmail(m.To) {
+		return 0, fmt.Errorf("invalid email: %s", m.To)
+	}
+
+	messageId, err := newEmailMessageId(h.Config.AuthReplyEmail)
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s.email_message
+			(message_id, to_email, subject, html_body, text_body, template_context, lang, user_uuid, org_uuid)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, '')::uuid, NULLIF($9, '')::uuid)
+		RETURNING id`,
+		h.DbSchema)
+	var id int64
+	err = q.QueryRow(ctx, query,
+		messageId, m.To, m.Subject, m.Html, m.Text, m.TemplateContext, m.Lang, m.UserUuid, m.OrgUuid).Scan(&id)
+	return id, err
+}
+
+// enqueueTemplateEmail renders a system email template and queues the mail.
+func (h *ApiHandler) enqueueTemplateEmail(ctx context.Context, q rowQuerier, to string, templateContext string, lang string, vars map[string]string, userUuid string, orgUuid string) (int64, error) {
+	m, err := h.renderEmailTemplate(ctx, q, templateContext, lang, vars)
+	if err != nil {
+		return 0, err
+	}
+	m.To = to
+	m.UserUuid = userUuid
+	m.OrgUuid = orgUuid
+	return h.enqueueEmail(ctx, q, m)
+}
+
+// newEmailMessageId returns a Message-ID in the domain of the sender.
+// Bounces refer to it.
+func newEmailMessageId(from string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	domain := "uranus.local"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
+
+// composeEmail returns a multipart/alternative mail with a text and an HTML
+// part.
+func composeEmail(from string, to string, subject string, messageId string, date time.Time, htmlBody string, textBody string) ([]byte, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	boundary := "uranus-" + hex.EncodeToString(b)
+
+	var buf bytes.Buffer
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + to + "\r\n")
+	buf.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	buf.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("Message-ID: " + messageId + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n")
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain", textBody},
+		{"text/html", htmlBody},
+	} {
+		buf.WriteString("--" + boundary + "\r\n")
+		buf.WriteString("Content-Type: " + part.contentType + "; charset=\"UTF-8\"\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+		buf.WriteString("\r\n")
+		w := quotedprintable.NewWriter(&buf)
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+
+	return buf.Bytes(), nil
+}
+
+// emailRetryDelay returns the wait time after a failed attempt, doubling
+// from one minute up to emailMaxRetryWait
+func emailRetryDelay(attempt int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempt && delay < emailMaxRetryWait; i++ {
+		delay *= 2
+	}
+	return min(delay, emailMaxRetryWait)
+}
+
+type pendingEmail struct {
+	Id           int64
+	MessageId    string
+	To           string
+	Subject      string
+	Html         string
+	Text         string
+	AttemptCount int
+	CreatedAt    time.Time
+}
+
+// SendQueuedEmails sends a batch of due mails. The mails are claimed for
+// emailLease with SKIP LOCKED, so several API processes can share the work.
+// Returns the number of claimed mails.
+func (h *ApiHandler) SendQueuedEmails(ctx context.Context) (int, error) {
+	query := fmt.Sprintf(`
+		UPDATE %[1]s.email_message m
+		SET attempt_count = m.attempt_count + 1,
+			last_attempt_at = NOW(),
+			next_attempt_at = NOW() + make_interval(secs => $2)
+		WHERE m.id IN (
+			SELECT id
+			FROM %[1]s.email_message
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING m.id, m.message_id, m.to_email, m.subject, m.html_body, m.text_body, m.attempt_count, m.created_at`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, emailBatchSize, emailLease.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	var emails []pendingEmail
+	for rows.Next() {
+		var e pendingEmail
+		err := rows.Scan(&e.Id, &e.MessageId, &e.To, &e.Subject, &e.Html, &e.Text, &e.AttemptCount, &e.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		emails = append(emails, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range emails {
+		if err := h.sendQueuedEmail(ctx, e); err != nil {
+			debugf("email %d to %s failed: %v", e.Id, e.To, err)
+		}
+	}
+
+	return len(emails), nil
+}
+
+// sendQueuedEmail sends a mail and stores the result. Failed mails are
+// retried with exponential backoff until emailMaxAttempts, permanent
+// rejections are not retried.
+func (h *ApiHandler) sendQueuedEmail(ctx context.Context, e pendingEmail) error {
+	from := h.Config.AuthReplyEmail
+	message, err := composeEmail(from, e.To, e.Subject, e.MessageId, e.CreatedAt, e.Html, e.Text)
+	if err == nil {
+		sendCtx, cancel := context.WithTimeout(ctx, emailSendTimeout)
+		err = h.EmailTransport.Send(sendCtx, from, e.To, message)
+		cancel()
+	}
+
+	status := "sent"
+	var retryDelay time.Duration
+	var errText *string
+	if err != nil {
+		text := err.Error()
+		errText = &text
+		var permanentErr *permanentEmailError
+		if errors.As(err, &permanentErr) || e.AttemptCount >= emailMaxAttempts {
+			status = "failed"
+		} else {
+			status = "pending"
+			retryDelay = emailRetryDelay(e.AttemptCount)
+		}
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.email_message
+		SET status = $2,
+			error = $3,
+			next_attempt_at = NOW() + make_interval(secs => $4),
+			sent_at = CASE WHEN $2 = 'sent' THEN NOW() END
+		WHERE id = $1`,
+		h.DbSchema)
+	_, dbErr := h.DbPool.Exec(ctx, query, e.Id, status, errText, retryDelay.Seconds())
+	if dbErr != nil {
+		return dbErr
+	}
+	return err
+}
+
+// PurgeEmailMessages deletes sent, failed and bounced mails older than the
+// retention period, their bodies may contain tokens.
+func (h *ApiHandler) PurgeEmailMessages(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		DELETE FROM %s.email_message
+		WHERE status <> 'pending' AND COALESCE(last_attempt_at, created_at) < NOW() - make_interval(days => $1)`,
+		h.DbSchema)
+	_, err := h.DbPool.Exec(ctx, query, emailRetentionDays)
+	return err
+}
+
+// RunEmailWorker sends queued mails in the given interval until ctx is
+// cancelled. Full batches are followed by the next batch at once. Old mails
+// are purged once a day.
+func (h *ApiHandler) RunEmailWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		for {
+			n, err := h.SendQueuedEmails(ctx)
+			if err != nil {
+				debugf("send queued emails failed: %v", err)
+			}
+			if err != nil || n < emailBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) > 24*time.Hour {
+			if err := h.PurgeEmailMessages(ctx); err != nil {
+				debugf("purge email messages failed: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
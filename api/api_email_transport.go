@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sndcds/uranus/app"
+	"golang.org/x/net/idna"
+)
+
+// EmailTransport hands composed mails over for delivery.
+type EmailTransport interface {
+	// Send delivers message, a complete RFC 5322 mail, to one recipient.
+	Send(ctx context.Context, from string, to string, message []byte) error
+}
+
+// NewEmailTransport returns the transport selected by Config.MailTransport:
+// "smtp" sends with the AuthSmtp* settings, "file" writes the mails into the
+// maildir Config.MailDir, e.g. for development.
+func NewEmailTransport(config *app.Config) (EmailTransport, error) {
+	switch config.MailTransport {
+	case "", "smtp":
+		return &smtpEmailTransport{
+			host:     config.AuthSmtpHost,
+			port:     config.AuthSmtpPort,
+			login:    config.AuthSmtpLogin,
+			password: config.AuthSmtpPassword,
+		}, nil
+	case "file":
+		if config.MailDir == "" {
+			return nil, errors.New("mail_transport file requires mail_dir")
+		}
+		for _, sub := range []string{"tmp", "new", "cur"} {
+			if err := os.MkdirAll(filepath.Join(config.MailDir, sub), 0o750); err != nil {
+				return nil, err
+			}
+		}
+		return &fileEmailTransport{dir: config.MailDir}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail_transport: %s", config.MailTransport)
+	}
+}
+
+// permanentEmailError marks a rejection which will not succeed on retry,
+// e.g. an unknown recipient.
+type permanentEmailError struct {
+	err error
+}
+
+func (e *permanentEmailError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentEmailError) Unwrap() error {
+	return e.err
+}
+
+// smtpEmailTransport sends with STARTTLS if the server offers it, or with
+// implicit TLS on port 465.
+type smtpEmailTransport struct {
+	host     string
+	port     int
+	login    string
+	password string
+}
+
+func (t *smtpEmailTransport) Send(ctx context.Context, from string, to string, message []byte) error {
+	// Servers requiring a login usually only accept it as envelope sender
+	if strings.Contains(t.login, "@") {
+		from = t.login
+	}
+	asciiFrom, err := encodeEmailAddress(from)
+	if err != nil {
+		return &permanentEmailError{err}
+	}
+	asciiTo, err := encodeEmailAddress(to)
+	if err != nil {
+		return &permanentEmailError{err}
+	}
+
+	addr := fmt.Sprintf("%s:%d", t.host, t.port)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if t.port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: t.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, t.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if t.port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: t.host}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.login != "" {
+		if err := client.Auth(smtp.PlainAuth("", t.login, t.password, t.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(asciiFrom); err != nil {
+		return smtpError(err)
+	}
+	if err := client.Rcpt(asciiTo); err != nil {
+		return smtpError(err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+
+	return client.Quit()
+}
+
+// smtpError marks 5xx replies as permanent.
+func smtpError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return &permanentEmailError{err}
+	}
+	return err
+}
+
+// fileEmailTransport writes each mail into the new directory of a maildir.
+type fileEmailTransport struct {
+	dir string
+}
+
+func (t *fileEmailTransport) Send(ctx context.Context, from string, to string, message []byte) error {
+	name := fmt.Sprintf("%d.%s.uranus", time.Now().UnixNano(), strings.NewReplacer("/", "_", "@", "_at_").Replace(to))
+	tmpPath := filepath.Join(t.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, message, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(t.dir, "new", name))
+}
+
+// Encode an email address for SMTP
+func encodeEmailAddress(email string) (string, error) {
+	parts := strings.Split(email, "@")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid email: %s", email)
+	}
+
+	local := parts[0]  // user
+	domain := parts[1] // domain
+
+	asciiDomain, err := idna.ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+
+	return local + "@" + asciiDomain, nil
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// eventDateStatusTransitions lists the allowed status changes of a single
+// event date. A date without own status follows the event ("inherited"),
+// "deferred" means postponed without a new date yet. A rescheduled date
+// links to its new date and can not be changed anymore.
+var eventDateStatusTransitions = map[string][]string{
+	"inherited":   {"cancelled", "deferred", "rescheduled"},
+	"deferred":    {"inherited", "cancelled", "rescheduled"},
+	"cancelled":   {"inherited"},
+	"rescheduled": {},
+}
+
+// eventDateStatusState returns the state of an event date for
+// eventDateStatusTransitions. Own release states other than cancelled,
+// deferred and rescheduled count as "inherited".
+func eventDateStatusState(releaseStatus *string) string {
+	if releaseStatus == nil {
+		return "inherited"
+	}
+	if _, ok := eventDateStatusTransitions[*releaseStatus]; ok {
+		return *releaseStatus
+	}
+	return "inherited"
+}
+
+func canChangeEventDateStatus(from string, to string) bool {
+	return slices.Contains(eventDateStatusTransitions[from], to)
+}
+
+// cancelEventDatesTx cancels the given future event dates of an event which
+// are not cancelled or rescheduled yet, instead of deleting them, and
+// notifies users who saved them. Generated dates stay generated, so the
+// recurrence restores them when its rule produces their day again. Returns
+// the cancelled uuids.
+func (h *ApiHandler) cancelEventDatesTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	userUuid string,
+	eventUuid string,
+	eventDateUuids []string,
+) ([]string, error) {
+	if len(eventDateUuids) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.event_date
+		SET release_status = 'cancelled',
+			status_changed_at = NOW(),
+			status_changed_by = NULLIF($3, '')::uuid,
+			modified_by = NULLIF($3, '')::uuid
+		WHERE uuid = ANY($2::uuid[])
+			AND event_uuid = $1::uuid
+			AND start_date >= CURRENT_DATE
+			AND release_status IS DISTINCT FROM 'cancelled'
+			AND release_status IS DISTINCT FROM 'rescheduled'
+		RETURNING uuid`,
+		h.DbSchema)
+	cancelledUuids, err := queryUuidsTx(ctx, tx, query, eventUuid, eventDateUuids, userUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, eventDateUuid := range cancelledUuids {
+		_, err := h.notifyFavoriteEventDateTx(ctx, tx, userUuid, eventUuid, eventDateUuid, "cancelled", nil, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cancelledUuids, nil
+}
+
+// notifyFavoriteEventDateTx notifies all users who saved the event date in a
+// favorite list. Returns the number of notified users.
+func (h *ApiHandler) notifyFavoriteEventDateTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	fromUserUuid string,
+	eventUuid string,
+	eventDateUuid string,
+	status string,
+	reason *string,
+	rescheduledToUuid *string,
+) (int, error) {
+	query := fmt.Sprintf(`
+		SELECT e.title, TO_CHAR(ed.start_date, 'DD.MM.YYYY'), COALESCE(TO_CHAR(ed.start_time, 'HH24:MI'), '')
+		FROM %[1]s.event_date ed
+		JOIN %[1]s.event e ON e.uuid = ed.event_uuid
+		WHERE ed.uuid = $1::uuid`,
+		h.DbSchema)
+	var title, startDate, startTime string
+	err := tx.QueryRow(ctx, query, eventDateUuid).Scan(&title, &startDate, &startTime)
+	if err != nil {
+		return 0, err
+	}
+
+	when := strings.TrimSpace(startDate + " " + startTime)
+
+	var subject string
+	switch status {
+	case "cancelled":
+		subject = fmt.Sprintf("Cancelled: %s (%s)", title, when)
+	case "deferred":
+		subject = fmt.Sprintf("Postponed: %s (%s)", title, when)
+	case "rescheduled":
+		subject = fmt.Sprintf("Rescheduled: %s (%s)", title, when)
+	default:
+		subject = fmt.Sprintf("Takes place again: %s (%s)", title, when)
+	}
+
+	var lines []string
+	if reason != nil && *reason != "" {
+		lines = append(lines, *reason)
+	}
+	if rescheduledToUuid != nil {
+		query = fmt.Sprintf(`
+			SELECT TO_CHAR(start_date, 'DD.MM.YYYY'), COALESCE(TO_CHAR(start_time, 'HH24:MI'), '')
+			FROM %s.event_date
+			WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		var newDate, newTime string
+		err = tx.QueryRow(ctx, query, *rescheduledToUuid).Scan(&newDate, &newTime)
+		if err != nil {
+			return 0, err
+		}
+		lines = append(lines, "New date: "+strings.TrimSpace(newDate+" "+newTime))
+	}
+
+	query = fmt.Sprintf(`
+		SELECT DISTINCT f.created_by
+		FROM %s.favorite f
+		WHERE f.context = 'event-date'
+			AND f.context_uuid = $1::uuid
+			AND f.created_by IS NOT NULL`,
+		h.DbSchema)
+	userUuids, err := queryUuidsTx(ctx, tx, query, eventDateUuid)
+	if err != nil {
+		return 0, err
+	}
+
+	link := ""
+	if h.Config.Frontend != "" {
+		linkDateUuid := eventDateUuid
+		if rescheduledToUuid != nil {
+			linkDateUuid = *rescheduledToUuid
+		}
+		link = fmt.Sprintf("%s/event/%s/date/%s", h.Config.Frontend, eventUuid, linkDateUuid)
+	}
+
+	data := map[string]any{"event_uuid": eventUuid, "status": status}
+	if rescheduledToUuid != nil {
+		data["rescheduled_to_uuid"] = *rescheduledToUuid
+	}
+
+	return h.notifyUsersTx(ctx, tx, notification{
+		Type:       notificationEventDateStatus,
+		EntityType: notificationEntityEventDate,
+		EntityUuid: eventDateUuid,
+		Title:      subject,
+		Body:       strings.Join(lines, "\n\n"),
+		Link:       link,
+		Data:       data,
+		CreatedBy:  fromUserUuid,
+	}, userUuids)
+}
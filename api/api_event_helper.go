@@ -2,19 +2,39 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 
+	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sndcds/grains/grains_uuid"
 	"github.com/sndcds/uranus/app"
 )
 
 // GetUserEventOrgPermissionsTx fetches a user's permission for the organizer of an event within a transaction.
+// For requests authenticated by an API key the permissions of the key apply.
 func (h *ApiHandler) GetUserEventOrgPermissionsTx(
-	ctx context.Context,
+	gc *gin.Context,
 	tx pgx.Tx,
 	userUuid string,
 	eventUuid string,
 ) (app.Permissions, error) {
+	ctx := gc.Request.Context()
+
+	if _, ok := requestApiKey(gc); ok {
+		orgUuid, err := h.GetOrgUuidByEventUuidTx(gc, tx, eventUuid)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return 0, nil
+			}
+			return 0, err
+		}
+		permissions, _ := apiKeyOrgPermissions(gc, orgUuid)
+		return permissions, nil
+	}
+
 	var permissions pgtype.Int8
 
 	err := tx.QueryRow(
@@ -66,3 +86,128 @@ func (h *ApiHandler) GetUserEventOrganizerPermissions(
 
 	return app.Permissions(permissions.Int64), nil
 }
+
+// insertEventPayloadTx inserts an event with its dates, recurrence and types
+// and returns the uuid of the new event. Permissions and venues must be
+// checked by the caller. userUuid may be nil if there is no acting user.
+func (h *ApiHandler) insertEventPayloadTx(
+	gc *gin.Context,
+	tx pgx.Tx,
+	userUuid *string,
+	p *eventPayload,
+) (string, *ApiTxError) {
+	ctx := gc.Request.Context()
+
+	eventUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		return "", TxInternalError(err)
+	}
+
+	var priceType *string
+	if p.PriceType != "" {
+		v := string(p.PriceType)
+		priceType = &v
+	}
+
+	_, err = tx.Exec(ctx, app.UranusInstance.SqlAdminInsertEvent,
+		eventUuid,
+		p.OrgUuid,
+		p.VenueUuid,
+		p.SpaceUuid,
+		p.ExternalId,
+		p.ReleaseStatus,
+		p.ReleaseDate,
+		p.Categories,
+		p.Title,
+		p.Subtitle,
+		p.Description,
+		p.Summary,
+		p.Languages,
+		p.Tags,
+		p.SourceLink,
+		p.OnlineLink,
+		p.OccasionTypeId,
+		p.ParticipationInfo,
+		p.MinAge,
+		p.MaxAge,
+		p.MeetingPoint,
+		p.MaxAttendees,
+		priceType,
+		p.Currency,
+		p.TicketLink,
+		p.TicketFlags,
+		p.Custom,
+		p.Style,
+		userUuid,
+	)
+	if err != nil {
+		return "", &ApiTxError{
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("failed to insert event: %v", err),
+		}
+	}
+
+	for _, d := range p.Dates {
+		eventDateUuid, err := grains_uuid.Uuidv7String()
+		if err != nil {
+			return "", TxInternalError(err)
+		}
+		_, err = tx.Exec(ctx, app.UranusInstance.SqlAdminInsertEventDate,
+			eventDateUuid,
+			eventUuid,
+			"inherited",
+			d.VenueUuid,
+			d.SpaceUuid,
+			d.StartDate,
+			d.StartTime,
+			d.EndDate,
+			d.EndTime,
+			d.EntryTime,
+			d.Duration,
+			d.AllDay,
+			userUuid,
+		)
+		if err != nil {
+			return "", &ApiTxError{
+				Code: http.StatusInternalServerError,
+				Err:  fmt.Errorf("failed to insert event date: %v", err),
+			}
+		}
+	}
+
+	if p.Recurrence != nil {
+		if userUuid == nil {
+			return "", &ApiTxError{
+				Code:    http.StatusBadRequest,
+				Err:     errors.New("recurrence requires an acting user"),
+				Message: "recurrence is not supported here",
+			}
+		}
+		txErr := h.saveEventRecurrenceTx(gc, tx, eventUuid, *userUuid, *p.Recurrence)
+		if txErr != nil {
+			return "", txErr
+		}
+		_, err = h.materializeEventRecurrenceTx(ctx, tx, eventUuid, *userUuid)
+		if err != nil {
+			return "", &ApiTxError{
+				Code: http.StatusInternalServerError,
+				Err:  fmt.Errorf("failed to generate recurring event dates: %v", err),
+			}
+		}
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s.event_type_link (event_uuid, type_id, genre_id) VALUES ($1::uuid, $2, $3)`,
+		h.DbSchema)
+	for _, pair := range p.TypeGenrePairs {
+		_, err := tx.Exec(ctx, query, eventUuid, pair.TypeId, pair.GenreId)
+		if err != nil {
+			return "", &ApiTxError{
+				Code: http.StatusInternalServerError,
+				Err:  fmt.Errorf("failed to insert type-genre pair: %v", err),
+			}
+		}
+	}
+
+	return eventUuid, nil
+}
@@ -0,0 +1,604 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sndcds/uranus/model"
+)
+
+// eventImportRow is an event parsed from an import file. Row is the line
+// (CSV) or the VEVENT number (ICS) where the event was found. Venue holds
+// the venue name or uuid as given in the file, it is resolved before import.
+type eventImportRow struct {
+	Row     int
+	Payload eventPayload
+	Venue   string
+	Errors  []string
+}
+
+func (r *eventImportRow) addError(format string, args ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// Columns understood by the CSV import, title and start_date are required
+var eventImportCsvColumns = map[string]struct{}{
+	"title":          {},
+	"subtitle":       {},
+	"description":    {},
+	"summary":        {},
+	"start_date":     {},
+	"start_time":     {},
+	"end_date":       {},
+	"end_time":       {},
+	"entry_time":     {},
+	"all_day":        {},
+	"venue":          {},
+	"space":          {},
+	"categories":     {},
+	"types":          {},
+	"languages":      {},
+	"tags":           {},
+	"price_type":     {},
+	"currency":       {},
+	"min_age":        {},
+	"max_age":        {},
+	"ticket_link":    {},
+	"online_link":    {},
+	"source_link":    {},
+	"external_id":    {},
+	"release_status": {},
+}
+
+// parseEventImportCsv parses a CSV file with a header line. The delimiter is
+// detected from the header (',' or ';'). Rows sharing the same external_id
+// are merged into one event with several dates.
+func parseEventImportCsv(data []byte) ([]*eventImportRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	headerLine := data
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		headerLine = data[:i]
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(headerLine, []byte(";")) > bytes.Count(headerLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := eventImportCsvColumns[name]; !ok {
+			return nil, fmt.Errorf("unknown csv column: %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"title", "start_date"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv column %q is required", name)
+		}
+	}
+
+	rows := []*eventImportRow{}
+	byExternalId := map[string]*eventImportRow{}
+	line := 1
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv line %d: %v", line, err)
+		}
+
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		optional := func(name string) *string {
+			if v := value(name); v != "" {
+				return &v
+			}
+			return nil
+		}
+
+		// Skip empty lines
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := &eventImportRow{Row: line}
+
+		date := model.EventDatePayload{}
+		if v, err := parseImportDate(value("start_date")); err != nil {
+			row.addError("start_date: %v", err)
+		} else {
+			date.StartDate = v
+		}
+		if v := value("end_date"); v != "" {
+			if d, err := parseImportDate(v); err != nil {
+				row.addError("end_date: %v", err)
+			} else {
+				date.EndDate = &d
+			}
+		}
+		date.StartTime = value("start_time")
+		date.EndTime = optional("end_time")
+		date.EntryTime = optional("entry_time")
+		if v := value("all_day"); v != "" {
+			allDay, err := parseImportBool(v)
+			if err != nil {
+				row.addError("all_day: %v", err)
+			}
+			date.AllDay = &allDay
+		}
+		if date.AllDay != nil && *date.AllDay && date.StartTime == "" {
+			date.StartTime = "00:00"
+		}
+		date.SpaceUuid = optional("space")
+		date.TicketLink = optional("ticket_link")
+
+		externalId := value("external_id")
+		if existing, ok := byExternalId[externalId]; ok && externalId != "" {
+			// Additional date of an event listed before
+			existing.Payload.Dates = append(existing.Payload.Dates, date)
+			existing.Errors = append(existing.Errors, row.Errors...)
+			continue
+		}
+
+		p := &row.Payload
+		p.Title = value("title")
+		p.Subtitle = optional("subtitle")
+		p.Description = value("description")
+		p.Summary = optional("summary")
+		p.ExternalId = optional("external_id")
+		p.ReleaseStatus = optional("release_status")
+		p.OnlineLink = optional("online_link")
+		p.SourceLink = optional("source_link")
+		p.TicketLink = optional("ticket_link")
+		p.Currency = optional("currency")
+		p.PriceType = model.EventPriceType(value("price_type"))
+		p.Tags = splitImportList(value("tags"))
+		p.Languages = splitImportList(value("languages"))
+		p.Dates = []model.EventDatePayload{date}
+		row.Venue = value("venue")
+
+		if v := value("categories"); v != "" {
+			for _, s := range splitImportList(v) {
+				n, err := strconv.Atoi(s)
+				if err != nil {
+					row.addError("categories: invalid value %q", s)
+					continue
+				}
+				p.Categories = append(p.Categories, n)
+			}
+		}
+
+		if v := value("types"); v != "" {
+			pairs, err := parseImportTypeGenrePairs(v)
+			if err != nil {
+				row.addError("types: %v", err)
+			}
+			p.TypeGenrePairs = pairs
+		}
+
+		for _, name := range []string{"min_age", "max_age"} {
+			v := value(name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				row.addError("%s: invalid number %q", name, v)
+				continue
+			}
+			if name == "min_age" {
+				p.MinAge = &n
+			} else {
+				p.MaxAge = &n
+			}
+		}
+
+		rows = append(rows, row)
+		if externalId != "" {
+			byExternalId[externalId] = row
+		}
+	}
+
+	return rows, nil
+}
+
+// parseEventImportICS parses the VEVENT components of an iCalendar file.
+// Times are converted into the given location, recurring events are imported
+// with their RRULE. Modified instances (RECURRENCE-ID) are not supported.
+func parseEventImportICS(data []byte, location *time.Location) ([]*eventImportRow, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n ", "")
+	text = strings.ReplaceAll(text, "\n\t", "")
+
+	if !strings.Contains(text, "BEGIN:VCALENDAR") {
+		return nil, errors.New("not an iCalendar file")
+	}
+
+	type property struct {
+		Params map[string]string
+		Value  string
+	}
+
+	rows := []*eventImportRow{}
+	byUid := map[string]*eventImportRow{}
+	var props map[string][]property
+	inEvent := false
+	eventIndex := 0
+	depth := 0
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case line == "BEGIN:VEVENT":
+			inEvent = true
+			eventIndex++
+			props = map[string][]property{}
+			continue
+		case inEvent && strings.HasPrefix(line, "BEGIN:"):
+			// Nested components like VALARM
+			depth++
+			continue
+		case inEvent && depth > 0 && strings.HasPrefix(line, "END:"):
+			depth--
+			continue
+		case line == "END:VEVENT":
+			inEvent = false
+		default:
+			if !inEvent || depth > 0 {
+				continue
+			}
+			colon := strings.Index(line, ":")
+			if colon < 0 {
+				continue
+			}
+			nameParams := strings.Split(line[:colon], ";")
+			p := property{Params: map[string]string{}, Value: line[colon+1:]}
+			for _, param := range nameParams[1:] {
+				if k, v, ok := strings.Cut(param, "="); ok {
+					p.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+				}
+			}
+			name := strings.ToUpper(nameParams[0])
+			props[name] = append(props[name], p)
+			continue
+		}
+
+		// END:VEVENT, build the event
+		first := func(name string) *property {
+			if list := props[name]; len(list) > 0 {
+				return &list[0]
+			}
+			return nil
+		}
+		value := func(name string) string {
+			if p := first(name); p != nil {
+				return unescapeICSText(p.Value)
+			}
+			return ""
+		}
+		optional := func(name string) *string {
+			if v := value(name); v != "" {
+				return &v
+			}
+			return nil
+		}
+
+		row := &eventImportRow{Row: eventIndex}
+
+		if first("RECURRENCE-ID") != nil {
+			row.addError("modified instances of recurring events (RECURRENCE-ID) are not supported")
+			rows = append(rows, row)
+			continue
+		}
+
+		start := first("DTSTART")
+		if start == nil {
+			row.addError("DTSTART is missing")
+			rows = append(rows, row)
+			continue
+		}
+		startAt, allDay, err := parseICSDateTime(start.Value, start.Params, location)
+		if err != nil {
+			row.addError("DTSTART: %v", err)
+			rows = append(rows, row)
+			continue
+		}
+
+		date := model.EventDatePayload{
+			StartDate: startAt.Format("2006-01-02"),
+			StartTime: startAt.Format("15:04"),
+		}
+		if allDay {
+			date.AllDay = &allDay
+		}
+
+		if end := first("DTEND"); end != nil {
+			endAt, _, err := parseICSDateTime(end.Value, end.Params, location)
+			if err != nil {
+				row.addError("DTEND: %v", err)
+			} else if allDay {
+				// DTEND of all day events is exclusive
+				if endAt = endAt.AddDate(0, 0, -1); endAt.After(startAt) {
+					endDate := endAt.Format("2006-01-02")
+					date.EndDate = &endDate
+				}
+			} else if endAt.After(startAt) {
+				endDate := endAt.Format("2006-01-02")
+				endTime := endAt.Format("15:04")
+				if endDate != date.StartDate {
+					date.EndDate = &endDate
+				}
+				date.EndTime = &endTime
+			}
+		} else if duration := first("DURATION"); duration != nil && !allDay {
+			d, err := parseICSDuration(duration.Value)
+			if err != nil {
+				row.addError("DURATION: %v", err)
+			} else {
+				endAt := startAt.Add(d)
+				endDate := endAt.Format("2006-01-02")
+				endTime := endAt.Format("15:04")
+				if endDate != date.StartDate {
+					date.EndDate = &endDate
+				}
+				date.EndTime = &endTime
+			}
+		}
+
+		uid := value("UID")
+		if existing, ok := byUid[uid]; ok && uid != "" {
+			// Another occurrence of an event listed before
+			existing.Payload.Dates = append(existing.Payload.Dates, date)
+			existing.Errors = append(existing.Errors, row.Errors...)
+			continue
+		}
+
+		p := &row.Payload
+		p.Title = value("SUMMARY")
+		p.Description = value("DESCRIPTION")
+		p.ExternalId = optional("UID")
+		p.SourceLink = optional("URL")
+		row.Venue = value("LOCATION")
+		if i := strings.Index(row.Venue, ","); i > 0 {
+			// LOCATION usually contains the full address, use the name only
+			row.Venue = strings.TrimSpace(row.Venue[:i])
+		}
+
+		for _, c := range props["CATEGORIES"] {
+			for _, tag := range splitICSList(c.Value) {
+				p.Tags = append(p.Tags, tag)
+			}
+		}
+
+		if strings.EqualFold(value("STATUS"), "CANCELLED") {
+			status := "cancelled"
+			p.ReleaseStatus = &status
+		}
+
+		if rrule := first("RRULE"); rrule != nil {
+			recurrence := model.EventRecurrencePayload{
+				Rule:      rrule.Value,
+				StartDate: date.StartDate,
+				StartTime: date.StartTime,
+				EndTime:   date.EndTime,
+				AllDay:    date.AllDay,
+			}
+			for _, name := range []string{"RDATE", "EXDATE"} {
+				for _, prop := range props[name] {
+					for _, v := range strings.Split(prop.Value, ",") {
+						t, _, err := parseICSDateTime(v, prop.Params, location)
+						if err != nil {
+							row.addError("%s: %v", name, err)
+							continue
+						}
+						if name == "RDATE" {
+							recurrence.RDates = append(recurrence.RDates, t.Format("2006-01-02"))
+						} else {
+							recurrence.ExDates = append(recurrence.ExDates, t.Format("2006-01-02"))
+						}
+					}
+				}
+			}
+			p.Recurrence = &recurrence
+		} else {
+			p.Dates = []model.EventDatePayload{date}
+		}
+
+		rows = append(rows, row)
+		if uid != "" {
+			byUid[uid] = row
+		}
+	}
+
+	return rows, nil
+}
+
+// parseICSDateTime parses a DATE or DATE-TIME value and converts it into
+// location. UTC values (Z suffix) and values with TZID are converted, floating
+// values are taken as they are.
+func parseICSDateTime(value string, params map[string]string, location *time.Location) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, location)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return t, false, err
+		}
+		return t.In(location), false, nil
+	}
+
+	source := location
+	if tzid := params["TZID"]; tzid != "" {
+		loc, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %q", tzid)
+		}
+		source = loc
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, source)
+	if err != nil {
+		return t, false, err
+	}
+	return t.In(location), false, nil
+}
+
+// parseICSDuration parses the time part of an RFC 5545 duration (e.g. PT1H30M, P1D)
+func parseICSDuration(value string) (time.Duration, error) {
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	var d time.Duration
+	number := ""
+	for _, c := range value {
+		switch {
+		case c >= '0' && c <= '9':
+			number += string(c)
+		case c == 'T':
+		default:
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration")
+			}
+			number = ""
+			switch c {
+			case 'W':
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case 'D':
+				d += time.Duration(n) * 24 * time.Hour
+			case 'H':
+				d += time.Duration(n) * time.Hour
+			case 'M':
+				d += time.Duration(n) * time.Minute
+			case 'S':
+				d += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("invalid duration")
+			}
+		}
+	}
+	return d, nil
+}
+
+// unescapeICSText reverts escapeICSText
+func unescapeICSText(value string) string {
+	var b strings.Builder
+	escaped := false
+	for _, c := range value {
+		if escaped {
+			switch c {
+			case 'n', 'N':
+				b.WriteRune('\n')
+			default:
+				b.WriteRune(c)
+			}
+			escaped = false
+			continue
+		}
+		if c == '\\' {
+			escaped = true
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// splitICSList splits a comma separated ICS value, honoring escaped commas
+func splitICSList(value string) []string {
+	var list []string
+	for _, part := range strings.Split(strings.ReplaceAll(value, `\,`, "\x00"), ",") {
+		part = unescapeICSText(strings.ReplaceAll(part, "\x00", `\,`))
+		if part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// splitImportList splits a list of values separated by '|' or ','
+func splitImportList(value string) []string {
+	list := []string{}
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == '|' || r == ',' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// parseImportTypeGenrePairs parses "type_id:genre_id" pairs, the genre is optional
+func parseImportTypeGenrePairs(value string) ([]model.EventTypeGenrePairPayload, error) {
+	pairs := []model.EventTypeGenrePairPayload{}
+	for _, part := range splitImportList(value) {
+		typeStr, genreStr, hasGenre := strings.Cut(part, ":")
+		typeId, err := strconv.Atoi(strings.TrimSpace(typeStr))
+		if err != nil {
+			return pairs, fmt.Errorf("invalid type %q", part)
+		}
+		pair := model.EventTypeGenrePairPayload{TypeId: typeId}
+		if hasGenre {
+			genreId, err := strconv.Atoi(strings.TrimSpace(genreStr))
+			if err != nil {
+				return pairs, fmt.Errorf("invalid genre %q", part)
+			}
+			pair.GenreId = &genreId
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// parseImportDate accepts YYYY-MM-DD and the DD.MM.YYYY format common in spreadsheets
+func parseImportDate(value string) (string, error) {
+	if value == "" {
+		return "", errors.New("date is required")
+	}
+	for _, layout := range []string{"2006-01-02", "02.01.2006", "2.1.2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", value)
+}
+
+func parseImportBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "x", "ja":
+		return true, nil
+	case "0", "false", "no", "nein":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
@@ -0,0 +1,496 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+const eventRecurrenceLockName = "uranus-event-recurrence"
+
+// parsedRecurrence is a validated recurrence payload.
+type parsedRecurrence struct {
+	Rule    *app.RecurrenceRule
+	DtStart time.Time
+	RDates  []time.Time
+	ExDates []time.Time
+}
+
+// parseEventRecurrence validates a recurrence payload and parses its rule and dates.
+func parseEventRecurrence(p model.EventRecurrencePayload) (*parsedRecurrence, error) {
+	var errs []string
+
+	rule, err := app.ParseRecurrenceRule(p.Rule)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("rrule: %v", err))
+	}
+
+	dtStart, err := time.Parse("2006-01-02", p.StartDate)
+	if err != nil {
+		errs = append(errs, "start_date must be in format YYYY-MM-DD")
+	}
+
+	if _, err := time.Parse("15:04", p.StartTime); err != nil {
+		errs = append(errs, "start_time must be in format HH:MM (24-hour)")
+	}
+	if err := app.ValidateOptionalTime("end_time", p.EndTime); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := app.ValidateOptionalTime("entry_time", p.EntryTime); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if p.Duration != nil && *p.Duration <= 0 {
+		errs = append(errs, "duration must be greater than 0 if provided")
+	}
+	if p.SpaceUuid != nil && p.VenueUuid == nil {
+		errs = append(errs, "space_uuid requires venue_uuid")
+	}
+
+	parseDates := func(field string, values []string) []time.Time {
+		dates := make([]time.Time, 0, len(values))
+		for i, v := range values {
+			d, err := time.Parse("2006-01-02", strings.TrimSpace(v))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s[%d] must be in format YYYY-MM-DD", field, i))
+				continue
+			}
+			dates = append(dates, d)
+		}
+		return dates
+	}
+
+	rDates := parseDates("rdates", p.RDates)
+	exDates := parseDates("exdates", p.ExDates)
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	return &parsedRecurrence{
+		Rule:    rule,
+		DtStart: dtStart,
+		RDates:  rDates,
+		ExDates: exDates,
+	}, nil
+}
+
+// saveEventRecurrenceTx validates and stores the recurrence rule of an event.
+// The caller is responsible for materializing the event dates afterwards.
+func (h *ApiHandler) saveEventRecurrenceTx(
+	gc *gin.Context,
+	tx pgx.Tx,
+	eventUuid string,
+	userUuid string,
+	p model.EventRecurrencePayload,
+) *ApiTxError {
+	ctx := gc.Request.Context()
+
+	parsed, err := parseEventRecurrence(p)
+	if err != nil {
+		return &ApiTxError{Code: http.StatusBadRequest, Err: err, Message: err.Error()}
+	}
+
+	if p.VenueUuid != nil {
+		venuePermissions, err := h.GetUserEffectiveVenuePermissionsTx(gc, tx, userUuid, *p.VenueUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !venuePermissions.Has(app.UserPermChooseVenue) {
+			return ApiErrForbidden("")
+		}
+
+		if p.SpaceUuid != nil {
+			spaceOK, err := h.IsSpaceInVenueTx(gc, tx, *p.SpaceUuid, *p.VenueUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if !spaceOK {
+				return &ApiTxError{
+					Code:    http.StatusBadRequest,
+					Err:     errors.New("invalid venue/space combination"),
+					Message: "invalid venue/space combination",
+				}
+			}
+		}
+	}
+
+	formatDates := func(dates []time.Time) []string {
+		result := make([]string, len(dates))
+		for i, d := range dates {
+			result[i] = d.Format("2006-01-02")
+		}
+		return result
+	}
+
+	_, err = tx.Exec(ctx, app.UranusInstance.SqlAdminUpsertEventRecurrence,
+		eventUuid,
+		parsed.Rule.String(),
+		formatDates(parsed.RDates),
+		formatDates(parsed.ExDates),
+		p.StartDate,
+		p.StartTime,
+		p.EndTime,
+		p.EntryTime,
+		p.AllDay,
+		p.Duration,
+		p.VenueUuid,
+		p.SpaceUuid,
+		userUuid,
+	)
+	if err != nil {
+		return TxInternalError(fmt.Errorf("upsert event recurrence failed: %w", err))
+	}
+
+	return nil
+}
+
+// rowQuerier is implemented by pgx.Tx and pgxpool.Pool.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// loadEventRecurrence returns the recurrence of an event, or nil if the
+// event is not recurring.
+func loadEventRecurrence(ctx context.Context, db rowQuerier, eventUuid string) (*model.EventRecurrence, error) {
+	var rec model.EventRecurrence
+	err := db.QueryRow(ctx, app.UranusInstance.SqlAdminGetEventRecurrence, eventUuid).Scan(
+		&rec.EventUuid,
+		&rec.Rule,
+		&rec.RDates,
+		&rec.ExDates,
+		&rec.StartDate,
+		&rec.StartTime,
+		&rec.EndTime,
+		&rec.EntryTime,
+		&rec.AllDay,
+		&rec.Duration,
+		&rec.VenueUuid,
+		&rec.SpaceUuid,
+		&rec.MaterializedUntil,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// materializeEventRecurrenceTx generates the event dates of a recurring event
+// from today up to the rolling horizon (Config.RecurrenceHorizonDays).
+// Previously generated future dates are updated, cancelled or restored to
+// match the rule, past dates and explicitly entered dates are never touched.
+// The recurrence row is locked, so concurrent calls for an event are
+// serialized.
+// userUuid may be empty, e.g. when called from the background worker.
+// Returns false if the event has no recurrence.
+func (h *ApiHandler) materializeEventRecurrenceTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	eventUuid string,
+	userUuid string,
+) (bool, error) {
+	query := fmt.Sprintf(`SELECT 1 FROM %s.event_recurrence WHERE event_uuid = $1::uuid FOR UPDATE`, h.DbSchema)
+	var exists int
+	if err := tx.QueryRow(ctx, query, eventUuid).Scan(&exists); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	rec, err := loadEventRecurrence(ctx, tx, eventUuid)
+	if err != nil {
+		return false, err
+	}
+	if rec == nil {
+		return false, nil
+	}
+
+	parsed, err := parseEventRecurrence(rec.EventRecurrencePayload)
+	if err != nil {
+		return true, err
+	}
+
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	horizon := today.AddDate(0, 0, h.recurrenceHorizonDays())
+
+	occurrences := parsed.Rule.Occurrences(parsed.DtStart, today, horizon, parsed.RDates, parsed.ExDates)
+
+	// Existing future dates of this event. Generated dates are keyed by day,
+	// explicit dates by day and start time, so that only an explicit date at
+	// the time of the series replaces an occurrence
+
+	type generatedDate struct {
+		Uuid      string
+		Cancelled bool
+	}
+
+	query = fmt.Sprintf(`
+		SELECT uuid, TO_CHAR(start_date, 'YYYY-MM-DD'), COALESCE(TO_CHAR(start_time, 'HH24:MI'), ''),
+			recurrence_generated, release_status IS NOT DISTINCT FROM 'cancelled'
+		FROM %s.event_date
+		WHERE event_uuid = $1::uuid AND start_date >= $2::date`,
+		h.DbSchema)
+	rows, err := tx.Query(ctx, query, eventUuid, today)
+	if err != nil {
+		return true, err
+	}
+
+	generated := make(map[string]generatedDate)
+	explicit := make(map[string]struct{})
+	for rows.Next() {
+		var uuid, startDate, startTime string
+		var isGenerated, cancelled bool
+		if err := rows.Scan(&uuid, &startDate, &startTime, &isGenerated, &cancelled); err != nil {
+			rows.Close()
+			return true, err
+		}
+		if isGenerated {
+			generated[startDate] = generatedDate{Uuid: uuid, Cancelled: cancelled}
+		} else {
+			explicit[startDate+" "+startTime] = struct{}{}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return true, err
+	}
+
+	var createdBy *string
+	if userUuid != "" {
+		createdBy = &userUuid
+	}
+
+	wanted := make(map[string]struct{}, len(occurrences))
+	for _, occurrence := range occurrences {
+		startDate := occurrence.Format("2006-01-02")
+
+		// Explicit dates at the time of the series win over occurrences
+		if _, ok := explicit[startDate+" "+rec.StartTime]; ok {
+			continue
+		}
+
+		wanted[startDate] = struct{}{}
+		endDate := recurrenceEndDate(occurrence, rec.StartTime, rec.EndTime)
+
+		if e, ok := generated[startDate]; ok {
+			_, err = tx.Exec(ctx, app.UranusInstance.SqlAdminUpdateRecurrenceEventDate,
+				e.Uuid,
+				rec.VenueUuid,
+				rec.SpaceUuid,
+				rec.StartTime,
+				endDate,
+				rec.EndTime,
+				rec.EntryTime,
+				rec.Duration,
+				rec.AllDay,
+				createdBy,
+			)
+			if err != nil {
+				return true, fmt.Errorf("update generated event date failed: %w", err)
+			}
+			// A date cancelled because the rule dropped its day is restored
+			if e.Cancelled {
+				_, err = h.notifyFavoriteEventDateTx(ctx, tx, userUuid, eventUuid, e.Uuid, "inherited", nil, nil)
+				if err != nil {
+					return true, err
+				}
+			}
+			continue
+		}
+
+		eventDateUuid, err := grains_uuid.Uuidv7String()
+		if err != nil {
+			return true, fmt.Errorf("failed to generate uuid: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, app.UranusInstance.SqlAdminInsertRecurrenceEventDate,
+			eventDateUuid,
+			eventUuid,
+			rec.VenueUuid,
+			rec.SpaceUuid,
+			startDate,
+			rec.StartTime,
+			endDate,
+			rec.EndTime,
+			rec.EntryTime,
+			rec.Duration,
+			rec.AllDay,
+			createdBy,
+		)
+		if err != nil {
+			return true, fmt.Errorf("insert generated event date failed: %w", err)
+		}
+	}
+
+	// Cancel generated future dates no longer produced by the rule, tickets,
+	// registrations and shared links of these dates stay valid
+
+	var obsolete []string
+	for startDate, e := range generated {
+		if _, ok := wanted[startDate]; !ok && !e.Cancelled {
+			obsolete = append(obsolete, e.Uuid)
+		}
+	}
+	if _, err := h.cancelEventDatesTx(ctx, tx, userUuid, eventUuid, obsolete); err != nil {
+		return true, fmt.Errorf("cancel obsolete event dates failed: %w", err)
+	}
+
+	query = fmt.Sprintf(
+		`UPDATE %s.event_recurrence SET materialized_until = $2::date WHERE event_uuid = $1::uuid`,
+		h.DbSchema)
+	if _, err := tx.Exec(ctx, query, eventUuid, horizon); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// deleteEventRecurrenceTx removes the recurrence of an event and cancels all
+// of its generated future dates.
+func (h *ApiHandler) deleteEventRecurrenceTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	eventUuid string,
+	userUuid string,
+) (bool, error) {
+	query := fmt.Sprintf(`DELETE FROM %s.event_recurrence WHERE event_uuid = $1::uuid`, h.DbSchema)
+	tag, err := tx.Exec(ctx, query, eventUuid)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	query = fmt.Sprintf(`
+		SELECT uuid
+		FROM %s.event_date
+		WHERE event_uuid = $1::uuid AND recurrence_generated AND start_date >= CURRENT_DATE`,
+		h.DbSchema)
+	eventDateUuids, err := queryUuidsTx(ctx, tx, query, eventUuid)
+	if err != nil {
+		return true, err
+	}
+	if _, err := h.cancelEventDatesTx(ctx, tx, userUuid, eventUuid, eventDateUuids); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// MaterializeEventRecurrences extends the generated dates of all recurring
+// events whose materialized range ends before the rolling horizon. Each
+// event is materialized by the instance holding its advisory lock, others
+// skip it.
+func (h *ApiHandler) MaterializeEventRecurrences(ctx context.Context) error {
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	horizon := today.AddDate(0, 0, h.recurrenceHorizonDays())
+
+	query := fmt.Sprintf(`
+		SELECT event_uuid
+		FROM %s.event_recurrence
+		WHERE materialized_until IS NULL OR materialized_until < $1::date`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, horizon)
+	if err != nil {
+		return err
+	}
+
+	var eventUuids []string
+	for rows.Next() {
+		var eventUuid string
+		if err := rows.Scan(&eventUuid); err != nil {
+			rows.Close()
+			return err
+		}
+		eventUuids = append(eventUuids, eventUuid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, eventUuid := range eventUuids {
+		txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+			locked, err := tryAdvisoryXactLockTx(ctx, tx, eventRecurrenceLockName+":"+eventUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if !locked {
+				return nil
+			}
+
+			_, err = h.materializeEventRecurrenceTx(ctx, tx, eventUuid, "")
+			if err != nil {
+				return TxInternalError(err)
+			}
+
+			err = RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
+			if err != nil {
+				return TxInternalError(err)
+			}
+
+			return nil
+		})
+		if txErr != nil {
+			debugf("materialize recurrence of event %s failed: %v", eventUuid, txErr)
+		}
+	}
+
+	return nil
+}
+
+// RunEventRecurrenceWorker calls MaterializeEventRecurrences once at start
+// and then in the given interval until ctx is cancelled.
+func (h *ApiHandler) RunEventRecurrenceWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.MaterializeEventRecurrences(ctx); err != nil {
+			debugf("materialize event recurrences failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *ApiHandler) recurrenceHorizonDays() int {
+	if h.Config.RecurrenceHorizonDays > 0 {
+		return h.Config.RecurrenceHorizonDays
+	}
+	return 365
+}
+
+// recurrenceEndDate returns the end date of a generated date. Events ending
+// before they start are assumed to end after midnight on the following day.
+func recurrenceEndDate(date time.Time, startTime string, endTime *string) *string {
+	if endTime == nil || *endTime == "" {
+		return nil
+	}
+
+	endDate := date
+	if *endTime < startTime {
+		endDate = date.AddDate(0, 0, 1)
+	}
+
+	result := endDate.Format("2006-01-02")
+	return &result
+}
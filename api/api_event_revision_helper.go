@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/model"
+)
+
+// eventRevisionKeepFields are snapshot fields which are not restored as
+// event columns. Types and links are restored separately. The release state
+// is changed by its own endpoint, dates and recurrence have their own
+// lifecycle and are part of the snapshot for the diff only.
+var eventRevisionKeepFields = []string{
+	"org_uuid",
+	"release_status",
+	"release_date",
+	"released_at",
+	"archived_at",
+	"external_id",
+	"types",
+	"links",
+	"dates",
+	"recurrence",
+}
+
+// loadEventSnapshotTx returns the current state of an event as stored in a
+// revision and locks the event row, so that changes of the same event are
+// recorded one after another. Returns nil if the event does not exist.
+func (h *ApiHandler) loadEventSnapshotTx(ctx context.Context, tx pgx.Tx, eventUuid string) (map[string]any, error) {
+	query := fmt.Sprintf(`
+		SELECT to_jsonb(e)
+			- '{uuid,created_at,created_by,modified_at,modified_by,search_vector,sync_hash}'::text[]
+			|| jsonb_build_object(
+				'types', COALESCE((
+					SELECT jsonb_agg(jsonb_build_array(etl.type_id, etl.genre_id) ORDER BY etl.type_id, etl.genre_id)
+					FROM %[1]s.event_type_link etl
+					WHERE etl.event_uuid = e.uuid
+				), '[]'::jsonb),
+				'links', COALESCE((
+					SELECT jsonb_agg(jsonb_build_object('label', l.label, 'type', l.type, 'url', l.url) ORDER BY l.type, l.url)
+					FROM %[1]s.event_link l
+					WHERE l.event_uuid = e.uuid
+				), '[]'::jsonb),
+				'dates', COALESCE((
+					SELECT jsonb_agg(jsonb_build_object(
+						'uuid', ed.uuid,
+						'start_date', ed.start_date,
+						'start_time', ed.start_time,
+						'end_date', ed.end_date,
+						'end_time', ed.end_time,
+						'venue_uuid', ed.venue_uuid,
+						'space_uuid', ed.space_uuid,
+						'release_status', ed.release_status,
+						'status_reason', ed.status_reason,
+						'rescheduled_to_uuid', ed.rescheduled_to_uuid
+					) ORDER BY ed.start_date, ed.start_time, ed.uuid)
+					FROM %[1]s.event_date ed
+					WHERE ed.event_uuid = e.uuid AND NOT ed.recurrence_generated
+				), '[]'::jsonb),
+				'recurrence', (
+					SELECT jsonb_build_object('rrule', r.rrule, 'rdates', r.rdates, 'exdates', r.exdates)
+					FROM %[1]s.event_recurrence r
+					WHERE r.event_uuid = e.uuid
+				)
+			)
+		FROM %[1]s.event e
+		WHERE e.uuid = $1::uuid
+		FOR UPDATE OF e`,
+		h.DbSchema)
+
+	var snapshot map[string]any
+	err := tx.QueryRow(ctx, query, eventUuid).Scan(&snapshot)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// recordEventRevisionTx stores a revision of an event after a change, given
+// the snapshot from before the change. Nothing is stored if the event did not
+// change. The first recorded change of an event, which existed before
+// revisions were kept, additionally stores its previous state as baseline.
+func (h *ApiHandler) recordEventRevisionTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	eventUuid string,
+	userUuid string,
+	action string,
+	before map[string]any,
+) error {
+	return h.insertEventRevisionTx(ctx, tx, eventUuid, userUuid, action, before, nil)
+}
+
+func (h *ApiHandler) insertEventRevisionTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	eventUuid string,
+	userUuid string,
+	action string,
+	before map[string]any,
+	restoredFrom *int,
+) error {
+	after, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+	if err != nil || after == nil {
+		return err
+	}
+
+	diff := diffEventSnapshots(before, after)
+	if len(diff) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(
+		`SELECT COALESCE(MAX(revision), 0) FROM %s.event_revision WHERE event_uuid = $1::uuid`,
+		h.DbSchema)
+	var revision int
+	err = tx.QueryRow(ctx, query, eventUuid).Scan(&revision)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s.event_revision (event_uuid, revision, action, user_uuid, snapshot, diff, restored_from)
+		VALUES ($1::uuid, $2, $3, NULLIF($4, '')::uuid, $5, $6, $7)`,
+		h.DbSchema)
+
+	if revision == 0 && before != nil {
+		revision++
+		_, err = tx.Exec(ctx, insertQuery, eventUuid, revision, "baseline", "", before, nil, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	revision++
+	_, err = tx.Exec(ctx, insertQuery, eventUuid, revision, action, userUuid, after, diff, restoredFrom)
+	if err != nil {
+		return err
+	}
+
+	// Tell other editors of the organization, who may have the event open
+	query = fmt.Sprintf(`SELECT org_uuid::text FROM %s.event WHERE uuid = $1::uuid`, h.DbSchema)
+	var orgUuid *string
+	err = tx.QueryRow(ctx, query, eventUuid).Scan(&orgUuid)
+	if err != nil || orgUuid == nil {
+		return err
+	}
+	return h.publishLiveEventTx(ctx, tx, "", *orgUuid, liveEventEventModified, map[string]any{
+		"event_uuid": eventUuid,
+		"revision":   revision,
+		"action":     action,
+		"user_uuid":  userUuid,
+		"fields":     sortedDiffFields(diff),
+	}, userUuid)
+}
+
+// diffEventSnapshots returns the changed fields between two snapshots. A nil
+// snapshot counts as empty, so all fields of a new event are reported.
+func diffEventSnapshots(before, after map[string]any) map[string]model.EventRevisionChange {
+	diff := make(map[string]model.EventRevisionChange)
+	for key, newValue := range after {
+		oldValue := before[key]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			diff[key] = model.EventRevisionChange{Old: oldValue, New: newValue}
+		}
+	}
+	for key, oldValue := range before {
+		if _, ok := after[key]; !ok && oldValue != nil {
+			diff[key] = model.EventRevisionChange{Old: oldValue, New: nil}
+		}
+	}
+	return diff
+}
+
+func sortedDiffFields(diff map[string]model.EventRevisionChange) []string {
+	return slices.Sorted(maps.Keys(diff))
+}
+
+// loadEventRevisionSnapshotTx returns the snapshot of a revision, nil if the
+// revision does not exist
+func (h *ApiHandler) loadEventRevisionSnapshotTx(ctx context.Context, tx pgx.Tx, eventUuid string, revision int) (map[string]any, error) {
+	query := fmt.Sprintf(
+		`SELECT snapshot FROM %s.event_revision WHERE event_uuid = $1::uuid AND revision = $2`,
+		h.DbSchema)
+	var snapshot map[string]any
+	err := tx.QueryRow(ctx, query, eventUuid, revision).Scan(&snapshot)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// restoreEventSnapshotTx writes the fields of a snapshot back to the event,
+// its type links and its links. current is the snapshot of the event before. Only
+// fields present in both are written, so columns added or removed since the
+// revision are left alone.
+func (h *ApiHandler) restoreEventSnapshotTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	eventUuid string,
+	snapshot map[string]any,
+	current map[string]any,
+) error {
+	var columns []string
+	for _, key := range slices.Sorted(maps.Keys(current)) {
+		if slices.Contains(eventRevisionKeepFields, key) {
+			continue
+		}
+		if _, ok := snapshot[key]; !ok {
+			continue
+		}
+		columns = append(columns, pgx.Identifier{key}.Sanitize())
+	}
+
+	if len(columns) > 0 {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = "r." + column
+		}
+		query := fmt.Sprintf(`
+			UPDATE %[1]s.event e
+			SET (%[2]s) = (
+				SELECT %[3]s
+				FROM jsonb_populate_record(NULL::%[1]s.event, $2::jsonb) r
+			)
+			WHERE e.uuid = $1::uuid`,
+			h.DbSchema, strings.Join(columns, ", "), strings.Join(values, ", "))
+		_, err := tx.Exec(ctx, query, eventUuid, snapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	if types, ok := snapshot["types"].([]any); ok {
+		query := fmt.Sprintf(`DELETE FROM %s.event_type_link WHERE event_uuid = $1::uuid`, h.DbSchema)
+		_, err := tx.Exec(ctx, query, eventUuid)
+		if err != nil {
+			return err
+		}
+
+		query = fmt.Sprintf(`INSERT INTO %s.event_type_link (event_uuid, type_id, genre_id) VALUES ($1::uuid, $2, $3)`, h.DbSchema)
+		for _, t := range types {
+			pair, ok := t.([]any)
+			if !ok || len(pair) != 2 {
+				continue
+			}
+			typeId, ok := pair[0].(float64)
+			if !ok {
+				continue
+			}
+			genreId, _ := pair[1].(float64)
+			_, err = tx.Exec(ctx, query, eventUuid, int(typeId), int(genreId))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if links, ok := snapshot["links"].([]any); ok {
+		query := fmt.Sprintf(`DELETE FROM %s.event_link WHERE event_uuid = $1::uuid`, h.DbSchema)
+		_, err := tx.Exec(ctx, query, eventUuid)
+		if err != nil {
+			return err
+		}
+
+		query = fmt.Sprintf(`INSERT INTO %s.event_link (event_uuid, label, type, url) VALUES ($1::uuid, $2, $3, $4)`, h.DbSchema)
+		for _, l := range links {
+			link, ok := l.(map[string]any)
+			if !ok {
+				continue
+			}
+			_, err = tx.Exec(ctx, query, eventUuid, link["label"], link["type"], link["url"])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -16,11 +16,17 @@ import (
 // TODO: Review code
 
 type ApiHandler struct {
-	Config        *app.Config
-	DbPool        *pgxpool.Pool
-	DbSchema      string
-	EventTemplate *template.Template
-	Accessibility *service.AccessibilityLookup
+	Config         *app.Config
+	DbPool         *pgxpool.Pool
+	DbSchema       string
+	EventTemplate  *template.Template
+	Accessibility  *service.AccessibilityLookup
+	RateLimits     RateLimitStore
+	EmailTransport EmailTransport
+	LiveEvents     *LiveEventHub
+	WebPush        *WebPushSender
+
+	apiKeyRoutes map[string]bool // "METHOD /full/path" of routes accepting API keys
 }
 
 type ApiTxError struct {
@@ -0,0 +1,516 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// icsEvent holds the data of a single VEVENT
+type icsEvent struct {
+	Uid             string
+	EventUuid       string
+	DateUuid        string
+	StartDate       string
+	StartTime       string
+	EndDate         string
+	EndTime         string
+	AllDay          bool
+	Title           string
+	Description     string
+	Location        string
+	Url             string
+	OrgName         string
+	OrgContactEmail string
+	ReleaseStatus   string
+	StatusReason    string
+	RescheduledTo   string // uuid of the date an event date was moved to
+	RescheduledUrl  string
+	Availability    *int // availability_status_id of the date
+	ModifiedAt      *time.Time
+	Recurrence      *model.EventRecurrence
+}
+
+// icsWriter builds an iCalendar document in a given time zone
+type icsWriter struct {
+	b        strings.Builder
+	location *time.Location
+	tzid     string
+	dtStamp  string
+}
+
+func newICSWriter(timezone string) *icsWriter {
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		debugf("ics: unknown timezone %q, using UTC", timezone)
+		location = time.UTC
+	}
+
+	return &icsWriter{
+		location: location,
+		tzid:     location.String(),
+		dtStamp:  time.Now().UTC().Format("20060102T150405Z"),
+	}
+}
+
+// Begin writes the calendar header and the VTIMEZONE block. An empty name
+// omits the X-WR-CALNAME property.
+func (w *icsWriter) Begin(name string) {
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Uranus//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if name != "" {
+		w.line("X-WR-CALNAME:" + escapeICSText(name))
+	}
+	if w.location != time.UTC {
+		w.line("X-WR-TIMEZONE:" + w.tzid)
+		w.writeTimezone(time.Now().In(w.location).Year())
+	}
+}
+
+// End closes the calendar and returns the document
+func (w *icsWriter) End() string {
+	w.line("END:VCALENDAR")
+	return w.b.String()
+}
+
+// WriteEvent writes a VEVENT. Dates are given in local time of the writer's
+// time zone, an event without end defaults to one hour after the start.
+func (w *icsWriter) WriteEvent(e icsEvent) {
+	if e.StartDate == "" {
+		return
+	}
+
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + e.Uid)
+	w.line("DTSTAMP:" + w.dtStamp)
+
+	if e.AllDay {
+		start, err := time.Parse("2006-01-02", e.StartDate)
+		if err != nil {
+			debugf("ics: invalid start date %q", e.StartDate)
+			start = time.Now()
+		}
+		end := start.AddDate(0, 0, 1)
+		if e.EndDate != "" {
+			if t, err := time.Parse("2006-01-02", e.EndDate); err == nil && t.After(start) {
+				end = t.AddDate(0, 0, 1)
+			}
+		}
+		w.line("DTSTART;VALUE=DATE:" + start.Format("20060102"))
+		w.line("DTEND;VALUE=DATE:" + end.Format("20060102"))
+	} else {
+		startTime := e.StartTime
+		endDate := e.EndDate
+		endTime := e.EndTime
+
+		// If no end date is supplied, assume the event ends on the start date.
+		// If start and end are on the same date and the end time is earlier than
+		// the start time, assume the event ends after midnight on the following day.
+		if endTime != "" {
+			if endDate == "" {
+				endDate = e.StartDate
+			}
+			if endDate == e.StartDate && startTime != "" && endTime < startTime {
+				if t, err := time.Parse("2006-01-02", endDate); err == nil {
+					endDate = t.AddDate(0, 0, 1).Format("2006-01-02")
+				}
+			}
+		}
+
+		dtStart := formatICSDatetime(e.StartDate, startTime)
+		dtEnd := formatICSDatetime(endDate, endTime)
+		if endTime == "" || dtEnd == "" {
+			// No explicit end time: default to one hour after the start.
+			if start, err := time.Parse("20060102T150405", dtStart); err == nil {
+				dtEnd = start.Add(time.Hour).Format("20060102T150405")
+			}
+		}
+
+		w.line("DTSTART" + w.tzParam() + ":" + dtStart)
+		if dtEnd != "" {
+			w.line("DTEND" + w.tzParam() + ":" + dtEnd)
+		}
+	}
+
+	if e.Recurrence != nil {
+		w.writeRecurrence(e.Recurrence, e.AllDay)
+	}
+
+	if e.ModifiedAt != nil {
+		w.line("LAST-MODIFIED:" + e.ModifiedAt.UTC().Format("20060102T150405Z"))
+		// Unix seconds grow with every modification, which is all
+		// calendar clients need to replace an older copy
+		w.line("SEQUENCE:" + strconv.FormatInt(e.ModifiedAt.Unix(), 10))
+	}
+
+	// A date moved to another date does not take place at this time. The
+	// status of a series is left out, its dropped dates are EXDATEs
+	if e.Recurrence == nil {
+		status := icsStatus(e.ReleaseStatus)
+		if e.RescheduledTo != "" {
+			status = "CANCELLED"
+		}
+		w.line("STATUS:" + status)
+	}
+
+	title := e.Title
+	if title == "" {
+		title = "Event"
+	}
+	w.line("SUMMARY:" + escapeICSText(title))
+
+	// Not all calendar clients show STATUS, the note makes it visible
+	description := e.Description
+	if note := icsStatusNote(e); note != "" {
+		description = strings.TrimSpace(note + "\n\n" + description)
+	}
+	if description != "" {
+		w.line("DESCRIPTION:" + escapeICSText(description))
+	}
+	if e.Location != "" {
+		w.line("LOCATION:" + escapeICSText(e.Location))
+	}
+	if e.Url != "" {
+		w.line("URL:" + e.Url)
+	}
+	if e.OrgContactEmail != "" {
+		w.line("ORGANIZER;CN=" + quoteICSParam(e.OrgName) + ":mailto:" + e.OrgContactEmail)
+	}
+
+	w.line("END:VEVENT")
+}
+
+// writeRecurrence writes the RRULE, RDATE and EXDATE properties of a
+// recurring event. UNTIL and the dates use the value type of DTSTART, DATE
+// for all day events and DATE-TIME otherwise.
+func (w *icsWriter) writeRecurrence(rec *model.EventRecurrence, allDay bool) {
+	rule := strings.TrimPrefix(rec.Rule, "RRULE:")
+	if r, err := app.ParseRecurrenceRule(rule); err == nil {
+		rule = r.String()
+		if r.Until != nil && !allDay {
+			rule = r.StringWithUntil(w.formatUntil(r.Until.Format("2006-01-02"), rec.StartTime))
+		}
+	}
+	w.line("RRULE:" + rule)
+
+	formatDates := func(dates []string) string {
+		values := make([]string, 0, len(dates))
+		for _, d := range dates {
+			v := ""
+			if allDay {
+				if t, err := time.Parse("2006-01-02", d); err == nil {
+					v = t.Format("20060102")
+				}
+			} else {
+				v = formatICSDatetime(d, rec.StartTime)
+			}
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+		return strings.Join(values, ",")
+	}
+
+	param := w.tzParam()
+	if allDay {
+		param = ";VALUE=DATE"
+	}
+	if rDates := formatDates(rec.RDates); rDates != "" {
+		w.line("RDATE" + param + ":" + rDates)
+	}
+	if exDates := formatDates(rec.ExDates); exDates != "" {
+		w.line("EXDATE" + param + ":" + exDates)
+	}
+}
+
+// formatUntil returns the UNTIL value for a DATE-TIME DTSTART, the start of
+// the last possible occurrence. With a time zone it must be given in UTC,
+// a floating DTSTART requires a floating UNTIL.
+func (w *icsWriter) formatUntil(date string, startTime string) string {
+	local := formatICSDatetime(date, startTime)
+	if local == "" || w.location == time.UTC {
+		return local
+	}
+	t, err := time.ParseInLocation("20060102T150405", local, w.location)
+	if err != nil {
+		return local
+	}
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// loadICSRecurrence returns the recurrence of an event for export, nil if
+// the event is not recurring. Days of dates at the time of the series which
+// were cancelled, postponed or rescheduled are added to the EXDATEs, so the
+// series leaves them out.
+func (h *ApiHandler) loadICSRecurrence(ctx context.Context, db rowQuerier, eventUuid string) (*model.EventRecurrence, error) {
+	rec, err := loadEventRecurrence(ctx, db, eventUuid)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT ARRAY(
+			SELECT DISTINCT TO_CHAR(start_date, 'YYYY-MM-DD')
+			FROM %s.event_date
+			WHERE event_uuid = $1::uuid
+				AND TO_CHAR(start_time, 'HH24:MI') = $2
+				AND release_status IN ('cancelled', 'deferred', 'rescheduled')
+		)`,
+		h.DbSchema)
+	var dropped []string
+	if err := db.QueryRow(ctx, query, eventUuid, rec.StartTime).Scan(&dropped); err != nil {
+		return nil, err
+	}
+
+	for _, d := range dropped {
+		if !slices.Contains(rec.ExDates, d) {
+			rec.ExDates = append(rec.ExDates, d)
+		}
+	}
+	slices.Sort(rec.ExDates)
+
+	return rec, nil
+}
+
+// asICSSeries turns the VEVENT of a generated date into the VEVENT of the
+// whole series. The status of a single date does not apply to the series.
+func (h *ApiHandler) asICSSeries(e icsEvent, eventUuid string, rec *model.EventRecurrence) icsEvent {
+	e.Uid = fmt.Sprintf("%s@%s", eventUuid, h.Config.IcsDomain)
+	e.StartDate = rec.StartDate
+	e.StartTime = rec.StartTime
+	e.EndDate = ""
+	e.EndTime = ""
+	if rec.EndTime != nil {
+		e.EndTime = *rec.EndTime
+	}
+	e.Recurrence = rec
+	e.ReleaseStatus = ""
+	e.StatusReason = ""
+	e.RescheduledTo = ""
+	e.RescheduledUrl = ""
+	e.Availability = nil
+	return e
+}
+
+func (w *icsWriter) tzParam() string {
+	if w.location == time.UTC {
+		return ""
+	}
+	return ";TZID=" + w.tzid
+}
+
+// writeTimezone writes a VTIMEZONE block derived from the Go time zone
+// database. Transitions are detected in the given year and expressed as
+// yearly rules, which covers the usual "n-th/last weekday of month" schemes.
+func (w *icsWriter) writeTimezone(year int) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + w.tzid)
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, w.location)
+	end := start.AddDate(1, 0, 0)
+	_, startOffset := start.Zone()
+
+	var transitions []time.Time
+	prevOffset := startOffset
+	for t := start; t.Before(end); t = t.Add(24 * time.Hour) {
+		next := t.Add(24 * time.Hour)
+		_, offset := next.Zone()
+		if offset != prevOffset {
+			transitions = append(transitions, findZoneTransition(t, next))
+			prevOffset = offset
+		}
+	}
+
+	if len(transitions) == 0 {
+		name, offset := start.Zone()
+		w.line("BEGIN:STANDARD")
+		w.line("DTSTART:19700101T000000")
+		w.line("TZOFFSETFROM:" + formatICSOffset(offset))
+		w.line("TZOFFSETTO:" + formatICSOffset(offset))
+		w.line("TZNAME:" + name)
+		w.line("END:STANDARD")
+	} else {
+		for _, tr := range transitions {
+			_, offsetFrom := tr.Add(-time.Second).Zone()
+			name, offsetTo := tr.Zone()
+			component := "STANDARD"
+			if offsetTo > offsetFrom {
+				component = "DAYLIGHT"
+			}
+
+			// Local wall clock time at which the transition happens
+			local := tr.UTC().Add(time.Duration(offsetFrom) * time.Second)
+			ordinal := (local.Day()-1)/7 + 1
+			if local.AddDate(0, 0, 7).Month() != local.Month() {
+				ordinal = -1
+			}
+
+			w.line("BEGIN:" + component)
+			w.line("DTSTART:1970" + local.Format("0102T150405"))
+			w.line(fmt.Sprintf("RRULE:FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s",
+				int(local.Month()), ordinal, weekdayICSCode(local.Weekday())))
+			w.line("TZOFFSETFROM:" + formatICSOffset(offsetFrom))
+			w.line("TZOFFSETTO:" + formatICSOffset(offsetTo))
+			w.line("TZNAME:" + name)
+			w.line("END:" + component)
+		}
+	}
+
+	w.line("END:VTIMEZONE")
+}
+
+// line writes a content line, folded at 75 octets as required by RFC 5545
+func (w *icsWriter) line(s string) {
+	const maxLen = 75
+	first := true
+	for len(s) > 0 {
+		limit := maxLen
+		if !first {
+			limit-- // leading space of continuation lines
+		}
+		if len(s) <= limit {
+			if !first {
+				w.b.WriteString(" ")
+			}
+			w.b.WriteString(s)
+			break
+		}
+
+		// Do not split multi byte UTF-8 sequences
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		if !first {
+			w.b.WriteString(" ")
+		}
+		w.b.WriteString(s[:cut])
+		w.b.WriteString("\r\n")
+		s = s[cut:]
+		first = false
+	}
+	w.b.WriteString("\r\n")
+}
+
+// findZoneTransition returns the first second in (from, to] with a
+// different UTC offset than from
+func findZoneTransition(from, to time.Time) time.Time {
+	_, fromOffset := from.Zone()
+	for to.Sub(from) > time.Second {
+		mid := from.Add(to.Sub(from) / 2)
+		if _, offset := mid.Zone(); offset == fromOffset {
+			from = mid
+		} else {
+			to = mid
+		}
+	}
+	return to
+}
+
+func formatICSOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, (seconds%3600)/60)
+}
+
+func weekdayICSCode(d time.Weekday) string {
+	return [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}[d]
+}
+
+// icsStatus maps an event release status to the VEVENT STATUS property
+func icsStatus(releaseStatus string) string {
+	switch releaseStatus {
+	case "cancelled":
+		return "CANCELLED"
+	case "deferred":
+		return "TENTATIVE"
+	default:
+		return "CONFIRMED"
+	}
+}
+
+// icsStatusNote describes a cancelled, postponed or rescheduled date
+func icsStatusNote(e icsEvent) string {
+	var note string
+	switch {
+	case e.RescheduledTo != "":
+		note = "Rescheduled"
+	case e.ReleaseStatus == "cancelled":
+		note = "Cancelled"
+	case e.ReleaseStatus == "deferred":
+		note = "Postponed"
+	case e.ReleaseStatus == "rescheduled":
+		note = "Rescheduled"
+	default:
+		return icsAvailabilityNote(e.Availability)
+	}
+	if e.StatusReason != "" {
+		note += ": " + e.StatusReason
+	}
+	if e.RescheduledUrl != "" {
+		note += "\nNew date: " + e.RescheduledUrl
+	}
+	return note
+}
+
+// icsAvailabilityNote returns a note for event dates which are booked up or
+// almost, "" otherwise
+func icsAvailabilityNote(statusId *int) string {
+	if statusId == nil {
+		return ""
+	}
+	switch *statusId {
+	case availabilityStatusFewLeft:
+		return "Few seats left"
+	case availabilityStatusSoldOut:
+		return "Sold out"
+	case availabilityStatusWaitlist:
+		return "Sold out, waiting list available"
+	}
+	return ""
+}
+
+// quoteICSParam quotes a parameter value if it contains characters which are
+// not allowed in unquoted parameter values
+func quoteICSParam(value string) string {
+	value = strings.ReplaceAll(value, `"`, "'")
+	if strings.ContainsAny(value, ",;:") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// icsLocation joins the non-empty parts of a venue address
+func icsLocation(name, street, houseNumber, postalCode, city *string) string {
+	str := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return strings.TrimSpace(*p)
+	}
+
+	parts := []string{}
+	if v := str(name); v != "" {
+		parts = append(parts, v)
+	}
+	if v := strings.TrimSpace(str(street) + " " + str(houseNumber)); v != "" {
+		parts = append(parts, v)
+	}
+	if v := strings.TrimSpace(str(postalCode) + " " + str(city)); v != "" {
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,355 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sndcds/uranus/model"
+)
+
+// BuildEventJsonLD builds a schema.org Event object for the event and its
+// selected date. The result is meant to be embedded as application/ld+json
+// or returned as part of the event details.
+func (h *ApiHandler) BuildEventJsonLD(event model.EventDetails, eventUrl string) map[string]any {
+	ld := map[string]any{
+		"@context": "https://schema.org",
+		"@type":    "Event",
+		"name":     event.Title,
+	}
+
+	if description := firstNonEmpty(deref(event.Description), deref(event.Summary), deref(event.Subtitle)); description != "" {
+		ld["description"] = description
+	}
+	if eventUrl != "" {
+		ld["url"] = eventUrl
+	}
+	if event.ContentLanguage != nil && *event.ContentLanguage != "" {
+		ld["inLanguage"] = *event.ContentLanguage
+	}
+	if len(event.Tags) > 0 {
+		ld["keywords"] = strings.Join(event.Tags, ", ")
+	}
+	if event.MinAge != nil || event.MaxAge != nil {
+		audience := map[string]any{"@type": "PeopleAudience"}
+		if event.MinAge != nil {
+			audience["suggestedMinAge"] = *event.MinAge
+		}
+		if event.MaxAge != nil {
+			audience["suggestedMaxAge"] = *event.MaxAge
+		}
+		ld["audience"] = audience
+	}
+	if event.MaxAttendees != nil && *event.MaxAttendees > 0 {
+		ld["maximumAttendeeCapacity"] = *event.MaxAttendees
+	}
+
+	releaseStatus := deref(event.ReleaseStatus)
+	date := event.Date
+	if date != nil {
+		if date.EventReleaseStatus != "" {
+			releaseStatus = date.EventReleaseStatus
+		}
+
+		startTime, err := combineDateTime(date.StartDate, date.StartTime, h.Config.IcsTimezone)
+		if err != nil {
+			debugf(err.Error())
+		}
+		if startTime != nil {
+			if date.StartTime == "" {
+				ld["startDate"] = date.StartDate
+			} else {
+				ld["startDate"] = startTime.Format(time.RFC3339)
+			}
+		}
+
+		if date.EndDate != nil || date.EndTime != nil {
+			endDate := date.StartDate
+			if date.EndDate != nil {
+				endDate = *date.EndDate
+			}
+			if date.EndTime == nil {
+				ld["endDate"] = endDate
+			} else if endTime, err := combineDateTime(endDate, *date.EndTime, h.Config.IcsTimezone); err == nil && endTime != nil {
+				// Events ending after midnight without explicit end date
+				if startTime != nil && endTime.Before(*startTime) {
+					t := endTime.AddDate(0, 0, 1)
+					endTime = &t
+				}
+				ld["endDate"] = endTime.Format(time.RFC3339)
+			}
+		}
+
+		if date.EntryTime != nil && *date.EntryTime != "" {
+			if doorTime, err := combineDateTime(date.StartDate, *date.EntryTime, h.Config.IcsTimezone); err == nil && doorTime != nil {
+				ld["doorTime"] = doorTime.Format(time.RFC3339)
+			}
+		}
+	}
+
+	ld["eventStatus"] = jsonLDEventStatus(releaseStatus)
+
+	// A date moved to another date, or the new date of a moved one
+	if date != nil && releaseStatus != "cancelled" && (date.RescheduledToUuid != nil || date.RescheduledFromUuid != nil) {
+		ld["eventStatus"] = jsonLDEventStatus("rescheduled")
+		if date.RescheduledFromUuid != nil {
+			for _, d := range event.FurtherDates {
+				if d.Uuid != *date.RescheduledFromUuid {
+					continue
+				}
+				if t, err := combineDateTime(d.StartDate, d.StartTime, h.Config.IcsTimezone); err == nil && t != nil {
+					ld["previousStartDate"] = t.Format(time.RFC3339)
+				}
+				break
+			}
+		}
+	}
+
+	// Attendance mode and location
+
+	var place map[string]any
+	if date != nil && date.VenueName != nil {
+		place = jsonLDPlace(date)
+	}
+
+	onlineLink := deref(event.OnlineLink)
+	var locations []any
+	if place != nil {
+		locations = append(locations, place)
+	}
+	if onlineLink != "" {
+		locations = append(locations, map[string]any{
+			"@type": "VirtualLocation",
+			"url":   onlineLink,
+		})
+	}
+
+	switch {
+	case place != nil && onlineLink != "":
+		ld["eventAttendanceMode"] = "https://schema.org/MixedEventAttendanceMode"
+	case onlineLink != "":
+		ld["eventAttendanceMode"] = "https://schema.org/OnlineEventAttendanceMode"
+	default:
+		ld["eventAttendanceMode"] = "https://schema.org/OfflineEventAttendanceMode"
+	}
+
+	switch len(locations) {
+	case 0:
+	case 1:
+		ld["location"] = locations[0]
+	default:
+		ld["location"] = locations
+	}
+
+	// Organizer
+
+	if event.OrgName != "" {
+		organizer := map[string]any{
+			"@type": "Organization",
+			"name":  event.OrgName,
+		}
+		if event.OrgWebLink != nil && *event.OrgWebLink != "" {
+			organizer["url"] = *event.OrgWebLink
+		}
+		ld["organizer"] = organizer
+	}
+
+	// Price and tickets
+
+	priceType := deref(event.PriceType)
+	if priceType == string(model.Free) {
+		ld["isAccessibleForFree"] = true
+	} else if priceType == string(model.RegularPrice) || priceType == string(model.TieredPrices) || priceType == string(model.Donation) {
+		ld["isAccessibleForFree"] = false
+	}
+
+	if offers := jsonLDOffers(event, eventUrl); offers != nil {
+		ld["offers"] = offers
+	}
+
+	// Images
+
+	var images []any
+	for _, identifier := range []string{"main", "gallery1", "gallery2", "gallery3"} {
+		image, ok := event.Images[identifier]
+		if !ok || image.Url == "" {
+			continue
+		}
+		images = append(images, jsonLDImage(image))
+	}
+	if len(images) > 0 {
+		ld["image"] = images
+	}
+
+	return ld
+}
+
+// jsonLDEventStatus maps a release status to a schema.org EventStatusType
+func jsonLDEventStatus(releaseStatus string) string {
+	switch releaseStatus {
+	case "cancelled":
+		return "https://schema.org/EventCancelled"
+	case "deferred":
+		return "https://schema.org/EventPostponed"
+	case "rescheduled":
+		return "https://schema.org/EventRescheduled"
+	default:
+		return "https://schema.org/EventScheduled"
+	}
+}
+
+// jsonLDPlace returns the venue of an event date as schema.org Place
+func jsonLDPlace(date *model.EventDate) map[string]any {
+	name := deref(date.VenueName)
+	if date.SpaceName != nil && *date.SpaceName != "" {
+		name = name + ", " + *date.SpaceName
+	}
+
+	place := map[string]any{
+		"@type": "Place",
+		"name":  name,
+	}
+
+	address := map[string]any{"@type": "PostalAddress"}
+	if v := strings.TrimSpace(deref(date.VenueStreet) + " " + deref(date.VenueHouseNumber)); v != "" {
+		address["streetAddress"] = v
+	}
+	if v := deref(date.VenuePostalCode); v != "" {
+		address["postalCode"] = v
+	}
+	if v := deref(date.VenueCity); v != "" {
+		address["addressLocality"] = v
+	}
+	if v := deref(date.VenueState); v != "" {
+		address["addressRegion"] = v
+	}
+	if v := deref(date.VenueCountry); v != "" {
+		address["addressCountry"] = v
+	}
+	if len(address) > 1 {
+		place["address"] = address
+	}
+
+	if date.VenueLat != nil && date.VenueLon != nil {
+		place["geo"] = map[string]any{
+			"@type":     "GeoCoordinates",
+			"latitude":  *date.VenueLat,
+			"longitude": *date.VenueLon,
+		}
+	}
+
+	if v := deref(date.VenueWebLink); v != "" {
+		place["url"] = v
+	}
+
+	return place
+}
+
+// jsonLDOffers returns an Offer or AggregateOffer, nil if the event has
+// neither prices nor a ticket link
+func jsonLDOffers(event model.EventDetails, eventUrl string) map[string]any {
+	priceType := deref(event.PriceType)
+	ticketLink := deref(event.TicketLink)
+
+	offerUrl := ticketLink
+	if offerUrl == "" {
+		offerUrl = eventUrl
+	}
+
+	offer := map[string]any{}
+	if offerUrl != "" {
+		offer["url"] = offerUrl
+	}
+	if event.Currency != nil && *event.Currency != "" {
+		offer["priceCurrency"] = *event.Currency
+	}
+
+	switch {
+	case priceType == string(model.Free):
+		offer["@type"] = "Offer"
+		offer["price"] = 0
+		if _, ok := offer["priceCurrency"]; !ok {
+			offer["priceCurrency"] = "EUR"
+		}
+	case event.MinPrice != nil && event.MaxPrice != nil && *event.MaxPrice > *event.MinPrice:
+		offer["@type"] = "AggregateOffer"
+		offer["lowPrice"] = *event.MinPrice
+		offer["highPrice"] = *event.MaxPrice
+	case event.MinPrice != nil:
+		offer["@type"] = "Offer"
+		offer["price"] = *event.MinPrice
+	case event.MaxPrice != nil:
+		offer["@type"] = "Offer"
+		offer["price"] = *event.MaxPrice
+	case ticketLink != "":
+		offer["@type"] = "Offer"
+	default:
+		return nil
+	}
+
+	if event.Date != nil {
+		if availability := availabilitySchemaOrg(event.Date.AvailabilityStatusId); availability != "" {
+			offer["availability"] = availability
+		}
+	}
+
+	return offer
+}
+
+// jsonLDImage returns an image as schema.org ImageObject including creator
+// and license information
+func jsonLDImage(image model.Image) map[string]any {
+	obj := map[string]any{
+		"@type":      "ImageObject",
+		"contentUrl": image.Url,
+		"url":        image.Url,
+	}
+	if v := deref(image.Alt); v != "" {
+		obj["caption"] = v
+	}
+	if image.Width != nil && image.Height != nil {
+		obj["width"] = *image.Width
+		obj["height"] = *image.Height
+	}
+	if v := deref(image.Creator); v != "" {
+		obj["creator"] = map[string]any{
+			"@type": "Person",
+			"name":  v,
+		}
+		obj["creditText"] = v
+	}
+	if v := deref(image.Copyright); v != "" {
+		obj["copyrightNotice"] = v
+	}
+	if v := jsonLDLicenseUrl(deref(image.License)); v != "" {
+		obj["license"] = v
+	} else if v := deref(image.LicenseName); v != "" {
+		obj["license"] = v
+	}
+	return obj
+}
+
+// jsonLDLicenseUrl maps Creative Commons license keys such as "cc-by-sa-4.0"
+// or "cc0-1.0" to the URL of the license deed
+func jsonLDLicenseUrl(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+
+	if strings.HasPrefix(key, "cc0") {
+		return "https://creativecommons.org/publicdomain/zero/1.0/"
+	}
+	if key == "public-domain" || key == "pdm" {
+		return "https://creativecommons.org/publicdomain/mark/1.0/"
+	}
+	if !strings.HasPrefix(key, "cc-") {
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimPrefix(key, "cc-"), "-")
+	if len(parts) < 2 {
+		return ""
+	}
+	version := parts[len(parts)-1]
+	if version == "" || version[0] < '0' || version[0] > '9' {
+		return ""
+	}
+	return "https://creativecommons.org/licenses/" + strings.Join(parts[:len(parts)-1], "-") + "/" + version + "/"
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Types of live events
+const (
+	liveEventNotification  = "notification"
+	liveEventEventModified = "event-modified"
+)
+
+const (
+	liveEventBufferSize     = 64
+	liveEventReplayLimit    = 500
+	liveEventRetention      = 24 * time.Hour
+	liveEventReconnectDelay = 5 * time.Second
+)
+
+// liveEvent is a row of live_event. Events with UserUuid are for this user,
+// events with OrgUuid for the members of the organization.
+type liveEvent struct {
+	Id        int64
+	UserUuid  *string
+	OrgUuid   *string
+	Type      string
+	Payload   json.RawMessage
+	CreatedBy *string
+}
+
+// liveSubscriber is a connected client of the event stream.
+type liveSubscriber struct {
+	userUuid string
+	orgUuids map[string]bool
+	events   chan liveEvent
+}
+
+// wants reports whether an event is for the subscriber. Editors do not get
+// the organization events of their own changes.
+func (s *liveSubscriber) wants(e liveEvent) bool {
+	if e.UserUuid != nil {
+		return *e.UserUuid == s.userUuid
+	}
+	if e.CreatedBy != nil && *e.CreatedBy == s.userUuid {
+		return false
+	}
+	return e.OrgUuid != nil && s.orgUuids[*e.OrgUuid]
+}
+
+// LiveEventHub listens for new live events with LISTEN/NOTIFY and passes
+// them on to the subscribers of this instance.
+type LiveEventHub struct {
+	dbPool   *pgxpool.Pool
+	dbSchema string
+
+	mu          sync.Mutex
+	subscribers map[*liveSubscriber]struct{}
+}
+
+// NewLiveEventHub returns a hub, which starts listening with Run.
+func NewLiveEventHub(dbPool *pgxpool.Pool, dbSchema string) *LiveEventHub {
+	return &LiveEventHub{
+		dbPool:      dbPool,
+		dbSchema:    dbSchema,
+		subscribers: map[*liveSubscriber]struct{}{},
+	}
+}
+
+// Subscribe registers a client of a user, who is a member of orgUuids. The
+// events channel is closed if the client does not keep up, it resumes with
+// replayLiveEvents after reconnecting.
+func (hub *LiveEventHub) Subscribe(userUuid string, orgUuids []string) *liveSubscriber {
+	s := &liveSubscriber{
+		userUuid: userUuid,
+		orgUuids: map[string]bool{},
+		events:   make(chan liveEvent, liveEventBufferSize),
+	}
+	for _, orgUuid := range orgUuids {
+		s.orgUuids[orgUuid] = true
+	}
+
+	hub.mu.Lock()
+	hub.subscribers[s] = struct{}{}
+	hub.mu.Unlock()
+	return s
+}
+
+// Unsubscribe removes a client registered with Subscribe.
+func (hub *LiveEventHub) Unsubscribe(s *liveSubscriber) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.subscribers[s]; ok {
+		delete(hub.subscribers, s)
+		close(s.events)
+	}
+}
+
+func (hub *LiveEventHub) dispatch(e liveEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for s := range hub.subscribers {
+		if !s.wants(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			delete(hub.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+func (hub *LiveEventHub) channel() string {
+	return hub.dbSchema + "_live_event"
+}
+
+// Run listens for live events until ctx is cancelled. After a lost
+// connection it reconnects and passes on the events written in between.
+// Old events are deleted once an hour.
+func (hub *LiveEventHub) Run(ctx context.Context) {
+	var lastId int64
+	query := fmt.Sprintf(`SELECT COALESCE(MAX(id), 0) FROM %s.live_event`, hub.dbSchema)
+	if err := hub.dbPool.QueryRow(ctx, query).Scan(&lastId); err != nil {
+		debugf("live events: %v", err)
+	}
+
+	go hub.runCleanup(ctx)
+
+	for {
+		err := hub.listen(ctx, &lastId)
+		if ctx.Err() != nil {
+			return
+		}
+		debugf("live events: %v, reconnecting", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(liveEventReconnectDelay):
+		}
+	}
+}
+
+func (hub *LiveEventHub) listen(ctx context.Context, lastId *int64) error {
+	conn, err := hub.dbPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{hub.channel()}.Sanitize())
+	if err != nil {
+		return err
+	}
+	// Left over connections must not keep listening in the pool
+	defer func() {
+		_, _ = conn.Exec(context.Background(), "UNLISTEN *")
+	}()
+
+	// Events written while not listening
+	if err := hub.dispatchAfter(ctx, lastId); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseInt(n.Payload, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// Ids of concurrent transactions may commit out of order, so the
+		// announced event is loaded by its id
+		var e liveEvent
+		query := fmt.Sprintf(`
+			SELECT id, user_uuid::text, org_uuid::text, type, payload, created_by::text
+			FROM %s.live_event
+			WHERE id = $1`,
+			hub.dbSchema)
+		err = conn.QueryRow(ctx, query, id).Scan(&e.Id, &e.UserUuid, &e.OrgUuid, &e.Type, &e.Payload, &e.CreatedBy)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return err
+		}
+		hub.dispatch(e)
+		*lastId = max(*lastId, id)
+	}
+}
+
+// dispatchAfter passes on all events after lastId and advances it.
+func (hub *LiveEventHub) dispatchAfter(ctx context.Context, lastId *int64) error {
+	events, err := hub.loadLiveEvents(ctx, *lastId, "", nil)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		hub.dispatch(e)
+		*lastId = e.Id
+	}
+	return nil
+}
+
+// loadLiveEvents returns up to liveEventReplayLimit events after afterId,
+// all of them or, with userUuid set, those for the user or orgUuids.
+func (hub *LiveEventHub) loadLiveEvents(ctx context.Context, afterId int64, userUuid string, orgUuids []string) ([]liveEvent, error) {
+	query := fmt.Sprintf(`
+		SELECT id, user_uuid::text, org_uuid::text, type, payload, created_by::text
+		FROM %s.live_event
+		WHERE id > $1
+			AND ($2 = '' OR user_uuid = NULLIF($2, '')::uuid OR org_uuid = ANY($3::uuid[]))
+		ORDER BY id
+		LIMIT $4`,
+		hub.dbSchema)
+	if orgUuids == nil {
+		orgUuids = []string{}
+	}
+	rows, err := hub.dbPool.Query(ctx, query, afterId, userUuid, orgUuids, liveEventReplayLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []liveEvent
+	for rows.Next() {
+		var e liveEvent
+		if err := rows.Scan(&e.Id, &e.UserUuid, &e.OrgUuid, &e.Type, &e.Payload, &e.CreatedBy); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (hub *LiveEventHub) runCleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		query := fmt.Sprintf(
+			`DELETE FROM %s.live_event WHERE created_at < NOW() - make_interval(secs => $1)`,
+			hub.dbSchema)
+		if _, err := hub.dbPool.Exec(ctx, query, liveEventRetention.Seconds()); err != nil {
+			debugf("delete live events failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishLiveEventTx writes a live event for a user or the members of an
+// organization, it is sent after tx commits.
+func (h *ApiHandler) publishLiveEventTx(ctx context.Context, tx pgx.Tx, userUuid string, orgUuid string, eventType string, payload any, createdBy string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s.live_event (user_uuid, org_uuid, type, payload, created_by)
+		VALUES (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid, $3, $4, NULLIF($5, '')::uuid)`,
+		h.DbSchema)
+	_, err := tx.Exec(ctx, query, userUuid, orgUuid, eventType, payload, createdBy)
+	return err
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/app"
+)
+
+// Longest delay between two login attempts after failures
+const loginMaxDelay = time.Minute
+
+// loginThrottle holds the failed logins of an account.
+type loginThrottle struct {
+	FailedCount  int
+	LastFailedAt *time.Time
+	LockedUntil  *time.Time
+}
+
+// retryAfter returns how long the next login attempt has to wait, 0 if it
+// may be made now. After Config.LoginDelayAfter failures the delay starts
+// at one second and doubles with each further failure.
+func (h *ApiHandler) loginRetryAfter(t loginThrottle, now time.Time) time.Duration {
+	if t.LockedUntil != nil && t.LockedUntil.After(now) {
+		return t.LockedUntil.Sub(now)
+	}
+
+	after := h.Config.LoginDelayAfter
+	if after <= 0 || t.FailedCount < after || t.LastFailedAt == nil {
+		return 0
+	}
+
+	delay := loginMaxDelay
+	if shift := t.FailedCount - after; shift < 6 {
+		delay = min(time.Second<<shift, loginMaxDelay)
+	}
+
+	if wait := t.LastFailedAt.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// loadLoginThrottle returns the failed logins of a user.
+func (h *ApiHandler) loadLoginThrottle(ctx context.Context, userUuid string) (loginThrottle, error) {
+	var t loginThrottle
+	query := fmt.Sprintf(
+		`SELECT failed_login_count, last_failed_login_at, login_locked_until FROM %s.user WHERE uuid = $1::uuid`,
+		h.DbSchema)
+	err := h.DbPool.QueryRow(ctx, query, userUuid).Scan(&t.FailedCount, &t.LastFailedAt, &t.LockedUntil)
+	return t, err
+}
+
+// recordLoginFailure counts a failed login of a user. Reaching
+// Config.LoginLockoutThreshold locks the login for
+// Config.LoginLockoutMinutes and starts counting anew. Returns whether the
+// login is locked now.
+func (h *ApiHandler) recordLoginFailure(ctx context.Context, userUuid string) (bool, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s.user
+		SET failed_login_count = CASE
+				WHEN $2 > 0 AND failed_login_count + 1 >= $2 THEN 0
+				ELSE failed_login_count + 1
+			END,
+			last_failed_login_at = NOW(),
+			login_locked_until = CASE
+				WHEN $2 > 0 AND failed_login_count + 1 >= $2 THEN NOW() + make_interval(mins => $3)
+				ELSE login_locked_until
+			END
+		WHERE uuid = $1::uuid
+		RETURNING COALESCE(login_locked_until > NOW(), false)`,
+		h.DbSchema)
+	var locked bool
+	err := h.DbPool.QueryRow(ctx, query, userUuid, h.Config.LoginLockoutThreshold, h.Config.LoginLockoutMinutes).Scan(&locked)
+	return locked, err
+}
+
+// loginEmailHash returns the key of an email address in login_failure.
+func loginEmailHash(email string) string {
+	return hashOrgKey(strings.ToLower(strings.TrimSpace(email)))
+}
+
+// loadUnknownLoginThrottle returns the failed logins with an email address
+// which belongs to no account.
+func (h *ApiHandler) loadUnknownLoginThrottle(ctx context.Context, email string) (loginThrottle, error) {
+	var t loginThrottle
+	query := fmt.Sprintf(
+		`SELECT failed_login_count, last_failed_login_at, login_locked_until FROM %s.login_failure WHERE email_hash = $1`,
+		h.DbSchema)
+	err := h.DbPool.QueryRow(ctx, query, loginEmailHash(email)).Scan(&t.FailedCount, &t.LastFailedAt, &t.LockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return t, nil
+	}
+	return t, err
+}
+
+// recordUnknownLoginFailure counts a failed login with an email address
+// which belongs to no account, the same way as recordLoginFailure. Entries
+// of the last day are kept.
+func (h *ApiHandler) recordUnknownLoginFailure(ctx context.Context, email string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s.login_failure AS lf (email_hash, failed_login_count, last_failed_login_at, login_locked_until)
+		VALUES ($1, CASE WHEN $2 = 1 THEN 0 ELSE 1 END, NOW(), CASE WHEN $2 = 1 THEN NOW() + make_interval(mins => $3) END)
+		ON CONFLICT (email_hash) DO UPDATE SET
+			failed_login_count = CASE
+				WHEN $2 > 0 AND lf.failed_login_count + 1 >= $2 THEN 0
+				ELSE lf.failed_login_count + 1
+			END,
+			last_failed_login_at = NOW(),
+			login_locked_until = CASE
+				WHEN $2 > 0 AND lf.failed_login_count + 1 >= $2 THEN NOW() + make_interval(mins => $3)
+				ELSE lf.login_locked_until
+			END`,
+		h.DbSchema)
+	_, err := h.DbPool.Exec(ctx, query, loginEmailHash(email), h.Config.LoginLockoutThreshold, h.Config.LoginLockoutMinutes)
+	if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`
+		DELETE FROM %s.login_failure
+		WHERE last_failed_login_at < NOW() - interval '1 day'
+			AND (login_locked_until IS NULL OR login_locked_until < NOW())`,
+		h.DbSchema)
+	_, err = h.DbPool.Exec(ctx, query)
+	return err
+}
+
+// loginDummyPasswordHash is compared with the password of logins with
+// unknown email addresses, so they take as long as a wrong password.
+var loginDummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := app.EncryptPassword("uranus-login-dummy-password")
+	if err != nil {
+		debugf(err.Error())
+	}
+	return hash
+})
+
+// resetLoginFailures clears the failed logins of a user after a successful
+// login.
+func (h *ApiHandler) resetLoginFailures(ctx context.Context, userUuid string) error {
+	query := fmt.Sprintf(`
+		UPDATE %s.user
+		SET failed_login_count = 0, last_failed_login_at = NULL, login_locked_until = NULL
+		WHERE uuid = $1::uuid AND (failed_login_count > 0 OR login_locked_until IS NOT NULL)`,
+		h.DbSchema)
+	_, err := h.DbPool.Exec(ctx, query, userUuid)
+	return err
+}
+
+// loginThrottled aborts a login attempt made too early with 429 and
+// reports whether it did.
+func (h *ApiHandler) loginThrottled(gc *gin.Context, t loginThrottle) bool {
+	retryAfter := h.loginRetryAfter(t, time.Now())
+	if retryAfter <= 0 {
+		return false
+	}
+	message := "too many failed logins, try again later"
+	if t.LockedUntil != nil && t.LockedUntil.After(time.Now()) {
+		message = "login temporarily locked after too many failed attempts"
+	}
+	abortTooManyRequests(gc, "login", retryAfter, message)
+	return true
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sndcds/uranus/app"
+)
+
+func TestLoginRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *time.Time {
+		v := now.Add(offset)
+		return &v
+	}
+
+	h := &ApiHandler{Config: &app.Config{LoginDelayAfter: 3}}
+	noDelay := &ApiHandler{Config: &app.Config{LoginDelayAfter: 0}}
+
+	tests := []struct {
+		name     string
+		h        *ApiHandler
+		throttle loginThrottle
+		want     time.Duration
+	}{
+		{name: "no failures", h: h, want: 0},
+		{name: "below threshold", h: h, throttle: loginThrottle{FailedCount: 2, LastFailedAt: at(0)}, want: 0},
+		{name: "threshold reached", h: h, throttle: loginThrottle{FailedCount: 3, LastFailedAt: at(0)}, want: time.Second},
+		{name: "delay doubles", h: h, throttle: loginThrottle{FailedCount: 4, LastFailedAt: at(0)}, want: 2 * time.Second},
+		{name: "delay doubles again", h: h, throttle: loginThrottle{FailedCount: 5, LastFailedAt: at(0)}, want: 4 * time.Second},
+		{name: "part of the delay passed", h: h, throttle: loginThrottle{FailedCount: 5, LastFailedAt: at(-time.Second)}, want: 3 * time.Second},
+		{name: "delay passed", h: h, throttle: loginThrottle{FailedCount: 5, LastFailedAt: at(-5 * time.Second)}, want: 0},
+		{name: "last doubling", h: h, throttle: loginThrottle{FailedCount: 8, LastFailedAt: at(0)}, want: 32 * time.Second},
+		{name: "capped", h: h, throttle: loginThrottle{FailedCount: 9, LastFailedAt: at(0)}, want: loginMaxDelay},
+		{name: "capped without overflow", h: h, throttle: loginThrottle{FailedCount: 100, LastFailedAt: at(0)}, want: loginMaxDelay},
+		{name: "without last failure", h: h, throttle: loginThrottle{FailedCount: 5}, want: 0},
+		{name: "delay disabled", h: noDelay, throttle: loginThrottle{FailedCount: 5, LastFailedAt: at(0)}, want: 0},
+
+		{name: "locked", h: h, throttle: loginThrottle{LockedUntil: at(10 * time.Minute)}, want: 10 * time.Minute},
+		{name: "lock wins over delay", h: h, throttle: loginThrottle{FailedCount: 3, LastFailedAt: at(0), LockedUntil: at(time.Minute)}, want: time.Minute},
+		{name: "lock expired", h: h, throttle: loginThrottle{LockedUntil: at(-time.Second)}, want: 0},
+		{name: "locked while delay disabled", h: noDelay, throttle: loginThrottle{LockedUntil: at(time.Minute)}, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.h.loginRetryAfter(tt.throttle, now)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,601 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+)
+
+// Types of notifications
+const (
+	notificationPartnerRequest      = "partner-request"
+	notificationPartnerAccepted     = "partner-accepted"
+	notificationTeamInvite          = "team-invite"
+	notificationEventReleasePending = "event-release-pending"
+	notificationEventIncomplete     = "event-incomplete"
+	notificationEventDateStatus     = "event-date-status"
+	notificationMessage             = "message"
+	notificationAgendaReminder      = "agenda-reminder"
+	notificationAgendaChange        = "agenda-change"
+)
+
+// Channels of notifications, see notification_preference
+const (
+	notificationChannelInApp     = "in-app"
+	notificationChannelImmediate = "immediate"
+	notificationChannelDaily     = "daily"
+	notificationChannelWeekly    = "weekly"
+)
+
+var notificationChannels = []string{
+	notificationChannelInApp,
+	notificationChannelImmediate,
+	notificationChannelDaily,
+	notificationChannelWeekly,
+}
+
+// notificationType holds the channel used without preference of the user.
+// Mandatory types ignore preferences, e.g. invitations which can only be
+// accepted with the link of their mail.
+type notificationType struct {
+	DefaultChannel string
+	Mandatory      bool
+}
+
+var notificationTypes = map[string]notificationType{
+	notificationPartnerRequest:      {DefaultChannel: notificationChannelImmediate},
+	notificationPartnerAccepted:     {DefaultChannel: notificationChannelInApp},
+	notificationTeamInvite:          {DefaultChannel: notificationChannelImmediate, Mandatory: true},
+	notificationEventReleasePending: {DefaultChannel: notificationChannelDaily},
+	notificationEventIncomplete:     {DefaultChannel: notificationChannelWeekly},
+	notificationEventDateStatus:     {DefaultChannel: notificationChannelImmediate},
+	notificationMessage:             {DefaultChannel: notificationChannelImmediate},
+	notificationAgendaReminder:      {DefaultChannel: notificationChannelImmediate},
+	notificationAgendaChange:        {DefaultChannel: notificationChannelImmediate},
+}
+
+// notificationTypeNames lists notificationTypes in a stable order
+var notificationTypeNames = []string{
+	notificationPartnerRequest,
+	notificationPartnerAccepted,
+	notificationTeamInvite,
+	notificationEventReleasePending,
+	notificationEventIncomplete,
+	notificationEventDateStatus,
+	notificationMessage,
+	notificationAgendaReminder,
+	notificationAgendaChange,
+}
+
+// Entity types notifications link to
+const (
+	notificationEntityOrg       = "org"
+	notificationEntityEvent     = "event"
+	notificationEntityEventDate = "event_date"
+	notificationEntityUser      = "user"
+)
+
+// Name of the advisory lock which elects the instance checking events for
+// notifications
+const notificationChecksLockName = "uranus-notification-checks"
+
+// notification is a notification to be created by notifyUsersTx. Title and
+// Body are plain text. Notifications with a DedupKey are created once per
+// recipient. EmailTemplate and EmailVars replace the generic
+// "notification" mail, e.g. for invitations. Lang is the language of mails
+// to recipients without locale.
+type notification struct {
+	Type          string
+	OrgUuid       string
+	EntityType    string
+	EntityUuid    string
+	Title         string
+	Body          string
+	Link          string
+	Data          map[string]any
+	DedupKey      string
+	CreatedBy     string
+	EmailTemplate string
+	EmailVars     map[string]string
+	Lang          string
+}
+
+// emailLang returns the language of a user locale like "de-DE", or
+// defaultLang without locale.
+func emailLang(locale *string, defaultLang string) string {
+	if locale == nil || len(*locale) < 2 {
+		if defaultLang != "" {
+			return defaultLang
+		}
+		return "en"
+	}
+	return strings.ToLower((*locale)[:2])
+}
+
+// notifyUsersTx creates a notification for each user, except for its
+// creator, on the channel the user chose for the type. Immediate mails are
+// queued within tx. Returns the number of created notifications.
+func (h *ApiHandler) notifyUsersTx(ctx context.Context, tx pgx.Tx, n notification, userUuids []string) (int, error) {
+	typ, ok := notificationTypes[n.Type]
+	if !ok {
+		return 0, fmt.Errorf("unknown notification type: %s", n.Type)
+	}
+
+	data := n.Data
+	if data == nil {
+		data = map[string]any{}
+	}
+
+	count := 0
+	seen := map[string]bool{}
+	for _, userUuid := range userUuids {
+		if userUuid == n.CreatedBy || seen[userUuid] {
+			continue
+		}
+		seen[userUuid] = true
+
+		query := fmt.Sprintf(`
+			SELECT u.email, u.locale, p.channel
+			FROM %[1]s.user u
+			LEFT JOIN %[1]s.notification_preference p ON p.user_uuid = u.uuid AND p.type = $2
+			WHERE u.uuid = $1::uuid`,
+			h.DbSchema)
+		var email string
+		var locale, preferredChannel *string
+		err := tx.QueryRow(ctx, query, userUuid, n.Type).Scan(&email, &locale, &preferredChannel)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return count, err
+		}
+
+		channel := typ.DefaultChannel
+		if !typ.Mandatory && preferredChannel != nil {
+			channel = *preferredChannel
+		}
+
+		notificationUuid, err := grains_uuid.Uuidv7String()
+		if err != nil {
+			return count, err
+		}
+
+		query = fmt.Sprintf(`
+			INSERT INTO %s.notification
+				(uuid, user_uuid, type, org_uuid, entity_type, entity_uuid, title, body, link, data, dedup_key, channel, created_by)
+			VALUES ($1::uuid, $2::uuid, $3, NULLIF($4, '')::uuid, NULLIF($5, ''), NULLIF($6, '')::uuid, $7, $8,
+				NULLIF($9, ''), $10, NULLIF($11, ''), $12, NULLIF($13, '')::uuid)
+			ON CONFLICT (user_uuid, dedup_key) WHERE dedup_key IS NOT NULL DO NOTHING`,
+			h.DbSchema)
+		res, err := tx.Exec(ctx, query,
+			notificationUuid, userUuid, n.Type, n.OrgUuid, n.EntityType, n.EntityUuid, n.Title, n.Body,
+			n.Link, data, n.DedupKey, channel, n.CreatedBy)
+		if err != nil {
+			return count, err
+		}
+		if res.RowsAffected() == 0 {
+			continue
+		}
+		count++
+
+		err = h.publishLiveEventTx(ctx, tx, userUuid, "", liveEventNotification, map[string]any{
+			"uuid":        notificationUuid,
+			"type":        n.Type,
+			"org_uuid":    n.OrgUuid,
+			"entity_type": n.EntityType,
+			"entity_uuid": n.EntityUuid,
+			"title":       n.Title,
+		}, n.CreatedBy)
+		if err != nil {
+			return count, err
+		}
+
+		if channel != notificationChannelImmediate {
+			continue
+		}
+
+		m, err := h.renderNotificationEmail(ctx, tx, n, emailLang(locale, n.Lang))
+		if err != nil {
+			return count, err
+		}
+		m.To = email
+		m.UserUuid = userUuid
+		m.OrgUuid = n.OrgUuid
+		if _, err := h.enqueueEmail(ctx, tx, m); err != nil {
+			return count, err
+		}
+
+		query = fmt.Sprintf(`UPDATE %s.notification SET emailed_at = NOW() WHERE uuid = $1::uuid`, h.DbSchema)
+		if _, err := tx.Exec(ctx, query, notificationUuid); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// renderNotificationEmail returns the mail of a single notification, using
+// its own template or the "notification" template.
+func (h *ApiHandler) renderNotificationEmail(ctx context.Context, q rowQuerier, n notification, lang string) (emailMessage, error) {
+	if n.EmailTemplate != "" {
+		return h.renderEmailTemplate(ctx, q, n.EmailTemplate, lang, n.EmailVars)
+	}
+
+	m, err := h.renderEmailTemplate(ctx, q, "notification", lang, map[string]string{
+		"title": n.Title,
+		"body":  n.Body,
+		"link":  n.Link,
+	})
+	if errors.Is(err, errNoEmailTemplate) {
+		return plainEmail(n.Title, n.Body, n.Link, "notification"), nil
+	}
+	return m, err
+}
+
+// plainEmail returns a mail in the built-in layout, used where a system
+// email template is missing. Paragraphs of body are separated by blank
+// lines.
+func plainEmail(subject string, body string, link string, templateContext string) emailMessage {
+	var b strings.Builder
+	b.WriteString("<p><strong>" + html.EscapeString(subject) + "</strong></p>")
+	for _, paragraph := range strings.Split(body, "\n\n") {
+		if paragraph != "" {
+			b.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>") + "</p>")
+		}
+	}
+	if link != "" {
+		b.WriteString(`<p><a href="` + html.EscapeString(link) + `">` + html.EscapeString(link) + "</a></p>")
+	}
+	return emailMessage{
+		Subject:         subject,
+		Html:            b.String(),
+		Text:            htmlToText(b.String()),
+		TemplateContext: templateContext,
+		Lang:            "en",
+	}
+}
+
+// orgMembersWithPermissionTx returns the members of an organization holding
+// any of the given permissions.
+func (h *ApiHandler) orgMembersWithPermissionTx(ctx context.Context, tx pgx.Tx, orgUuid string, perms app.Permissions) ([]string, error) {
+	query := fmt.Sprintf(`
+		SELECT DISTINCT user_uuid
+		FROM %s.user_organization_link
+		WHERE org_uuid = $1::uuid AND (permissions & $2::bigint) <> 0`,
+		h.DbSchema)
+	return queryUuidsTx(ctx, tx, query, orgUuid, int64(perms))
+}
+
+// notifyOrgMembersTx notifies the members of an organization holding any of
+// the given permissions.
+func (h *ApiHandler) notifyOrgMembersTx(ctx context.Context, tx pgx.Tx, n notification, orgUuid string, perms app.Permissions) (int, error) {
+	userUuids, err := h.orgMembersWithPermissionTx(ctx, tx, orgUuid, perms)
+	if err != nil {
+		return 0, err
+	}
+	return h.notifyUsersTx(ctx, tx, n, userUuids)
+}
+
+// CheckEventNotifications notifies the event editors of organizations about
+// upcoming events, which are not released yet or are missing an image, a
+// venue or online link, an event type or a title. Each event is reported
+// once per first date. Only the instance holding the advisory lock does the
+// work.
+func (h *ApiHandler) CheckEventNotifications(ctx context.Context) error {
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		locked, err := tryAdvisoryXactLockTx(ctx, tx, notificationChecksLockName)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !locked {
+			return nil
+		}
+
+		type eventCheck struct {
+			Uuid                string
+			Title               string
+			OrgUuid             string
+			ReleaseStatus       string
+			FirstDate           time.Time
+			DaysUntilFirstDate  int
+			NoImage             bool
+			NoVenueOrOnlineLink bool
+			NoEventType         bool
+			NoTitle             bool
+		}
+
+		rows, err := tx.Query(ctx, app.UranusInstance.SqlNotificationEventChecks, h.Config.NotificationLookaheadDays)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		var checks []eventCheck
+		for rows.Next() {
+			var c eventCheck
+			err := rows.Scan(
+				&c.Uuid, &c.Title, &c.OrgUuid, &c.ReleaseStatus, &c.FirstDate, &c.DaysUntilFirstDate,
+				&c.NoImage, &c.NoVenueOrOnlineLink, &c.NoEventType, &c.NoTitle)
+			if err != nil {
+				rows.Close()
+				return TxInternalError(err)
+			}
+			checks = append(checks, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return TxInternalError(err)
+		}
+
+		for _, c := range checks {
+			firstDate := c.FirstDate.Format("2006-01-02")
+			title := c.Title
+			if strings.TrimSpace(title) == "" {
+				title = c.Uuid
+			}
+
+			if c.ReleaseStatus == "draft" || c.ReleaseStatus == "review" {
+				_, err := h.notifyOrgMembersTx(ctx, tx, notification{
+					Type:       notificationEventReleasePending,
+					OrgUuid:    c.OrgUuid,
+					EntityType: notificationEntityEvent,
+					EntityUuid: c.Uuid,
+					Title:      "Not released yet: " + title,
+					Body: fmt.Sprintf("The event starts on %s (in %d days) and is still in %s.",
+						c.FirstDate.Format("02.01.2006"), c.DaysUntilFirstDate, c.ReleaseStatus),
+					Data: map[string]any{
+						"release_status":        c.ReleaseStatus,
+						"first_date":            firstDate,
+						"days_until_first_date": c.DaysUntilFirstDate,
+					},
+					DedupKey: notificationEventReleasePending + ":" + c.Uuid + ":" + firstDate,
+				}, c.OrgUuid, app.UserPermReleaseEvent)
+				if err != nil {
+					return TxInternalError(err)
+				}
+			}
+
+			var missing []string
+			if c.NoTitle {
+				missing = append(missing, "title")
+			}
+			if c.NoImage {
+				missing = append(missing, "image")
+			}
+			if c.NoVenueOrOnlineLink {
+				missing = append(missing, "venue or online link")
+			}
+			if c.NoEventType {
+				missing = append(missing, "event type")
+			}
+			if len(missing) == 0 {
+				continue
+			}
+
+			_, err := h.notifyOrgMembersTx(ctx, tx, notification{
+				Type:       notificationEventIncomplete,
+				OrgUuid:    c.OrgUuid,
+				EntityType: notificationEntityEvent,
+				EntityUuid: c.Uuid,
+				Title:      "Incomplete event: " + title,
+				Body: fmt.Sprintf("The event starts on %s and is missing: %s.",
+					c.FirstDate.Format("02.01.2006"), strings.Join(missing, ", ")),
+				Data: map[string]any{
+					"first_date":              firstDate,
+					"no_image":                c.NoImage,
+					"no_venue_or_online_link": c.NoVenueOrOnlineLink,
+					"no_event_type":           c.NoEventType,
+					"no_title":                c.NoTitle,
+				},
+				DedupKey: notificationEventIncomplete + ":" + c.Uuid + ":" + firstDate,
+			}, c.OrgUuid, app.UserPermEditEvent)
+			if err != nil {
+				return TxInternalError(err)
+			}
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+	return nil
+}
+
+// notificationDigestSlot returns the time of the latest digest of a
+// frequency before now: daily at the digest hour, weekly on Mondays.
+func notificationDigestSlot(frequency string, now time.Time, hour int) time.Time {
+	slot := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	days := 1
+	if frequency == notificationChannelWeekly {
+		slot = slot.AddDate(0, 0, -((int(slot.Weekday()) + 6) % 7))
+		days = 7
+	}
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -days)
+	}
+	return slot
+}
+
+// SendNotificationDigests queues the daily and weekly digest mails which are
+// due. A digest holds the notifications created before its time, which have
+// not been read in the meantime. Returns the number of queued mails.
+func (h *ApiHandler) SendNotificationDigests(ctx context.Context, now time.Time) (int, error) {
+	count := 0
+	for _, frequency := range []string{notificationChannelDaily, notificationChannelWeekly} {
+		slot := notificationDigestSlot(frequency, now, h.Config.NotificationDigestHour)
+
+		query := fmt.Sprintf(`
+			SELECT DISTINCT n.user_uuid
+			FROM %[1]s.notification n
+			LEFT JOIN %[1]s.notification_digest d ON d.user_uuid = n.user_uuid AND d.frequency = $1
+			WHERE n.channel = $1
+				AND n.emailed_at IS NULL
+				AND n.created_at < $2
+				AND (d.sent_at IS NULL OR d.sent_at < $2)`,
+			h.DbSchema)
+		rows, err := h.DbPool.Query(ctx, query, frequency, slot)
+		if err != nil {
+			return count, err
+		}
+		userUuids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return count, err
+		}
+
+		for _, userUuid := range userUuids {
+			sent, err := h.sendNotificationDigest(ctx, userUuid, frequency, slot)
+			if err != nil {
+				return count, err
+			}
+			if sent {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// sendNotificationDigest queues the digest mail of a user. Pending
+// notifications of the frequency are marked as mailed, also if all of them
+// have been read and no mail is needed.
+func (h *ApiHandler) sendNotificationDigest(ctx context.Context, userUuid string, frequency string, slot time.Time) (bool, error) {
+	sent := false
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			UPDATE %[1]s.notification n
+			SET emailed_at = NOW()
+			WHERE n.uuid IN (
+				SELECT uuid FROM %[1]s.notification
+				WHERE user_uuid = $1::uuid AND channel = $2 AND emailed_at IS NULL AND created_at < $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING n.title, n.body, n.link, n.read_at IS NOT NULL, n.created_at`,
+			h.DbSchema)
+		rows, err := tx.Query(ctx, query, userUuid, frequency, slot)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		type digestItem struct {
+			Title     string
+			Body      string
+			Link      *string
+			CreatedAt time.Time
+		}
+		var items []digestItem
+		for rows.Next() {
+			var item digestItem
+			var read bool
+			if err := rows.Scan(&item.Title, &item.Body, &item.Link, &read, &item.CreatedAt); err != nil {
+				rows.Close()
+				return TxInternalError(err)
+			}
+			if !read {
+				items = append(items, item)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return TxInternalError(err)
+		}
+
+		query = fmt.Sprintf(`
+			INSERT INTO %s.notification_digest (user_uuid, frequency, sent_at)
+			VALUES ($1::uuid, $2, NOW())
+			ON CONFLICT (user_uuid, frequency) DO UPDATE SET sent_at = EXCLUDED.sent_at`,
+			h.DbSchema)
+		if _, err := tx.Exec(ctx, query, userUuid, frequency); err != nil {
+			return TxInternalError(err)
+		}
+
+		if len(items) == 0 {
+			return nil
+		}
+
+		var email string
+		var locale *string
+		query = fmt.Sprintf(`SELECT email, locale FROM %s.user WHERE uuid = $1::uuid`, h.DbSchema)
+		if err := tx.QueryRow(ctx, query, userUuid).Scan(&email, &locale); err != nil {
+			return TxInternalError(err)
+		}
+
+		var itemsText, itemsHtml strings.Builder
+		itemsHtml.WriteString("<ul>")
+		for _, item := range items {
+			itemsText.WriteString("- " + item.Title + "\n")
+			itemsHtml.WriteString("<li><strong>" + html.EscapeString(item.Title) + "</strong>")
+			if item.Body != "" {
+				itemsText.WriteString("  " + strings.ReplaceAll(item.Body, "\n", "\n  ") + "\n")
+				itemsHtml.WriteString("<br>" + strings.ReplaceAll(html.EscapeString(item.Body), "\n", "<br>"))
+			}
+			if item.Link != nil && *item.Link != "" {
+				itemsText.WriteString("  " + *item.Link + "\n")
+				itemsHtml.WriteString(`<br><a href="` + html.EscapeString(*item.Link) + `">` + html.EscapeString(*item.Link) + "</a>")
+			}
+			itemsHtml.WriteString("</li>")
+		}
+		itemsHtml.WriteString("</ul>")
+
+		m, err := h.renderEmailTemplate(ctx, tx, "notification-digest", emailLang(locale, ""), map[string]string{
+			"count":      strconv.Itoa(len(items)),
+			"frequency":  frequency,
+			"items":      itemsText.String(),
+			"items_html": itemsHtml.String(),
+			"link":       h.Config.Frontend,
+		})
+		if errors.Is(err, errNoEmailTemplate) {
+			body := fmt.Sprintf("<p>You have %d unread notifications.</p>", len(items)) + itemsHtml.String()
+			m = emailMessage{
+				Subject:         fmt.Sprintf("%d unread notifications", len(items)),
+				Html:            body,
+				Text:            htmlToText(body),
+				TemplateContext: "notification-digest",
+				Lang:            "en",
+			}
+		} else if err != nil {
+			return TxInternalError(err)
+		}
+		m.To = email
+		m.UserUuid = userUuid
+		if _, err := h.enqueueEmail(ctx, tx, m); err != nil {
+			return TxInternalError(err)
+		}
+		sent = true
+
+		return nil
+	})
+	if txErr != nil {
+		return false, txErr
+	}
+	return sent, nil
+}
+
+// RunNotificationWorker checks events for notifications and sends due
+// digests once at start and then in the given interval until ctx is
+// cancelled.
+func (h *ApiHandler) RunNotificationWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.CheckEventNotifications(ctx); err != nil {
+			debugf("check event notifications failed: %v", err)
+		}
+		if _, err := h.SendNotificationDigests(ctx, time.Now()); err != nil {
+			debugf("send notification digests failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
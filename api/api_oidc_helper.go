@@ -0,0 +1,780 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// Validity of a login started with an OpenID Connect provider, the callback
+// must arrive within
+const oidcLoginStateLifetime = 10 * time.Minute
+
+// Discovery documents and keys of providers are fetched again after this
+// duration
+const oidcCacheLifetime = time.Hour
+
+// Signing algorithms accepted for ID tokens
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+var oidcHttpClient = &http.Client{Timeout: 10 * time.Second}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+	fetchedAt             time.Time
+}
+
+type oidcKeySet struct {
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+var oidcCache = struct {
+	mu        sync.Mutex
+	discovery map[string]*oidcDiscovery
+	keySets   map[string]*oidcKeySet
+}{
+	discovery: map[string]*oidcDiscovery{},
+	keySets:   map[string]*oidcKeySet{},
+}
+
+// oidcIdentity holds the claims of an ID token used by Uranus.
+type oidcIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+	Groups        []string
+}
+
+type oidcTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IdToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// randomUrlToken returns n random bytes, base64url encoded.
+func randomUrlToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// pkceChallenge returns the S256 code challenge of a PKCE code verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func oidcGetJson(ctx context.Context, endpoint string, bearer string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := oidcHttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(target)
+}
+
+// oidcDiscover returns the discovery document of a provider, read from
+// {issuer}/.well-known/openid-configuration.
+func oidcDiscover(ctx context.Context, provider app.OidcProvider) (*oidcDiscovery, error) {
+	oidcCache.mu.Lock()
+	d, ok := oidcCache.discovery[provider.Issuer]
+	oidcCache.mu.Unlock()
+	if ok && time.Since(d.fetchedAt) < oidcCacheLifetime {
+		return d, nil
+	}
+
+	d = &oidcDiscovery{}
+	endpoint := strings.TrimSuffix(provider.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := oidcGetJson(ctx, endpoint, "", d); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(provider.Issuer, "/") {
+		return nil, fmt.Errorf("oidc provider %s: issuer mismatch %q", provider.Name, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JwksUri == "" {
+		return nil, fmt.Errorf("oidc provider %s: incomplete discovery document", provider.Name)
+	}
+	d.fetchedAt = time.Now()
+
+	oidcCache.mu.Lock()
+	oidcCache.discovery[provider.Issuer] = d
+	oidcCache.mu.Unlock()
+	return d, nil
+}
+
+// oidcPublicKey returns the key kid of a key set. The key set is fetched
+// again if the key is unknown, providers publish new keys before using them.
+func oidcPublicKey(ctx context.Context, jwksUri string, kid string) (any, error) {
+	oidcCache.mu.Lock()
+	keySet, ok := oidcCache.keySets[jwksUri]
+	oidcCache.mu.Unlock()
+	if ok && time.Since(keySet.fetchedAt) < oidcCacheLifetime {
+		if key, found := keySet.lookup(kid); found {
+			return key, nil
+		}
+	}
+
+	var doc struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := oidcGetJson(ctx, jwksUri, "", &doc); err != nil {
+		return nil, err
+	}
+
+	keySet = &oidcKeySet{keys: map[string]any{}, fetchedAt: time.Now()}
+	for _, raw := range doc.Keys {
+		keyId, key, err := parseJwk(raw)
+		if err != nil {
+			debugf("oidc: skipping key of %s: %v", jwksUri, err)
+			continue
+		}
+		if key != nil {
+			keySet.keys[keyId] = key
+		}
+	}
+
+	oidcCache.mu.Lock()
+	oidcCache.keySets[jwksUri] = keySet
+	oidcCache.mu.Unlock()
+
+	key, found := keySet.lookup(kid)
+	if !found {
+		return nil, fmt.Errorf("oidc: unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup returns the key kid. Tokens without kid are accepted if the key set
+// has a single key.
+func (s *oidcKeySet) lookup(kid string) (any, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// parseJwk returns the id and public key of an RSA or EC JSON web key. Keys
+// for encryption are skipped with a nil key.
+func parseJwk(raw json.RawMessage) (string, any, error) {
+	var jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return jwk.Kid, nil, nil
+	}
+
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return "", nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return "", nil, errors.New("invalid rsa exponent")
+		}
+		return jwk.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.Kid, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return jwk.Kid, nil, nil
+	}
+}
+
+// oidcScopes returns the scopes requested from a provider, "openid" is
+// always included.
+func oidcScopes(provider app.OidcProvider) string {
+	scopes := provider.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	for _, scope := range scopes {
+		if scope == "openid" {
+			return strings.Join(scopes, " ")
+		}
+	}
+	return strings.Join(append([]string{"openid"}, scopes...), " ")
+}
+
+// oidcAuthorizationUrl returns the URL the browser is sent to for logging in
+// with a provider.
+func oidcAuthorizationUrl(d *oidcDiscovery, provider app.OidcProvider, state string, nonce string, codeVerifier string) string {
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", provider.ClientId)
+	values.Set("redirect_uri", provider.RedirectUrl)
+	values.Set("scope", oidcScopes(provider))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", pkceChallenge(codeVerifier))
+	values.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + values.Encode()
+}
+
+// oidcExchangeCode exchanges an authorization code at the token endpoint of
+// a provider. Confidential clients authenticate with client_secret_basic.
+func oidcExchangeCode(ctx context.Context, d *oidcDiscovery, provider app.OidcProvider, code string, codeVerifier string) (oidcTokenResponse, error) {
+	var tokens oidcTokenResponse
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectUrl)
+	form.Set("client_id", provider.ClientId)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokens, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientId), url.QueryEscape(provider.ClientSecret))
+	}
+
+	resp, err := oidcHttpClient.Do(req)
+	if err != nil {
+		return tokens, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens)
+	if resp.StatusCode != http.StatusOK {
+		if tokens.Error != "" {
+			return tokens, fmt.Errorf("token endpoint: %s %s", tokens.Error, tokens.ErrorDescription)
+		}
+		return tokens, fmt.Errorf("token endpoint: %s", resp.Status)
+	}
+	if err != nil {
+		return tokens, err
+	}
+	if tokens.IdToken == "" {
+		return tokens, errors.New("token endpoint: no id_token")
+	}
+	return tokens, nil
+}
+
+// oidcVerifyIdToken verifies signature, issuer, audience, expiry and nonce
+// of an ID token and returns its claims.
+func oidcVerifyIdToken(ctx context.Context, d *oidcDiscovery, provider app.OidcProvider, rawToken string, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return oidcPublicKey(ctx, d.JwksUri, kid)
+	},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(provider.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, errors.New("id token: nonce mismatch")
+	}
+
+	// With several audiences the token must have been issued to us
+	audience, _ := claims.GetAudience()
+	if len(audience) > 1 {
+		if azp, _ := claims["azp"].(string); azp != provider.ClientId {
+			return nil, errors.New("id token: azp mismatch")
+		}
+	}
+
+	return claims, nil
+}
+
+// oidcMergeUserinfo adds claims missing in the ID token from the userinfo
+// endpoint, some providers only put email or groups there.
+func oidcMergeUserinfo(ctx context.Context, d *oidcDiscovery, provider app.OidcProvider, accessToken string, claims jwt.MapClaims) error {
+	_, hasEmail := claims["email"]
+	_, hasGroups := claims[oidcGroupsClaim(provider)]
+	if (hasEmail && hasGroups) || d.UserinfoEndpoint == "" || accessToken == "" {
+		return nil
+	}
+
+	userinfo := map[string]any{}
+	if err := oidcGetJson(ctx, d.UserinfoEndpoint, accessToken, &userinfo); err != nil {
+		return err
+	}
+	if sub, _ := userinfo["sub"].(string); sub != claims["sub"] {
+		return errors.New("userinfo: sub mismatch")
+	}
+
+	for key, value := range userinfo {
+		if _, ok := claims[key]; !ok {
+			claims[key] = value
+		}
+	}
+	return nil
+}
+
+func oidcGroupsClaim(provider app.OidcProvider) string {
+	if provider.GroupsClaim != "" {
+		return provider.GroupsClaim
+	}
+	return "groups"
+}
+
+// oidcIdentityFromClaims reads the identity from the claims of an ID token.
+// email_verified is accepted as boolean or string, groups as list or single
+// string.
+func oidcIdentityFromClaims(provider app.OidcProvider, claims jwt.MapClaims) oidcIdentity {
+	identity := oidcIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Email = strings.TrimSpace(identity.Email)
+	identity.GivenName, _ = claims["given_name"].(string)
+	identity.FamilyName, _ = claims["family_name"].(string)
+	identity.Name, _ = claims["name"].(string)
+
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+
+	switch v := claims[oidcGroupsClaim(provider)].(type) {
+	case []any:
+		for _, group := range v {
+			if s, ok := group.(string); ok && s != "" {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	case string:
+		if v != "" {
+			identity.Groups = []string{v}
+		}
+	}
+
+	return identity
+}
+
+// createOidcLoginState stores the state, PKCE code verifier and nonce of a
+// login started with a provider.
+func (h *ApiHandler) createOidcLoginState(ctx context.Context, provider string) (string, string, string, error) {
+	state, err := randomUrlToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	codeVerifier, err := randomUrlToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	nonce, err := randomUrlToken(16)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s.oidc_login_state (state, provider, code_verifier, nonce) VALUES ($1, $2, $3, $4)`,
+		h.DbSchema)
+	_, err = h.DbPool.Exec(ctx, query, state, provider, codeVerifier, nonce)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return state, codeVerifier, nonce, nil
+}
+
+// consumeOidcLoginState removes the state of a login and returns its code
+// verifier and nonce, ok is false for unknown or expired states. Stale
+// states of abandoned logins are deleted on the way.
+func (h *ApiHandler) consumeOidcLoginState(ctx context.Context, provider string, state string) (string, string, bool, error) {
+	query := fmt.Sprintf(
+		`DELETE FROM %s.oidc_login_state WHERE created_at < NOW() - INTERVAL '1 hour'`,
+		h.DbSchema)
+	if _, err := h.DbPool.Exec(ctx, query); err != nil {
+		debugf("delete stale oidc login states failed: %v", err)
+	}
+
+	query = fmt.Sprintf(`
+		DELETE FROM %s.oidc_login_state
+		WHERE state = $1 AND provider = $2
+		RETURNING code_verifier, nonce, created_at`,
+		h.DbSchema)
+	var codeVerifier, nonce string
+	var createdAt time.Time
+	err := h.DbPool.QueryRow(ctx, query, state, provider).Scan(&codeVerifier, &nonce, &createdAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", "", false, nil
+		}
+		return "", "", false, err
+	}
+	if time.Since(createdAt) > oidcLoginStateLifetime {
+		return "", "", false, nil
+	}
+
+	return codeVerifier, nonce, true, nil
+}
+
+// resolveOidcUserTx returns the user linked to the identity of a provider.
+// An identity seen for the first time is linked to the user with the same
+// email if the provider has verified it. Otherwise a new, active user is
+// created if the provider allows signups.
+func (h *ApiHandler) resolveOidcUserTx(ctx context.Context, tx pgx.Tx, provider app.OidcProvider, identity oidcIdentity) (string, *ApiTxError) {
+	var userUuid string
+	query := fmt.Sprintf(`
+		UPDATE %s.user_identity
+		SET last_login_at = NOW(), email = NULLIF($3, '')
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_uuid`,
+		h.DbSchema)
+	err := tx.QueryRow(ctx, query, provider.Name, identity.Subject, identity.Email).Scan(&userUuid)
+	if err == nil {
+		return userUuid, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", TxInternalError(err)
+	}
+
+	if identity.Email == "" || !app.IsValidEmail(identity.Email) {
+		return "", NewApiTxError(http.StatusUnauthorized, "identity provider did not provide an email")
+	}
+
+	query = fmt.Sprintf(`SELECT uuid FROM %s.user WHERE LOWER(email) = LOWER($1)`, h.DbSchema)
+	err = tx.QueryRow(ctx, query, identity.Email).Scan(&userUuid)
+	switch {
+	case err == nil:
+		// Linking by an unverified email would hand the account to whoever
+		// registered the email at the provider
+		if !identity.EmailVerified {
+			return "", NewApiTxError(http.StatusConflict, "email is not verified by the identity provider")
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		if !provider.AllowSignup {
+			return "", NewApiTxError(http.StatusForbidden, "no account with this email")
+		}
+		userUuid, err = h.createOidcUserTx(ctx, tx, identity)
+		if err != nil {
+			return "", TxInternalError(err)
+		}
+	default:
+		return "", TxInternalError(err)
+	}
+
+	query = fmt.Sprintf(`
+		INSERT INTO %s.user_identity (provider, subject, user_uuid, email, last_login_at)
+		VALUES ($1, $2, $3::uuid, NULLIF($4, ''), NOW())`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, provider.Name, identity.Subject, userUuid, identity.Email)
+	if err != nil {
+		return "", TxInternalError(err)
+	}
+
+	return userUuid, nil
+}
+
+// createOidcUserTx creates an active user without password for an identity
+// of a provider.
+func (h *ApiHandler) createOidcUserTx(ctx context.Context, tx pgx.Tx, identity oidcIdentity) (string, error) {
+	userUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		return "", err
+	}
+
+	displayName := identity.Name
+	if displayName == "" {
+		displayName = strings.TrimSpace(identity.GivenName + " " + identity.FamilyName)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s.user (uuid, email, first_name, last_name, display_name, is_active)
+		VALUES ($1::uuid, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), TRUE)`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, userUuid, identity.Email, identity.GivenName, identity.FamilyName, displayName)
+	if err != nil {
+		return "", err
+	}
+
+	return userUuid, nil
+}
+
+// syncOidcMembershipsTx applies the group mappings of a provider to the
+// memberships of a user logging in with it. The user joins the organizations
+// mapping one of the groups with the combined permissions of the groups and
+// leaves those whose mappings no longer match. Only memberships created by
+// a mapping of the provider are changed or removed.
+func (h *ApiHandler) syncOidcMembershipsTx(gc *gin.Context, tx pgx.Tx, provider string, userUuid string, groups []string) error {
+	ctx := gc.Request.Context()
+	if groups == nil {
+		groups = []string{}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT org_uuid::text, BIT_OR(permissions)
+		FROM %s.org_oidc_group_mapping
+		WHERE provider = $1 AND group_name = ANY($2::text[])
+		GROUP BY org_uuid`,
+		h.DbSchema)
+	rows, err := tx.Query(ctx, query, provider, groups)
+	if err != nil {
+		return err
+	}
+	mapped := map[string]int64{}
+	for rows.Next() {
+		var orgUuid string
+		var permissions int64
+		if err := rows.Scan(&orgUuid, &permissions); err != nil {
+			rows.Close()
+			return err
+		}
+		mapped[orgUuid] = permissions
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`
+		SELECT m.org_uuid::text, m.oidc_provider, COALESCE(l.permissions, 0)
+		FROM %[1]s.organization_member_link m
+		LEFT JOIN %[1]s.user_organization_link l ON l.org_uuid = m.org_uuid AND l.user_uuid = m.user_uuid
+		WHERE m.user_uuid = $1::uuid
+		FOR UPDATE OF m`,
+		h.DbSchema)
+	rows, err = tx.Query(ctx, query, userUuid)
+	if err != nil {
+		return err
+	}
+	type membership struct {
+		oidcProvider *string
+		permissions  int64
+	}
+	memberships := map[string]membership{}
+	for rows.Next() {
+		var orgUuid string
+		var m membership
+		if err := rows.Scan(&orgUuid, &m.oidcProvider, &m.permissions); err != nil {
+			rows.Close()
+			return err
+		}
+		memberships[orgUuid] = m
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for orgUuid, permissions := range mapped {
+		m, isMember := memberships[orgUuid]
+		switch {
+		case !isMember:
+			err = h.joinOidcOrgTx(gc, tx, provider, orgUuid, userUuid, permissions)
+		case m.oidcProvider != nil && *m.oidcProvider == provider && m.permissions != permissions:
+			err = h.updateOidcOrgPermissionsTx(gc, tx, orgUuid, userUuid, permissions)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for orgUuid, m := range memberships {
+		if _, ok := mapped[orgUuid]; ok || m.oidcProvider == nil || *m.oidcProvider != provider {
+			continue
+		}
+		if err := h.leaveOidcOrgTx(gc, tx, orgUuid, userUuid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *ApiHandler) joinOidcOrgTx(gc *gin.Context, tx pgx.Tx, provider string, orgUuid string, userUuid string, permissions int64) error {
+	ctx := gc.Request.Context()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s.organization_member_link (org_uuid, user_uuid, has_joined, oidc_provider)
+		VALUES ($1::uuid, $2::uuid, TRUE, $3)`,
+		h.DbSchema)
+	_, err := tx.Exec(ctx, query, orgUuid, userUuid, provider)
+	if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`
+		INSERT INTO %s.user_organization_link (user_uuid, org_uuid, permissions, extra_permissions)
+		VALUES ($1::uuid, $2::uuid, $3, $3)`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, userUuid, orgUuid, permissions)
+	if err != nil {
+		return err
+	}
+
+	after, err := h.loadAuditMemberStateTx(ctx, tx, orgUuid, userUuid)
+	if err != nil {
+		return err
+	}
+	return h.writeAuditLogTx(gc, tx, orgUuid, auditEntityMember, userUuid, "oidc-join", nil, after)
+}
+
+func (h *ApiHandler) updateOidcOrgPermissionsTx(gc *gin.Context, tx pgx.Tx, orgUuid string, userUuid string, permissions int64) error {
+	ctx := gc.Request.Context()
+
+	before, err := h.loadAuditMemberStateTx(ctx, tx, orgUuid, userUuid)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.user_organization_link SET permissions = $3, role_uuid = NULL, extra_permissions = $3
+		WHERE user_uuid = $1::uuid AND org_uuid = $2::uuid`,
+		h.DbSchema)
+	res, err := tx.Exec(ctx, query, userUuid, orgUuid, permissions)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		query = fmt.Sprintf(`
+			INSERT INTO %s.user_organization_link (user_uuid, org_uuid, permissions, extra_permissions)
+			VALUES ($1::uuid, $2::uuid, $3, $3)`,
+			h.DbSchema)
+		_, err = tx.Exec(ctx, query, userUuid, orgUuid, permissions)
+		if err != nil {
+			return err
+		}
+	}
+
+	after, err := h.loadAuditMemberStateTx(ctx, tx, orgUuid, userUuid)
+	if err != nil {
+		return err
+	}
+	return h.writeAuditLogTx(gc, tx, orgUuid, auditEntityMember, userUuid, "oidc-update", before, after)
+}
+
+func (h *ApiHandler) leaveOidcOrgTx(gc *gin.Context, tx pgx.Tx, orgUuid string, userUuid string) error {
+	ctx := gc.Request.Context()
+
+	before, err := h.loadAuditMemberStateTx(ctx, tx, orgUuid, userUuid)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		`DELETE FROM %s.organization_member_link WHERE org_uuid = $1::uuid AND user_uuid = $2::uuid`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, orgUuid, userUuid)
+	if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(
+		`DELETE FROM %s.user_organization_link WHERE org_uuid = $1::uuid AND user_uuid = $2::uuid`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, orgUuid, userUuid)
+	if err != nil {
+		return err
+	}
+
+	return h.writeAuditLogTx(gc, tx, orgUuid, auditEntityMember, userUuid, "oidc-leave", before, nil)
+}
+
+const orgOidcGroupMappingColumns = `uuid, provider, group_name, permissions, created_by, created_at`
+
+func scanOrgOidcGroupMapping(row pgx.Row) (model.OrgOidcGroupMapping, error) {
+	var m model.OrgOidcGroupMapping
+	err := row.Scan(&m.Uuid, &m.Provider, &m.GroupName, &m.Permissions, &m.CreatedBy, &m.CreatedAt)
+	return m, err
+}
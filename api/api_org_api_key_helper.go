@@ -0,0 +1,259 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// Prefix of API keys, distinguishes them from JWTs in the Authorization header
+const apiKeyPrefix = "uak_"
+
+// Permissions which cannot be granted to API keys, managing people is left
+// to people
+const apiKeyForbiddenPermissions = app.UserPermManagePermissions | app.UserPermManageTeam
+
+// Interval in which the last used time of an API key is updated
+const apiKeyTouchInterval = time.Minute
+
+type apiKeyIdentity struct {
+	Uuid        string
+	OrgUuid     string
+	Permissions app.Permissions
+}
+
+// generateApiKey returns a new random API key, its hash and the prefix
+// shown in listings.
+func generateApiKey() (string, string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(b)
+	return key, hashOrgKey(key), key[:len(apiKeyPrefix)+8], nil
+}
+
+// apiKeyFromRequest returns the API key of a request, given in the header
+// X-Api-Key or as bearer token, empty if there is none.
+func apiKeyFromRequest(gc *gin.Context) string {
+	if key := strings.TrimSpace(gc.GetHeader("X-Api-Key")); key != "" {
+		return key
+	}
+	authHeader := gc.GetHeader("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer "+apiKeyPrefix) {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	return ""
+}
+
+// requestApiKey returns the API key identity the request is authenticated
+// with, ok is false for requests of users.
+func requestApiKey(gc *gin.Context) (apiKeyIdentity, bool) {
+	v, ok := gc.Get("api-key")
+	if !ok {
+		return apiKeyIdentity{}, false
+	}
+	identity, ok := v.(apiKeyIdentity)
+	return identity, ok
+}
+
+// apiKeyUuid returns the uuid of the API key of the request, empty for
+// requests of users.
+func (h *ApiHandler) apiKeyUuid(gc *gin.Context) string {
+	identity, _ := requestApiKey(gc)
+	return identity.Uuid
+}
+
+// actorUuid returns the uuid of the user or, for requests authenticated by
+// an API key, of the key. Used where a creator is required, e.g. by Pluto.
+func (h *ApiHandler) actorUuid(gc *gin.Context) string {
+	if identity, ok := requestApiKey(gc); ok {
+		return identity.Uuid
+	}
+	return h.userUuid(gc)
+}
+
+// lookupApiKey returns the identity of a valid API key. Revoked and expired
+// keys are not found. The last used time and IP are updated on the way.
+func (h *ApiHandler) lookupApiKey(ctx context.Context, key string, clientIp string) (apiKeyIdentity, bool, error) {
+	var identity apiKeyIdentity
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return identity, false, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT uuid, org_uuid, permissions, last_used_at
+		FROM %s.org_api_key
+		WHERE key_hash = $1
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())`,
+		h.DbSchema)
+	var permissions int64
+	var lastUsedAt *time.Time
+	err := h.DbPool.QueryRow(ctx, query, hashOrgKey(key)).
+		Scan(&identity.Uuid, &identity.OrgUuid, &permissions, &lastUsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return identity, false, nil
+		}
+		return identity, false, err
+	}
+	identity.Permissions = app.Permissions(permissions) &^ apiKeyForbiddenPermissions
+
+	if lastUsedAt == nil || time.Since(*lastUsedAt) > apiKeyTouchInterval {
+		query = fmt.Sprintf(
+			`UPDATE %s.org_api_key SET last_used_at = NOW(), last_used_ip = NULLIF($2, '') WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		_, err = h.DbPool.Exec(ctx, query, identity.Uuid, clientIp)
+		if err != nil {
+			debugf("touch api key failed: %v", err)
+		}
+	}
+
+	return identity, true, nil
+}
+
+// AuthMiddleware authenticates admin requests either by an API key of an
+// organization or by the access token of a user (app.JWTMiddleware).
+//
+// A request with an API key has no user, it acts as the organization of the
+// key with the permissions of the key. These are applied by the org
+// permission checks (RequireOrgPermissions, CheckOrgPermissionTx etc.) for
+// the organization of the key only, so API keys are accepted for routes
+// registered via ApiKeyRoutes only. Other routes, e.g. user scoped ones, are
+// rejected with 403.
+func (h *ApiHandler) AuthMiddleware(gc *gin.Context) {
+	key := apiKeyFromRequest(gc)
+	if key == "" {
+		app.JWTMiddleware(gc)
+		return
+	}
+
+	identity, ok, err := h.lookupApiKey(gc.Request.Context(), key, gc.ClientIP())
+	if err != nil {
+		debugf(err.Error())
+		apiRequest := grains_api.NewRequest(gc, "api-key-auth")
+		apiRequest.InternalServerError()
+		gc.Abort()
+		return
+	}
+	if !ok {
+		gc.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+		return
+	}
+	if !h.apiKeyRoute(gc) {
+		gc.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not available for API keys"})
+		return
+	}
+
+	gc.Set("api-key", identity)
+	gc.Next()
+}
+
+// ApiKeyRoutes registers routes of group which accept API keys. Only routes
+// registered this way are available for API keys. They must declare their
+// org permissions (RequireOrgPermissions etc.), which apply the permissions
+// of the key for the organization of the key only.
+func (h *ApiHandler) ApiKeyRoutes(group *gin.RouterGroup) ApiKeyRouteGroup {
+	return ApiKeyRouteGroup{h: h, group: group}
+}
+
+// ApiKeyRouteGroup registers routes accepting API keys, see ApiKeyRoutes
+type ApiKeyRouteGroup struct {
+	h     *ApiHandler
+	group *gin.RouterGroup
+}
+
+func (r ApiKeyRouteGroup) GET(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodGet, relativePath, handlers)
+}
+
+func (r ApiKeyRouteGroup) POST(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPost, relativePath, handlers)
+}
+
+func (r ApiKeyRouteGroup) PUT(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPut, relativePath, handlers)
+}
+
+func (r ApiKeyRouteGroup) DELETE(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodDelete, relativePath, handlers)
+}
+
+func (r ApiKeyRouteGroup) handle(method string, relativePath string, handlers []gin.HandlerFunc) {
+	if r.h.apiKeyRoutes == nil {
+		r.h.apiKeyRoutes = map[string]bool{}
+	}
+	r.h.apiKeyRoutes[method+" "+path.Join(r.group.BasePath(), relativePath)] = true
+	r.group.Handle(method, relativePath, handlers...)
+}
+
+// apiKeyRoute reports whether the route of the request accepts API keys.
+// Routes are registered before the server starts, so the map is only read
+// here.
+func (h *ApiHandler) apiKeyRoute(gc *gin.Context) bool {
+	return h.apiKeyRoutes[gc.Request.Method+" "+gc.FullPath()]
+}
+
+// apiKeyOrgPermissions returns the permissions of the API key of the
+// request for an organization, ok is false for requests of users.
+func apiKeyOrgPermissions(gc *gin.Context, orgUuid string) (app.Permissions, bool) {
+	identity, ok := requestApiKey(gc)
+	if !ok {
+		return 0, false
+	}
+	if identity.OrgUuid != orgUuid {
+		return 0, true
+	}
+	return identity.Permissions, true
+}
+
+// orgApiKeyPermissionsTx returns the permissions of key and whether it is a
+// valid API key of the organization.
+func (h *ApiHandler) orgApiKeyPermissionsTx(ctx context.Context, tx pgx.Tx, orgUuid string, key string) (app.Permissions, bool, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return 0, false, nil
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.org_api_key
+		SET last_used_at = NOW()
+		WHERE key_hash = $1
+			AND org_uuid = $2::uuid
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING permissions`,
+		h.DbSchema)
+	var permissions int64
+	err := tx.QueryRow(ctx, query, hashOrgKey(key), orgUuid).Scan(&permissions)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return app.Permissions(permissions), true, nil
+}
+
+const orgApiKeyColumns = `uuid, name, key_prefix, permissions, expires_at, last_used_at, last_used_ip, created_by, created_at, revoked_at`
+
+func scanOrgApiKey(row pgx.Row) (model.OrgApiKey, error) {
+	var k model.OrgApiKey
+	err := row.Scan(
+		&k.Uuid, &k.Name, &k.KeyPrefix, &k.Permissions, &k.ExpiresAt,
+		&k.LastUsedAt, &k.LastUsedIp, &k.CreatedBy, &k.CreatedAt, &k.RevokedAt)
+	return k, err
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -67,6 +68,71 @@ func (h *ApiHandler) GetOrgUuidBySpaceUuidTx(
 	return orgUuid, nil
 }
 
+func (h *ApiHandler) GetOrgUuidByVenueUuidTx(
+	gc *gin.Context,
+	tx pgx.Tx,
+	venueUuid string,
+) (string, error) {
+	ctx := gc.Request.Context()
+	query := fmt.Sprintf(`SELECT v.org_uuid FROM %s.venue v WHERE v.uuid = $1::uuid`, h.DbSchema)
+	orgUuid := ""
+	err := tx.QueryRow(ctx, query, venueUuid).Scan(&orgUuid)
+	if err != nil {
+		return "", err
+	}
+
+	return orgUuid, nil
+}
+
+func (h *ApiHandler) GetOrgUuidByPortalUuidTx(
+	gc *gin.Context,
+	tx pgx.Tx,
+	portalUuid string,
+) (string, error) {
+	ctx := gc.Request.Context()
+	query := fmt.Sprintf(`SELECT p.org_uuid FROM %s.portal p WHERE p.uuid = $1::uuid`, h.DbSchema)
+	orgUuid := ""
+	err := tx.QueryRow(ctx, query, portalUuid).Scan(&orgUuid)
+	if err != nil {
+		return "", err
+	}
+
+	return orgUuid, nil
+}
+
+// GetOrgUuidByEntityTx returns the uuid of the organization owning the given
+// entity. Returns pgx.ErrNoRows if the entity does not exist.
+func (h *ApiHandler) GetOrgUuidByEntityTx(
+	gc *gin.Context,
+	tx pgx.Tx,
+	entity OrgEntity,
+	entityUuid string,
+) (string, error) {
+	switch entity {
+	case OrgEntityOrg:
+		ctx := gc.Request.Context()
+		query := fmt.Sprintf(`SELECT o.uuid FROM %s.organization o WHERE o.uuid = $1::uuid`, h.DbSchema)
+		orgUuid := ""
+		err := tx.QueryRow(ctx, query, entityUuid).Scan(&orgUuid)
+		if err != nil {
+			return "", err
+		}
+		return orgUuid, nil
+	case OrgEntityVenue:
+		return h.GetOrgUuidByVenueUuidTx(gc, tx, entityUuid)
+	case OrgEntitySpace:
+		return h.GetOrgUuidBySpaceUuidTx(gc, tx, entityUuid)
+	case OrgEntityEvent:
+		return h.GetOrgUuidByEventUuidTx(gc, tx, entityUuid)
+	case OrgEntityEventDate:
+		return h.GetOrgUuidByEventDateUuidTx(gc, tx, entityUuid)
+	case OrgEntityPortal:
+		return h.GetOrgUuidByPortalUuidTx(gc, tx, entityUuid)
+	default:
+		return "", fmt.Errorf("unknown entity: %s", entity)
+	}
+}
+
 // CheckOrgPermissionTx verifies if a user has a specific permission
 // in the given organization. Returns an ApiTxError if the check fails.
 func (h *ApiHandler) CheckOrgPermissionTx(
@@ -122,12 +188,17 @@ func (h *ApiHandler) CheckAllOrgPermissionsTx(
 }
 
 // GetUserOrgPermissionsTx returns the permissions a user has for an organization.
+// For requests authenticated by an API key the permissions of the key apply.
 func (h *ApiHandler) GetUserOrgPermissionsTx(
 	gc *gin.Context,
 	tx pgx.Tx,
 	userUuid string,
 	orgUuid string,
 ) (app.Permissions, error) {
+	if permissions, ok := apiKeyOrgPermissions(gc, orgUuid); ok {
+		return permissions, nil
+	}
+
 	ctx := gc.Request.Context()
 	var result pgtype.Int8
 
@@ -149,3 +220,13 @@ func (h *ApiHandler) GetUserOrgPermissionsTx(
 	}
 	return app.Permissions(result.Int64), nil
 }
+
+// isOrgFavoriteListTx reports whether the favorite list belongs to the org
+func (h *ApiHandler) isOrgFavoriteListTx(ctx context.Context, tx pgx.Tx, orgUuid string, listUuid string) (bool, error) {
+	query := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s.favorite_list WHERE uuid = $1::uuid AND org_uuid = $2::uuid)`,
+		h.DbSchema)
+	var exists bool
+	err := tx.QueryRow(ctx, query, listUuid, orgUuid).Scan(&exists)
+	return exists, err
+}
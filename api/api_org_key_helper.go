@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// generateOrgKey returns a new random organization key and its hash.
+// Only the hash is stored, the key is shown to the user once.
+func generateOrgKey() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key := hex.EncodeToString(b)
+	return key, hashOrgKey(key), nil
+}
+
+func hashOrgKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyOrgKeyTx reports whether key is the current key of the organization.
+func (h *ApiHandler) verifyOrgKeyTx(ctx context.Context, tx pgx.Tx, orgUuid string, key string) (bool, error) {
+	if key == "" {
+		return false, nil
+	}
+
+	query := fmt.Sprintf(`SELECT sync_key_hash FROM %s.organization WHERE uuid = $1::uuid`, h.DbSchema)
+	var keyHash *string
+	err := tx.QueryRow(ctx, query, orgUuid).Scan(&keyHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if keyHash == nil {
+		return false, nil
+	}
+
+	return subtle.ConstantTimeCompare([]byte(*keyHash), []byte(hashOrgKey(key))) == 1, nil
+}
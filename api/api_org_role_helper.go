@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// Permissions members cannot change for themselves, neither directly nor by
+// a role they hold
+const selfProtectedPermissions = app.UserPermManagePermissions | app.UserPermManageTeam
+
+// memberPermissions holds how the permissions of a member are composed.
+type memberPermissions struct {
+	RoleUuid         *string
+	RolePermissions  int64
+	ExtraPermissions int64
+	Permissions      int64
+}
+
+// orgMemberRoleQuery returns the query selecting roles as scanned by
+// scanOrgMemberRole, conditions are appended with WHERE on the alias r.
+func (h *ApiHandler) orgMemberRoleQuery() string {
+	return fmt.Sprintf(`
+		SELECT r.uuid, r.name, r.description, r.permissions, r.created_at, r.modified_at,
+			(SELECT COUNT(*) FROM %[1]s.user_organization_link l WHERE l.role_uuid = r.uuid)
+		FROM %[1]s.org_member_role r`,
+		h.DbSchema)
+}
+
+func scanOrgMemberRole(row pgx.Row) (model.OrgMemberRole, error) {
+	var r model.OrgMemberRole
+	err := row.Scan(&r.Uuid, &r.Name, &r.Description, &r.Permissions, &r.CreatedAt, &r.ModifiedAt, &r.MemberCount)
+	return r, err
+}
+
+// orgMemberRolePermissionsTx returns the permissions of a role of an
+// organization, ok is false if the organization has no such role.
+func (h *ApiHandler) orgMemberRolePermissionsTx(ctx context.Context, tx pgx.Tx, orgUuid string, roleUuid string) (int64, bool, error) {
+	query := fmt.Sprintf(
+		`SELECT permissions FROM %s.org_member_role WHERE uuid = $1::uuid AND org_uuid = $2::uuid`,
+		h.DbSchema)
+	var permissions int64
+	err := tx.QueryRow(ctx, query, roleUuid, orgUuid).Scan(&permissions)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return permissions, true, nil
+}
+
+// loadMemberPermissionsTx returns and locks the permissions of a member of an
+// organization, ok is false if the user is no member.
+func (h *ApiHandler) loadMemberPermissionsTx(ctx context.Context, tx pgx.Tx, orgUuid string, memberUuid string) (memberPermissions, bool, error) {
+	query := fmt.Sprintf(`
+		SELECT l.role_uuid::text, COALESCE(r.permissions, 0), l.extra_permissions, l.permissions
+		FROM %[1]s.user_organization_link l
+		LEFT JOIN %[1]s.org_member_role r ON r.uuid = l.role_uuid
+		WHERE l.org_uuid = $1::uuid AND l.user_uuid = $2::uuid
+		FOR UPDATE OF l`,
+		h.DbSchema)
+	var m memberPermissions
+	err := tx.QueryRow(ctx, query, orgUuid, memberUuid).
+		Scan(&m.RoleUuid, &m.RolePermissions, &m.ExtraPermissions, &m.Permissions)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return m, false, nil
+		}
+		return m, false, err
+	}
+	return m, true, nil
+}
+
+// setMemberPermissionsTx assigns a role (none if empty) and extra
+// permissions to a member and returns the resulting effective permissions.
+func (h *ApiHandler) setMemberPermissionsTx(ctx context.Context, tx pgx.Tx, orgUuid string, memberUuid string, roleUuid string, extraPermissions int64) (int64, error) {
+	query := fmt.Sprintf(`
+		UPDATE %[1]s.user_organization_link l
+		SET role_uuid = NULLIF($3, '')::uuid,
+			extra_permissions = $4,
+			permissions = $4 | COALESCE(
+				(SELECT r.permissions FROM %[1]s.org_member_role r
+				WHERE r.uuid = NULLIF($3, '')::uuid AND r.org_uuid = l.org_uuid), 0)
+		WHERE l.org_uuid = $1::uuid AND l.user_uuid = $2::uuid
+		RETURNING l.permissions`,
+		h.DbSchema)
+	var permissions int64
+	err := tx.QueryRow(ctx, query, orgUuid, memberUuid, roleUuid, extraPermissions).Scan(&permissions)
+	return permissions, err
+}
+
+// refreshRoleMembersTx recomputes the permissions of all members holding a
+// role after the role has been changed and returns their number.
+func (h *ApiHandler) refreshRoleMembersTx(ctx context.Context, tx pgx.Tx, roleUuid string) (int64, error) {
+	query := fmt.Sprintf(`
+		UPDATE %[1]s.user_organization_link l
+		SET permissions = r.permissions | l.extra_permissions
+		FROM %[1]s.org_member_role r
+		WHERE r.uuid = $1::uuid AND l.role_uuid = r.uuid`,
+		h.DbSchema)
+	res, err := tx.Exec(ctx, query, roleUuid)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+// checkPermissionChange guards changes of the permissions of a member by
+// the user of the request: users cannot grant permissions they do not hold
+// themselves and cannot change their own protected permissions.
+func checkPermissionChange(callerUuid string, callerPermissions app.Permissions, memberUuid string, before int64, after int64) *ApiTxError {
+	if memberUuid == callerUuid && app.Permissions(before^after).HasAny(selfProtectedPermissions) {
+		return ApiErrForbidden("you cannot change your own permissions to manage permissions or the team")
+	}
+	if !callerPermissions.HasAll(app.Permissions(after &^ before)) {
+		return ApiErrForbidden("you cannot grant permissions you do not have")
+	}
+	return nil
+}
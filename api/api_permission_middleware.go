@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+)
+
+// OrgEntity names the kind of entity an admin route operates on. The values
+// match the source names used by RefreshEventProjections and by the Pluto
+// image contexts.
+type OrgEntity string
+
+const (
+	OrgEntityOrg       OrgEntity = "organization"
+	OrgEntityVenue     OrgEntity = "venue"
+	OrgEntitySpace     OrgEntity = "space"
+	OrgEntityEvent     OrgEntity = "event"
+	OrgEntityEventDate OrgEntity = "event_date"
+	OrgEntityPortal    OrgEntity = "portal"
+)
+
+// orgEntityParams maps each entity kind to the route parameter holding its uuid.
+var orgEntityParams = map[OrgEntity]string{
+	OrgEntityOrg:       "orgUuid",
+	OrgEntityVenue:     "venueUuid",
+	OrgEntitySpace:     "spaceUuid",
+	OrgEntityEvent:     "eventUuid",
+	OrgEntityEventDate: "dateUuid",
+	OrgEntityPortal:    "portalUuid",
+}
+
+// RequireOrgPermissions returns a middleware which resolves the organization
+// owning the entity addressed by the route and aborts with 403 unless the
+// authenticated user holds all permissions in permMask for that organization.
+//
+// On success the organization uuid and the user's permissions are stored in
+// the context as "org-uuid" and "org-permissions".
+func (h *ApiHandler) RequireOrgPermissions(entity OrgEntity, permMask app.Permissions) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		h.checkOrgPermissions(gc, entity, gc.Param(orgEntityParams[entity]), permMask, true)
+	}
+}
+
+// RequireAnyOrgPermission works like RequireOrgPermissions but accepts the
+// request if the user holds at least one permission in permMask.
+func (h *ApiHandler) RequireAnyOrgPermission(entity OrgEntity, permMask app.Permissions) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		h.checkOrgPermissions(gc, entity, gc.Param(orgEntityParams[entity]), permMask, false)
+	}
+}
+
+// RequireContextOrgPermissions is used by routes addressing their entity via
+// the generic :context and :contextUuid parameters (e.g. image uploads).
+// permsByEntity declares the required permissions per supported context,
+// requests for other contexts are rejected.
+func (h *ApiHandler) RequireContextOrgPermissions(permsByEntity map[OrgEntity]app.Permissions) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		entity := OrgEntity(gc.Param("context"))
+		permMask, ok := permsByEntity[entity]
+		if !ok {
+			apiRequest := grains_api.NewRequest(gc, "admin-permission-check")
+			apiRequest.Error(http.StatusBadRequest, fmt.Sprintf("unsupported context: %s", entity))
+			gc.Abort()
+			return
+		}
+		h.checkOrgPermissions(gc, entity, gc.Param("contextUuid"), permMask, true)
+	}
+}
+
+func (h *ApiHandler) checkOrgPermissions(
+	gc *gin.Context,
+	entity OrgEntity,
+	entityUuid string,
+	permMask app.Permissions,
+	requireAll bool,
+) {
+	apiRequest := grains_api.NewRequest(gc, "admin-permission-check")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	if entityUuid == "" {
+		apiRequest.Required(fmt.Sprintf("%s uuid is required", entity))
+		gc.Abort()
+		return
+	}
+
+	var orgUuid string
+	var orgPermissions app.Permissions
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var err error
+		orgUuid, err = h.GetOrgUuidByEntityTx(gc, tx, entity, entityUuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("%s not found", entity)
+			}
+			return TxInternalError(err)
+		}
+
+		orgPermissions, err = h.GetUserOrgPermissionsTx(gc, tx, userUuid, orgUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		granted := orgPermissions.HasAll(permMask)
+		if !requireAll {
+			granted = orgPermissions.HasAny(permMask)
+		}
+		if !granted {
+			return ApiErrForbidden("insufficient permissions")
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		gc.Abort()
+		return
+	}
+
+	gc.Set("org-uuid", orgUuid)
+	gc.Set("org-permissions", orgPermissions)
+	gc.Next()
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// updatePortalJsonColumn sets one of the JSON columns of a portal (style,
+// prefilter, header, footer) and writes the change to the audit log.
+func (h *ApiHandler) updatePortalJsonColumn(gc *gin.Context, portalUuid string, column string, value []byte) error {
+	ctx := gc.Request.Context()
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		before, err := h.loadAuditStateTx(ctx, tx, "portal", portalUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if before == nil {
+			return nil
+		}
+
+		query := fmt.Sprintf(
+			`UPDATE %s.portal SET %s = $1::jsonb WHERE uuid = $2::uuid`,
+			h.DbSchema, pgx.Identifier{column}.Sanitize())
+		_, err = tx.Exec(ctx, query, value, portalUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		orgUuid, err := h.GetOrgUuidByPortalUuidTx(gc, tx, portalUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		err = h.writeAuditLogTx(
+			gc, tx, orgUuid, auditEntityPortal, portalUuid, "update-"+column,
+			map[string]any{column: before[column]}, map[string]any{column: json.RawMessage(value)})
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/app"
+)
+
+// Types of push messages
+const (
+	pushEventDateReleased  = "event_date.released"
+	pushEventDateCancelled = "event_date.cancelled"
+)
+
+// pushFollowTargetTypes lists what a push subscription can follow
+var pushFollowTargetTypes = []string{"org", "venue", "event_type", "portal"}
+
+const (
+	pushBatchSize       = 50
+	pushMaxAttempts     = 8
+	pushLease           = 5 * time.Minute
+	pushMaxRetryWait    = 6 * time.Hour
+	pushTTL             = 24 * time.Hour
+	pushRetentionDays   = 30
+	pushMaxFollows      = 200
+	pushQuietTimeLayout = "15:04"
+)
+
+func hasPushFollowsTx(ctx context.Context, tx pgx.Tx) (bool, error) {
+	query := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s.push_follow)`,
+		app.UranusInstance.Config.DbSchema)
+	var exists bool
+	err := tx.QueryRow(ctx, query).Scan(&exists)
+	return exists, err
+}
+
+// publicEventDateStatus returns the status visitors see for an event date:
+// its own cancelled, deferred or rescheduled status or the status of the
+// event.
+func publicEventDateStatus(s webhookState) string {
+	if status := eventDateStatusState(&s.ReleaseStatus); status != "inherited" {
+		return status
+	}
+	return s.EventReleaseStatus
+}
+
+// eventDatePushChanges compares the event date states before and after a
+// refresh and returns the dates, which were released or cancelled, with the
+// type of push message. New dates of released events count as released,
+// only dates visitors could see before are reported as cancelled.
+func eventDatePushChanges(before, after map[string]webhookState) ([]string, []string) {
+	var eventDateUuids, types []string
+	for uuid, a := range after {
+		b, existed := before[uuid]
+		status := publicEventDateStatus(a)
+		previous := ""
+		if existed {
+			previous = publicEventDateStatus(b)
+		}
+		if status == previous {
+			continue
+		}
+
+		switch {
+		case status == "released":
+			types = append(types, pushEventDateReleased)
+		case status == "cancelled" && isWebhookPublicStatus(previous):
+			types = append(types, pushEventDateCancelled)
+		default:
+			continue
+		}
+		eventDateUuids = append(eventDateUuids, uuid)
+	}
+	return eventDateUuids, types
+}
+
+// enqueuePushMessagesTx writes a push message for every subscription
+// following the organization, venue, an event type or a portal of the
+// changed event dates into the outbox. Past dates are skipped.
+func enqueuePushMessagesTx(ctx context.Context, tx pgx.Tx, eventDateUuids []string, types []string) error {
+	if len(eventDateUuids) == 0 {
+		return nil
+	}
+	query := strings.Replace(app.UranusInstance.SqlPushEnqueue, "{{portal_conditions}}", app.UranusInstance.SqlPortalCondition, 1)
+	_, err := tx.Exec(ctx, query, eventDateUuids, types)
+	return err
+}
+
+// pushQuietUntil reports whether now is within the quiet hours from
+// quietStart to quietEnd ("15:04", in timeZone) and returns their end.
+// Quiet hours may span midnight, e.g. from 22:00 to 07:00.
+func pushQuietUntil(now time.Time, quietStart string, quietEnd string, timeZone string) (time.Time, bool) {
+	start, err := time.Parse(pushQuietTimeLayout, quietStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := time.Parse(pushQuietTimeLayout, quietEnd)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	var quiet bool
+	switch {
+	case startMinute < endMinute:
+		quiet = minute >= startMinute && minute < endMinute
+	case startMinute > endMinute:
+		quiet = minute >= startMinute || minute < endMinute
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+func pushRetryDelay(attempt int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempt && delay < pushMaxRetryWait; i++ {
+		delay *= 2
+	}
+	return min(delay, pushMaxRetryWait)
+}
+
+// pushEventDate is the payload of a push message as written by the enqueue
+// query
+type pushEventDate struct {
+	EventUuid     string  `json:"event_uuid"`
+	EventDateUuid string  `json:"event_date_uuid"`
+	Title         string  `json:"title"`
+	StartDate     string  `json:"start_date"`
+	StartTime     *string `json:"start_time"`
+	VenueName     *string `json:"venue_name"`
+	StatusReason  *string `json:"status_reason"`
+}
+
+// pushNotification returns the JSON the service worker of the frontend
+// shows as notification: title, body with date and venue, the link of the
+// event date and a tag, so a cancellation replaces the release.
+func pushNotification(messageType string, p pushEventDate, frontend string) ([]byte, error) {
+	when := p.StartDate
+	if d, err := time.Parse("2006-01-02", p.StartDate); err == nil {
+		when = d.Format("Mon 02.01.2006")
+	}
+	if p.StartTime != nil && len(*p.StartTime) >= 5 {
+		when += ", " + (*p.StartTime)[:5]
+	}
+	body := when
+	if p.VenueName != nil && *p.VenueName != "" {
+		body += " · " + *p.VenueName
+	}
+
+	title := p.Title
+	if messageType == pushEventDateCancelled {
+		title = "Cancelled: " + p.Title
+		if p.StatusReason != nil && *p.StatusReason != "" {
+			body += "\n" + *p.StatusReason
+		}
+	}
+
+	notification := map[string]any{
+		"type":  messageType,
+		"title": title,
+		"body":  body,
+		"tag":   p.EventDateUuid,
+		"data":  p,
+	}
+	if frontend != "" {
+		notification["url"] = fmt.Sprintf("%s/event/%s/date/%s", frontend, p.EventUuid, p.EventDateUuid)
+	}
+	return json.Marshal(notification)
+}
+
+type pendingPushMessage struct {
+	Id               int64
+	SubscriptionUuid string
+	Type             string
+	Payload          json.RawMessage
+	AttemptCount     int
+	CreatedAt        time.Time
+	Endpoint         string
+	P256dh           string
+	Auth             string
+	QuietStart       *string
+	QuietEnd         *string
+	TimeZone         string
+}
+
+// SendPushMessages sends a batch of due push messages. The messages are
+// claimed for pushLease with SKIP LOCKED, so several API processes can
+// share the work. Returns the number of claimed messages.
+func (h *ApiHandler) SendPushMessages(ctx context.Context) (int, error) {
+	if h.WebPush == nil {
+		return 0, nil
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %[1]s.push_message m
+		SET attempt_count = m.attempt_count + 1,
+			last_attempt_at = NOW(),
+			next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM %[1]s.push_subscription s
+		WHERE s.uuid = m.subscription_uuid
+			AND m.id IN (
+				SELECT id
+				FROM %[1]s.push_message
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at, id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING
+			m.id, m.subscription_uuid::text, m.type, m.payload, m.attempt_count, m.created_at,
+			s.endpoint, s.p256dh, s.auth,
+			TO_CHAR(s.quiet_start, 'HH24:MI'), TO_CHAR(s.quiet_end, 'HH24:MI'), s.time_zone`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, pushBatchSize, pushLease.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	var messages []pendingPushMessage
+	for rows.Next() {
+		var m pendingPushMessage
+		err := rows.Scan(
+			&m.Id, &m.SubscriptionUuid, &m.Type, &m.Payload, &m.AttemptCount, &m.CreatedAt,
+			&m.Endpoint, &m.P256dh, &m.Auth,
+			&m.QuietStart, &m.QuietEnd, &m.TimeZone)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	client := h.webhookHttpClient()
+	for _, m := range messages {
+		if err := h.sendPushMessage(ctx, client, m); err != nil {
+			debugf("push message %d failed: %v", m.Id, err)
+		}
+	}
+
+	return len(messages), nil
+}
+
+// sendPushMessage sends a claimed message and stores the result. Messages
+// within quiet hours are held back until they end without counting as an
+// attempt, messages older than pushTTL are dropped. Subscriptions the push
+// service reports as gone are deleted along with their follows and
+// messages, other failures are retried with exponential backoff until
+// pushMaxAttempts.
+func (h *ApiHandler) sendPushMessage(ctx context.Context, client *http.Client, m pendingPushMessage) error {
+	now := time.Now()
+
+	if m.QuietStart != nil && m.QuietEnd != nil {
+		if until, quiet := pushQuietUntil(now, *m.QuietStart, *m.QuietEnd, m.TimeZone); quiet {
+			query := fmt.Sprintf(`
+				UPDATE %s.push_message
+				SET attempt_count = attempt_count - 1, next_attempt_at = $2
+				WHERE id = $1`,
+				h.DbSchema)
+			_, err := h.DbPool.Exec(ctx, query, m.Id, until)
+			return err
+		}
+	}
+
+	var responseStatus *int
+	var err error
+	if now.Sub(m.CreatedAt) > pushTTL {
+		err = fmt.Errorf("expired after %s", pushTTL)
+		m.AttemptCount = pushMaxAttempts
+	} else {
+		var payload pushEventDate
+		var body []byte
+		if err = json.Unmarshal(m.Payload, &payload); err == nil {
+			body, err = pushNotification(m.Type, payload, h.Config.Frontend)
+		}
+		if err == nil {
+			var status int
+			status, err = h.WebPush.Send(ctx, client, webPushMessage{
+				Endpoint: m.Endpoint,
+				P256dh:   m.P256dh,
+				Auth:     m.Auth,
+				Payload:  body,
+				TTL:      pushTTL - now.Sub(m.CreatedAt),
+				Topic:    strings.ReplaceAll(payload.EventDateUuid, "-", ""),
+				Urgency:  "normal",
+			})
+			if status != 0 {
+				responseStatus = &status
+			}
+			switch status {
+			case http.StatusNotFound, http.StatusGone:
+				query := fmt.Sprintf(`DELETE FROM %s.push_subscription WHERE uuid = $1::uuid`, h.DbSchema)
+				if _, dbErr := h.DbPool.Exec(ctx, query, m.SubscriptionUuid); dbErr != nil {
+					return dbErr
+				}
+				debugf("push subscription %s is gone, deleted", m.SubscriptionUuid)
+				return nil
+			case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusForbidden:
+				// Retrying does not help
+				m.AttemptCount = pushMaxAttempts
+			}
+		}
+	}
+
+	status := "sent"
+	var retryDelay time.Duration
+	var errText *string
+	if err != nil {
+		text := err.Error()
+		errText = &text
+		if m.AttemptCount >= pushMaxAttempts {
+			status = "failed"
+		} else {
+			status = "pending"
+			retryDelay = pushRetryDelay(m.AttemptCount)
+		}
+	}
+
+	query := fmt.Sprintf(`
+		WITH message AS (
+			UPDATE %[1]s.push_message
+			SET status = $2,
+				response_status = $3,
+				error = $4,
+				next_attempt_at = NOW() + make_interval(secs => $5),
+				sent_at = CASE WHEN $2 = 'sent' THEN NOW() END
+			WHERE id = $1
+			RETURNING subscription_uuid
+		)
+		UPDATE %[1]s.push_subscription
+		SET last_success_at = NOW()
+		WHERE $2 = 'sent' AND uuid = (SELECT subscription_uuid FROM message)`,
+		h.DbSchema)
+	_, dbErr := h.DbPool.Exec(ctx, query, m.Id, status, responseStatus, errText, retryDelay.Seconds())
+	if dbErr != nil {
+		return dbErr
+	}
+	return err
+}
+
+// PurgePushMessages deletes sent and failed push messages after
+// pushRetentionDays.
+func (h *ApiHandler) PurgePushMessages(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		DELETE FROM %s.push_message
+		WHERE status <> 'pending' AND COALESCE(last_attempt_at, created_at) < NOW() - make_interval(days => $1)`,
+		h.DbSchema)
+	_, err := h.DbPool.Exec(ctx, query, pushRetentionDays)
+	return err
+}
+
+// RunPushWorker sends pending push messages in the given interval until ctx
+// is cancelled. Full batches are followed by the next batch at once. Old
+// messages are purged once a day. Without VAPID keys it returns at once.
+func (h *ApiHandler) RunPushWorker(ctx context.Context, interval time.Duration) {
+	if h.WebPush == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		for {
+			n, err := h.SendPushMessages(ctx)
+			if err != nil {
+				debugf("send push messages failed: %v", err)
+			}
+			if err != nil || n < pushBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) > 24*time.Hour {
+			if err := h.PurgePushMessages(ctx); err != nil {
+				debugf("purge push messages failed: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -45,7 +45,7 @@ func addUpdateClauseNullable[T any](fieldName string, value NullableField[T], se
 }
 
 func addUpdateClauseUuid7(fieldName string, uuid string, setClauses *[]string, args *[]interface{}, argPos int) int {
-	*setClauses = append(*setClauses, fmt.Sprintf("%s = $%d::uuid", fieldName, argPos))
+	*setClauses = append(*setClauses, fmt.Sprintf("%s = NULLIF($%d, '')::uuid", fieldName, argPos))
 	*args = append(*args, uuid)
 	argPos++
 	return argPos
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// RateLimit returns a middleware which limits the requests of a route group
+// by the token bucket configured in Config.RateLimits. Groups without
+// configuration are not limited. Limited requests get 429 with Retry-After.
+// If the store fails, requests are let through.
+//
+// For groups keyed by "user" the middleware must run after AuthMiddleware.
+func (h *ApiHandler) RateLimit(group string) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		limit, ok := h.Config.RateLimits[group]
+		if !ok || h.RateLimits == nil || limit.Requests <= 0 || limit.WindowSeconds <= 0 {
+			gc.Next()
+			return
+		}
+		if limit.OnlyWithParam != "" {
+			if value, _ := GetContextParam(gc, limit.OnlyWithParam); value == "" {
+				gc.Next()
+				return
+			}
+		}
+
+		key := group + ":ip:" + gc.ClientIP()
+		if limit.Key == "user" {
+			if apiKeyUuid := h.apiKeyUuid(gc); apiKeyUuid != "" {
+				key = group + ":api-key:" + apiKeyUuid
+			} else if userUuid := h.userUuid(gc); userUuid != "" {
+				key = group + ":user:" + userUuid
+			}
+		}
+
+		allowed, retryAfter, err := h.RateLimits.Take(gc.Request.Context(), key, limit)
+		if err != nil {
+			debugf("rate limit %s failed: %v", group, err)
+			gc.Next()
+			return
+		}
+		if !allowed {
+			abortTooManyRequests(gc, "rate-limit", retryAfter, "too many requests, try again later")
+			return
+		}
+
+		gc.Next()
+	}
+}
+
+// abortTooManyRequests aborts a request with 429 and a Retry-After header.
+func abortTooManyRequests(gc *gin.Context, responseType string, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	gc.Header("Retry-After", fmt.Sprint(seconds))
+	apiRequest := grains_api.NewRequest(gc, responseType)
+	apiRequest.SetMeta("retry_after", seconds)
+	apiRequest.Error(http.StatusTooManyRequests, message)
+	gc.Abort()
+}
+
+// RunRateLimitCleanupWorker removes unused rate limit buckets in the given
+// interval until ctx is cancelled.
+func (h *ApiHandler) RunRateLimitCleanupWorker(ctx context.Context, interval time.Duration) {
+	if h.RateLimits == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := h.RateLimits.Cleanup(ctx); err != nil {
+			debugf("rate limit cleanup failed: %v", err)
+		}
+	}
+}
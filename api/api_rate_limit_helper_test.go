@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAbortTooManyRequestsRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		want       string
+	}{
+		{name: "whole seconds", retryAfter: 3 * time.Second, want: "3"},
+		{name: "rounded up", retryAfter: 2100 * time.Millisecond, want: "3"},
+		{name: "at least one second", retryAfter: 10 * time.Millisecond, want: "1"},
+		{name: "zero", retryAfter: 0, want: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			gc, _ := gin.CreateTestContext(w)
+			gc.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			abortTooManyRequests(gc, "rate-limit", tt.retryAfter, "too many requests")
+
+			if w.Code != http.StatusTooManyRequests {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusTooManyRequests)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.want {
+				t.Errorf("got Retry-After %q, want %q", got, tt.want)
+			}
+			if !gc.IsAborted() {
+				t.Error("request was not aborted")
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sndcds/uranus/app"
+)
+
+// RateLimitStore keeps the token buckets of the rate limiter.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key and reports whether there
+	// was one. If not, retryAfter tells when the next token is available.
+	Take(ctx context.Context, key string, limit app.RateLimit) (allowed bool, retryAfter time.Duration, err error)
+	// Cleanup removes buckets which have not been used for a while.
+	Cleanup(ctx context.Context) error
+}
+
+// NewRateLimitStore returns the store selected by Config.RateLimitStore.
+func NewRateLimitStore(config *app.Config, dbPool *pgxpool.Pool) (RateLimitStore, error) {
+	switch config.RateLimitStore {
+	case "", "memory":
+		return &memoryRateLimitStore{buckets: map[string]*rateLimitBucket{}}, nil
+	case "postgres":
+		return &pgRateLimitStore{dbPool: dbPool, dbSchema: config.DbSchema}, nil
+	default:
+		return nil, fmt.Errorf("unknown rate_limit_store: %s", config.RateLimitStore)
+	}
+}
+
+type rateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     app.RateLimit
+}
+
+// rateLimitBurst returns the size of the buckets of a limit.
+func rateLimitBurst(limit app.RateLimit) float64 {
+	if limit.Burst > 0 {
+		return float64(limit.Burst)
+	}
+	return float64(limit.Requests)
+}
+
+func rateLimitPerSecond(limit app.RateLimit) float64 {
+	return float64(limit.Requests) / float64(limit.WindowSeconds)
+}
+
+// refillTokens returns the tokens of a bucket which held tokens at updatedAt.
+func refillTokens(tokens float64, updatedAt time.Time, now time.Time, limit app.RateLimit) float64 {
+	elapsed := now.Sub(updatedAt).Seconds()
+	if elapsed <= 0 {
+		return tokens
+	}
+	return math.Min(rateLimitBurst(limit), tokens+elapsed*rateLimitPerSecond(limit))
+}
+
+// takeToken refills a bucket holding tokens since updatedAt and takes a
+// token. It returns the new number of tokens, whether a token was taken and
+// otherwise the time until the next token.
+func takeToken(tokens float64, updatedAt time.Time, now time.Time, limit app.RateLimit) (float64, bool, time.Duration) {
+	tokens = refillTokens(tokens, updatedAt, now, limit)
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+
+	wait := time.Duration((1 - tokens) / rateLimitPerSecond(limit) * float64(time.Second))
+	return tokens, false, wait
+}
+
+// memoryRateLimitStore keeps the buckets of a single instance.
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*rateLimitBucket
+}
+
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, limit app.RateLimit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &rateLimitBucket{tokens: rateLimitBurst(limit), updatedAt: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+
+	tokens, allowed, retryAfter := takeToken(b.tokens, b.updatedAt, now, limit)
+	b.tokens = tokens
+	b.updatedAt = now
+	return allowed, retryAfter, nil
+}
+
+// Cleanup removes buckets which have been refilled completely, they are
+// recreated full on their next use.
+func (s *memoryRateLimitStore) Cleanup(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, b := range s.buckets {
+		if refillTokens(b.tokens, b.updatedAt, now, b.limit) >= rateLimitBurst(b.limit) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
+
+// pgRateLimitStore keeps the buckets in the table rate_limit_bucket, shared
+// by all instances using the database.
+type pgRateLimitStore struct {
+	dbPool   *pgxpool.Pool
+	dbSchema string
+}
+
+func (s *pgRateLimitStore) Take(ctx context.Context, key string, limit app.RateLimit) (bool, time.Duration, error) {
+	var allowed bool
+	var retryAfter time.Duration
+
+	txErr := WithTransaction(ctx, s.dbPool, func(tx pgx.Tx) *ApiTxError {
+		query := fmt.Sprintf(`
+			INSERT INTO %s.rate_limit_bucket (key, tokens, updated_at)
+			VALUES ($1, $2, NOW())
+			ON CONFLICT (key) DO NOTHING`,
+			s.dbSchema)
+		_, err := tx.Exec(ctx, query, key, rateLimitBurst(limit))
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		query = fmt.Sprintf(
+			`SELECT tokens, updated_at, NOW() FROM %s.rate_limit_bucket WHERE key = $1 FOR UPDATE`,
+			s.dbSchema)
+		var tokens float64
+		var updatedAt, now time.Time
+		err = tx.QueryRow(ctx, query, key).Scan(&tokens, &updatedAt, &now)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		tokens, allowed, retryAfter = takeToken(tokens, updatedAt, now, limit)
+
+		query = fmt.Sprintf(
+			`UPDATE %s.rate_limit_bucket SET tokens = $2, updated_at = $3 WHERE key = $1`,
+			s.dbSchema)
+		_, err = tx.Exec(ctx, query, key, tokens, now)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		return false, 0, txErr
+	}
+
+	return allowed, retryAfter, nil
+}
+
+func (s *pgRateLimitStore) Cleanup(ctx context.Context) error {
+	query := fmt.Sprintf(
+		`DELETE FROM %s.rate_limit_bucket WHERE updated_at < NOW() - INTERVAL '1 day'`,
+		s.dbSchema)
+	_, err := s.dbPool.Exec(ctx, query)
+	return err
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sndcds/uranus/app"
+)
+
+func TestTakeToken(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	// 10 requests per 10 seconds refill one token per second
+	limit := app.RateLimit{Requests: 10, WindowSeconds: 10}
+	burst := app.RateLimit{Requests: 10, WindowSeconds: 10, Burst: 3}
+
+	tests := []struct {
+		name           string
+		tokens         float64
+		elapsed        time.Duration
+		limit          app.RateLimit
+		wantTokens     float64
+		wantAllowed    bool
+		wantRetryAfter time.Duration
+	}{
+		{name: "full bucket", tokens: 10, limit: limit, wantTokens: 9, wantAllowed: true},
+		{name: "last token", tokens: 1, limit: limit, wantTokens: 0, wantAllowed: true},
+		{name: "empty bucket", tokens: 0, limit: limit, wantTokens: 0, wantRetryAfter: time.Second},
+		{name: "half a token", tokens: 0.5, limit: limit, wantTokens: 0.5, wantRetryAfter: 500 * time.Millisecond},
+		{name: "refill", tokens: 0, elapsed: 2 * time.Second, limit: limit, wantTokens: 1, wantAllowed: true},
+		{name: "partial refill", tokens: 0, elapsed: 250 * time.Millisecond, limit: limit, wantTokens: 0.25, wantRetryAfter: 750 * time.Millisecond},
+		{name: "refill up to requests", tokens: 0, elapsed: time.Hour, limit: limit, wantTokens: 9, wantAllowed: true},
+		{name: "refill up to burst", tokens: 0, elapsed: time.Hour, limit: burst, wantTokens: 2, wantAllowed: true},
+		{name: "clock going back", tokens: 0, elapsed: -time.Second, limit: limit, wantTokens: 0, wantRetryAfter: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, allowed, retryAfter := takeToken(tt.tokens, start, start.Add(tt.elapsed), tt.limit)
+			if allowed != tt.wantAllowed {
+				t.Errorf("got allowed %v, want %v", allowed, tt.wantAllowed)
+			}
+			if diff := tokens - tt.wantTokens; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("got %f tokens, want %f", tokens, tt.wantTokens)
+			}
+			if diff := retryAfter - tt.wantRetryAfter; diff > time.Millisecond || diff < -time.Millisecond {
+				t.Errorf("got retry after %v, want %v", retryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreBurst(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit app.RateLimit
+		want  int
+	}{
+		{name: "requests", limit: app.RateLimit{Requests: 5, WindowSeconds: 3600}, want: 5},
+		{name: "burst", limit: app.RateLimit{Requests: 5, WindowSeconds: 3600, Burst: 2}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryRateLimitStore{buckets: map[string]*rateLimitBucket{}}
+			ctx := context.Background()
+
+			for i := range tt.want {
+				allowed, _, err := store.Take(ctx, "key", tt.limit)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !allowed {
+					t.Fatalf("request %d was limited", i+1)
+				}
+			}
+
+			allowed, retryAfter, err := store.Take(ctx, "key", tt.limit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed {
+				t.Fatal("request after the burst was allowed")
+			}
+			if retryAfter <= 0 || retryAfter > 720*time.Second {
+				t.Errorf("got retry after %v", retryAfter)
+			}
+
+			allowed, _, _ = store.Take(ctx, "other", tt.limit)
+			if !allowed {
+				t.Error("bucket of another key was limited")
+			}
+		})
+	}
+}
@@ -0,0 +1,521 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/model"
+)
+
+// States of event registrations
+const (
+	registrationPending    = "pending"
+	registrationConfirmed  = "confirmed"
+	registrationWaitlisted = "waitlisted"
+	registrationCancelled  = "cancelled"
+)
+
+const (
+	registrationConfirmTTL      = 24 * time.Hour
+	registrationMaxQuestions    = 20
+	registrationMaxAnswerLength = 2000
+	registrationMaxNameLength   = 200
+)
+
+var registrationQuestionTypes = []string{"text", "textarea", "checkbox", "select"}
+
+var registrationQuestionKeyRegexp = regexp.MustCompile(`^[a-z0-9_]{1,40}$`)
+
+// validateRegistrationQuestions checks the custom questions of a
+// registration form.
+func validateRegistrationQuestions(questions []model.RegistrationQuestion) error {
+	if len(questions) > registrationMaxQuestions {
+		return fmt.Errorf("at most %d questions are allowed", registrationMaxQuestions)
+	}
+	keys := map[string]bool{}
+	for _, q := range questions {
+		if !registrationQuestionKeyRegexp.MatchString(q.Key) {
+			return fmt.Errorf("question key %q must consist of 1 to 40 lowercase letters, digits or underscores", q.Key)
+		}
+		if keys[q.Key] {
+			return fmt.Errorf("question key %q is used twice", q.Key)
+		}
+		keys[q.Key] = true
+		if strings.TrimSpace(q.Label) == "" {
+			return fmt.Errorf("question %q needs a label", q.Key)
+		}
+		if !slices.Contains(registrationQuestionTypes, q.Type) {
+			return fmt.Errorf("type of question %q must be text, textarea, checkbox or select", q.Key)
+		}
+		if q.Type == "select" && len(q.Options) == 0 {
+			return fmt.Errorf("select question %q needs options", q.Key)
+		}
+	}
+	return nil
+}
+
+// validateRegistrationAnswers checks the answers of a registration against
+// the questions of the form and returns them without empty answers.
+func validateRegistrationAnswers(questions []model.RegistrationQuestion, answers map[string]any) (map[string]any, error) {
+	known := map[string]bool{}
+	for _, q := range questions {
+		known[q.Key] = true
+	}
+	for key := range answers {
+		if !known[key] {
+			return nil, fmt.Errorf("unknown question %q", key)
+		}
+	}
+
+	result := map[string]any{}
+	for _, q := range questions {
+		value, ok := answers[q.Key]
+		if ok && value == nil {
+			ok = false
+		}
+
+		switch q.Type {
+		case "checkbox":
+			checked := false
+			if ok {
+				b, isBool := value.(bool)
+				if !isBool {
+					return nil, fmt.Errorf("answer to %q must be true or false", q.Key)
+				}
+				checked = b
+			}
+			if q.Required && !checked {
+				return nil, fmt.Errorf("question %q must be checked", q.Key)
+			}
+			if ok {
+				result[q.Key] = checked
+			}
+
+		default:
+			text := ""
+			if ok {
+				s, isString := value.(string)
+				if !isString {
+					return nil, fmt.Errorf("answer to %q must be text", q.Key)
+				}
+				text = strings.TrimSpace(s)
+			}
+			if text == "" {
+				if q.Required {
+					return nil, fmt.Errorf("question %q is required", q.Key)
+				}
+				continue
+			}
+			if len(text) > registrationMaxAnswerLength {
+				return nil, fmt.Errorf("answer to %q is too long", q.Key)
+			}
+			if q.Type == "select" && !slices.Contains(q.Options, text) {
+				return nil, fmt.Errorf("answer to %q must be one of the options", q.Key)
+			}
+			result[q.Key] = text
+		}
+	}
+	return result, nil
+}
+
+// registrationDate is the registration state of an event date
+type registrationDate struct {
+	EventDateUuid   string
+	EventUuid       string
+	OrgUuid         string
+	Title           string
+	StartDate       string
+	StartTime       string
+	VenueName       string
+	StartAt         *time.Time
+	ReleaseStatus   string
+	Enabled         bool
+	WaitlistEnabled bool
+	Questions       []model.RegistrationQuestion
+	MaxSeats        int
+	Capacity        *int
+	ConfirmedSeats  int
+	IssuedTickets   int
+	WaitlistCount   int
+}
+
+func (d registrationDate) when() string {
+	return strings.TrimSpace(d.StartDate + " " + d.StartTime)
+}
+
+// open reports whether visitors can register: registration is enabled, the
+// date is released and has not started yet.
+func (d registrationDate) open(now time.Time) bool {
+	return d.Enabled && d.ReleaseStatus == "released" && d.StartAt != nil && d.StartAt.After(now)
+}
+
+// visible reports whether visitors can see the event date.
+func (d registrationDate) visible() bool {
+	return slices.Contains([]string{"released", "cancelled", "deferred", "rescheduled"}, d.ReleaseStatus)
+}
+
+// seatsLeft returns the number of free seats, nil without capacity limit.
+// Seats of confirmed registrations and issued tickets are taken.
+func (d registrationDate) seatsLeft() *int {
+	if d.Capacity == nil {
+		return nil
+	}
+	left := max(*d.Capacity-d.ConfirmedSeats-d.IssuedTickets, 0)
+	return &left
+}
+
+// fits reports whether seats can be confirmed without exceeding the
+// capacity.
+func (d registrationDate) fits(seats int) bool {
+	return d.Capacity == nil || d.ConfirmedSeats+d.IssuedTickets+seats <= *d.Capacity
+}
+
+// ticketCapacity returns how many tickets the capacity leaves room for
+// besides the confirmed registrations, nil without capacity limit.
+func (d registrationDate) ticketCapacity() *int {
+	if d.Capacity == nil {
+		return nil
+	}
+	left := max(*d.Capacity-d.ConfirmedSeats, 0)
+	return &left
+}
+
+// lockEventDateSeatsTx serializes the registrations and ticket sales of an
+// event date until the end of tx, so the capacity is not exceeded by
+// concurrent bookings.
+func lockEventDateSeatsTx(ctx context.Context, tx pgx.Tx, eventDateUuid string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "uranus-event-date-seats:"+eventDateUuid)
+	return err
+}
+
+// loadRegistrationDateTx returns the registration state of an event date,
+// pgx.ErrNoRows if there is no such date.
+func (h *ApiHandler) loadRegistrationDateTx(ctx context.Context, q rowQuerier, eventDateUuid string) (registrationDate, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			edp.event_date_uuid,
+			edp.event_uuid,
+			COALESCE(ep.org_uuid::text, ''),
+			COALESCE(ep.title, ''),
+			TO_CHAR(edp.start_date, 'DD.MM.YYYY'),
+			COALESCE(TO_CHAR(edp.start_time, 'HH24:MI'), ''),
+			COALESCE(edp.venue_name, ep.venue_name, ''),
+			edp.event_start_at,
+			CASE
+				WHEN ep.release_status IN ('released', 'cancelled', 'deferred', 'rescheduled')
+					AND edp.release_status IN ('cancelled', 'deferred', 'rescheduled') THEN edp.release_status
+				ELSE COALESCE(ep.release_status, '')
+			END,
+			COALESCE(c.enabled, false),
+			COALESCE(c.waitlist_enabled, true),
+			COALESCE(c.questions, '[]'::jsonb),
+			COALESCE(c.max_seats_per_registration, 1),
+			COALESCE(ep.max_attendees, edp.space_total_capacity, ep.space_total_capacity),
+			COALESCE((
+				SELECT SUM(r.seats) FROM %[1]s.event_registration r
+				WHERE r.event_date_uuid = edp.event_date_uuid AND r.status = 'confirmed'
+			), 0),
+			(
+				SELECT COUNT(*) FROM %[1]s.event_ticket k
+				WHERE k.event_date_uuid = edp.event_date_uuid AND k.status <> 'cancelled'
+			),
+			(
+				SELECT COUNT(*) FROM %[1]s.event_registration r
+				WHERE r.event_date_uuid = edp.event_date_uuid AND r.status = 'waitlisted'
+			)
+		FROM %[1]s.event_date_projection edp
+		JOIN %[1]s.event_projection ep ON ep.event_uuid = edp.event_uuid
+		LEFT JOIN %[1]s.event_registration_config c ON c.event_uuid = edp.event_uuid
+		WHERE edp.event_date_uuid::text = $1`,
+		h.DbSchema)
+
+	var d registrationDate
+	var questions []byte
+	err := q.QueryRow(ctx, query, eventDateUuid).Scan(
+		&d.EventDateUuid, &d.EventUuid, &d.OrgUuid, &d.Title, &d.StartDate, &d.StartTime, &d.VenueName,
+		&d.StartAt, &d.ReleaseStatus, &d.Enabled, &d.WaitlistEnabled, &questions, &d.MaxSeats,
+		&d.Capacity, &d.ConfirmedSeats, &d.IssuedTickets, &d.WaitlistCount)
+	if err != nil {
+		return d, err
+	}
+	if err := json.Unmarshal(questions, &d.Questions); err != nil {
+		return d, err
+	}
+	if d.Questions == nil {
+		d.Questions = []model.RegistrationQuestion{}
+	}
+	return d, nil
+}
+
+// generateRegistrationToken returns the token of the confirmation and
+// cancellation links of a registration and its hash.
+func generateRegistrationToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(b)
+	return token, hashOrgKey(token), nil
+}
+
+func (h *ApiHandler) registrationLink(action string, token string) string {
+	return strings.TrimRight(h.Config.Frontend, "/") + "/registration/" + action + "?token=" + token
+}
+
+// registrationWaitlistPositionTx returns the position of a registration on
+// the waitlist of its event date.
+func (h *ApiHandler) registrationWaitlistPositionTx(ctx context.Context, tx pgx.Tx, registrationUuid string) (int, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM %[1]s.event_registration r
+		JOIN %[1]s.event_registration me ON me.uuid = $1::uuid
+		WHERE r.event_date_uuid = me.event_date_uuid
+			AND r.status = 'waitlisted'
+			AND (r.waitlisted_at, r.uuid) <= (me.waitlisted_at, me.uuid)`,
+		h.DbSchema)
+	var position int
+	err := tx.QueryRow(ctx, query, registrationUuid).Scan(&position)
+	return position, err
+}
+
+// registrationMail is a mail to a registered visitor
+type registrationMail struct {
+	To         string
+	Name       string
+	Lang       string
+	Seats      int
+	Link       string
+	CancelLink string
+	Position   int
+}
+
+// enqueueRegistrationEmailTx queues a registration mail of a template
+// context ("event-registration-confirm", "-confirmed", "-waitlisted",
+// "-promoted" or "-cancelled").
+func (h *ApiHandler) enqueueRegistrationEmailTx(ctx context.Context, tx pgx.Tx, templateContext string, d registrationDate, r registrationMail) error {
+	vars := map[string]string{
+		"name":         r.Name,
+		"title":        d.Title,
+		"date":         d.StartDate,
+		"time":         d.StartTime,
+		"venue":        d.VenueName,
+		"seats":        strconv.Itoa(r.Seats),
+		"link":         r.Link,
+		"cancel_link":  r.CancelLink,
+		"position":     strconv.Itoa(r.Position),
+		"expiry_hours": strconv.Itoa(int(registrationConfirmTTL.Hours())),
+	}
+
+	m, err := h.renderEmailTemplate(ctx, tx, templateContext, r.Lang, vars)
+	if errors.Is(err, errNoEmailTemplate) {
+		greeting := "Hello " + r.Name + ","
+		where := d.when()
+		if d.VenueName != "" {
+			where += ", " + d.VenueName
+		}
+		var subject, body, link string
+		switch templateContext {
+		case "event-registration-confirm":
+			subject = fmt.Sprintf("Please confirm your registration: %s (%s)", d.Title, d.when())
+			body = fmt.Sprintf("%s\n\nplease confirm your registration for %s (%s, %d seats) with the link below within %s hours.\n\n"+
+				"If you did not register, you can ignore this mail.",
+				greeting, d.Title, where, r.Seats, vars["expiry_hours"])
+			link = r.Link
+		case "event-registration-confirmed":
+			subject = fmt.Sprintf("Registration confirmed: %s (%s)", d.Title, d.when())
+			body = fmt.Sprintf("%s\n\nyour registration for %s (%s, %d seats) is confirmed.\n\n"+
+				"If you cannot come, please cancel your registration with the link below.",
+				greeting, d.Title, where, r.Seats)
+			link = r.CancelLink
+		case "event-registration-waitlisted":
+			subject = fmt.Sprintf("On the waitlist: %s (%s)", d.Title, d.when())
+			body = fmt.Sprintf("%s\n\n%s (%s) is fully booked, you are number %d on the waitlist. "+
+				"We will let you know as soon as a place becomes free.\n\n"+
+				"To leave the waitlist use the link below.",
+				greeting, d.Title, where, r.Position)
+			link = r.CancelLink
+		case "event-registration-promoted":
+			subject = fmt.Sprintf("You got a place: %s (%s)", d.Title, d.when())
+			body = fmt.Sprintf("%s\n\na place became free, your registration for %s (%s, %d seats) is confirmed now.\n\n"+
+				"If you cannot come, please cancel your registration with the link below.",
+				greeting, d.Title, where, r.Seats)
+			link = r.CancelLink
+		default:
+			subject = fmt.Sprintf("Registration cancelled: %s (%s)", d.Title, d.when())
+			body = fmt.Sprintf("%s\n\nyour registration for %s (%s) has been cancelled.", greeting, d.Title, where)
+		}
+		m = plainEmail(subject, body, link, templateContext)
+	} else if err != nil {
+		return err
+	}
+
+	m.To = r.To
+	m.OrgUuid = d.OrgUuid
+	_, err = h.enqueueEmail(ctx, tx, m)
+	return err
+}
+
+// promoteWaitlistTx confirms waitlisted registrations of an event date in
+// order as long as their seats fit, the caller must hold the lock of the
+// date. Nobody is promoted for dates which are not open anymore. Returns
+// the number of promoted registrations.
+func (h *ApiHandler) promoteWaitlistTx(ctx context.Context, tx pgx.Tx, eventDateUuid string) (int, error) {
+	d, err := h.loadRegistrationDateTx(ctx, tx, eventDateUuid)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if d.WaitlistCount == 0 || !d.open(time.Now()) {
+		return 0, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT uuid, name, email, seats, lang
+		FROM %s.event_registration
+		WHERE event_date_uuid = $1::uuid AND status = 'waitlisted'
+		ORDER BY waitlisted_at, uuid`,
+		h.DbSchema)
+	rows, err := tx.Query(ctx, query, eventDateUuid)
+	if err != nil {
+		return 0, err
+	}
+	type waitlisted struct {
+		Uuid  string
+		Name  string
+		Email string
+		Seats int
+		Lang  string
+	}
+	var waitlist []waitlisted
+	for rows.Next() {
+		var w waitlisted
+		if err := rows.Scan(&w.Uuid, &w.Name, &w.Email, &w.Seats, &w.Lang); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		waitlist = append(waitlist, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for _, w := range waitlist {
+		// Strictly in order, later registrations do not pass by
+		if !d.fits(w.Seats) {
+			break
+		}
+
+		// The links of the waitlist mail stop working, the new ones are sent
+		token, tokenHash, err := generateRegistrationToken()
+		if err != nil {
+			return promoted, err
+		}
+		query := fmt.Sprintf(`
+			UPDATE %s.event_registration
+			SET status = 'confirmed', promoted_at = NOW(), token_hash = $2
+			WHERE uuid = $1::uuid`,
+			h.DbSchema)
+		if _, err := tx.Exec(ctx, query, w.Uuid, tokenHash); err != nil {
+			return promoted, err
+		}
+		d.ConfirmedSeats += w.Seats
+		promoted++
+
+		err = h.enqueueRegistrationEmailTx(ctx, tx, "event-registration-promoted", d, registrationMail{
+			To:         w.Email,
+			Name:       w.Name,
+			Lang:       w.Lang,
+			Seats:      w.Seats,
+			CancelLink: h.registrationLink("cancel", token),
+		})
+		if err != nil {
+			return promoted, err
+		}
+	}
+	return promoted, nil
+}
+
+// ExpireEventRegistrations deletes registrations which were not confirmed
+// in time and promotes the waitlists of upcoming event dates, e.g. after
+// their capacity was raised. Returns the number of promoted registrations.
+func (h *ApiHandler) ExpireEventRegistrations(ctx context.Context) (int, error) {
+	query := fmt.Sprintf(
+		`DELETE FROM %s.event_registration WHERE status = 'pending' AND confirm_expires_at < NOW()`,
+		h.DbSchema)
+	if _, err := h.DbPool.Exec(ctx, query); err != nil {
+		return 0, err
+	}
+
+	query = fmt.Sprintf(`
+		SELECT DISTINCT r.event_date_uuid
+		FROM %[1]s.event_registration r
+		JOIN %[1]s.event_date_projection edp ON edp.event_date_uuid = r.event_date_uuid
+		WHERE r.status = 'waitlisted' AND edp.event_start_at > NOW()`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	eventDateUuids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for _, eventDateUuid := range eventDateUuids {
+		txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+			if err := lockEventDateSeatsTx(ctx, tx, eventDateUuid); err != nil {
+				return TxInternalError(err)
+			}
+			n, err := h.promoteWaitlistTx(ctx, tx, eventDateUuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			if n > 0 {
+				if err := h.updateAvailabilityTx(ctx, tx, []string{eventDateUuid}); err != nil {
+					return TxInternalError(err)
+				}
+			}
+			promoted += n
+			return nil
+		})
+		if txErr != nil {
+			return promoted, txErr
+		}
+	}
+	return promoted, nil
+}
+
+// RunRegistrationWorker expires unconfirmed registrations and promotes
+// waitlists once at start and then in the given interval until ctx is
+// cancelled.
+func (h *ApiHandler) RunRegistrationWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := h.ExpireEventRegistrations(ctx); err != nil {
+			debugf("expire event registrations failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Name of the advisory lock which elects the instance running the release
+// schedule when several API processes share a database
+const releaseScheduleLockName = "uranus-release-schedule"
+
+// tryAdvisoryXactLockTx tries to acquire a transaction level advisory lock.
+// It returns false if another session holds the lock. The lock is released
+// with the end of the transaction.
+func tryAdvisoryXactLockTx(ctx context.Context, tx pgx.Tx, name string) (bool, error) {
+	var locked bool
+	err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock(hashtext($1))`, name).Scan(&locked)
+	return locked, err
+}
+
+// releaseStatusForDate returns "scheduled" for events to be released at a
+// future release date, the status unchanged otherwise
+func releaseStatusForDate(releaseStatus string, releaseDate *string) string {
+	if releaseStatus != "released" || releaseDate == nil {
+		return releaseStatus
+	}
+	if strings.TrimSpace(*releaseDate) > time.Now().Format("2006-01-02") {
+		return "scheduled"
+	}
+	return releaseStatus
+}
+
+// ApplyReleaseSchedule releases scheduled events whose release_date is
+// reached and archives released events whose last date has passed. Only the
+// instance holding the advisory lock does the work, others return at once.
+func (h *ApiHandler) ApplyReleaseSchedule(ctx context.Context) error {
+	var releasedUuids, archivedUuids []string
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		locked, err := tryAdvisoryXactLockTx(ctx, tx, releaseScheduleLockName)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !locked {
+			return nil
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s.event
+			SET release_status = 'released', released_at = NOW()
+			WHERE release_status = 'scheduled'
+				AND release_date IS NOT NULL
+				AND release_date <= CURRENT_DATE
+			RETURNING uuid`,
+			h.DbSchema)
+		releasedUuids, err = queryUuidsTx(ctx, tx, query)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		// Recurring series without UNTIL or COUNT never end
+		query = fmt.Sprintf(`
+			UPDATE %[1]s.event e
+			SET release_status = 'archived', archived_at = NOW()
+			WHERE e.release_status = 'released'
+				AND EXISTS (
+					SELECT 1 FROM %[1]s.event_date ed WHERE ed.event_uuid = e.uuid
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM %[1]s.event_date ed
+					WHERE ed.event_uuid = e.uuid
+						AND COALESCE(ed.end_date, ed.start_date) >= CURRENT_DATE
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM %[1]s.event_recurrence r
+					WHERE r.event_uuid = e.uuid
+						AND r.rrule !~* '(UNTIL|COUNT)='
+				)
+			RETURNING e.uuid`,
+			h.DbSchema)
+		archivedUuids, err = queryUuidsTx(ctx, tx, query)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		err = RefreshEventProjections(ctx, tx, "event", append(releasedUuids, archivedUuids...))
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	if len(releasedUuids) > 0 || len(archivedUuids) > 0 {
+		debugf("release schedule: %d events released, %d events archived", len(releasedUuids), len(archivedUuids))
+	}
+
+	return nil
+}
+
+// RunReleaseScheduleWorker calls ApplyReleaseSchedule once at start and
+// then in the given interval until ctx is cancelled.
+func (h *ApiHandler) RunReleaseScheduleWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.ApplyReleaseSchedule(ctx); err != nil {
+			debugf("apply release schedule failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// queryUuidsTx runs a query returning a single uuid column
+func queryUuidsTx(ctx context.Context, tx pgx.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uuids []string
+	for rows.Next() {
+		var uuid string
+		if err := rows.Scan(&uuid); err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, uuid)
+	}
+	return uuids, rows.Err()
+}
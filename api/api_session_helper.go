@@ -0,0 +1,314 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+)
+
+// Revoked and expired sessions are deleted after this number of days
+const sessionRetentionDays = 30
+
+func (h *ApiHandler) sessionUuid(gc *gin.Context) string {
+	return gc.GetString("session-uuid")
+}
+
+// generateRefreshToken returns a new random refresh token and its hash.
+// Only the hash is stored.
+func generateRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueAccessToken returns a signed access token for a session and its
+// expiration time.
+func (h *ApiHandler) issueAccessToken(userUuid string, sessionUuid string) (string, time.Time, error) {
+	exp := time.Now().Add(time.Duration(h.Config.AuthTokenExpirationTime) * time.Second)
+	claims := &app.Claims{
+		UserUuid:    userUuid,
+		SessionUuid: sessionUuid,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenStr, err := token.SignedString(app.UranusInstance.JwtKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenStr, exp, nil
+}
+
+// createUserSessionTx starts a new session for a user logging in and
+// returns its uuid and first refresh token.
+func (h *ApiHandler) createUserSessionTx(gc *gin.Context, tx pgx.Tx, userUuid string) (string, string, error) {
+	ctx := gc.Request.Context()
+
+	sessionUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, tokenHash, err := generateRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s.user_session (uuid, user_uuid, user_agent, client_ip, expires_at)
+		VALUES ($1::uuid, $2::uuid, NULLIF($3, ''), NULLIF($4, ''), NOW() + make_interval(days => $5))`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, sessionUuid, userUuid, gc.Request.UserAgent(), gc.ClientIP(), h.Config.AuthRefreshTokenDays)
+	if err != nil {
+		return "", "", err
+	}
+
+	query = fmt.Sprintf(
+		`INSERT INTO %s.user_session_token (token_hash, session_uuid) VALUES ($1, $2::uuid)`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, tokenHash, sessionUuid)
+	if err != nil {
+		return "", "", err
+	}
+
+	return sessionUuid, refreshToken, nil
+}
+
+type refreshedSession struct {
+	UserUuid     string
+	SessionUuid  string
+	RefreshToken string
+	Reused       bool
+}
+
+// rotateRefreshTokenTx exchanges a refresh token for a new one. A token
+// which has already been rotated means it was copied, the session is revoked
+// and Reused is set. The caller must commit the transaction in this case, so
+// the revocation persists.
+func (h *ApiHandler) rotateRefreshTokenTx(gc *gin.Context, tx pgx.Tx, refreshToken string) (refreshedSession, *ApiTxError) {
+	ctx := gc.Request.Context()
+	var result refreshedSession
+
+	query := fmt.Sprintf(`
+		SELECT t.rotated_at IS NOT NULL, s.uuid, s.user_uuid,
+			s.revoked_at IS NULL AND s.expires_at > NOW() AND u.is_active
+		FROM %[1]s.user_session_token t
+		JOIN %[1]s.user_session s ON s.uuid = t.session_uuid
+		JOIN %[1]s.user u ON u.uuid = s.user_uuid
+		WHERE t.token_hash = $1
+		FOR UPDATE OF t, s`,
+		h.DbSchema)
+	var rotated, active bool
+	err := tx.QueryRow(ctx, query, hashRefreshToken(refreshToken)).
+		Scan(&rotated, &result.SessionUuid, &result.UserUuid, &active)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return result, NewApiTxError(http.StatusUnauthorized, "invalid refresh token")
+		}
+		return result, TxInternalError(err)
+	}
+
+	reused, txErr := checkRefreshToken(rotated, active)
+	if txErr != nil {
+		return result, txErr
+	}
+	if reused {
+		_, err = h.revokeSessionTx(ctx, tx, result.UserUuid, result.SessionUuid, "refresh-token-reuse")
+		if err != nil {
+			return result, TxInternalError(err)
+		}
+		result.Reused = true
+		return result, nil
+	}
+
+	query = fmt.Sprintf(
+		`UPDATE %s.user_session_token SET rotated_at = NOW() WHERE token_hash = $1`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, hashRefreshToken(refreshToken))
+	if err != nil {
+		return result, TxInternalError(err)
+	}
+
+	newToken, newHash, err := generateRefreshToken()
+	if err != nil {
+		return result, TxInternalError(err)
+	}
+	query = fmt.Sprintf(
+		`INSERT INTO %s.user_session_token (token_hash, session_uuid) VALUES ($1, $2::uuid)`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, newHash, result.SessionUuid)
+	if err != nil {
+		return result, TxInternalError(err)
+	}
+
+	// Sessions in use are kept alive
+	query = fmt.Sprintf(`
+		UPDATE %s.user_session
+		SET last_seen_at = NOW(),
+			client_ip = NULLIF($2, ''),
+			expires_at = NOW() + make_interval(days => $3)
+		WHERE uuid = $1::uuid`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, result.SessionUuid, gc.ClientIP(), h.Config.AuthRefreshTokenDays)
+	if err != nil {
+		return result, TxInternalError(err)
+	}
+
+	result.RefreshToken = newToken
+	return result, nil
+}
+
+// checkRefreshToken decides on a presented refresh token by whether it has
+// been rotated already and whether its session is active. Tokens of inactive
+// sessions are rejected. A rotated token of an active session is a reuse,
+// its session has to be revoked. Otherwise the token can be rotated.
+func checkRefreshToken(rotated bool, active bool) (bool, *ApiTxError) {
+	if !active {
+		return false, NewApiTxError(http.StatusUnauthorized, "session expired")
+	}
+	return rotated, nil
+}
+
+// revokeSessionTx revokes a session of a user, returns false if the user has
+// no such active session.
+func (h *ApiHandler) revokeSessionTx(ctx context.Context, tx pgx.Tx, userUuid string, sessionUuid string, reason string) (bool, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s.user_session
+		SET revoked_at = NOW(), revoke_reason = $3
+		WHERE uuid = $1::uuid AND user_uuid = $2::uuid AND revoked_at IS NULL`,
+		h.DbSchema)
+	res, err := tx.Exec(ctx, query, sessionUuid, userUuid, reason)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// revokeUserSessionsTx revokes all sessions of a user except the one given
+// by exceptSessionUuid (may be empty) and returns the number of revoked
+// sessions.
+func (h *ApiHandler) revokeUserSessionsTx(ctx context.Context, tx pgx.Tx, userUuid string, exceptSessionUuid string, reason string) (int64, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s.user_session
+		SET revoked_at = NOW(), revoke_reason = $3
+		WHERE user_uuid = $1::uuid
+			AND revoked_at IS NULL
+			AND ($2 = '' OR uuid <> NULLIF($2, '')::uuid)`,
+		h.DbSchema)
+	res, err := tx.Exec(ctx, query, userUuid, exceptSessionUuid, reason)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+// sessionDeviceLabel returns a short description like "Firefox on Linux"
+// derived from a user agent.
+func sessionDeviceLabel(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	browser := ""
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+
+	if len(userAgent) > 64 {
+		return userAgent[:64]
+	}
+	return userAgent
+}
+
+// PurgeUserSessions deletes sessions which have been revoked or expired for
+// longer than the retention period, together with their refresh tokens.
+func (h *ApiHandler) PurgeUserSessions(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		DELETE FROM %s.user_session
+		WHERE COALESCE(revoked_at, expires_at) < NOW() - make_interval(days => $1)`,
+		h.DbSchema)
+	res, err := h.DbPool.Exec(ctx, query, sessionRetentionDays)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() > 0 {
+		debugf("session cleanup: %d sessions deleted", res.RowsAffected())
+	}
+
+	return nil
+}
+
+// RunUserSessionCleanupWorker calls PurgeUserSessions once at start and then
+// in the given interval until ctx is cancelled.
+func (h *ApiHandler) RunUserSessionCleanupWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.PurgeUserSessions(ctx); err != nil {
+			debugf("purge user sessions failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
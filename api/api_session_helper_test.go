@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCheckRefreshToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		rotated    bool
+		active     bool
+		wantReused bool
+		wantCode   int
+	}{
+		{name: "fresh token", active: true},
+		{name: "rotated token is a reuse", rotated: true, active: true, wantReused: true},
+		{name: "inactive session", wantCode: http.StatusUnauthorized},
+		{name: "rotated token of inactive session", rotated: true, wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reused, txErr := checkRefreshToken(tt.rotated, tt.active)
+			if tt.wantCode != 0 {
+				if txErr == nil || txErr.Code != tt.wantCode {
+					t.Fatalf("got error %v, want status %d", txErr, tt.wantCode)
+				}
+				return
+			}
+			if txErr != nil {
+				t.Fatalf("unexpected error: %v", txErr)
+			}
+			if reused != tt.wantReused {
+				t.Errorf("got reused %v, want %v", reused, tt.wantReused)
+			}
+		})
+	}
+}
+
+func TestHashRefreshToken(t *testing.T) {
+	token, hash, err := generateRefreshToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(token) != 64 {
+		t.Errorf("got token of length %d, want 64", len(token))
+	}
+	if hash != hashRefreshToken(token) {
+		t.Error("hash does not match the token")
+	}
+	if hash == token {
+		t.Error("token is stored in plain")
+	}
+
+	other, _, err := generateRefreshToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if other == token {
+		t.Error("tokens repeat")
+	}
+}
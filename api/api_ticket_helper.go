@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/model"
+)
+
+// States of tickets
+const (
+	ticketValid     = "valid"
+	ticketUsed      = "used"
+	ticketCancelled = "cancelled"
+)
+
+const (
+	ticketCodePrefix   = "T1"
+	ticketMaxPerOrder  = 10
+	ticketMaxBoxOffice = 50
+	ticketMaxPerEmail  = 10
+	ticketMaxScans     = 500
+	ticketMaxTierName  = 100
+	ticketMaxHolderLen = 200
+)
+
+// ticketPayment returns how a ticket of an amount is paid.
+func ticketPayment(amount float64) string {
+	if amount == 0 {
+		return "free"
+	}
+	return "at_door"
+}
+
+// ticketSigningKey returns the key of the ticket codes. Installations
+// without secret_key sign with the JWT secret.
+func (h *ApiHandler) ticketSigningKey() []byte {
+	if h.Config.SecretKey != "" {
+		return []byte(h.Config.SecretKey)
+	}
+	return []byte(h.Config.JwtSecret)
+}
+
+func (h *ApiHandler) ticketSignature(ticketUuid string) string {
+	mac := hmac.New(sha256.New, h.ticketSigningKey())
+	mac.Write([]byte("ticket:" + ticketUuid))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signTicketCode returns the content of the QR code of a ticket
+// ("T1.<uuid>.<signature>").
+func (h *ApiHandler) signTicketCode(ticketUuid string) string {
+	return ticketCodePrefix + "." + ticketUuid + "." + h.ticketSignature(ticketUuid)
+}
+
+// verifyTicketCode returns the ticket uuid of a code if its signature is
+// valid.
+func (h *ApiHandler) verifyTicketCode(code string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(code), ".")
+	if len(parts) != 3 || parts[0] != ticketCodePrefix || !grains_uuid.IsValidUuidv7(parts[1]) {
+		return "", false
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(h.ticketSignature(parts[1]))) {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func (h *ApiHandler) ticketLink(code string) string {
+	return strings.TrimRight(h.Config.Frontend, "/") + "/ticket?code=" + code
+}
+
+// rowsQuerier is implemented by pgxpool.Pool and pgx.Tx
+type rowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// loadTicketTiersTx returns the price tiers offered for an event date with
+// the number of issued tickets.
+func (h *ApiHandler) loadTicketTiersTx(ctx context.Context, q rowsQuerier, eventDateUuid string) ([]model.EventDateTicketTier, error) {
+	query := fmt.Sprintf(`
+		SELECT t.uuid, t.name, t.amount::float8, t.currency, t.quota,
+			(
+				SELECT COUNT(*) FROM %[1]s.event_ticket k
+				WHERE k.price_tier_uuid = t.uuid AND k.event_date_uuid = ed.uuid AND k.status <> 'cancelled'
+			)
+		FROM %[1]s.event_price_tier t
+		JOIN %[1]s.event_date ed ON ed.uuid::text = $1 AND ed.event_uuid = t.event_uuid
+		WHERE t.event_date_uuid IS NULL OR t.event_date_uuid = ed.uuid
+		ORDER BY t.sort_order, t.amount, t.uuid`,
+		h.DbSchema)
+	rows, err := q.Query(ctx, query, eventDateUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := make([]model.EventDateTicketTier, 0)
+	for rows.Next() {
+		var t model.EventDateTicketTier
+		if err := rows.Scan(&t.Uuid, &t.Name, &t.Amount, &t.Currency, &t.Quota, &t.Sold); err != nil {
+			return nil, err
+		}
+		t.Payment = ticketPayment(t.Amount)
+		if t.Quota != nil {
+			remaining := max(*t.Quota-t.Sold, 0)
+			t.Remaining = &remaining
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
+
+// ticketLimit returns how many tickets an event date can have in total, the
+// sum of the quotas of its tiers or the capacity left for tickets, whichever
+// is lower, and how many are issued. The limit is nil if neither is set.
+func ticketLimit(tiers []model.EventDateTicketTier, capacity *int) (*int, int) {
+	sold := 0
+	quotas := 0
+	allLimited := len(tiers) > 0
+	for _, t := range tiers {
+		sold += t.Sold
+		if t.Quota == nil {
+			allLimited = false
+		} else {
+			quotas += *t.Quota
+		}
+	}
+
+	var limit *int
+	if allLimited {
+		limit = &quotas
+	}
+	if capacity != nil && (limit == nil || *capacity < *limit) {
+		limit = capacity
+	}
+	return limit, sold
+}
+
+// ticketOrder is a request for tickets of a single price tier
+type ticketOrder struct {
+	TierUuid    string
+	Quantity    int
+	HolderName  string
+	HolderEmail *string
+	IssuedBy    *string
+}
+
+// issueTicketsTx issues the tickets of an order if the quota of the tier
+// and the limit of the event date allow it. The caller must hold the seats
+// lock of the date.
+func (h *ApiHandler) issueTicketsTx(ctx context.Context, tx pgx.Tx, d registrationDate, order ticketOrder) ([]model.EventTicket, *ApiTxError) {
+	tiers, err := h.loadTicketTiersTx(ctx, tx, d.EventDateUuid)
+	if err != nil {
+		return nil, TxInternalError(err)
+	}
+
+	var tier *model.EventDateTicketTier
+	for i := range tiers {
+		if tiers[i].Uuid == order.TierUuid {
+			tier = &tiers[i]
+		}
+	}
+	if tier == nil {
+		return nil, ApiErrNotFound("price tier not found")
+	}
+
+	if tier.Remaining != nil && *tier.Remaining < order.Quantity {
+		return nil, NewApiTxError(http.StatusConflict, "only %d tickets of %s are left", *tier.Remaining, tier.Name)
+	}
+	limit, sold := ticketLimit(tiers, d.ticketCapacity())
+	if limit != nil && *limit-sold < order.Quantity {
+		return nil, NewApiTxError(http.StatusConflict, "only %d tickets are left", max(*limit-sold, 0))
+	}
+
+	orderUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		return nil, TxInternalError(err)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s.event_ticket
+			(uuid, order_uuid, event_date_uuid, price_tier_uuid, holder_name, holder_email, amount, currency, payment, issued_by)
+		VALUES ($1::uuid, $2::uuid, $3::uuid, $4::uuid, $5, $6, $7, $8, $9, $10::uuid)
+		RETURNING issued_at`,
+		h.DbSchema)
+
+	tickets := make([]model.EventTicket, 0, order.Quantity)
+	for range order.Quantity {
+		ticketUuid, err := grains_uuid.Uuidv7String()
+		if err != nil {
+			return nil, TxInternalError(err)
+		}
+		t := model.EventTicket{
+			Uuid:          ticketUuid,
+			OrderUuid:     orderUuid,
+			EventDateUuid: d.EventDateUuid,
+			PriceTierUuid: tier.Uuid,
+			PriceTierName: tier.Name,
+			HolderName:    order.HolderName,
+			HolderEmail:   order.HolderEmail,
+			Amount:        tier.Amount,
+			Currency:      tier.Currency,
+			Payment:       tier.Payment,
+			Status:        ticketValid,
+			Code:          h.signTicketCode(ticketUuid),
+		}
+		err = tx.QueryRow(ctx, query,
+			t.Uuid, t.OrderUuid, t.EventDateUuid, t.PriceTierUuid, t.HolderName, t.HolderEmail,
+			t.Amount, t.Currency, t.Payment, order.IssuedBy).Scan(&t.IssuedAt)
+		if err != nil {
+			return nil, TxInternalError(err)
+		}
+		tickets = append(tickets, t)
+	}
+
+	if err := h.updateAvailabilityTx(ctx, tx, []string{d.EventDateUuid}); err != nil {
+		return nil, TxInternalError(err)
+	}
+	return tickets, nil
+}
+
+// enqueueTicketsEmailTx mails the links of issued tickets to their holder.
+func (h *ApiHandler) enqueueTicketsEmailTx(ctx context.Context, tx pgx.Tx, d registrationDate, tickets []model.EventTicket, lang string) error {
+	if len(tickets) == 0 || tickets[0].HolderEmail == nil {
+		return nil
+	}
+	t := tickets[0]
+
+	var lines []string
+	for i, ticket := range tickets {
+		price := "free"
+		if ticket.Payment == "at_door" {
+			price = strconv.FormatFloat(ticket.Amount, 'f', 2, 64) + " " + ticket.Currency + ", to be paid at the door"
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s (%s): %s", i+1, ticket.PriceTierName, price, h.ticketLink(ticket.Code)))
+	}
+
+	vars := map[string]string{
+		"name":    t.HolderName,
+		"title":   d.Title,
+		"date":    d.StartDate,
+		"time":    d.StartTime,
+		"venue":   d.VenueName,
+		"count":   strconv.Itoa(len(tickets)),
+		"tickets": strings.Join(lines, "\n"),
+		"link":    h.ticketLink(t.Code),
+	}
+
+	m, err := h.renderEmailTemplate(ctx, tx, "event-tickets-issued", lang, vars)
+	if errors.Is(err, errNoEmailTemplate) {
+		where := d.when()
+		if d.VenueName != "" {
+			where += ", " + d.VenueName
+		}
+		m = plainEmail(
+			fmt.Sprintf("Your tickets: %s (%s)", d.Title, d.when()),
+			fmt.Sprintf("Hello %s,\n\nhere are your tickets for %s (%s). Please show the QR code of each ticket at the entrance.\n\n%s",
+				t.HolderName, d.Title, where, vars["tickets"]),
+			"",
+			"event-tickets-issued")
+	} else if err != nil {
+		return err
+	}
+
+	m.To = *t.HolderEmail
+	m.OrgUuid = d.OrgUuid
+	_, err = h.enqueueEmail(ctx, tx, m)
+	return err
+}
+
+// syncEventPricesTx sets the price fields of an event from its price tiers
+// so listings, filters and JSON-LD show them, and records the change as a
+// revision. Events without tiers keep their prices.
+func (h *ApiHandler) syncEventPricesTx(ctx context.Context, tx pgx.Tx, eventUuid string, userUuid string) error {
+	query := fmt.Sprintf(`
+		SELECT MIN(amount)::float8, MAX(amount)::float8, COUNT(DISTINCT currency), MIN(currency)
+		FROM %s.event_price_tier
+		WHERE event_uuid = $1::uuid`,
+		h.DbSchema)
+	var minPrice, maxPrice *float64
+	var currencyCount int
+	var currency *string
+	err := tx.QueryRow(ctx, query, eventUuid).Scan(&minPrice, &maxPrice, &currencyCount, &currency)
+	if err != nil {
+		return err
+	}
+	if minPrice == nil || maxPrice == nil {
+		return nil
+	}
+
+	priceType := model.TieredPrices
+	switch {
+	case *maxPrice == 0:
+		priceType = model.Free
+	case *minPrice == *maxPrice:
+		priceType = model.RegularPrice
+	}
+	if currencyCount != 1 {
+		currency = nil
+	}
+
+	before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+	if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`
+		UPDATE %s.event
+		SET price_type = $2, min_price = $3, max_price = $4, currency = COALESCE($5, currency)
+		WHERE uuid = $1::uuid`,
+		h.DbSchema)
+	if _, err := tx.Exec(ctx, query, eventUuid, string(priceType), *minPrice, *maxPrice, currency); err != nil {
+		return err
+	}
+	if err := h.recordEventRevisionTx(ctx, tx, eventUuid, userUuid, "prices", before); err != nil {
+		return err
+	}
+	return RefreshEventProjections(ctx, tx, "event", []string{eventUuid})
+}
+
+// ticketScanTime returns the time of a scan in the precision of the
+// database, scans from the future are taken as now.
+func ticketScanTime(scan model.TicketScan, now time.Time) time.Time {
+	t := now
+	if scan.ScannedAt != nil && !scan.ScannedAt.After(now) {
+		t = *scan.ScannedAt
+	}
+	return t.Truncate(time.Microsecond)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/app"
+)
+
+// Purpose of the interim token returned by Login for accounts with
+// two-factor authentication
+const twoFactorTokenPurpose = "2fa"
+
+// Validity of the interim token, the second factor must be sent within
+const twoFactorTokenLifetime = 5 * time.Minute
+
+// Number of recovery codes generated at once
+const recoveryCodeCount = 10
+
+// issueTwoFactorToken returns the interim token of a login waiting for the
+// second factor. It is no access token and rejected by JWTMiddleware.
+func (h *ApiHandler) issueTwoFactorToken(userUuid string) (string, error) {
+	claims := &app.Claims{
+		UserUuid: userUuid,
+		Purpose:  twoFactorTokenPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(twoFactorTokenLifetime)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(app.UranusInstance.JwtKey)
+}
+
+// parseTwoFactorToken returns the user uuid of a valid interim token.
+func parseTwoFactorToken(tokenStr string) (string, error) {
+	claims := &app.Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return app.UranusInstance.JwtKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return "", errors.New("invalid two-factor token")
+	}
+	if claims.Purpose != twoFactorTokenPurpose || claims.UserUuid == "" {
+		return "", errors.New("invalid two-factor token")
+	}
+	return claims.UserUuid, nil
+}
+
+// userTwoFactorEnabled reports whether a user has confirmed a TOTP secret.
+func (h *ApiHandler) userTwoFactorEnabled(ctx context.Context, q rowQuerier, userUuid string) (bool, error) {
+	query := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s.user_totp WHERE user_uuid = $1::uuid AND enabled_at IS NOT NULL)`,
+		h.DbSchema)
+	var enabled bool
+	err := q.QueryRow(ctx, query, userUuid).Scan(&enabled)
+	return enabled, err
+}
+
+// verifyTotpTx checks a TOTP code of a user. With pending set the secret
+// which is being set up is checked instead of the enabled one. An accepted
+// code is remembered and cannot be used again.
+func (h *ApiHandler) verifyTotpTx(ctx context.Context, tx pgx.Tx, userUuid string, code string, pending bool) (bool, error) {
+	query := fmt.Sprintf(`
+		SELECT secret, last_used_step
+		FROM %s.user_totp
+		WHERE user_uuid = $1::uuid AND (enabled_at IS NULL) = $2
+		FOR UPDATE`,
+		h.DbSchema)
+	var secret string
+	var lastUsedStep int64
+	err := tx.QueryRow(ctx, query, userUuid, pending).Scan(&secret, &lastUsedStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	step, ok := app.VerifyTotp(secret, code, time.Now(), lastUsedStep)
+	if !ok {
+		return false, nil
+	}
+
+	query = fmt.Sprintf(`UPDATE %s.user_totp SET last_used_step = $2 WHERE user_uuid = $1::uuid`, h.DbSchema)
+	_, err = tx.Exec(ctx, query, userUuid, step)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// generateRecoveryCodes returns new recovery codes like "3f9a1-c07b2" and
+// their hashes. Only the hashes are stored.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		s := hex.EncodeToString(b)
+		codes[i] = s[:5] + "-" + s[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes, so codes can be typed
+// in as the user likes.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(code)
+	normalized = strings.NewReplacer("-", "", " ", "").Replace(normalized)
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// replaceRecoveryCodesTx generates new recovery codes for a user, codes
+// generated before become invalid.
+func (h *ApiHandler) replaceRecoveryCodesTx(ctx context.Context, tx pgx.Tx, userUuid string) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %s.user_recovery_code WHERE user_uuid = $1::uuid`, h.DbSchema)
+	_, err = tx.Exec(ctx, query, userUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf(`
+		INSERT INTO %s.user_recovery_code (user_uuid, code_hash)
+		SELECT $1::uuid, UNNEST($2::text[])`,
+		h.DbSchema)
+	_, err = tx.Exec(ctx, query, userUuid, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// useRecoveryCodeTx marks an unused recovery code of a user as used and
+// reports whether there was one.
+func (h *ApiHandler) useRecoveryCodeTx(ctx context.Context, tx pgx.Tx, userUuid string, code string) (bool, error) {
+	if strings.TrimSpace(code) == "" {
+		return false, nil
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.user_recovery_code
+		SET used_at = NOW()
+		WHERE user_uuid = $1::uuid AND code_hash = $2 AND used_at IS NULL`,
+		h.DbSchema)
+	res, err := tx.Exec(ctx, query, userUuid, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// verifySecondFactorTx accepts either a TOTP code or a recovery code of a
+// user with two-factor authentication enabled.
+func (h *ApiHandler) verifySecondFactorTx(ctx context.Context, tx pgx.Tx, userUuid string, code string, recoveryCode string) (bool, error) {
+	if code != "" {
+		return h.verifyTotpTx(ctx, tx, userUuid, code, false)
+	}
+	return h.useRecoveryCodeTx(ctx, tx, userUuid, recoveryCode)
+}
+
+// twoFactorRequiringOrgsTx returns the names of the organizations in which
+// the user holds permissions that require two-factor authentication.
+func (h *ApiHandler) twoFactorRequiringOrgsTx(ctx context.Context, tx pgx.Tx, userUuid string) ([]string, error) {
+	query := fmt.Sprintf(`
+		SELECT o.name
+		FROM %[1]s.user_organization_link uol
+		JOIN %[1]s.organization o ON o.uuid = uol.org_uuid
+		WHERE uol.user_uuid = $1::uuid
+			AND uol.permissions & o.two_factor_permissions <> 0
+		ORDER BY o.name`,
+		h.DbSchema)
+	rows, err := tx.Query(ctx, query, userUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
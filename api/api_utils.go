@@ -9,7 +9,6 @@ import (
 	"sync"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/sndcds/grains/grains_api"
 	"github.com/sndcds/grains/grains_uuid"
 	"github.com/sndcds/uranus/app"
@@ -203,33 +202,6 @@ func GetContextParamFloat64(gc *gin.Context, name string) (*float64, bool) {
 	return &f, true
 }
 
-// Get the Authorization header
-func UseUuidFromAccessToken(gc *gin.Context) string {
-	authHeader := gc.GetHeader("Authorization")
-	if authHeader == "" {
-		return ""
-	}
-
-	// Expect header format: "Bearer <token>"
-	parts := strings.SplitN(authHeader, " ", 2)
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		return ""
-	}
-	accessToken := parts[1]
-
-	// Parse the token
-	claims := &app.Claims{}
-	token, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
-		return app.UranusInstance.JwtKey, nil
-	})
-
-	if err != nil || !token.Valid {
-		return ""
-	}
-
-	return claims.UserUuid
-}
-
 const dummyPasswordHash = "$2a$12$wGf6R8t2pFzq9yQmYv8y1u8y0v7E4Qv9ZJ8tQ6lH5E8QK3yQyZCwK"
 
 // VerifyUserPassword reads password from request body, validates it against user.uuis.
@@ -251,7 +223,7 @@ func (h *ApiHandler) VerifyUserPassword(gc *gin.Context, userUuid string) error
 	query := fmt.Sprintf(`SELECT password_hash FROM %s.user WHERE uuid = $1::uuid`, h.DbSchema)
 	err := h.DbPool.QueryRow(gc.Request.Context(), query, userUuid).Scan(&passwordHash)
 	if err != nil {
-		err.Error()
+		return err
 	}
 
 	if app.ComparePasswords(passwordHash, body.Password) != nil {
@@ -263,7 +235,7 @@ func (h *ApiHandler) VerifyUserPassword(gc *gin.Context, userUuid string) error
 
 func IsEventReleaseStatus(fieldName string, value *string) (bool, error) {
 	return ValidateEnum(fieldName, value,
-		"draft", "review", "released", "cancelled", "deferred", "rescheduled",
+		"draft", "review", "scheduled", "released", "cancelled", "deferred", "rescheduled", "archived",
 	)
 }
 
@@ -18,6 +18,20 @@ func (h *ApiHandler) GetUserEffectiveVenuePermissionsTx(
 	venueUuid string,
 ) (app.Permissions, error) {
 	ctx := gc.Request.Context()
+
+	if _, ok := requestApiKey(gc); ok {
+		// API keys act for the organization owning the venue only
+		orgUuid, err := h.GetOrgUuidByVenueUuidTx(gc, tx, venueUuid)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return 0, nil
+			}
+			return 0, err
+		}
+		permissions, _ := apiKeyOrgPermissions(gc, orgUuid)
+		return permissions, nil
+	}
+
 	var result pgtype.Int8
 
 	err := tx.QueryRow(
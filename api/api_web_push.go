@@ -0,0 +1,336 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sndcds/uranus/app"
+)
+
+const (
+	webPushRecordSize  = 4096
+	webPushMaxPayload  = webPushRecordSize - 16 - 1 - 86 // tag, delimiter, header
+	webPushJwtLifetime = 12 * time.Hour
+)
+
+// WebPushSender sends push messages to the push services of browsers. The
+// payload is encrypted for the subscription (RFC 8291, aes128gcm) and the
+// request is signed with the VAPID key of the server (RFC 8292), whose
+// public key browsers pass as applicationServerKey when subscribing.
+type WebPushSender struct {
+	publicKey  string
+	privateKey *ecdsa.PrivateKey
+	subject    string
+
+	mu   sync.Mutex
+	jwts map[string]webPushJwt
+}
+
+type webPushJwt struct {
+	token   string
+	expires time.Time
+}
+
+// webPushMessage is a message for a single subscription. Topic replaces a
+// message of the same topic still waiting in the push service.
+type webPushMessage struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+	Payload  []byte
+	TTL      time.Duration
+	Topic    string
+	Urgency  string
+}
+
+// NewWebPushSender returns a sender for the VAPID keys of the config, or
+// nil if no keys are configured and web push is disabled. The keys are
+// base64url encoded, the public key as uncompressed point and the private
+// key as raw scalar, as generated by GenerateVapidKeys or common web push
+// tools.
+func NewWebPushSender(config *app.Config) (*WebPushSender, error) {
+	if config.VapidPublicKey == "" && config.VapidPrivateKey == "" {
+		return nil, nil
+	}
+
+	privateBytes, err := decodeWebPushKey(config.VapidPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("vapid_private_key: %w", err)
+	}
+	privateKey, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), privateBytes)
+	if err != nil {
+		return nil, fmt.Errorf("vapid_private_key: %w", err)
+	}
+	publicBytes, err := privateKey.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	publicKey := base64.RawURLEncoding.EncodeToString(publicBytes)
+	if configured, err := decodeWebPushKey(config.VapidPublicKey); err != nil || !bytes.Equal(configured, publicBytes) {
+		return nil, errors.New("vapid_public_key does not match vapid_private_key")
+	}
+
+	subject := config.VapidSubject
+	if subject == "" && config.AuthReplyEmail != "" {
+		subject = "mailto:" + config.AuthReplyEmail
+	}
+	if subject == "" {
+		subject = config.BaseApiUrl
+	}
+
+	return &WebPushSender{
+		publicKey:  publicKey,
+		privateKey: privateKey,
+		subject:    subject,
+		jwts:       map[string]webPushJwt{},
+	}, nil
+}
+
+// GenerateVapidKeys returns a new VAPID key pair in the format of the config.
+// The server prints one when started with -generate-vapid-keys.
+func GenerateVapidKeys() (publicKey string, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// PublicKey returns the applicationServerKey for subscribing browsers.
+func (s *WebPushSender) PublicKey() string {
+	return s.publicKey
+}
+
+// Send posts the message to the push service and returns the status code of
+// the response. Status codes other than 2xx are returned with an error.
+func (s *WebPushSender) Send(ctx context.Context, client *http.Client, m webPushMessage) (int, error) {
+	uaPublic, err := decodeWebPushKey(m.P256dh)
+	if err != nil {
+		return 0, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeWebPushKey(m.Auth)
+	if err != nil {
+		return 0, fmt.Errorf("invalid auth secret: %w", err)
+	}
+	body, err := encryptWebPushPayload(uaPublic, authSecret, m.Payload)
+	if err != nil {
+		return 0, err
+	}
+
+	endpoint, err := url.Parse(m.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return 0, fmt.Errorf("invalid endpoint %q", m.Endpoint)
+	}
+	token, err := s.vapidJwt(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(m.TTL.Seconds())))
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, s.publicKey))
+	if m.Topic != "" {
+		req.Header.Set("Topic", m.Topic)
+	}
+	if m.Urgency != "" {
+		req.Header.Set("Urgency", m.Urgency)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxBodyLog))
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("push service responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return resp.StatusCode, nil
+}
+
+// vapidJwt returns the signed token for a push service, tokens are reused
+// until shortly before they expire.
+func (s *WebPushSender) vapidJwt(audience string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if t, ok := s.jwts[audience]; ok && now.Before(t.expires.Add(-time.Hour)) {
+		return t.token, nil
+	}
+
+	expires := now.Add(webPushJwtLifetime)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": audience,
+		"exp": expires.Unix(),
+		"sub": s.subject,
+	}).SignedString(s.privateKey)
+	if err != nil {
+		return "", err
+	}
+	s.jwts[audience] = webPushJwt{token: token, expires: expires}
+	return token, nil
+}
+
+// decodeWebPushKey decodes a base64url key with or without padding.
+func decodeWebPushKey(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(s), "="))
+}
+
+// webPushKeys derives the content encryption key and nonce of RFC 8291 from
+// the shared ECDH secret.
+func webPushKeys(sharedSecret, authSecret, uaPublic, asPublic, salt []byte) (cek []byte, nonce []byte, err error) {
+	prkKey, err := hkdf.Extract(sha256.New, sharedSecret, authSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	cek, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
+
+// encryptWebPushPayload encrypts the payload for the browser with the public
+// key uaPublic and authSecret of its subscription as a single aes128gcm
+// record, using a new ephemeral key and salt.
+func encryptWebPushPayload(uaPublic, authSecret, payload []byte) ([]byte, error) {
+	if len(payload) > webPushMaxPayload {
+		return nil, fmt.Errorf("push payload exceeds %d bytes", webPushMaxPayload)
+	}
+	if len(authSecret) != 16 {
+		return nil, errors.New("auth secret must be 16 bytes")
+	}
+
+	curve := ecdh.P256()
+	uaKey, err := curve.NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	asKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+	cek, nonce, err := webPushKeys(sharedSecret, authSecret, uaPublic, asPublic, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key id length and key id (the ephemeral
+	// public key), followed by the record with the last record delimiter
+	header := make([]byte, 0, 21+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// decryptWebPushPayload reverses encryptWebPushPayload with the private key
+// of the subscription. It is used by the local push endpoint stub.
+func decryptWebPushPayload(uaKey *ecdh.PrivateKey, authSecret, body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("push message too short")
+	}
+	salt := body[:16]
+	idLen := int(body[20])
+	if len(body) < 21+idLen {
+		return nil, errors.New("push message too short")
+	}
+	asPublic := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key id: %w", err)
+	}
+	sharedSecret, err := uaKey.ECDH(asKey)
+	if err != nil {
+		return nil, err
+	}
+	cek, nonce, err := webPushKeys(sharedSecret, authSecret, uaKey.PublicKey().Bytes(), asPublic, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Strip the padding up to the delimiter
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return nil, errors.New("invalid push message padding")
+	}
+	return plaintext[:len(plaintext)-1], nil
+}
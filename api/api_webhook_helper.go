@@ -0,0 +1,638 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// webhookEventTypes lists the event types a webhook subscription can filter
+// on. A subscription without event types receives all of them.
+var webhookEventTypes = []string{
+	"event.created",
+	"event.updated",
+	"event.released",
+	"event.cancelled",
+	"event.unpublished",
+	"event.archived",
+	"event_date.created",
+	"event_date.updated",
+	"event_date.cancelled",
+	"event_date.postponed",
+	"event_date.rescheduled",
+	"event_date.reinstated",
+	"venue.updated",
+	"space.updated",
+	"org.updated",
+}
+
+const (
+	webhookBatchSize    = 50
+	webhookMaxAttempts  = 10
+	webhookTimeout      = 10 * time.Second
+	webhookLease        = 5 * time.Minute
+	webhookMaxRetryWait = 6 * time.Hour
+	webhookMaxBodyLog   = 1024
+)
+
+// webhookEvent is a change to be delivered to the matching subscriptions.
+// EventUuid is set for changes of events and event dates, only those are
+// delivered to portal subscriptions, and only while Public.
+type webhookEvent struct {
+	Type       string
+	OrgUuid    string
+	EntityUuid string
+	EventUuid  *string
+	Public     bool
+	Data       map[string]any
+}
+
+// webhookState is the state of an event or an event date in the projection
+// tables, loaded before and after a refresh to detect changes
+type webhookState struct {
+	EventUuid          string
+	OrgUuid            string
+	EventReleaseStatus string
+	ReleaseStatus      string
+	StatusReason       *string
+	RescheduledToUuid  *string
+	Schedule           string
+}
+
+// isWebhookPublicStatus reports whether events with the release status are
+// visible to the public
+func isWebhookPublicStatus(releaseStatus string) bool {
+	switch releaseStatus {
+	case "released", "cancelled", "deferred", "rescheduled", "archived":
+		return true
+	}
+	return false
+}
+
+func hasActiveWebhooksTx(ctx context.Context, tx pgx.Tx) (bool, error) {
+	query := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s.webhook_subscription WHERE active)`,
+		app.UranusInstance.Config.DbSchema)
+	var exists bool
+	err := tx.QueryRow(ctx, query).Scan(&exists)
+	return exists, err
+}
+
+func loadWebhookEventStatesTx(ctx context.Context, tx pgx.Tx, eventUuids []string) (map[string]webhookState, error) {
+	query := fmt.Sprintf(`
+		SELECT event_uuid, COALESCE(org_uuid::text, ''), COALESCE(release_status, '')
+		FROM %s.event_projection
+		WHERE event_uuid = ANY($1::uuid[])`,
+		app.UranusInstance.Config.DbSchema)
+	rows, err := tx.Query(ctx, query, eventUuids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]webhookState)
+	for rows.Next() {
+		var uuid string
+		var s webhookState
+		if err := rows.Scan(&uuid, &s.OrgUuid, &s.ReleaseStatus); err != nil {
+			return nil, err
+		}
+		s.EventUuid = uuid
+		s.EventReleaseStatus = s.ReleaseStatus
+		states[uuid] = s
+	}
+	return states, rows.Err()
+}
+
+func loadWebhookEventDateStatesTx(ctx context.Context, tx pgx.Tx, eventDateUuids []string) (map[string]webhookState, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			edp.event_date_uuid,
+			edp.event_uuid,
+			COALESCE(ep.org_uuid::text, ''),
+			COALESCE(ep.release_status, ''),
+			COALESCE(edp.release_status, ''),
+			edp.status_reason,
+			edp.rescheduled_to_uuid,
+			concat_ws('|', edp.start_date, edp.start_time, edp.end_date, edp.end_time, edp.venue_uuid, edp.space_uuid)
+		FROM %[1]s.event_date_projection edp
+		LEFT JOIN %[1]s.event_projection ep ON ep.event_uuid = edp.event_uuid
+		WHERE edp.event_date_uuid = ANY($1::uuid[])`,
+		app.UranusInstance.Config.DbSchema)
+	rows, err := tx.Query(ctx, query, eventDateUuids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]webhookState)
+	for rows.Next() {
+		var uuid string
+		var s webhookState
+		if err := rows.Scan(
+			&uuid, &s.EventUuid, &s.OrgUuid, &s.EventReleaseStatus, &s.ReleaseStatus,
+			&s.StatusReason, &s.RescheduledToUuid, &s.Schedule); err != nil {
+			return nil, err
+		}
+		states[uuid] = s
+	}
+	return states, rows.Err()
+}
+
+// eventWebhookEvents compares the event projections before and after a
+// refresh. Changes caused by a venue, space or organization are reported by
+// entityWebhookEvents instead of once per event.
+func eventWebhookEvents(sourceTable string, before, after map[string]webhookState) []webhookEvent {
+	var events []webhookEvent
+	for uuid, a := range after {
+		if a.OrgUuid == "" {
+			continue
+		}
+		b, existed := before[uuid]
+
+		var types []string
+		switch {
+		case !existed:
+			types = append(types, "event.created")
+			if a.ReleaseStatus == "released" {
+				types = append(types, "event.released")
+			}
+		case a.ReleaseStatus != b.ReleaseStatus:
+			switch {
+			case a.ReleaseStatus == "released":
+				types = append(types, "event.released")
+			case a.ReleaseStatus == "cancelled":
+				types = append(types, "event.cancelled")
+			case a.ReleaseStatus == "archived":
+				types = append(types, "event.archived")
+			case isWebhookPublicStatus(b.ReleaseStatus) && !isWebhookPublicStatus(a.ReleaseStatus):
+				types = append(types, "event.unpublished")
+			default:
+				types = append(types, "event.updated")
+			}
+		case sourceTable == "event" || sourceTable == "pluto_image":
+			types = append(types, "event.updated")
+		}
+
+		data := map[string]any{
+			"event_uuid":     uuid,
+			"org_uuid":       a.OrgUuid,
+			"release_status": a.ReleaseStatus,
+		}
+		if existed && b.ReleaseStatus != a.ReleaseStatus {
+			data["previous_release_status"] = b.ReleaseStatus
+		}
+		if frontend := app.UranusInstance.Config.Frontend; frontend != "" {
+			data["url"] = fmt.Sprintf("%s/event/%s", frontend, uuid)
+		}
+
+		eventUuid := uuid
+		for _, t := range types {
+			events = append(events, webhookEvent{
+				Type:       t,
+				OrgUuid:    a.OrgUuid,
+				EntityUuid: uuid,
+				EventUuid:  &eventUuid,
+				Public:     isWebhookPublicStatus(a.ReleaseStatus) || (existed && isWebhookPublicStatus(b.ReleaseStatus)),
+				Data:       data,
+			})
+		}
+	}
+	return events
+}
+
+// eventDateWebhookEvents compares the event date projections before and
+// after a refresh. Besides own status changes of a date, changes of time or
+// place are reported.
+func eventDateWebhookEvents(sourceTable string, before, after map[string]webhookState) []webhookEvent {
+	var events []webhookEvent
+	for uuid, a := range after {
+		if a.OrgUuid == "" {
+			continue
+		}
+		b, existed := before[uuid]
+
+		eventStatus := eventDateStatusState(&a.ReleaseStatus)
+		var eventType string
+		switch {
+		case !existed:
+			eventType = "event_date.created"
+		case eventStatus != eventDateStatusState(&b.ReleaseStatus):
+			switch eventStatus {
+			case "cancelled":
+				eventType = "event_date.cancelled"
+			case "deferred":
+				eventType = "event_date.postponed"
+			case "rescheduled":
+				eventType = "event_date.rescheduled"
+			default:
+				eventType = "event_date.reinstated"
+			}
+		case a.Schedule != b.Schedule || sourceTable == "event_date":
+			eventType = "event_date.updated"
+		default:
+			continue
+		}
+
+		releaseStatus := a.EventReleaseStatus
+		if eventStatus != "inherited" {
+			releaseStatus = eventStatus
+		}
+
+		data := map[string]any{
+			"event_uuid":          a.EventUuid,
+			"event_date_uuid":     uuid,
+			"org_uuid":            a.OrgUuid,
+			"release_status":      releaseStatus,
+			"status_reason":       a.StatusReason,
+			"rescheduled_to_uuid": a.RescheduledToUuid,
+		}
+		if frontend := app.UranusInstance.Config.Frontend; frontend != "" {
+			data["url"] = fmt.Sprintf("%s/event/%s/date/%s", frontend, a.EventUuid, uuid)
+		}
+
+		eventUuid := a.EventUuid
+		events = append(events, webhookEvent{
+			Type:       eventType,
+			OrgUuid:    a.OrgUuid,
+			EntityUuid: uuid,
+			EventUuid:  &eventUuid,
+			Public:     isWebhookPublicStatus(a.EventReleaseStatus),
+			Data:       data,
+		})
+	}
+	return events
+}
+
+// entityWebhookEvents returns venue.updated, space.updated or org.updated for
+// refreshes caused by these tables
+func entityWebhookEvents(ctx context.Context, tx pgx.Tx, sourceTable string, uuids []string) ([]webhookEvent, error) {
+	schema := app.UranusInstance.Config.DbSchema
+
+	var eventType, key, query string
+	switch sourceTable {
+	case "venue":
+		eventType, key = "venue.updated", "venue_uuid"
+		query = fmt.Sprintf(`SELECT uuid, org_uuid FROM %s.venue WHERE uuid = ANY($1::uuid[])`, schema)
+	case "space":
+		eventType, key = "space.updated", "space_uuid"
+		query = fmt.Sprintf(`
+			SELECT s.uuid, v.org_uuid
+			FROM %[1]s.space s
+			JOIN %[1]s.venue v ON v.uuid = s.venue_uuid
+			WHERE s.uuid = ANY($1::uuid[])`,
+			schema)
+	case "organization":
+		eventType, key = "org.updated", "org_uuid"
+		query = fmt.Sprintf(`SELECT uuid, uuid FROM %s.organization WHERE uuid = ANY($1::uuid[])`, schema)
+	default:
+		return nil, nil
+	}
+
+	rows, err := tx.Query(ctx, query, uuids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []webhookEvent
+	for rows.Next() {
+		var uuid string
+		var orgUuid *string
+		if err := rows.Scan(&uuid, &orgUuid); err != nil {
+			return nil, err
+		}
+		if orgUuid == nil {
+			continue
+		}
+		data := map[string]any{
+			key:        uuid,
+			"org_uuid": *orgUuid,
+		}
+		events = append(events, webhookEvent{
+			Type:       eventType,
+			OrgUuid:    *orgUuid,
+			EntityUuid: uuid,
+			Data:       data,
+		})
+	}
+	return events, rows.Err()
+}
+
+// enqueueWebhookEventsTx writes a delivery for each matching subscription
+// into the outbox. The deliveries become visible to the webhook worker with
+// the commit of the transaction.
+func enqueueWebhookEventsTx(ctx context.Context, tx pgx.Tx, events []webhookEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	n := len(events)
+	types := make([]string, 0, n)
+	orgUuids := make([]string, 0, n)
+	entityUuids := make([]string, 0, n)
+	eventUuids := make([]*string, 0, n)
+	payloads := make([]string, 0, n)
+	for _, e := range events {
+		eventUuid := e.EventUuid
+		if !e.Public {
+			// Portal subscriptions only see public events
+			eventUuid = nil
+		}
+		payload, err := json.Marshal(e.Data)
+		if err != nil {
+			return err
+		}
+		types = append(types, e.Type)
+		orgUuids = append(orgUuids, e.OrgUuid)
+		entityUuids = append(entityUuids, e.EntityUuid)
+		eventUuids = append(eventUuids, eventUuid)
+		payloads = append(payloads, string(payload))
+	}
+
+	query := strings.Replace(app.UranusInstance.SqlWebhookEnqueue, "{{portal_conditions}}", app.UranusInstance.SqlPortalCondition, 1)
+	_, err := tx.Exec(ctx, query, types, orgUuids, entityUuids, eventUuids, payloads)
+	return err
+}
+
+// generateWebhookSecret returns a new random secret for signing deliveries
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// signWebhookPayload returns the value of the X-Uranus-Signature header, a
+// HMAC-SHA256 over timestamp, "." and the request body.
+func signWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay returns the wait time after a failed attempt, doubling
+// from 30 seconds up to webhookMaxRetryWait
+func webhookRetryDelay(attempt int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempt && delay < webhookMaxRetryWait; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxRetryWait)
+}
+
+// validateWebhookUrl requires an absolute https URL, http is accepted in
+// dev mode
+func validateWebhookUrl(rawUrl string, devMode bool) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be an absolute URL")
+	}
+	if u.Scheme != "https" && !(devMode && u.Scheme == "http") {
+		return errors.New("url must use https")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	return nil
+}
+
+func validateWebhookEventTypes(eventTypes []string) error {
+	for _, t := range eventTypes {
+		if !slices.Contains(webhookEventTypes, t) {
+			return fmt.Errorf("unknown event type: %s", t)
+		}
+	}
+	return nil
+}
+
+// webhookSubscriptionColumns are the columns read by scanWebhookSubscription
+const webhookSubscriptionColumns = `uuid, org_uuid, portal_uuid, url, event_types, description, active, created_at, modified_at`
+
+func scanWebhookSubscription(row pgx.Row) (model.WebhookSubscription, error) {
+	var s model.WebhookSubscription
+	err := row.Scan(
+		&s.Uuid, &s.OrgUuid, &s.PortalUuid, &s.Url, &s.EventTypes,
+		&s.Description, &s.Active, &s.CreatedAt, &s.ModifiedAt)
+	return s, err
+}
+
+// isOrgPortalTx reports whether the portal belongs to the organization
+func (h *ApiHandler) isOrgPortalTx(ctx context.Context, tx pgx.Tx, orgUuid string, portalUuid string) (bool, error) {
+	query := fmt.Sprintf(
+		`SELECT EXISTS (SELECT 1 FROM %s.portal2 WHERE uuid = $1::uuid AND org_uuid = $2::uuid)`,
+		h.DbSchema)
+	var exists bool
+	err := tx.QueryRow(ctx, query, portalUuid, orgUuid).Scan(&exists)
+	return exists, err
+}
+
+// webhookHttpClient returns the client for webhook deliveries and push
+// messages. Outside dev mode it refuses to connect to loopback, private and
+// link-local addresses, so that webhooks can not be used to reach internal
+// services.
+func (h *ApiHandler) webhookHttpClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !h.Config.DevMode {
+		dialer.Control = func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type pendingWebhookDelivery struct {
+	Id           int64
+	EventType    string
+	Payload      json.RawMessage
+	AttemptCount int
+	CreatedAt    time.Time
+	Url          string
+	Secret       string
+}
+
+// DeliverWebhooks sends a batch of due deliveries. The deliveries are claimed
+// for webhookLease with SKIP LOCKED, so several API processes can share the
+// work. Returns the number of claimed deliveries.
+func (h *ApiHandler) DeliverWebhooks(ctx context.Context) (int, error) {
+	query := fmt.Sprintf(`
+		UPDATE %[1]s.webhook_delivery d
+		SET attempt_count = d.attempt_count + 1,
+			last_attempt_at = NOW(),
+			next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM %[1]s.webhook_subscription s
+		WHERE s.uuid = d.subscription_uuid
+			AND d.id IN (
+				SELECT wd.id
+				FROM %[1]s.webhook_delivery wd
+				JOIN %[1]s.webhook_subscription ws ON ws.uuid = wd.subscription_uuid AND ws.active
+				WHERE wd.status = 'pending' AND wd.next_attempt_at <= NOW()
+				ORDER BY wd.next_attempt_at, wd.id
+				LIMIT $1
+				FOR UPDATE OF wd SKIP LOCKED
+			)
+		RETURNING d.id, d.event_type, d.payload, d.attempt_count, d.created_at, s.url, s.secret`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, webhookBatchSize, webhookLease.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	var deliveries []pendingWebhookDelivery
+	for rows.Next() {
+		var d pendingWebhookDelivery
+		if err := rows.Scan(&d.Id, &d.EventType, &d.Payload, &d.AttemptCount, &d.CreatedAt, &d.Url, &d.Secret); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	client := h.webhookHttpClient()
+	for _, d := range deliveries {
+		if err := h.deliverWebhook(ctx, client, d); err != nil {
+			debugf("webhook delivery %d failed: %v", d.Id, err)
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// deliverWebhook posts a delivery and stores the result. Failed deliveries
+// are retried with exponential backoff until webhookMaxAttempts.
+func (h *ApiHandler) deliverWebhook(ctx context.Context, client *http.Client, d pendingWebhookDelivery) error {
+	body, err := json.Marshal(map[string]any{
+		"id":         d.Id,
+		"type":       d.EventType,
+		"created_at": d.CreatedAt.UTC().Format(time.RFC3339),
+		"data":       d.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	var responseStatus *int
+	var responseBody, errText *string
+
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Uranus-Webhook/1.0")
+		req.Header.Set("X-Uranus-Event", d.EventType)
+		req.Header.Set("X-Uranus-Delivery", strconv.FormatInt(d.Id, 10))
+		req.Header.Set("X-Uranus-Timestamp", strconv.FormatInt(timestamp, 10))
+		req.Header.Set("X-Uranus-Signature", signWebhookPayload(d.Secret, timestamp, body))
+
+		var resp *http.Response
+		resp, err = client.Do(req)
+		if err == nil {
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxBodyLog))
+			_ = resp.Body.Close()
+			status := resp.StatusCode
+			text := string(b)
+			responseStatus, responseBody = &status, &text
+			if status < 200 || status > 299 {
+				err = fmt.Errorf("endpoint responded with status %d", status)
+			}
+		}
+	}
+
+	status := "delivered"
+	var retryDelay time.Duration
+	if err != nil {
+		text := err.Error()
+		errText = &text
+		if d.AttemptCount >= webhookMaxAttempts {
+			status = "failed"
+		} else {
+			status = "pending"
+			retryDelay = webhookRetryDelay(d.AttemptCount)
+		}
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.webhook_delivery
+		SET status = $2,
+			response_status = $3,
+			response_body = $4,
+			error = $5,
+			next_attempt_at = NOW() + make_interval(secs => $6),
+			delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+		WHERE id = $1`,
+		h.DbSchema)
+	_, dbErr := h.DbPool.Exec(ctx, query, d.Id, status, responseStatus, responseBody, errText, retryDelay.Seconds())
+	if dbErr != nil {
+		return dbErr
+	}
+	return err
+}
+
+// RunWebhookWorker delivers pending webhooks in the given interval until ctx
+// is cancelled. Full batches are followed by the next batch at once.
+func (h *ApiHandler) RunWebhookWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := h.DeliverWebhooks(ctx)
+			if err != nil {
+				debugf("deliver webhooks failed: %v", err)
+			}
+			if err != nil || n < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// registrationRow is a registration found by the token of its links
+type registrationRow struct {
+	Uuid             string
+	EventDateUuid    string
+	Name             string
+	Email            string
+	Seats            int
+	Lang             string
+	Status           string
+	ConfirmExpiresAt time.Time
+}
+
+// lockRegistrationByTokenTx returns the registration of a token and holds
+// the lock of its event date until the end of tx.
+func (h *ApiHandler) lockRegistrationByTokenTx(ctx context.Context, tx pgx.Tx, token string) (registrationRow, error) {
+	var r registrationRow
+	tokenHash := hashOrgKey(token)
+
+	query := fmt.Sprintf(`SELECT event_date_uuid FROM %s.event_registration WHERE token_hash = $1`, h.DbSchema)
+	if err := tx.QueryRow(ctx, query, tokenHash).Scan(&r.EventDateUuid); err != nil {
+		return r, err
+	}
+	if err := lockEventDateSeatsTx(ctx, tx, r.EventDateUuid); err != nil {
+		return r, err
+	}
+
+	query = fmt.Sprintf(`
+		SELECT uuid, event_date_uuid, name, email, seats, lang, status, confirm_expires_at
+		FROM %s.event_registration
+		WHERE token_hash = $1
+		FOR UPDATE`,
+		h.DbSchema)
+	err := tx.QueryRow(ctx, query, tokenHash).Scan(
+		&r.Uuid, &r.EventDateUuid, &r.Name, &r.Email, &r.Seats, &r.Lang, &r.Status, &r.ConfirmExpiresAt)
+	return r, err
+}
+
+// GetEventDateRegistration returns whether visitors can register for an
+// event date, the questions of the form and the free seats.
+//
+// PermissionNote: No user authentication.
+func (h *ApiHandler) GetEventDateRegistration(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-event-date-registration")
+	ctx := gc.Request.Context()
+
+	req, ok := h.ResolveEventDateRequest(gc, apiRequest)
+	if !ok {
+		return
+	}
+	if !req.DateMatch {
+		apiRequest.NotFound("event date not found")
+		return
+	}
+
+	d, err := h.loadRegistrationDateTx(ctx, h.DbPool, req.DateUuid)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if err != nil || d.EventUuid != req.EventUuid || !d.visible() {
+		apiRequest.NotFound("event date not found")
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, model.EventDateRegistrationState{
+		EventUuid:               d.EventUuid,
+		EventDateUuid:           d.EventDateUuid,
+		Open:                    d.open(time.Now()),
+		Questions:               d.Questions,
+		WaitlistEnabled:         d.WaitlistEnabled,
+		MaxSeatsPerRegistration: d.MaxSeats,
+		Capacity:                d.Capacity,
+		SeatsLeft:               d.seatsLeft(),
+		WaitlistCount:           d.WaitlistCount,
+	}, "")
+}
+
+// CreateEventRegistration registers a visitor for an event date with name,
+// email, the number of seats and the answers to the questions of the form.
+// The registration is pending until it is confirmed with the link sent by
+// email. Registering again with a pending registration sends a new link.
+//
+// PermissionNote: No user authentication, rate limited.
+func (h *ApiHandler) CreateEventRegistration(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "create-event-registration")
+	ctx := gc.Request.Context()
+
+	req, ok := h.ResolveEventDateRequest(gc, apiRequest)
+	if !ok {
+		return
+	}
+	if !req.DateMatch {
+		apiRequest.NotFound("event date not found")
+		return
+	}
+
+	type Payload struct {
+		Name    string         `json:"name" binding:"required"`
+		Email   string         `json:"email" binding:"required"`
+		Seats   *int           `json:"seats"`
+		Answers map[string]any `json:"answers"`
+		Lang    string         `json:"lang"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	name := strings.TrimSpace(payload.Name)
+	if name == "" || len(name) > registrationMaxNameLength {
+		apiRequest.Error(http.StatusBadRequest, "name is required and must not be longer than 200 characters")
+		return
+	}
+	email := strings.TrimSpace(payload.Email)
+	if !app.IsValidEmail(email) {
+		apiRequest.Error(http.StatusBadRequest, "invalid email address")
+		return
+	}
+	seats := 1
+	if payload.Seats != nil {
+		seats = *payload.Seats
+	}
+	lang := payload.Lang
+	if lang == "" {
+		lang = req.Lang
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if err := lockEventDateSeatsTx(ctx, tx, req.DateUuid); err != nil {
+			return TxInternalError(err)
+		}
+		d, err := h.loadRegistrationDateTx(ctx, tx, req.DateUuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("event date not found")
+			}
+			return TxInternalError(err)
+		}
+		if d.EventUuid != req.EventUuid {
+			return ApiErrNotFound("event date not found")
+		}
+		if !d.open(time.Now()) {
+			return NewApiTxError(http.StatusConflict, "registration is not open for this event date")
+		}
+		if seats < 1 || seats > d.MaxSeats {
+			return NewApiTxError(http.StatusBadRequest, "seats must be between 1 and %d", d.MaxSeats)
+		}
+		answers, err := validateRegistrationAnswers(d.Questions, payload.Answers)
+		if err != nil {
+			return NewApiTxError(http.StatusBadRequest, "%s", err.Error())
+		}
+		if !d.WaitlistEnabled && (!d.fits(seats) || d.WaitlistCount > 0) {
+			return NewApiTxError(http.StatusConflict, "event date is fully booked")
+		}
+
+		token, tokenHash, err := generateRegistrationToken()
+		if err != nil {
+			return TxInternalError(err)
+		}
+		expiresAt := time.Now().Add(registrationConfirmTTL)
+
+		var registrationUuid, status string
+		query := fmt.Sprintf(`
+			SELECT uuid, status
+			FROM %s.event_registration
+			WHERE event_date_uuid = $1::uuid AND lower(email) = lower($2) AND status <> 'cancelled'
+			FOR UPDATE`,
+			h.DbSchema)
+		err = tx.QueryRow(ctx, query, req.DateUuid, email).Scan(&registrationUuid, &status)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			registrationUuid, err = grains_uuid.Uuidv7String()
+			if err != nil {
+				return TxInternalError(err)
+			}
+			query = fmt.Sprintf(`
+				INSERT INTO %s.event_registration
+					(uuid, event_date_uuid, name, email, seats, answers, lang, token_hash, confirm_expires_at)
+				VALUES ($1::uuid, $2::uuid, $3, $4, $5, $6, $7, $8, $9)`,
+				h.DbSchema)
+			_, err = tx.Exec(ctx, query,
+				registrationUuid, req.DateUuid, name, email, seats, answers, lang, tokenHash, expiresAt)
+			if err != nil {
+				return TxInternalError(err)
+			}
+		case err != nil:
+			return TxInternalError(err)
+		case status == registrationPending:
+			// The earlier link stops working
+			query = fmt.Sprintf(`
+				UPDATE %s.event_registration
+				SET name = $2, seats = $3, answers = $4, lang = $5, token_hash = $6, confirm_expires_at = $7
+				WHERE uuid = $1::uuid`,
+				h.DbSchema)
+			_, err = tx.Exec(ctx, query, registrationUuid, name, seats, answers, lang, tokenHash, expiresAt)
+			if err != nil {
+				return TxInternalError(err)
+			}
+		default:
+			return NewApiTxError(http.StatusConflict, "email is already registered for this event date")
+		}
+
+		err = h.enqueueRegistrationEmailTx(ctx, tx, "event-registration-confirm", d, registrationMail{
+			To:    email,
+			Name:  name,
+			Lang:  lang,
+			Seats: seats,
+			Link:  h.registrationLink("confirm", token),
+		})
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.Success(http.StatusCreated, gin.H{
+		"status": registrationPending,
+	}, "registration received, please confirm it with the link sent by email")
+}
+
+// ConfirmEventRegistration confirms a registration with the token of the
+// link sent by email. The seats are booked if they are free and nobody is
+// waiting, otherwise the registration goes onto the waitlist. Without
+// waitlist a fully booked date cancels the registration. Confirming twice
+// returns the current state.
+//
+// PermissionNote: No user authentication, the registration is authenticated
+// by its token.
+func (h *ApiHandler) ConfirmEventRegistration(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "confirm-event-registration")
+	ctx := gc.Request.Context()
+
+	var payload struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	var status string
+	waitlistPosition := 0
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		r, err := h.lockRegistrationByTokenTx(ctx, tx, payload.Token)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("registration not found")
+			}
+			return TxInternalError(err)
+		}
+
+		status = r.Status
+		switch r.Status {
+		case registrationConfirmed:
+			return nil
+		case registrationWaitlisted:
+			waitlistPosition, err = h.registrationWaitlistPositionTx(ctx, tx, r.Uuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			return nil
+		case registrationCancelled:
+			return NewApiTxError(http.StatusConflict, "registration was cancelled")
+		}
+
+		if time.Now().After(r.ConfirmExpiresAt) {
+			return NewApiTxError(http.StatusGone, "confirmation link expired, please register again")
+		}
+
+		d, err := h.loadRegistrationDateTx(ctx, tx, r.EventDateUuid)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !d.open(time.Now()) {
+			return NewApiTxError(http.StatusConflict, "registration is not open for this event date")
+		}
+
+		mail := registrationMail{
+			To:         r.Email,
+			Name:       r.Name,
+			Lang:       r.Lang,
+			Seats:      r.Seats,
+			CancelLink: h.registrationLink("cancel", payload.Token),
+		}
+		var templateContext string
+
+		switch {
+		case d.fits(r.Seats) && d.WaitlistCount == 0:
+			status = registrationConfirmed
+			templateContext = "event-registration-confirmed"
+			query := fmt.Sprintf(`
+				UPDATE %s.event_registration
+				SET status = 'confirmed', confirmed_at = NOW()
+				WHERE uuid = $1::uuid`,
+				h.DbSchema)
+			if _, err := tx.Exec(ctx, query, r.Uuid); err != nil {
+				return TxInternalError(err)
+			}
+
+		case d.WaitlistEnabled:
+			status = registrationWaitlisted
+			templateContext = "event-registration-waitlisted"
+			query := fmt.Sprintf(`
+				UPDATE %s.event_registration
+				SET status = 'waitlisted', confirmed_at = NOW(), waitlisted_at = NOW()
+				WHERE uuid = $1::uuid`,
+				h.DbSchema)
+			if _, err := tx.Exec(ctx, query, r.Uuid); err != nil {
+				return TxInternalError(err)
+			}
+			waitlistPosition, err = h.registrationWaitlistPositionTx(ctx, tx, r.Uuid)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			mail.Position = waitlistPosition
+
+		default:
+			// Booked up since the registration, the registration is cancelled
+			// so the visitor can register again for another date
+			status = registrationCancelled
+			query := fmt.Sprintf(`
+				UPDATE %s.event_registration
+				SET status = 'cancelled', cancelled_at = NOW()
+				WHERE uuid = $1::uuid`,
+				h.DbSchema)
+			if _, err := tx.Exec(ctx, query, r.Uuid); err != nil {
+				return TxInternalError(err)
+			}
+			return nil
+		}
+
+		if err := h.updateAvailabilityTx(ctx, tx, []string{r.EventDateUuid}); err != nil {
+			return TxInternalError(err)
+		}
+
+		err = h.enqueueRegistrationEmailTx(ctx, tx, templateContext, d, mail)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	if status == registrationCancelled {
+		apiRequest.Error(http.StatusConflict, "event date is fully booked")
+		return
+	}
+
+	data := gin.H{"status": status}
+	if status == registrationWaitlisted {
+		data["waitlist_position"] = waitlistPosition
+	}
+	apiRequest.Success(http.StatusOK, data, "registration confirmed")
+}
+
+// CancelEventRegistration cancels a registration with the token of the
+// links sent by email. Seats which become free are given to the waitlist.
+// Cancelling twice succeeds.
+//
+// PermissionNote: No user authentication, the registration is authenticated
+// by its token.
+func (h *ApiHandler) CancelEventRegistration(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "cancel-event-registration")
+	ctx := gc.Request.Context()
+
+	var payload struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		r, err := h.lockRegistrationByTokenTx(ctx, tx, payload.Token)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("registration not found")
+			}
+			return TxInternalError(err)
+		}
+		if r.Status == registrationCancelled {
+			return nil
+		}
+
+		return h.cancelEventRegistrationTx(ctx, tx, r, "attendee")
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "registration cancelled")
+}
+
+// cancelEventRegistrationTx cancels a registration, mails the visitor and
+// promotes the waitlist if seats became free. The caller must hold the lock
+// of the event date.
+func (h *ApiHandler) cancelEventRegistrationTx(ctx context.Context, tx pgx.Tx, r registrationRow, cancelledBy string) *ApiTxError {
+	query := fmt.Sprintf(`
+		UPDATE %s.event_registration
+		SET status = 'cancelled', cancelled_at = NOW(), cancelled_by = $2
+		WHERE uuid = $1::uuid`,
+		h.DbSchema)
+	if _, err := tx.Exec(ctx, query, r.Uuid, cancelledBy); err != nil {
+		return TxInternalError(err)
+	}
+
+	d, err := h.loadRegistrationDateTx(ctx, tx, r.EventDateUuid)
+	if err != nil {
+		return TxInternalError(err)
+	}
+
+	// Unconfirmed registrations never got a mail besides the confirmation
+	if r.Status != registrationPending {
+		err = h.enqueueRegistrationEmailTx(ctx, tx, "event-registration-cancelled", d, registrationMail{
+			To:    r.Email,
+			Name:  r.Name,
+			Lang:  r.Lang,
+			Seats: r.Seats,
+		})
+		if err != nil {
+			return TxInternalError(err)
+		}
+	}
+
+	if r.Status == registrationConfirmed {
+		if _, err := h.promoteWaitlistTx(ctx, tx, r.EventDateUuid); err != nil {
+			return TxInternalError(err)
+		}
+	}
+
+	if err := h.updateAvailabilityTx(ctx, tx, []string{r.EventDateUuid}); err != nil {
+		return TxInternalError(err)
+	}
+	return nil
+}
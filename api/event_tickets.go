@@ -0,0 +1,234 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// GetEventDateTickets returns the price tiers offered for an event date
+// with the tickets left.
+//
+// PermissionNote: No user authentication.
+func (h *ApiHandler) GetEventDateTickets(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-event-date-tickets")
+	ctx := gc.Request.Context()
+
+	req, ok := h.ResolveEventDateRequest(gc, apiRequest)
+	if !ok {
+		return
+	}
+	if !req.DateMatch {
+		apiRequest.NotFound("event date not found")
+		return
+	}
+
+	d, err := h.loadRegistrationDateTx(ctx, h.DbPool, req.DateUuid)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if err != nil || d.EventUuid != req.EventUuid || !d.visible() {
+		apiRequest.NotFound("event date not found")
+		return
+	}
+
+	tiers, err := h.loadTicketTiersTx(ctx, h.DbPool, req.DateUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+
+	limit, sold := ticketLimit(tiers, d.ticketCapacity())
+	var ticketsLeft *int
+	if limit != nil {
+		left := max(*limit-sold, 0)
+		ticketsLeft = &left
+	}
+
+	onSale := len(tiers) > 0 && d.ReleaseStatus == "released" && d.StartAt != nil && d.StartAt.After(time.Now())
+	apiRequest.Success(http.StatusOK, gin.H{
+		"event_uuid":      d.EventUuid,
+		"event_date_uuid": d.EventDateUuid,
+		"on_sale":         onSale,
+		"tickets_left":    ticketsLeft,
+		"tiers":           tiers,
+	}, "")
+}
+
+// CreateEventDateTickets issues free tickets or tickets paid at the door
+// for an event date and mails them to the holder. The tickets are returned
+// with the signed codes of their QR codes.
+//
+// PermissionNote: No user authentication, rate limited.
+func (h *ApiHandler) CreateEventDateTickets(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "create-event-date-tickets")
+	ctx := gc.Request.Context()
+
+	req, ok := h.ResolveEventDateRequest(gc, apiRequest)
+	if !ok {
+		return
+	}
+	if !req.DateMatch {
+		apiRequest.NotFound("event date not found")
+		return
+	}
+
+	type Payload struct {
+		PriceTierUuid string `json:"price_tier_uuid" binding:"required"`
+		Quantity      *int   `json:"quantity"`
+		Name          string `json:"name" binding:"required"`
+		Email         string `json:"email" binding:"required"`
+		Lang          string `json:"lang"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+
+	name := strings.TrimSpace(payload.Name)
+	if name == "" || len(name) > ticketMaxHolderLen {
+		apiRequest.Error(http.StatusBadRequest,
+			fmt.Sprintf("name is required and must not be longer than %d characters", ticketMaxHolderLen))
+		return
+	}
+	email := strings.TrimSpace(payload.Email)
+	if !app.IsValidEmail(email) {
+		apiRequest.Error(http.StatusBadRequest, "invalid email address")
+		return
+	}
+	quantity := 1
+	if payload.Quantity != nil {
+		quantity = *payload.Quantity
+	}
+	if quantity < 1 || quantity > ticketMaxPerOrder {
+		apiRequest.Error(http.StatusBadRequest, fmt.Sprintf("quantity must be between 1 and %d", ticketMaxPerOrder))
+		return
+	}
+	lang := payload.Lang
+	if lang == "" {
+		lang = req.Lang
+	}
+
+	var tickets []model.EventTicket
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		if err := lockEventDateSeatsTx(ctx, tx, req.DateUuid); err != nil {
+			return TxInternalError(err)
+		}
+		d, err := h.loadRegistrationDateTx(ctx, tx, req.DateUuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ApiErrNotFound("event date not found")
+			}
+			return TxInternalError(err)
+		}
+		if d.EventUuid != req.EventUuid || !d.visible() {
+			return ApiErrNotFound("event date not found")
+		}
+		if d.ReleaseStatus != "released" || d.StartAt == nil || !d.StartAt.After(time.Now()) {
+			return NewApiTxError(http.StatusConflict, "tickets are not on sale for this event date")
+		}
+
+		var issued int
+		query := fmt.Sprintf(`
+			SELECT COUNT(*) FROM %s.event_ticket
+			WHERE event_date_uuid = $1::uuid AND lower(holder_email) = lower($2) AND status <> 'cancelled'`,
+			h.DbSchema)
+		if err := tx.QueryRow(ctx, query, req.DateUuid, email).Scan(&issued); err != nil {
+			return TxInternalError(err)
+		}
+		if issued+quantity > ticketMaxPerEmail {
+			return NewApiTxError(http.StatusConflict, "at most %d tickets per email address", ticketMaxPerEmail)
+		}
+
+		var txErr *ApiTxError
+		tickets, txErr = h.issueTicketsTx(ctx, tx, d, ticketOrder{
+			TierUuid:    payload.PriceTierUuid,
+			Quantity:    quantity,
+			HolderName:  name,
+			HolderEmail: &email,
+		})
+		if txErr != nil {
+			return txErr
+		}
+
+		if err := h.enqueueTicketsEmailTx(ctx, tx, d, tickets, lang); err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("ticket_count", len(tickets))
+	apiRequest.Success(http.StatusCreated, tickets, "tickets issued successfully")
+}
+
+// GetTicket returns a ticket with its event date by the signed code of its
+// QR code, as linked in the ticket mail.
+//
+// PermissionNote: No user authentication, the ticket is authenticated by its
+// signed code.
+func (h *ApiHandler) GetTicket(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-ticket")
+	ctx := gc.Request.Context()
+
+	code := gc.Query("code")
+	ticketUuid, ok := h.verifyTicketCode(code)
+	if !ok {
+		apiRequest.NotFound("ticket not found")
+		return
+	}
+
+	query := fmt.Sprintf(`
+		SELECT t.uuid, t.order_uuid, t.event_date_uuid, t.price_tier_uuid, pt.name, t.holder_name,
+			t.amount::float8, t.currency, t.payment, t.status, t.issued_at, t.checked_in_at, t.cancelled_at,
+			edp.event_uuid, COALESCE(ep.title, ''), TO_CHAR(edp.start_date, 'YYYY-MM-DD'),
+			TO_CHAR(edp.start_time, 'HH24:MI'), COALESCE(edp.venue_name, ep.venue_name)
+		FROM %[1]s.event_ticket t
+		JOIN %[1]s.event_price_tier pt ON pt.uuid = t.price_tier_uuid
+		JOIN %[1]s.event_date_projection edp ON edp.event_date_uuid = t.event_date_uuid
+		JOIN %[1]s.event_projection ep ON ep.event_uuid = edp.event_uuid
+		WHERE t.uuid = $1::uuid`,
+		h.DbSchema)
+	var t model.EventTicket
+	var eventUuid, title string
+	var startDate, startTime, venueName *string
+	err := h.DbPool.QueryRow(ctx, query, ticketUuid).Scan(
+		&t.Uuid, &t.OrderUuid, &t.EventDateUuid, &t.PriceTierUuid, &t.PriceTierName, &t.HolderName,
+		&t.Amount, &t.Currency, &t.Payment, &t.Status, &t.IssuedAt, &t.CheckedInAt, &t.CancelledAt,
+		&eventUuid, &title, &startDate, &startTime, &venueName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("ticket not found")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	t.Code = h.signTicketCode(t.Uuid)
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"ticket":     t,
+		"event_uuid": eventUuid,
+		"title":      title,
+		"start_date": startDate,
+		"start_time": startTime,
+		"venue_name": venueName,
+	}, "")
+}
@@ -19,6 +19,7 @@ var publicStatuses = []string{
 	"cancelled",
 	"deferred",
 	"rescheduled",
+	"archived",
 }
 
 func (h *ApiHandler) GetEvent(gc *gin.Context) {
@@ -40,6 +41,10 @@ func (h *ApiHandler) GetEvent(gc *gin.Context) {
 		return
 	}
 
+	if gc.Query("jsonld") == "true" {
+		event.JsonLD = h.BuildEventJsonLD(event, h.eventDateUrl(event))
+	}
+
 	apiRequest.Success(http.StatusOK, event)
 }
 
@@ -75,6 +80,10 @@ func (h *ApiHandler) GetEventByDate(gc *gin.Context) {
 		return
 	}
 
+	if gc.Query("jsonld") == "true" {
+		event.JsonLD = h.BuildEventJsonLD(event, h.eventDateUrl(event))
+	}
+
 	apiRequest.SetMeta("event_date_count", len(event.FurtherDates)+1)
 	apiRequest.Success(http.StatusOK, event)
 }
@@ -110,10 +119,12 @@ func (h *ApiHandler) LoadEventByDateIdentifier(
 			usedStatuses = []string{
 				"draft",
 				"review",
+				"scheduled",
 				"released",
 				"cancelled",
 				"deferred",
 				"rescheduled",
+				"archived",
 			}
 		}
 	}
@@ -268,6 +279,10 @@ func (h *ApiHandler) LoadEventByDateIdentifier(
 			&edd.AccessibilityFlags,
 			&edd.AccessibilitySummary,
 			&edd.AccessibilityInfo,
+			&edd.StatusReason,
+			&edd.RescheduledToUuid,
+			&edd.RescheduledFromUuid,
+			&edd.AvailabilityStatusId,
 		)
 		if err != nil {
 			return event, err
@@ -335,6 +350,15 @@ func (h *ApiHandler) LoadEventByDateIdentifier(
 	return event, nil
 }
 
+// eventDateUrl returns the frontend URL of the selected event date, empty if
+// no frontend is configured
+func (h *ApiHandler) eventDateUrl(event model.EventDetails) string {
+	if h.Config.Frontend == "" || event.Date == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/event/%s/date/%s", h.Config.Frontend, event.Uuid, event.Date.Uuid)
+}
+
 func intFromAny(v interface{}) int {
 	switch t := v.(type) {
 	case int32:
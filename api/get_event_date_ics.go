@@ -25,38 +25,56 @@ func (h *ApiHandler) GetEventDateICS(gc *gin.Context) {
 	dateUuid := eventDateRequest.DateUuid
 
 	type EventDateICS struct {
-		EventDateUUID    string
-		VenueName        *string
-		VenueStreet      *string
-		VenueHouseNumber *string
-		VenueCity        *string
-		StartDate        *string
-		StartTime        *string
-		EndDate          *string
-		EndTime          *string
-		Title            *string
-		Subtitle         *string
-		Description      *string
-		OrgName          *string
-		OrgContactEmail  *string
+		EventDateUUID       string
+		EventUUID           string
+		RecurrenceGenerated bool
+		VenueName           *string
+		VenueStreet         *string
+		VenueHouseNumber    *string
+		VenuePostalCode     *string
+		VenueCity           *string
+		StartDate           *string
+		StartTime           *string
+		EndDate             *string
+		EndTime             *string
+		AllDay              bool
+		Title               *string
+		Subtitle            *string
+		Description         *string
+		OrgName             *string
+		OrgContactEmail     *string
+		ReleaseStatus       *string
+		StatusReason        *string
+		RescheduledToUuid   *string
+		Availability        *int
+		ModifiedAt          *time.Time
 	}
 
 	var event EventDateICS
 	err := h.DbPool.QueryRow(ctx, app.UranusInstance.SqlGetEventDateICS, dateUuid).Scan(
 		&event.EventDateUUID,
+		&event.EventUUID,
+		&event.RecurrenceGenerated,
 		&event.VenueName,
 		&event.VenueStreet,
 		&event.VenueHouseNumber,
+		&event.VenuePostalCode,
 		&event.VenueCity,
 		&event.StartDate,
 		&event.StartTime,
 		&event.EndDate,
 		&event.EndTime,
+		&event.AllDay,
 		&event.Title,
 		&event.Subtitle,
 		&event.Description,
 		&event.OrgName,
 		&event.OrgContactEmail,
+		&event.ReleaseStatus,
+		&event.StatusReason,
+		&event.RescheduledToUuid,
+		&event.Availability,
+		&event.ModifiedAt,
 	)
 	if err != nil {
 		apiRequest.InternalServerError()
@@ -70,110 +88,57 @@ func (h *ApiHandler) GetEventDateICS(gc *gin.Context) {
 		return *p
 	}
 
-	startDate := str(event.StartDate)
-	startTime := str(event.StartTime)
-	endDate := str(event.EndDate)
-	endTime := str(event.EndTime)
-
-	dtStart := formatICSDatetime(startDate, startTime)
-
-	dtEnd := ""
-
-	// If an explicit end time exists, use it.
-	// If no end date is supplied, assume the event ends on the start date.
-	if endTime != "" {
-		if endDate == "" {
-			endDate = startDate
-		}
-
-		// If start and end are on the same date and the end time
-		// is earlier than the start time, assume the event ends
-		// after midnight on the following day.
-		if endDate == startDate && startTime != "" && endTime < startTime {
-			start, err := time.Parse("2006-01-02", endDate)
-			if err == nil {
-				endDate = start.AddDate(0, 0, 1).Format("2006-01-02")
-			}
-		}
-
-		dtEnd = formatICSDatetime(endDate, endTime)
+	icsEv := icsEvent{
+		Uid:             fmt.Sprintf("%s@%s", event.EventDateUUID, h.Config.IcsDomain),
+		StartDate:       str(event.StartDate),
+		StartTime:       str(event.StartTime),
+		EndDate:         str(event.EndDate),
+		EndTime:         str(event.EndTime),
+		AllDay:          event.AllDay,
+		Title:           str(event.Title),
+		Description:     str(event.Description),
+		Location:        icsLocation(event.VenueName, event.VenueStreet, event.VenueHouseNumber, event.VenuePostalCode, event.VenueCity),
+		OrgName:         str(event.OrgName),
+		OrgContactEmail: str(event.OrgContactEmail),
+		ReleaseStatus:   str(event.ReleaseStatus),
+		StatusReason:    str(event.StatusReason),
+		RescheduledTo:   str(event.RescheduledToUuid),
+		Availability:    event.Availability,
+		ModifiedAt:      event.ModifiedAt,
 	}
 
-	debugf("dtStart: %s, dtEnd: %s", dtStart, dtEnd)
-
-	// Content
-
-	title := str(event.Title)
-	if title == "" {
-		title = "Event"
-	}
-
-	description := str(event.Description)
 	if sub := str(event.Subtitle); sub != "" {
-		description = sub + "\n\n" + description
+		icsEv.Description = sub + "\n\n" + icsEv.Description
 	}
-
-	location := fmt.Sprintf("%s, %s %s, %s",
-		str(event.VenueName),
-		str(event.VenueStreet),
-		str(event.VenueHouseNumber),
-		str(event.VenueCity),
-	)
-
-	// ICS fields
-
-	// Floating time format
-	timeFormat := "20060102T150405"
-
-	uid := fmt.Sprintf("%s@%s", event.EventDateUUID, h.Config.IcsDomain)
-	dtStamp := time.Now().UTC().Format(timeFormat)
-
-	// Build ICS
-
-	var b strings.Builder
-
-	b.WriteString("BEGIN:VCALENDAR\r\n")
-	b.WriteString("VERSION:2.0\r\n")
-	b.WriteString("PRODID:-//Uranus//EN\r\n")
-	b.WriteString("METHOD:PUBLISH\r\n")
-
-	b.WriteString("BEGIN:VEVENT\r\n")
-	b.WriteString("UID:" + uid + "\r\n")
-	b.WriteString("DTSTAMP:" + dtStamp + "\r\n")
-	b.WriteString("DTSTART:" + dtStart + "\r\n")
-
-	// Use the event's actual end date/time when available.
-	// Otherwise, default to one hour after the start.
-	if dtEnd != "" {
-		b.WriteString("DTEND:" + dtEnd + "\r\n")
-	} else {
-		// No explicit end time: default to one hour after the start.
-		start, err := time.Parse(timeFormat, dtStart)
-		if err == nil {
-			dtEnd = start.Add(time.Hour).Format(timeFormat)
-			b.WriteString("DTEND:" + dtEnd + "\r\n")
-		} else {
-			debugf(err.Error())
+	if h.Config.Frontend != "" {
+		icsEv.Url = fmt.Sprintf("%s/event/%s/date/%s", h.Config.Frontend, event.EventUUID, event.EventDateUUID)
+		if icsEv.RescheduledTo != "" {
+			icsEv.RescheduledUrl = fmt.Sprintf("%s/event/%s/date/%s", h.Config.Frontend, event.EventUUID, icsEv.RescheduledTo)
 		}
 	}
 
-	b.WriteString("SUMMARY:" + escapeICSText(title) + "\r\n")
-	b.WriteString("DESCRIPTION:" + escapeICSText(description) + "\r\n")
-	b.WriteString("LOCATION:" + escapeICSText(location) + "\r\n")
-
-	if email := str(event.OrgContactEmail); email != "" {
-		b.WriteString("ORGANIZER;CN=" + escapeICSText(str(event.OrgName)) +
-			":mailto:" + email + "\r\n")
+	// Dates generated from a recurrence rule are exported as the whole series
+	if event.RecurrenceGenerated {
+		recurrence, err := h.loadICSRecurrence(ctx, h.DbPool, event.EventUUID)
+		if err != nil {
+			apiRequest.InternalServerError()
+			return
+		}
+		if recurrence != nil {
+			icsEv = h.asICSSeries(icsEv, event.EventUUID, recurrence)
+		}
 	}
 
-	b.WriteString("END:VEVENT\r\n")
-	b.WriteString("END:VCALENDAR\r\n")
+	// Build ICS
 
-	ics := b.String()
+	w := newICSWriter(h.Config.IcsTimezone)
+	w.Begin("")
+	w.WriteEvent(icsEv)
+	ics := w.End()
 
 	// Response
 
-	filename := title
+	filename := str(event.Title)
 	if filename == "" {
 		filename = "event"
 	}
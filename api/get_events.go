@@ -44,6 +44,8 @@ type EventFilterRequest struct {
 	Age   string `json:"age,omitempty"`
 	Price string `json:"price,omitempty"`
 
+	FreeSeats bool `json:"free_seats,omitempty"`
+
 	Lon    *float64 `json:"lon,omitempty"`
 	Lat    *float64 `json:"lat,omitempty"`
 	Radius *float64 `json:"radius,omitempty"`
@@ -86,6 +88,7 @@ type eventResponse struct {
 	Duration                *int        `json:"duration,omitempty"`
 	AllDay                  *bool       `json:"all_day,omitempty"`
 	TicketLink              *string     `json:"ticket_link,omitempty"`
+	AvailabilityStatusId    *int        `json:"availability_status_id,omitempty"`
 	SpaceUuid               *string     `json:"space_uuid,omitempty"`
 	SpaceName               *string     `json:"space_name,omitempty"`
 	SpaceAccessibilityFlags *string     `json:"space_accessibility_flags,omitempty"`
@@ -112,6 +115,8 @@ type eventResponse struct {
 	PriceType               *string     `json:"price_type,omitempty"`
 	VisitorInfoFlags        *string     `json:"visitor_info_flags,omitempty"`
 	ReleaseStatus           *string     `json:"release_status,omitempty"`
+	StatusReason            *string     `json:"status_reason,omitempty"`
+	RescheduledToUuid       *string     `json:"rescheduled_to_uuid,omitempty"`
 }
 
 type eventsResponse struct {
@@ -341,6 +346,13 @@ func (h *ApiHandler) buildEventFilters(request EventFilterRequest, useTypeFilter
 		return filters, errBuild
 	}
 
+	// Dates without known availability are not booked up
+	if request.FreeSeats {
+		conditions = append(conditions, fmt.Sprintf(
+			"COALESCE(edp.availability_status_id, %d) IN (%d, %d)",
+			availabilityStatusAvailable, availabilityStatusAvailable, availabilityStatusFewLeft))
+	}
+
 	filters.ArgIndex, errBuild = sql_utils.BuildBitmaskCondition(
 		request.Accessibility,
 		"edp.space_accessibility_flags",
@@ -580,7 +592,10 @@ func (h *ApiHandler) GetEvents(gc *gin.Context) {
 			&e.Duration,
 			&e.AllDay,
 			&e.ReleaseStatus,
+			&e.StatusReason,
+			&e.RescheduledToUuid,
 			&e.TicketLink,
+			&e.AvailabilityStatusId,
 			&e.Title,
 			&e.Subtitle,
 			&e.Summary,
@@ -1241,6 +1256,7 @@ func getEventFilterRequestFromQuery(
 		"visitor_infos":        {},
 		"age":                  {},
 		"price":                {},
+		"free_seats":           {},
 		"lon":                  {},
 		"lat":                  {},
 		"radius":               {},
@@ -1324,6 +1340,14 @@ func getEventFilterRequestFromQuery(
 		request.Lat = &v
 	}
 
+	if value, exists := GetContextParam(gc, "free_seats"); exists && value != "" {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return request, fmt.Errorf("free_seats has invalid format: %s (expected true or false)", value)
+		}
+		request.FreeSeats = v
+	}
+
 	if value, exists := GetContextParam(gc, "radius"); exists && value != "" {
 		v, err := strconv.ParseFloat(value, 64)
 		if err != nil {
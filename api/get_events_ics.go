@@ -0,0 +1,377 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// Parameters which make no sense in a calendar subscription
+var icsFeedIgnoredParams = []string{
+	"last_event_start_at",
+	"last_event_date_uuid",
+	"week_start",
+}
+
+// GetEventsICS returns all events matching the EventFilterRequest
+// parameters as subscribable iCalendar feed.
+func (h *ApiHandler) GetEventsICS(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-events-ics")
+
+	request, err := getEventFilterRequest(gc, icsFeedIgnoredParams)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.writeEventsICSFeed(gc, apiRequest, request, "Uranus", "events")
+}
+
+// GetOrgEventsICS returns the events of an organization as iCalendar feed.
+func (h *ApiHandler) GetOrgEventsICS(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-org-events-ics")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+
+	request, err := getEventFilterRequest(gc, icsFeedIgnoredParams)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var orgName string
+	query := fmt.Sprintf(`SELECT name FROM %s.organization WHERE uuid = $1::uuid`, h.DbSchema)
+	err = h.DbPool.QueryRow(ctx, query, orgUuid).Scan(&orgName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("organization not found")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	request.OrgUuids = []string{orgUuid}
+	h.writeEventsICSFeed(gc, apiRequest, request, orgName, orgUuid)
+}
+
+// GetVenueEventsICS returns the events of a venue as iCalendar feed. The
+// venue is identified by uuid or slug.
+func (h *ApiHandler) GetVenueEventsICS(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-venue-events-ics")
+	ctx := gc.Request.Context()
+
+	venueIdentifier := gc.Param("venueIdentifier")
+	if venueIdentifier == "" {
+		apiRequest.Required("venueIdentifier is required")
+		return
+	}
+
+	request, err := getEventFilterRequest(gc, icsFeedIgnoredParams)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	condition := "slug = $1::text"
+	if grains_uuid.IsValidUuidv7(venueIdentifier) {
+		condition = "uuid = $1::uuid"
+	}
+
+	var venueUuid, venueName string
+	query := fmt.Sprintf(`SELECT uuid, name FROM %s.venue WHERE %s`, h.DbSchema, condition)
+	err = h.DbPool.QueryRow(ctx, query, venueIdentifier).Scan(&venueUuid, &venueName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("venue not found")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	request.VenueUuids = []string{venueUuid}
+	h.writeEventsICSFeed(gc, apiRequest, request, venueName, venueIdentifier)
+}
+
+// GetPortalEventsICS returns the events shown in a portal as iCalendar feed.
+func (h *ApiHandler) GetPortalEventsICS(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-portal-events-ics")
+	ctx := gc.Request.Context()
+
+	portalUuid := gc.Param("uuid")
+	if portalUuid == "" {
+		apiRequest.Required("uuid is required")
+		return
+	}
+
+	request, err := getEventFilterRequest(gc, append(icsFeedIgnoredParams, "portal", "geolist_region"))
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var portalName string
+	query := fmt.Sprintf(`SELECT name FROM %s.portal2 WHERE uuid = $1::uuid`, h.DbSchema)
+	err = h.DbPool.QueryRow(ctx, query, portalUuid).Scan(&portalName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("portal not found")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	request.PortalUuid = portalUuid
+	request.GeolistRegion = ""
+	h.writeEventsICSFeed(gc, apiRequest, request, portalName, portalUuid)
+}
+
+// writeEventsICSFeed queries the event dates matching request and responds
+// with an iCalendar document, one VEVENT per event date.
+func (h *ApiHandler) writeEventsICSFeed(
+	gc *gin.Context,
+	apiRequest *grains_api.Request,
+	request EventFilterRequest,
+	calendarName string,
+	filename string,
+) {
+	ctx := gc.Request.Context()
+
+	filters, err := h.buildEventFilters(request, true)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := app.UranusInstance.SqlGetEventsICS
+	query = strings.Replace(query, "{{search_rank}}", filters.SearchRankSelect, 1)
+	query = strings.Replace(query, "{{date_conditions}}", filters.DateConditions, 1)
+	query = strings.Replace(query, "{{conditions}}", filters.ConditionsStr, 1)
+	query = strings.Replace(query, "{{limit}}", filters.LimitClause, 1)
+	query = strings.Replace(query, "{{portal_join}}", filters.PortalJoin, 1)
+	query = strings.Replace(query, "{{portal_conditions}}", filters.PortalConditions, 1)
+
+	rows, err := h.DbPool.Query(ctx, query, filters.Args...)
+	if err != nil {
+		debugf("Error querying events: %v", err)
+		apiRequest.InternalServerError()
+		return
+	}
+	defer rows.Close()
+
+	var events []icsEvent
+	for rows.Next() {
+		e, err := h.scanICSEvent(rows)
+		if err != nil {
+			debugf("Error scanning events: %v", err)
+			apiRequest.InternalServerError()
+			return
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf("Error reading events: %v", err)
+		apiRequest.InternalServerError()
+		return
+	}
+
+	series, err := h.loadICSFeedSeries(ctx, events)
+	if err != nil {
+		debugf("Error loading recurrences: %v", err)
+		apiRequest.InternalServerError()
+		return
+	}
+
+	w := newICSWriter(h.Config.IcsTimezone)
+	w.Begin(calendarName)
+
+	// Generated dates of a recurring event are written once, as the series
+	written := make(map[string]bool)
+	for _, e := range events {
+		if rec := series[e.DateUuid]; rec != nil {
+			if written[e.EventUuid] {
+				continue
+			}
+			written[e.EventUuid] = true
+			e = h.asICSSeries(e, e.EventUuid, rec)
+		}
+		w.WriteEvent(e)
+	}
+
+	gc.Header("Content-Type", "text/calendar; charset=utf-8")
+	gc.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.ics"`, filename))
+	gc.String(http.StatusOK, w.End())
+}
+
+// loadICSFeedSeries returns the recurrences of the generated dates among
+// events, keyed by date uuid. Dates of the same event share one recurrence.
+func (h *ApiHandler) loadICSFeedSeries(ctx context.Context, events []icsEvent) (map[string]*model.EventRecurrence, error) {
+	series := make(map[string]*model.EventRecurrence)
+	if len(events) == 0 {
+		return series, nil
+	}
+
+	dateUuids := make([]string, 0, len(events))
+	for _, e := range events {
+		dateUuids = append(dateUuids, e.DateUuid)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT uuid::text, event_uuid::text
+		FROM %s.event_date
+		WHERE uuid = ANY($1::uuid[])
+			AND recurrence_generated`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, dateUuids)
+	if err != nil {
+		return nil, err
+	}
+	generated := make(map[string]string)
+	for rows.Next() {
+		var dateUuid, eventUuid string
+		if err := rows.Scan(&dateUuid, &eventUuid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		generated[dateUuid] = eventUuid
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	recurrences := make(map[string]*model.EventRecurrence)
+	for dateUuid, eventUuid := range generated {
+		rec, ok := recurrences[eventUuid]
+		if !ok {
+			rec, err = h.loadICSRecurrence(ctx, h.DbPool, eventUuid)
+			if err != nil {
+				return nil, err
+			}
+			recurrences[eventUuid] = rec
+		}
+		if rec != nil {
+			series[dateUuid] = rec
+		}
+	}
+
+	return series, nil
+}
+
+// scanICSEvent scans a row of get-events-ics.sql, or a query with the same
+// columns, into an icsEvent.
+func (h *ApiHandler) scanICSEvent(rows pgx.Rows) (icsEvent, error) {
+	str := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+
+	var (
+		searchRank       float32
+		dateUuid         string
+		eventUuid        string
+		startDate        *string
+		startTime        *string
+		endDate          *string
+		endTime          *string
+		allDay           bool
+		releaseStatus    *string
+		statusReason     *string
+		rescheduledTo    *string
+		availability     *int
+		title            *string
+		subtitle         *string
+		description      *string
+		orgName          *string
+		orgContactEmail  *string
+		venueName        *string
+		venueStreet      *string
+		venueHouseNumber *string
+		venuePostalCode  *string
+		venueCity        *string
+		modifiedAt       *time.Time
+	)
+
+	err := rows.Scan(
+		&searchRank,
+		&dateUuid,
+		&eventUuid,
+		&startDate,
+		&startTime,
+		&endDate,
+		&endTime,
+		&allDay,
+		&releaseStatus,
+		&statusReason,
+		&rescheduledTo,
+		&availability,
+		&title,
+		&subtitle,
+		&description,
+		&orgName,
+		&orgContactEmail,
+		&venueName,
+		&venueStreet,
+		&venueHouseNumber,
+		&venuePostalCode,
+		&venueCity,
+		&modifiedAt,
+	)
+	if err != nil {
+		return icsEvent{}, err
+	}
+
+	e := icsEvent{
+		Uid:             fmt.Sprintf("%s@%s", dateUuid, h.Config.IcsDomain),
+		EventUuid:       eventUuid,
+		DateUuid:        dateUuid,
+		StartDate:       str(startDate),
+		StartTime:       str(startTime),
+		EndDate:         str(endDate),
+		EndTime:         str(endTime),
+		AllDay:          allDay,
+		Title:           str(title),
+		Description:     str(description),
+		Location:        icsLocation(venueName, venueStreet, venueHouseNumber, venuePostalCode, venueCity),
+		OrgName:         str(orgName),
+		OrgContactEmail: str(orgContactEmail),
+		ReleaseStatus:   str(releaseStatus),
+		StatusReason:    str(statusReason),
+		RescheduledTo:   str(rescheduledTo),
+		Availability:    availability,
+		ModifiedAt:      modifiedAt,
+	}
+	if sub := str(subtitle); sub != "" {
+		e.Description = sub + "\n\n" + e.Description
+	}
+	if h.Config.Frontend != "" {
+		e.Url = fmt.Sprintf("%s/event/%s/date/%s", h.Config.Frontend, eventUuid, dateUuid)
+		if e.RescheduledTo != "" {
+			e.RescheduledUrl = fmt.Sprintf("%s/event/%s/date/%s", h.Config.Frontend, eventUuid, e.RescheduledTo)
+		}
+	}
+	return e, nil
+}
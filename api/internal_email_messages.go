@@ -0,0 +1,156 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// InternalGetEmailMessages returns the recently queued mails, newest first.
+// Optional parameters: status (pending, sent, failed, bounced), to (the
+// recipient, case-insensitive), limit (default 50, max 500) and offset.
+func (h *ApiHandler) InternalGetEmailMessages(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "internal-get-email-messages")
+	ctx := gc.Request.Context()
+
+	status := gc.Query("status")
+	if status != "" && status != "pending" && status != "sent" && status != "failed" && status != "bounced" {
+		apiRequest.Error(http.StatusBadRequest, "status must be pending, sent, failed or bounced")
+		return
+	}
+
+	to := gc.Query("to")
+
+	limit := GetContextParamIntDefault(gc, "limit", 50)
+	offset := GetContextParamIntDefault(gc, "offset", 0)
+	if limit < 1 || limit > 500 || offset < 0 {
+		apiRequest.Error(http.StatusBadRequest, "limit must be between 1 and 500, offset must not be negative")
+		return
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			id, message_id, to_email, subject, template_context, lang, user_uuid::text, org_uuid::text,
+			status, attempt_count, CASE WHEN status = 'pending' THEN next_attempt_at END,
+			last_attempt_at, error, created_at, sent_at, bounced_at
+		FROM %s.email_message
+		WHERE ($1 = '' OR status = $1)
+			AND ($2 = '' OR LOWER(to_email) = LOWER($2))
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, status, to, limit, offset)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	emails := make([]model.EmailMessage, 0)
+	for rows.Next() {
+		var m model.EmailMessage
+		err := rows.Scan(
+			&m.Id, &m.MessageId, &m.ToEmail, &m.Subject, &m.TemplateContext, &m.Lang, &m.UserUuid, &m.OrgUuid,
+			&m.Status, &m.AttemptCount, &m.NextAttemptAt,
+			&m.LastAttemptAt, &m.Error, &m.CreatedAt, &m.SentAt, &m.BouncedAt)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		emails = append(emails, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("email_count", len(emails))
+	apiRequest.SetMeta("limit", limit)
+	apiRequest.SetMeta("offset", offset)
+	apiRequest.Success(http.StatusOK, emails, "email messages loaded successfully")
+}
+
+// InternalRetryEmailMessage queues a failed or bounced mail for sending
+// again. The attempt count starts over.
+func (h *ApiHandler) InternalRetryEmailMessage(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "internal-retry-email-message")
+	ctx := gc.Request.Context()
+
+	emailId, err := strconv.ParseInt(gc.Param("emailId"), 10, 64)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, "emailId must be a number")
+		return
+	}
+	apiRequest.SetMeta("email_id", emailId)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.email_message
+		SET status = 'pending', attempt_count = 0, next_attempt_at = NOW(), sent_at = NULL, bounced_at = NULL
+		WHERE id = $1`,
+		h.DbSchema)
+	res, err := h.DbPool.Exec(ctx, query, emailId)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if res.RowsAffected() == 0 {
+		apiRequest.NotFound("email message not found")
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "email message queued")
+}
+
+// InternalBounceEmailMessage marks a sent mail as bounced, e.g. called by
+// the bounce processing of the mail server. The mail is identified by id or
+// by its Message-ID, as found in the bounce report.
+func (h *ApiHandler) InternalBounceEmailMessage(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "internal-bounce-email-message")
+	ctx := gc.Request.Context()
+
+	var payload struct {
+		Id        int64  `json:"id"`
+		MessageId string `json:"message_id"`
+		Reason    string `json:"reason"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+	if payload.Id == 0 && payload.MessageId == "" {
+		apiRequest.Required("id or message_id is required")
+		return
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s.email_message
+		SET status = 'bounced', bounced_at = NOW(), error = NULLIF($3, '')
+		WHERE (id = $1 OR message_id = $2) AND status <> 'pending'
+		RETURNING id`,
+		h.DbSchema)
+	var id int64
+	err := h.DbPool.QueryRow(ctx, query, payload.Id, payload.MessageId, payload.Reason).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("email message not found")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	apiRequest.SetMeta("email_id", id)
+
+	apiRequest.SuccessNoData(http.StatusOK, "email message marked as bounced")
+}
@@ -24,12 +24,17 @@ func (h *ApiHandler) Sitemap(gc *gin.Context) {
 
 func generateUpcomingEventsSitemap(h *ApiHandler, ctx context.Context, baseUrl string) (string, error) {
 	rows, err := h.DbPool.Query(ctx, `
-		SELECT event_uuid, start_date::text, start_time::text,
-		modified_at::date::text AS lastmod
-		FROM uranus.event_date_projection
-		WHERE (start_date > CURRENT_DATE)
-		   OR (start_date = CURRENT_DATE AND start_time >= CURRENT_TIME)
-		ORDER BY start_date ASC, start_time ASC
+		SELECT edp.event_uuid, edp.start_date::text, edp.start_time::text,
+		edp.modified_at::date::text AS lastmod,
+		COALESCE(NULLIF(edp.release_status, 'inherited'), ep.release_status) = 'cancelled' AS cancelled
+		FROM uranus.event_date_projection edp
+		JOIN uranus.event_projection ep ON ep.event_uuid = edp.event_uuid
+		WHERE ep.release_status IN ('released', 'cancelled', 'deferred', 'rescheduled')
+		  AND ((edp.start_date > CURRENT_DATE)
+		   OR (edp.start_date = CURRENT_DATE AND edp.start_time >= CURRENT_TIME))
+		  -- Dates moved to another date are listed with their new date
+		  AND edp.rescheduled_to_uuid IS NULL
+		ORDER BY edp.start_date ASC, edp.start_time ASC
 		LIMIT 50000
 	`)
 	if err != nil {
@@ -49,9 +54,10 @@ func generateUpcomingEventsSitemap(h *ApiHandler, ctx context.Context, baseUrl s
 			startDate string
 			startTime string
 			lastMod   string
+			cancelled *bool
 		)
 
-		if err := rows.Scan(&uuid, &startDate, &startTime, &lastMod); err != nil {
+		if err := rows.Scan(&uuid, &startDate, &startTime, &lastMod, &cancelled); err != nil {
 			continue
 		}
 
@@ -71,7 +77,12 @@ func generateUpcomingEventsSitemap(h *ApiHandler, ctx context.Context, baseUrl s
 
 		// best practice for event pages
 		b.WriteString(`<changefreq>weekly</changefreq>`)
-		b.WriteString(`<priority>0.8</priority></url>`)
+		if cancelled != nil && *cancelled {
+			// Keep shared links of cancelled dates, but rank them lower
+			b.WriteString(`<priority>0.3</priority></url>`)
+		} else {
+			b.WriteString(`<priority>0.8</priority></url>`)
+		}
 	}
 
 	b.WriteString(`</urlset>`)
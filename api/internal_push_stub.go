@@ -0,0 +1,327 @@
+package api
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+)
+
+// pushStub is a local push endpoint for testing web push without a
+// browser. It holds the keys of its subscription in memory, so it can check
+// the VAPID signature and decrypt the messages like a push service and
+// browser would. Stubs are lost on restart, their endpoint then responds
+// with 410 Gone.
+type pushStub struct {
+	endpoint string
+	key      *ecdh.PrivateKey
+	auth     []byte
+	status   int
+	messages []pushStubMessage
+}
+
+type pushStubMessage struct {
+	ReceivedAt time.Time       `json:"received_at"`
+	TTL        string          `json:"ttl"`
+	Topic      string          `json:"topic,omitempty"`
+	Urgency    string          `json:"urgency,omitempty"`
+	Status     int             `json:"status"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+const pushStubMaxMessages = 100
+
+var pushStubs = struct {
+	sync.Mutex
+	stubs map[string]*pushStub
+}{stubs: map[string]*pushStub{}}
+
+// InternalCreatePushStub creates a local push endpoint and a push
+// subscription for it, with optional quiet hours (quiet_start, quiet_end,
+// time_zone). Follows are added with the public routes, using the returned
+// subscription uuid and auth. The worker only reaches the stub in dev mode,
+// outside dev mode loopback addresses are refused.
+func (h *ApiHandler) InternalCreatePushStub(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "internal-create-push-stub")
+	ctx := gc.Request.Context()
+
+	if h.WebPush == nil {
+		apiRequest.Error(http.StatusServiceUnavailable, "web push is not configured")
+		return
+	}
+
+	var payload struct {
+		QuietStart *string `json:"quiet_start"`
+		QuietEnd   *string `json:"quiet_end"`
+		TimeZone   *string `json:"time_zone"`
+	}
+	if gc.Request.ContentLength > 0 {
+		if err := gc.ShouldBindJSON(&payload); err != nil {
+			apiRequest.PayloadError()
+			return
+		}
+	}
+	timeZone, err := validatePushQuietHours(payload.QuietStart, payload.QuietEnd, payload.TimeZone)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stubId, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	var s pushSubscriptionInput
+	s.Endpoint = strings.TrimRight(h.Config.BaseApiUrl, "/") + "/api/internal/push/stub/" + stubId
+	s.Keys.P256dh = base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+	s.Keys.Auth = base64.RawURLEncoding.EncodeToString(auth)
+	s.QuietStart = payload.QuietStart
+	s.QuietEnd = payload.QuietEnd
+
+	var subscriptionUuid string
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var err error
+		subscriptionUuid, err = h.upsertPushSubscriptionTx(ctx, tx, s, timeZone, "", "push-stub")
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	pushStubs.Lock()
+	pushStubs.stubs[stubId] = &pushStub{
+		endpoint: s.Endpoint,
+		key:      key,
+		auth:     auth,
+		status:   http.StatusCreated,
+	}
+	pushStubs.Unlock()
+
+	apiRequest.Success(http.StatusCreated, gin.H{
+		"stub_id":           stubId,
+		"subscription_uuid": subscriptionUuid,
+		"endpoint":          s.Endpoint,
+		"keys": gin.H{
+			"p256dh": s.Keys.P256dh,
+			"auth":   s.Keys.Auth,
+		},
+	}, "push stub created")
+}
+
+// InternalReceivePushStub is the endpoint of a push stub. It checks the VAPID
+// authorization, decrypts the message and keeps it for InternalGetPushStub.
+// It responds with the status set by InternalUpdatePushStub (default 201),
+// unknown stubs respond with 410 Gone.
+func (h *ApiHandler) InternalReceivePushStub(gc *gin.Context) {
+	stubId := gc.Param("stubId")
+
+	pushStubs.Lock()
+	stub, ok := pushStubs.stubs[stubId]
+	pushStubs.Unlock()
+	if !ok {
+		gc.String(http.StatusGone, "push subscription has expired or was unsubscribed")
+		return
+	}
+
+	m := pushStubMessage{
+		ReceivedAt: time.Now(),
+		TTL:        gc.GetHeader("TTL"),
+		Topic:      gc.GetHeader("Topic"),
+		Urgency:    gc.GetHeader("Urgency"),
+	}
+
+	body, err := io.ReadAll(io.LimitReader(gc.Request.Body, 8192))
+	if err == nil {
+		err = h.verifyPushStubRequest(gc, stub)
+	}
+	if err == nil {
+		var payload []byte
+		payload, err = decryptWebPushPayload(stub.key, stub.auth, body)
+		if err == nil && !json.Valid(payload) {
+			payload, _ = json.Marshal(string(payload))
+		}
+		m.Payload = payload
+	}
+
+	pushStubs.Lock()
+	m.Status = stub.status
+	if err != nil {
+		m.Error = err.Error()
+		m.Status = http.StatusBadRequest
+	}
+	stub.messages = append(stub.messages, m)
+	if len(stub.messages) > pushStubMaxMessages {
+		stub.messages = stub.messages[len(stub.messages)-pushStubMaxMessages:]
+	}
+	pushStubs.Unlock()
+
+	if m.Error != "" {
+		gc.String(m.Status, m.Error)
+		return
+	}
+	gc.Status(m.Status)
+}
+
+// verifyPushStubRequest checks the request like a push service: content
+// encoding, TTL and a VAPID token for the origin of the endpoint, signed
+// with the key of the server.
+func (h *ApiHandler) verifyPushStubRequest(gc *gin.Context, stub *pushStub) error {
+	if gc.GetHeader("Content-Encoding") != "aes128gcm" {
+		return errors.New("content encoding must be aes128gcm")
+	}
+	if gc.GetHeader("TTL") == "" {
+		return errors.New("missing TTL header")
+	}
+
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(gc.GetHeader("Authorization"), "vapid "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+	if token == "" || key == "" {
+		return errors.New("missing vapid authorization")
+	}
+	if h.WebPush == nil || key != h.WebPush.PublicKey() {
+		return errors.New("unknown vapid key")
+	}
+	keyBytes, err := decodeWebPushKey(key)
+	if err != nil {
+		return err
+	}
+	publicKey, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), keyBytes)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := url.Parse(stub.endpoint)
+	if err != nil {
+		return err
+	}
+	origin := endpoint.Scheme + "://" + endpoint.Host
+	_, err = jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(origin), jwt.WithExpirationRequired())
+	return err
+}
+
+// InternalGetPushStub returns the messages received by a push stub, oldest
+// first.
+func (h *ApiHandler) InternalGetPushStub(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "internal-get-push-stub")
+	stubId := gc.Param("stubId")
+	apiRequest.SetMeta("stub_id", stubId)
+
+	pushStubs.Lock()
+	defer pushStubs.Unlock()
+	stub, ok := pushStubs.stubs[stubId]
+	if !ok {
+		apiRequest.NotFound("push stub not found")
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"endpoint": stub.endpoint,
+		"status":   stub.status,
+		"messages": append([]pushStubMessage{}, stub.messages...),
+	}, "")
+}
+
+// InternalUpdatePushStub sets the status a push stub responds with, e.g.
+// 410 to test deleting gone subscriptions or 429 and 500 to test retries.
+func (h *ApiHandler) InternalUpdatePushStub(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "internal-update-push-stub")
+	stubId := gc.Param("stubId")
+	apiRequest.SetMeta("stub_id", stubId)
+
+	var payload struct {
+		Status int `json:"status" binding:"required"`
+	}
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.PayloadError()
+		return
+	}
+	if payload.Status < 200 || payload.Status > 599 {
+		apiRequest.Error(http.StatusBadRequest, "status must be an HTTP status code")
+		return
+	}
+
+	pushStubs.Lock()
+	defer pushStubs.Unlock()
+	stub, ok := pushStubs.stubs[stubId]
+	if !ok {
+		apiRequest.NotFound("push stub not found")
+		return
+	}
+	stub.status = payload.Status
+
+	apiRequest.SuccessNoData(http.StatusOK, "push stub updated")
+}
+
+// InternalSendPushMessages sends the due push messages at once instead of
+// waiting for the push worker.
+func (h *ApiHandler) InternalSendPushMessages(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "internal-send-push-messages")
+
+	if h.WebPush == nil {
+		apiRequest.Error(http.StatusServiceUnavailable, "web push is not configured")
+		return
+	}
+
+	total := 0
+	for {
+		n, err := h.SendPushMessages(gc.Request.Context())
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.DatabaseError()
+			return
+		}
+		total += n
+		if n < pushBatchSize {
+			break
+		}
+	}
+
+	apiRequest.SetMeta("claimed_count", total)
+	apiRequest.SuccessNoData(http.StatusOK, "push messages sent")
+}
@@ -89,9 +89,11 @@ func (h *ApiHandler) InternalTest(gc *gin.Context) {
 
 	sm := BuildShareMeta(event, event.Date, imageURL, eventUrl)
 	shareData := struct {
-		Share ShareMeta
+		Share  ShareMeta
+		JsonLD map[string]any
 	}{
-		Share: sm,
+		Share:  sm,
+		JsonLD: h.BuildEventJsonLD(event, eventUrl),
 	}
 
 	if err := h.EventTemplate.Execute(gc.Writer, shareData); err != nil {
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+	"github.com/sndcds/uranus/model"
+)
+
+// pushAuthHeader carries the auth secret of a push subscription. Only the
+// browser holding the subscription knows it, so it authorizes changes of
+// anonymous subscriptions.
+const pushAuthHeader = "X-Push-Auth"
+
+// pushSubscriptionInput is a subscription as returned by
+// PushSubscription.toJSON() in the browser, with optional quiet hours.
+type pushSubscriptionInput struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys" binding:"required"`
+	QuietStart *string `json:"quiet_start"`
+	QuietEnd   *string `json:"quiet_end"`
+	TimeZone   *string `json:"time_zone"`
+}
+
+func validatePushSubscriptionKeys(p256dh string, auth string) error {
+	key, err := decodeWebPushKey(p256dh)
+	if err != nil {
+		return errors.New("keys.p256dh must be base64url encoded")
+	}
+	if _, err := ecdh.P256().NewPublicKey(key); err != nil {
+		return errors.New("keys.p256dh is not a P-256 public key")
+	}
+	secret, err := decodeWebPushKey(auth)
+	if err != nil || len(secret) != 16 {
+		return errors.New("keys.auth must be 16 bytes base64url encoded")
+	}
+	return nil
+}
+
+// validatePushQuietHours checks the optional quiet hours ("15:04", both or
+// none) and the time zone, which defaults to UTC.
+func validatePushQuietHours(quietStart *string, quietEnd *string, timeZone *string) (string, error) {
+	tz := "UTC"
+	if timeZone != nil && *timeZone != "" {
+		if _, err := time.LoadLocation(*timeZone); err != nil {
+			return "", fmt.Errorf("unknown time_zone %q", *timeZone)
+		}
+		tz = *timeZone
+	}
+	if (quietStart == nil) != (quietEnd == nil) {
+		return "", errors.New("quiet_start and quiet_end must be given together")
+	}
+	for _, t := range []*string{quietStart, quietEnd} {
+		if t == nil {
+			continue
+		}
+		if _, err := time.Parse(pushQuietTimeLayout, *t); err != nil {
+			return "", errors.New("quiet_start and quiet_end must be formatted as HH:MM")
+		}
+	}
+	return tz, nil
+}
+
+// upsertPushSubscriptionTx stores a subscription by its endpoint and returns
+// its uuid. A browser subscribing again keeps its follows. The user is only
+// set, never removed.
+func (h *ApiHandler) upsertPushSubscriptionTx(ctx context.Context, tx pgx.Tx, s pushSubscriptionInput, timeZone string, userUuid string, userAgent string) (string, error) {
+	newUuid, err := grains_uuid.Uuidv7String()
+	if err != nil {
+		return "", err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s.push_subscription
+			(uuid, endpoint, p256dh, auth, user_uuid, quiet_start, quiet_end, time_zone, user_agent)
+		VALUES ($1::uuid, $2, $3, $4, NULLIF($5, '')::uuid, $6::time, $7::time, $8, NULLIF($9, ''))
+		ON CONFLICT (endpoint) DO UPDATE SET
+			p256dh = EXCLUDED.p256dh,
+			auth = EXCLUDED.auth,
+			user_uuid = COALESCE(EXCLUDED.user_uuid, %[1]s.push_subscription.user_uuid),
+			quiet_start = EXCLUDED.quiet_start,
+			quiet_end = EXCLUDED.quiet_end,
+			time_zone = EXCLUDED.time_zone,
+			user_agent = EXCLUDED.user_agent,
+			modified_at = NOW()
+		RETURNING uuid::text`,
+		h.DbSchema)
+	var subscriptionUuid string
+	err = tx.QueryRow(ctx, query,
+		newUuid, s.Endpoint, s.Keys.P256dh, s.Keys.Auth, userUuid,
+		s.QuietStart, s.QuietEnd, timeZone, userAgent).Scan(&subscriptionUuid)
+	return subscriptionUuid, err
+}
+
+// pushSubscriptionAuthorized reports whether the subscription exists and
+// auth is its auth secret.
+func (h *ApiHandler) pushSubscriptionAuthorized(ctx context.Context, q rowQuerier, subscriptionUuid string, auth string) (bool, error) {
+	if auth == "" {
+		return false, nil
+	}
+	query := fmt.Sprintf(`SELECT auth FROM %s.push_subscription WHERE uuid = $1::uuid`, h.DbSchema)
+	var stored string
+	err := q.QueryRow(ctx, query, subscriptionUuid).Scan(&stored)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(auth)) == 1, nil
+}
+
+// GetPushVapidKey returns the public VAPID key browsers pass as
+// applicationServerKey to pushManager.subscribe().
+func (h *ApiHandler) GetPushVapidKey(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-push-vapid-key")
+
+	if h.WebPush == nil {
+		apiRequest.Error(http.StatusServiceUnavailable, "web push is not configured")
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{"public_key": h.WebPush.PublicKey()}, "")
+}
+
+// UpsertPushSubscription registers the push subscription of a browser, as
+// returned by PushSubscription.toJSON(), with optional quiet hours
+// (quiet_start, quiet_end as HH:MM in time_zone). Subscribing again with the
+// same endpoint updates keys and quiet hours and keeps the follows. Visitors
+// don't need an account, the user of a valid access token of an active
+// session is linked to the subscription.
+// Returns the uuid of the subscription, further requests for it are
+// authorized by its auth secret in the X-Push-Auth header.
+//
+// PermissionNote: No user authentication required.
+func (h *ApiHandler) UpsertPushSubscription(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "upsert-push-subscription")
+	ctx := gc.Request.Context()
+
+	if h.WebPush == nil {
+		apiRequest.Error(http.StatusServiceUnavailable, "web push is not configured")
+		return
+	}
+
+	payload, ok := grains_api.DecodeJSONBody[pushSubscriptionInput](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+	if err := validateWebhookUrl(payload.Endpoint, h.Config.DevMode); err != nil {
+		apiRequest.Error(http.StatusBadRequest, strings.Replace(err.Error(), "url", "endpoint", 1))
+		return
+	}
+	if err := validatePushSubscriptionKeys(payload.Keys.P256dh, payload.Keys.Auth); err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+	timeZone, err := validatePushQuietHours(payload.QuietStart, payload.QuietEnd, payload.TimeZone)
+	if err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userAgent := gc.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	var subscriptionUuid string
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var err error
+		subscriptionUuid, err = h.upsertPushSubscriptionTx(ctx, tx, payload, timeZone, app.OptionalUserUuid(gc), userAgent)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("push_subscription_uuid", subscriptionUuid)
+	apiRequest.Success(http.StatusOK, gin.H{"uuid": subscriptionUuid}, "push subscription saved")
+}
+
+// GetPushSubscription returns the settings and follows of a push
+// subscription, with the names of the followed targets in the language of
+// the lang parameter (default "en").
+//
+// PermissionNote: No user authentication, the browser authenticates with
+// the auth secret of the subscription (header X-Push-Auth).
+func (h *ApiHandler) GetPushSubscription(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-push-subscription")
+	ctx := gc.Request.Context()
+
+	subscriptionUuid := gc.Param("subscriptionUuid")
+	apiRequest.SetMeta("push_subscription_uuid", subscriptionUuid)
+	lang := gc.DefaultQuery("lang", "en")
+
+	authorized, err := h.pushSubscriptionAuthorized(ctx, h.DbPool, subscriptionUuid, gc.GetHeader(pushAuthHeader))
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if !authorized {
+		apiRequest.NotFound("push subscription not found")
+		return
+	}
+
+	var s model.PushSubscription
+	query := fmt.Sprintf(`
+		SELECT uuid::text, endpoint, TO_CHAR(quiet_start, 'HH24:MI'), TO_CHAR(quiet_end, 'HH24:MI'), time_zone,
+			created_at, modified_at, last_success_at
+		FROM %s.push_subscription
+		WHERE uuid = $1::uuid`,
+		h.DbSchema)
+	err = h.DbPool.QueryRow(ctx, query, subscriptionUuid).Scan(
+		&s.Uuid, &s.Endpoint, &s.QuietStart, &s.QuietEnd, &s.TimeZone,
+		&s.CreatedAt, &s.ModifiedAt, &s.LastSuccessAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("push subscription not found")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+
+	query = fmt.Sprintf(`
+		SELECT
+			f.target_type,
+			f.target_id,
+			CASE f.target_type
+				WHEN 'org' THEN (SELECT name FROM %[1]s.organization WHERE uuid::text = f.target_id)
+				WHEN 'venue' THEN (SELECT name FROM %[1]s.venue WHERE uuid::text = f.target_id)
+				WHEN 'portal' THEN (SELECT name FROM %[1]s.portal2 WHERE uuid::text = f.target_id)
+				WHEN 'event_type' THEN (
+					SELECT name FROM %[1]s.event_type
+					WHERE type_id::text = f.target_id AND iso_639_1 = $2
+				)
+			END,
+			f.created_at
+		FROM %[1]s.push_follow f
+		WHERE f.subscription_uuid = $1::uuid
+		ORDER BY f.created_at`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, subscriptionUuid, lang)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	s.Follows = make([]model.PushFollow, 0)
+	for rows.Next() {
+		var f model.PushFollow
+		if err := rows.Scan(&f.TargetType, &f.TargetId, &f.TargetName, &f.CreatedAt); err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		s.Follows = append(s.Follows, f)
+	}
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.Success(http.StatusOK, s, "")
+}
+
+// DeletePushSubscription deletes a push subscription with its follows, e.g.
+// after the browser unsubscribed.
+//
+// PermissionNote: No user authentication, the browser authenticates with
+// the auth secret of the subscription (header X-Push-Auth).
+func (h *ApiHandler) DeletePushSubscription(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "delete-push-subscription")
+	ctx := gc.Request.Context()
+
+	subscriptionUuid := gc.Param("subscriptionUuid")
+	apiRequest.SetMeta("push_subscription_uuid", subscriptionUuid)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		authorized, err := h.pushSubscriptionAuthorized(ctx, tx, subscriptionUuid, gc.GetHeader(pushAuthHeader))
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !authorized {
+			return ApiErrNotFound("push subscription not found")
+		}
+
+		query := fmt.Sprintf(`DELETE FROM %s.push_subscription WHERE uuid = $1::uuid`, h.DbSchema)
+		if _, err := tx.Exec(ctx, query, subscriptionUuid); err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "push subscription deleted")
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+)
+
+// AddPushFollow lets a push subscription follow an organization, venue,
+// event type or portal (target_type "org", "venue", "event_type" or
+// "portal"; target_id the uuid, for event types the type id). The browser
+// gets a push when a matching event date is released or cancelled.
+//
+// PermissionNote: No user authentication, the browser authenticates with
+// the auth secret of the subscription (header X-Push-Auth).
+func (h *ApiHandler) AddPushFollow(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "add-push-follow")
+	ctx := gc.Request.Context()
+
+	subscriptionUuid := gc.Param("subscriptionUuid")
+	apiRequest.SetMeta("push_subscription_uuid", subscriptionUuid)
+
+	type Payload struct {
+		TargetType string `json:"target_type" binding:"required"`
+		TargetId   string `json:"target_id" binding:"required"`
+	}
+	payload, ok := grains_api.DecodeJSONBody[Payload](gc, apiRequest)
+	if !ok {
+		apiRequest.PayloadError()
+		return
+	}
+	if !slices.Contains(pushFollowTargetTypes, payload.TargetType) {
+		apiRequest.Error(http.StatusBadRequest, "target_type must be org, venue, event_type or portal")
+		return
+	}
+
+	var targetQuery string
+	switch payload.TargetType {
+	case "event_type":
+		typeId, err := strconv.Atoi(payload.TargetId)
+		if err != nil {
+			apiRequest.Error(http.StatusBadRequest, "target_id must be an event type id")
+			return
+		}
+		payload.TargetId = strconv.Itoa(typeId)
+		targetQuery = `SELECT EXISTS (SELECT 1 FROM %s.event_type WHERE type_id = $1::int)`
+	case "org":
+		targetQuery = `SELECT EXISTS (SELECT 1 FROM %s.organization WHERE uuid::text = $1)`
+	case "venue":
+		targetQuery = `SELECT EXISTS (SELECT 1 FROM %s.venue WHERE uuid::text = $1)`
+	case "portal":
+		targetQuery = `SELECT EXISTS (SELECT 1 FROM %s.portal2 WHERE uuid::text = $1)`
+	}
+	apiRequest.SetMeta("target_type", payload.TargetType)
+	apiRequest.SetMeta("target_id", payload.TargetId)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		authorized, err := h.pushSubscriptionAuthorized(ctx, tx, subscriptionUuid, gc.GetHeader(pushAuthHeader))
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !authorized {
+			return ApiErrNotFound("push subscription not found")
+		}
+
+		var exists bool
+		if err := tx.QueryRow(ctx, fmt.Sprintf(targetQuery, h.DbSchema), payload.TargetId).Scan(&exists); err != nil {
+			return TxInternalError(err)
+		}
+		if !exists {
+			return ApiErrNotFound("target not found")
+		}
+
+		var followCount int
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM %s.push_follow WHERE subscription_uuid = $1::uuid`, h.DbSchema)
+		if err := tx.QueryRow(ctx, query, subscriptionUuid).Scan(&followCount); err != nil {
+			return TxInternalError(err)
+		}
+		if followCount >= pushMaxFollows {
+			return NewApiTxError(http.StatusConflict, "a push subscription can follow at most %d targets", pushMaxFollows)
+		}
+
+		query = fmt.Sprintf(`
+			INSERT INTO %s.push_follow (subscription_uuid, target_type, target_id)
+			VALUES ($1::uuid, $2, $3)
+			ON CONFLICT DO NOTHING`,
+			h.DbSchema)
+		if _, err := tx.Exec(ctx, query, subscriptionUuid, payload.TargetType, payload.TargetId); err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "following")
+}
+
+// DeletePushFollow stops following a target.
+//
+// PermissionNote: No user authentication, the browser authenticates with
+// the auth secret of the subscription (header X-Push-Auth).
+func (h *ApiHandler) DeletePushFollow(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "delete-push-follow")
+	ctx := gc.Request.Context()
+
+	subscriptionUuid := gc.Param("subscriptionUuid")
+	targetType := gc.Param("targetType")
+	targetId := gc.Param("targetId")
+	apiRequest.SetMeta("push_subscription_uuid", subscriptionUuid)
+	apiRequest.SetMeta("target_type", targetType)
+	apiRequest.SetMeta("target_id", targetId)
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		authorized, err := h.pushSubscriptionAuthorized(ctx, tx, subscriptionUuid, gc.GetHeader(pushAuthHeader))
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !authorized {
+			return ApiErrNotFound("push subscription not found")
+		}
+
+		query := fmt.Sprintf(`
+			DELETE FROM %s.push_follow
+			WHERE subscription_uuid = $1::uuid AND target_type = $2 AND target_id = $3`,
+			h.DbSchema)
+		tag, err := tx.Exec(ctx, query, subscriptionUuid, targetType, targetId)
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ApiErrNotFound("follow not found")
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "unfollowed")
+}
@@ -46,6 +46,37 @@ func RefreshEventProjections(
 		return fmt.Errorf("unsupported source table: %s", sourceTable)
 	}
 
+	// Changes are only compared for webhooks if there are subscriptions
+	withWebhooks, err := hasActiveWebhooksTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	var webhookEvents []webhookEvent
+
+	// Event dates released or cancelled by the refresh are pushed to the
+	// followers, changes of saved event dates are noted in the agendas of
+	// visitors. Their states are loaded before the event projections change.
+	withPush, err := hasPushFollowsTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	withAgenda, err := hasAgendaItemsTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	var watchedEventDateUuids []string
+	var watchedBefore map[string]webhookState
+	if (withPush || withAgenda) && q.EventDateUuids != "" {
+		watchedEventDateUuids, err = fetchUuids(ctx, tx, q.EventDateUuids, uuids)
+		if err != nil {
+			return err
+		}
+		watchedBefore, err = loadWebhookEventDateStatesTx(ctx, tx, watchedEventDateUuids)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Refresh events
 
 	if q.EventUuids != "" {
@@ -55,6 +86,14 @@ func RefreshEventProjections(
 			return err
 		}
 		if len(eventUuids) > 0 {
+			var before map[string]webhookState
+			if withWebhooks {
+				before, err = loadWebhookEventStatesTx(ctx, tx, eventUuids)
+				if err != nil {
+					return err
+				}
+			}
+
 			err := upsertEventProjection(ctx, tx, eventUuids)
 			if err != nil {
 				debugf("Error updating event projection: %v", err)
@@ -66,6 +105,14 @@ func RefreshEventProjections(
 				debugf("Error updating event search vectors: %v", err)
 				return err
 			}
+
+			if withWebhooks {
+				after, err := loadWebhookEventStatesTx(ctx, tx, eventUuids)
+				if err != nil {
+					return err
+				}
+				webhookEvents = append(webhookEvents, eventWebhookEvents(sourceTable, before, after)...)
+			}
 		}
 	}
 
@@ -78,6 +125,14 @@ func RefreshEventProjections(
 			return err
 		}
 		if len(eventDateUuids) > 0 {
+			var before map[string]webhookState
+			if withWebhooks {
+				before, err = loadWebhookEventDateStatesTx(ctx, tx, eventDateUuids)
+				if err != nil {
+					return err
+				}
+			}
+
 			err := upsertEventDateProjection(ctx, tx, eventDateUuids)
 			if err != nil {
 				debugf("Error updating event date projection: %v", err)
@@ -89,6 +144,55 @@ func RefreshEventProjections(
 				debugf("Error updating event date search vectors: %v", err)
 				return err
 			}
+
+			if withWebhooks {
+				after, err := loadWebhookEventDateStatesTx(ctx, tx, eventDateUuids)
+				if err != nil {
+					return err
+				}
+				webhookEvents = append(webhookEvents, eventDateWebhookEvents(sourceTable, before, after)...)
+			}
+		}
+	}
+
+	// Queue webhook deliveries in the same transaction (outbox)
+
+	if withWebhooks {
+		entityEvents, err := entityWebhookEvents(ctx, tx, sourceTable, uuids)
+		if err != nil {
+			return err
+		}
+		webhookEvents = append(webhookEvents, entityEvents...)
+
+		err = enqueueWebhookEventsTx(ctx, tx, webhookEvents)
+		if err != nil {
+			debugf("Error queueing webhook deliveries: %v", err)
+			return err
+		}
+	}
+
+	// Queue push messages to the followers of the changed event dates and
+	// mark the changes in the agendas
+
+	if len(watchedEventDateUuids) > 0 {
+		after, err := loadWebhookEventDateStatesTx(ctx, tx, watchedEventDateUuids)
+		if err != nil {
+			return err
+		}
+		if withPush {
+			eventDateUuids, types := eventDatePushChanges(watchedBefore, after)
+			err = enqueuePushMessagesTx(ctx, tx, eventDateUuids, types)
+			if err != nil {
+				debugf("Error queueing push messages: %v", err)
+				return err
+			}
+		}
+		if withAgenda {
+			err = markAgendaChangesTx(ctx, tx, eventDateAgendaChanges(watchedBefore, after))
+			if err != nil {
+				debugf("Error marking agenda changes: %v", err)
+				return err
+			}
 		}
 	}
 
@@ -370,6 +474,7 @@ INSERT INTO %[1]s.event_date_projection (
     end_date, end_time,
     entry_time, duration, all_day, release_status,
     ticket_link, availability_status_id,
+    status_reason, rescheduled_to_uuid,
     accessibility_info, custom, created_at, modified_at
 )
 SELECT DISTINCT ON (ed.uuid)
@@ -405,6 +510,8 @@ SELECT DISTINCT ON (ed.uuid)
     ed.release_status,
     ed.ticket_link,
     ed.availability_status_id,
+    ed.status_reason,
+    ed.rescheduled_to_uuid,
     ed.accessibility_info,
     ed.custom,
     NOW(),
@@ -444,6 +551,8 @@ ON CONFLICT (event_date_uuid) DO UPDATE SET
     release_status = EXCLUDED.release_status,
     ticket_link = EXCLUDED.ticket_link,
     availability_status_id = EXCLUDED.availability_status_id,
+    status_reason = EXCLUDED.status_reason,
+    rescheduled_to_uuid = EXCLUDED.rescheduled_to_uuid,
     accessibility_info = EXCLUDED.accessibility_info,
     custom = EXCLUDED.custom,
     modified_at = NOW()
@@ -0,0 +1,535 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/grains/grains_uuid"
+	"github.com/sndcds/uranus/app"
+)
+
+// Status values of an event in the sync report
+const (
+	eventSyncCreated     = "created"
+	eventSyncUpdated     = "updated"
+	eventSyncUnchanged   = "unchanged"
+	eventSyncUnpublished = "unpublished"
+	eventSyncError       = "error"
+)
+
+type eventSyncPayload struct {
+	OrgKey           string         `json:"org_key"`
+	FullSync         bool           `json:"full_sync"`
+	UnpublishMissing bool           `json:"unpublish_missing"`
+	Events           []eventPayload `json:"events"`
+}
+
+type eventSyncResult struct {
+	ExternalId string   `json:"external_id"`
+	EventUuid  *string  `json:"event_uuid,omitempty"`
+	Status     string   `json:"status"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// PermissionNote: No user authentication, the organization authenticates
+// with its sync key (header X-Org-Key or org_key in the payload) or with an
+// API key of the organization (header X-Api-Key).
+// PermissionChecks: Org key or API key with UserPermAddEvent and
+// UserPermEditEvent in handler, UserPermReleaseEvent for released or
+// scheduled events and unpublish_missing. Venues must belong to the
+// organization.
+
+// SyncOrgEvents creates or updates the events of an organization keyed by
+// external_id. Sending the same payload again changes nothing. Dates before
+// today are ignored and kept as they are, future dates missing from the
+// payload are cancelled. With full_sync and unpublish_missing, released
+// events missing from the payload are set to draft.
+func (h *ApiHandler) SyncOrgEvents(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "sync-org-events")
+	ctx := gc.Request.Context()
+
+	orgUuid := gc.Param("orgUuid")
+	if orgUuid == "" {
+		apiRequest.Required("orgUuid is required")
+		return
+	}
+	apiRequest.SetMeta("org_uuid", orgUuid)
+
+	var payload eventSyncPayload
+	if err := gc.ShouldBindJSON(&payload); err != nil {
+		apiRequest.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(payload.Events) == 0 {
+		apiRequest.Required("events are required")
+		return
+	}
+
+	orgKey := gc.GetHeader("X-Org-Key")
+	if orgKey == "" {
+		orgKey = payload.OrgKey
+	}
+	apiKey := apiKeyFromRequest(gc)
+
+	results := make([]eventSyncResult, len(payload.Events))
+	skipped := make([]bool, len(payload.Events))
+	changedUuids := []string{}
+	today := time.Now().Format("2006-01-02")
+
+	// Validation
+	seen := map[string]bool{}
+	for i := range payload.Events {
+		p := &payload.Events[i]
+		result := &results[i]
+		result.Status = eventSyncError
+
+		if p.ExternalId == nil || strings.TrimSpace(*p.ExternalId) == "" {
+			result.Errors = append(result.Errors, "external_id is required")
+			continue
+		}
+		result.ExternalId = *p.ExternalId
+		if seen[*p.ExternalId] {
+			result.Errors = append(result.Errors, "duplicate external_id")
+			continue
+		}
+		seen[*p.ExternalId] = true
+
+		if p.Recurrence != nil {
+			result.Errors = append(result.Errors, "recurrence is not supported, send the dates instead")
+			continue
+		}
+
+		// The ticketing systems send their history, past dates are left alone
+		dates := p.Dates[:0]
+		for _, d := range p.Dates {
+			if d.StartDate >= today {
+				dates = append(dates, d)
+			}
+		}
+		if len(dates) == 0 && len(p.Dates) > 0 {
+			// Nothing left to sync for events which are over
+			result.Status = eventSyncUnchanged
+			skipped[i] = true
+			continue
+		}
+		p.Dates = dates
+		p.OrgUuid = &orgUuid
+		p.OrgKey = nil
+
+		if err := p.Validate(); err != nil {
+			result.Errors = append(result.Errors, strings.Split(err.Error(), "; ")...)
+		}
+	}
+
+	// Publishing and unpublishing events is up to keys which may release them
+	needsRelease := payload.FullSync && payload.UnpublishMissing
+	for i, p := range payload.Events {
+		if len(results[i].Errors) == 0 && !skipped[i] && p.ReleaseStatus != nil &&
+			(*p.ReleaseStatus == "released" || *p.ReleaseStatus == "scheduled") {
+			needsRelease = true
+		}
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		var ok bool
+		var err error
+		if apiKey != "" {
+			var permissions app.Permissions
+			permissions, ok, err = h.orgApiKeyPermissionsTx(ctx, tx, orgUuid, apiKey)
+			ok = ok && permissions.HasAll(app.UserPermAddEvent|app.UserPermEditEvent)
+			if ok && needsRelease && !permissions.Has(app.UserPermReleaseEvent) {
+				return ApiErrForbidden("releasing or unpublishing events requires UserPermReleaseEvent")
+			}
+		} else {
+			ok, err = h.verifyOrgKeyTx(ctx, tx, orgUuid, orgKey)
+		}
+		if err != nil {
+			return TxInternalError(err)
+		}
+		if !ok {
+			return &ApiTxError{
+				Code:    http.StatusUnauthorized,
+				Err:     errors.New("invalid org key"),
+				Message: "invalid org key",
+			}
+		}
+
+		for i := range payload.Events {
+			result := &results[i]
+			if len(result.Errors) > 0 || skipped[i] {
+				continue
+			}
+
+			// Each event runs in a savepoint, a failing event does not
+			// abort the whole sync
+			itemTx, err := tx.Begin(ctx)
+			if err != nil {
+				return TxInternalError(err)
+			}
+
+			eventUuid, status, syncErr := h.syncOrgEventTx(gc, itemTx, orgUuid, &payload.Events[i])
+			if syncErr != nil {
+				_ = itemTx.Rollback(ctx)
+				debugf("sync %s: %v", result.ExternalId, syncErr)
+				result.Errors = append(result.Errors, syncErr.Error())
+				continue
+			}
+			if err := itemTx.Commit(ctx); err != nil {
+				return TxInternalError(err)
+			}
+
+			result.EventUuid = &eventUuid
+			result.Status = status
+			if status != eventSyncUnchanged {
+				changedUuids = append(changedUuids, eventUuid)
+			}
+		}
+
+		if payload.FullSync && payload.UnpublishMissing {
+			externalIds := make([]string, 0, len(payload.Events))
+			for _, p := range payload.Events {
+				if p.ExternalId != nil {
+					externalIds = append(externalIds, *p.ExternalId)
+				}
+			}
+
+			query := fmt.Sprintf(`
+				UPDATE %[1]s.event e
+				SET release_status = 'draft', sync_hash = NULL
+				WHERE e.org_uuid = $1::uuid
+					AND e.external_id IS NOT NULL
+					AND NOT (e.external_id = ANY($2::text[]))
+					AND e.release_status = 'released'
+					AND EXISTS (
+						SELECT 1 FROM %[1]s.event_date ed
+						WHERE ed.event_uuid = e.uuid AND ed.start_date >= CURRENT_DATE
+					)
+				RETURNING e.uuid, e.external_id`,
+				h.DbSchema)
+			rows, err := tx.Query(ctx, query, orgUuid, externalIds)
+			if err != nil {
+				return TxInternalError(err)
+			}
+			for rows.Next() {
+				var eventUuid, externalId string
+				if err := rows.Scan(&eventUuid, &externalId); err != nil {
+					rows.Close()
+					return TxInternalError(err)
+				}
+				results = append(results, eventSyncResult{
+					ExternalId: externalId,
+					EventUuid:  &eventUuid,
+					Status:     eventSyncUnpublished,
+				})
+				changedUuids = append(changedUuids, eventUuid)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return TxInternalError(err)
+			}
+		}
+
+		err = RefreshEventProjections(ctx, tx, "event", changedUuids)
+		if err != nil {
+			return TxInternalError(err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	summary := map[string]int{
+		eventSyncCreated:     0,
+		eventSyncUpdated:     0,
+		eventSyncUnchanged:   0,
+		eventSyncUnpublished: 0,
+		eventSyncError:       0,
+	}
+	for _, r := range results {
+		summary[r.Status]++
+	}
+
+	apiRequest.Success(http.StatusOK, gin.H{
+		"summary": summary,
+		"events":  results,
+	})
+}
+
+// syncOrgEventTx creates or updates a single event and its future dates.
+// Returns the event uuid and the sync status.
+func (h *ApiHandler) syncOrgEventTx(
+	gc *gin.Context,
+	tx pgx.Tx,
+	orgUuid string,
+	p *eventPayload,
+) (string, string, error) {
+	ctx := gc.Request.Context()
+
+	// Venues must belong to the organization
+	venueUuids := []string{}
+	if p.VenueUuid != nil {
+		venueUuids = append(venueUuids, *p.VenueUuid)
+	}
+	for _, d := range p.Dates {
+		if d.VenueUuid != nil {
+			venueUuids = append(venueUuids, *d.VenueUuid)
+		}
+	}
+	for _, venueUuid := range venueUuids {
+		venueOrgUuid, err := h.GetOrgUuidByVenueUuidTx(gc, tx, venueUuid)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return "", "", fmt.Errorf("venue %s not found", venueUuid)
+			}
+			return "", "", err
+		}
+		if venueOrgUuid != orgUuid {
+			return "", "", fmt.Errorf("venue %s does not belong to the organization", venueUuid)
+		}
+	}
+	checkSpace := func(spaceUuid, venueUuid *string) error {
+		if spaceUuid == nil {
+			return nil
+		}
+		if venueUuid == nil {
+			return errors.New("space requires a venue")
+		}
+		ok, err := h.IsSpaceInVenueTx(gc, tx, *spaceUuid, *venueUuid)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("invalid venue/space combination")
+		}
+		return nil
+	}
+	if err := checkSpace(p.SpaceUuid, p.VenueUuid); err != nil {
+		return "", "", err
+	}
+	for _, d := range p.Dates {
+		venueUuid := d.VenueUuid
+		if venueUuid == nil {
+			venueUuid = p.VenueUuid
+		}
+		if err := checkSpace(d.SpaceUuid, venueUuid); err != nil {
+			return "", "", err
+		}
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(data)
+	syncHash := hex.EncodeToString(sum[:])
+
+	var eventUuid string
+	var existingHash *string
+	query := fmt.Sprintf(
+		`SELECT uuid, sync_hash FROM %s.event WHERE org_uuid = $1::uuid AND external_id = $2`,
+		h.DbSchema)
+	err = tx.QueryRow(ctx, query, orgUuid, *p.ExternalId).Scan(&eventUuid, &existingHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", "", err
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		eventUuid, txErr := h.insertEventPayloadTx(gc, tx, nil, p)
+		if txErr != nil {
+			return "", "", txErr
+		}
+		query := fmt.Sprintf(`UPDATE %s.event SET sync_hash = $2 WHERE uuid = $1::uuid`, h.DbSchema)
+		if _, err := tx.Exec(ctx, query, eventUuid, syncHash); err != nil {
+			return "", "", err
+		}
+		if err := h.recordEventRevisionTx(ctx, tx, eventUuid, "", "sync", nil); err != nil {
+			return "", "", err
+		}
+		return eventUuid, eventSyncCreated, nil
+	}
+
+	if existingHash != nil && *existingHash == syncHash {
+		return eventUuid, eventSyncUnchanged, nil
+	}
+
+	before, err := h.loadEventSnapshotTx(ctx, tx, eventUuid)
+	if err != nil {
+		return "", "", err
+	}
+
+	var priceType *string
+	if p.PriceType != "" {
+		v := string(p.PriceType)
+		priceType = &v
+	}
+
+	_, err = tx.Exec(ctx, app.UranusInstance.SqlSyncUpdateEvent,
+		eventUuid,
+		p.VenueUuid,
+		p.SpaceUuid,
+		p.ReleaseStatus,
+		p.ReleaseDate,
+		p.Categories,
+		p.Title,
+		p.Subtitle,
+		p.Description,
+		p.Summary,
+		p.Languages,
+		p.Tags,
+		p.SourceLink,
+		p.OnlineLink,
+		p.OccasionTypeId,
+		p.ParticipationInfo,
+		p.MinAge,
+		p.MaxAge,
+		p.MeetingPoint,
+		p.MaxAttendees,
+		priceType,
+		p.Currency,
+		p.TicketLink,
+		p.TicketFlags,
+		p.Custom,
+		p.Style,
+		syncHash,
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to update event: %v", err)
+	}
+
+	// Type-genre pairs are replaced
+	query = fmt.Sprintf(`DELETE FROM %s.event_type_link WHERE event_uuid = $1::uuid`, h.DbSchema)
+	if _, err := tx.Exec(ctx, query, eventUuid); err != nil {
+		return "", "", err
+	}
+	query = fmt.Sprintf(
+		`INSERT INTO %s.event_type_link (event_uuid, type_id, genre_id) VALUES ($1::uuid, $2, $3)`,
+		h.DbSchema)
+	for _, pair := range p.TypeGenrePairs {
+		if _, err := tx.Exec(ctx, query, eventUuid, pair.TypeId, pair.GenreId); err != nil {
+			return "", "", fmt.Errorf("failed to insert type-genre pair: %v", err)
+		}
+	}
+
+	// Future dates are matched by start date and time, so their uuids stay
+	// stable. Dates missing from the payload are cancelled and keep their
+	// uuids, so tickets, registrations and shared links stay valid. Cancelled
+	// dates which are sent again take place again.
+	query = fmt.Sprintf(`
+		SELECT uuid, TO_CHAR(start_date, 'YYYY-MM-DD') || ' ' || COALESCE(TO_CHAR(start_time, 'HH24:MI'), '')
+		FROM %s.event_date
+		WHERE event_uuid = $1::uuid
+			AND start_date >= CURRENT_DATE
+			AND NOT recurrence_generated`,
+		h.DbSchema)
+	rows, err := tx.Query(ctx, query, eventUuid)
+	if err != nil {
+		return "", "", err
+	}
+	existingDates := map[string]string{}
+	for rows.Next() {
+		var dateUuid, key string
+		if err := rows.Scan(&dateUuid, &key); err != nil {
+			rows.Close()
+			return "", "", err
+		}
+		existingDates[key] = dateUuid
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", "", err
+	}
+
+	updateDateQuery := fmt.Sprintf(`
+		UPDATE %[1]s.event_date ed
+		SET venue_uuid = $2::uuid, space_uuid = $3::uuid,
+			end_date = $4, end_time = $5, entry_time = $6, duration = $7,
+			all_day = COALESCE($8, ed.all_day), ticket_link = $9,
+			release_status = CASE WHEN prev.cancelled THEN 'inherited' ELSE ed.release_status END,
+			status_reason = CASE WHEN prev.cancelled THEN NULL ELSE ed.status_reason END,
+			status_changed_at = CASE WHEN prev.cancelled THEN NULL ELSE ed.status_changed_at END,
+			status_changed_by = CASE WHEN prev.cancelled THEN NULL ELSE ed.status_changed_by END
+		FROM (
+			SELECT uuid, release_status IS NOT DISTINCT FROM 'cancelled' AS cancelled
+			FROM %[1]s.event_date
+			WHERE uuid = $1::uuid
+			FOR UPDATE
+		) prev
+		WHERE ed.uuid = prev.uuid
+		RETURNING prev.cancelled`,
+		h.DbSchema)
+
+	for _, d := range p.Dates {
+		key := d.StartDate + " " + d.StartTime
+		if dateUuid, ok := existingDates[key]; ok {
+			delete(existingDates, key)
+			var reinstated bool
+			err := tx.QueryRow(ctx, updateDateQuery,
+				dateUuid, d.VenueUuid, d.SpaceUuid,
+				d.EndDate, d.EndTime, d.EntryTime, d.Duration,
+				d.AllDay, d.TicketLink).Scan(&reinstated)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to update event date: %v", err)
+			}
+			if reinstated {
+				_, err = h.notifyFavoriteEventDateTx(ctx, tx, "", eventUuid, dateUuid, "inherited", nil, nil)
+				if err != nil {
+					return "", "", err
+				}
+			}
+			continue
+		}
+
+		dateUuid, err := grains_uuid.Uuidv7String()
+		if err != nil {
+			return "", "", err
+		}
+		_, err = tx.Exec(ctx, app.UranusInstance.SqlAdminInsertEventDate,
+			dateUuid,
+			eventUuid,
+			"inherited",
+			d.VenueUuid,
+			d.SpaceUuid,
+			d.StartDate,
+			d.StartTime,
+			d.EndDate,
+			d.EndTime,
+			d.EntryTime,
+			d.Duration,
+			d.AllDay,
+			nil,
+		)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to insert event date: %v", err)
+		}
+	}
+
+	if len(existingDates) > 0 {
+		obsolete := make([]string, 0, len(existingDates))
+		for _, dateUuid := range existingDates {
+			obsolete = append(obsolete, dateUuid)
+		}
+		if _, err := h.cancelEventDatesTx(ctx, tx, "", eventUuid, obsolete); err != nil {
+			return "", "", fmt.Errorf("failed to cancel event dates: %v", err)
+		}
+	}
+
+	if err := h.recordEventRevisionTx(ctx, tx, eventUuid, "", "sync", before); err != nil {
+		return "", "", err
+	}
+
+	return eventUuid, eventSyncUpdated, nil
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/model"
+)
+
+// VisitorGetAgenda returns the event dates saved in the agenda of the user,
+// in order of their start. Past dates are included with past=true.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) VisitorGetAgenda(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "visitor-get-agenda")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	withPast := gc.Query("past") == "true"
+
+	query := fmt.Sprintf(`
+		SELECT
+			a.event_date_uuid,
+			edp.event_uuid,
+			ep.title,
+			TO_CHAR(edp.start_date, 'YYYY-MM-DD'),
+			TO_CHAR(edp.start_time, 'HH24:MI'),
+			TO_CHAR(edp.end_date, 'YYYY-MM-DD'),
+			TO_CHAR(edp.end_time, 'HH24:MI'),
+			COALESCE(edp.venue_name, ep.venue_name),
+			COALESCE(edp.venue_city, ep.venue_city),
+			CASE
+				WHEN edp.release_status IN ('cancelled', 'deferred', 'rescheduled') THEN edp.release_status
+				ELSE ep.release_status
+			END,
+			edp.status_reason,
+			edp.rescheduled_to_uuid,
+			a.remind_before_hours,
+			a.reminded_at,
+			a.created_at
+		FROM %[1]s.user_agenda a
+		JOIN %[1]s.event_date_projection edp ON edp.event_date_uuid = a.event_date_uuid
+		JOIN %[1]s.event_projection ep ON ep.event_uuid = edp.event_uuid
+		WHERE a.user_uuid = $1::uuid
+			AND ($2 OR edp.start_date >= CURRENT_DATE)
+		ORDER BY edp.event_start_at ASC, a.event_date_uuid ASC`,
+		h.DbSchema)
+	rows, err := h.DbPool.Query(ctx, query, userUuid, withPast)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	defer rows.Close()
+
+	items := make([]model.AgendaItem, 0)
+	for rows.Next() {
+		var item model.AgendaItem
+		err := rows.Scan(
+			&item.EventDateUuid, &item.EventUuid, &item.Title, &item.StartDate, &item.StartTime,
+			&item.EndDate, &item.EndTime, &item.VenueName, &item.VenueCity, &item.ReleaseStatus,
+			&item.StatusReason, &item.RescheduledToUuid, &item.RemindBeforeHours, &item.RemindedAt,
+			&item.CreatedAt)
+		if err != nil {
+			debugf(err.Error())
+			apiRequest.InternalServerError()
+			return
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+
+	apiRequest.SetMeta("agenda_count", len(items))
+	apiRequest.Success(http.StatusOK, items, "agenda loaded successfully")
+}
+
+// VisitorUpsertAgendaItem saves an event date in the agenda of the user or
+// changes its reminder. remind_before_hours (0 to 336, default 24) sets
+// when the reminder is sent, 0 turns it off. A changed reminder is sent
+// again.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) VisitorUpsertAgendaItem(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "visitor-upsert-agenda-item")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventDateUuid := gc.Param("eventDateUuid")
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	var payload struct {
+		RemindBeforeHours *int `json:"remind_before_hours"`
+	}
+	if gc.Request.ContentLength > 0 {
+		if err := gc.ShouldBindJSON(&payload); err != nil {
+			apiRequest.PayloadError()
+			return
+		}
+	}
+	remindBeforeHours := agendaDefaultRemindBeforeHours
+	if payload.RemindBeforeHours != nil {
+		remindBeforeHours = *payload.RemindBeforeHours
+	}
+	if remindBeforeHours < 0 || remindBeforeHours > agendaMaxRemindBeforeHours {
+		apiRequest.Error(http.StatusBadRequest,
+			fmt.Sprintf("remind_before_hours must be between 0 and %d", agendaMaxRemindBeforeHours))
+		return
+	}
+
+	txErr := WithTransaction(ctx, h.DbPool, func(tx pgx.Tx) *ApiTxError {
+		// Only event dates visitors can see can be saved
+		var visible bool
+		query := fmt.Sprintf(`
+			SELECT EXISTS (
+				SELECT 1
+				FROM %[1]s.event_date_projection edp
+				JOIN %[1]s.event_projection ep ON ep.event_uuid = edp.event_uuid
+				WHERE edp.event_date_uuid::text = $1
+					AND ep.release_status IN ('released', 'cancelled', 'deferred', 'rescheduled')
+			)`,
+			h.DbSchema)
+		if err := tx.QueryRow(ctx, query, eventDateUuid).Scan(&visible); err != nil {
+			return TxInternalError(err)
+		}
+		if !visible {
+			return ApiErrNotFound("event date not found")
+		}
+
+		var itemCount int
+		query = fmt.Sprintf(`
+			SELECT COUNT(*) FROM %s.user_agenda
+			WHERE user_uuid = $1::uuid AND event_date_uuid <> $2::uuid`,
+			h.DbSchema)
+		if err := tx.QueryRow(ctx, query, userUuid, eventDateUuid).Scan(&itemCount); err != nil {
+			return TxInternalError(err)
+		}
+		if itemCount >= agendaMaxItems {
+			return NewApiTxError(http.StatusConflict, "an agenda can hold at most %d event dates", agendaMaxItems)
+		}
+
+		query = fmt.Sprintf(`
+			INSERT INTO %s.user_agenda AS a (user_uuid, event_date_uuid, remind_before_hours)
+			VALUES ($1::uuid, $2::uuid, $3)
+			ON CONFLICT (user_uuid, event_date_uuid) DO UPDATE SET
+				remind_before_hours = EXCLUDED.remind_before_hours,
+				reminded_at = CASE
+					WHEN a.remind_before_hours = EXCLUDED.remind_before_hours THEN a.reminded_at
+				END`,
+			h.DbSchema)
+		if _, err := tx.Exec(ctx, query, userUuid, eventDateUuid, remindBeforeHours); err != nil {
+			return TxInternalError(err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		debugf(txErr.Error())
+		apiRequest.Error(txErr.Code, txErr.Error())
+		return
+	}
+
+	apiRequest.SetMeta("remind_before_hours", remindBeforeHours)
+	apiRequest.SuccessNoData(http.StatusOK, "event date saved in agenda")
+}
+
+// VisitorDeleteAgendaItem removes an event date from the agenda of the
+// user.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) VisitorDeleteAgendaItem(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "visitor-delete-agenda-item")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	eventDateUuid := gc.Param("eventDateUuid")
+	apiRequest.SetMeta("event_date_uuid", eventDateUuid)
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s.user_agenda
+		WHERE user_uuid = $1::uuid AND event_date_uuid::text = $2`,
+		h.DbSchema)
+	tag, err := h.DbPool.Exec(ctx, query, userUuid, eventDateUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiRequest.NotFound("agenda item not found")
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "event date removed from agenda")
+}
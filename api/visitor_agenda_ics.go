@@ -0,0 +1,135 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sndcds/grains/grains_api"
+	"github.com/sndcds/uranus/app"
+)
+
+func (h *ApiHandler) agendaICSUrl(token string) string {
+	return strings.TrimRight(h.Config.BaseApiUrl, "/") + "/api/agenda/" + token + "/events.ics"
+}
+
+// VisitorCreateAgendaICSToken creates the private URL of the agenda of the
+// user as iCalendar feed. A URL created before stops working. The URL is
+// only returned here, the token is stored as hash.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) VisitorCreateAgendaICSToken(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "visitor-create-agenda-ics-token")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		debugf(err.Error())
+		apiRequest.InternalServerError()
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	query := fmt.Sprintf(`UPDATE %s.user SET agenda_ics_token_hash = $2 WHERE uuid = $1::uuid`, h.DbSchema)
+	if _, err := h.DbPool.Exec(ctx, query, userUuid, hashOrgKey(token)); err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+
+	apiRequest.Success(http.StatusCreated, gin.H{
+		"url": h.agendaICSUrl(token),
+	}, "agenda feed created")
+}
+
+// VisitorDeleteAgendaICSToken revokes the private iCalendar URL of the
+// agenda of the user.
+//
+// PermissionNote: User must be authenticated.
+func (h *ApiHandler) VisitorDeleteAgendaICSToken(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "visitor-delete-agenda-ics-token")
+	ctx := gc.Request.Context()
+	userUuid := h.userUuid(gc)
+
+	query := fmt.Sprintf(`
+		UPDATE %s.user SET agenda_ics_token_hash = NULL
+		WHERE uuid = $1::uuid AND agenda_ics_token_hash IS NOT NULL`,
+		h.DbSchema)
+	tag, err := h.DbPool.Exec(ctx, query, userUuid)
+	if err != nil {
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiRequest.NotFound("agenda feed not found")
+		return
+	}
+
+	apiRequest.SuccessNoData(http.StatusOK, "agenda feed deleted")
+}
+
+// GetAgendaICS returns the saved event dates of a user as subscribable
+// iCalendar feed, including the dates of the last 30 days. Cancelled and
+// postponed dates stay in the feed with their status.
+//
+// PermissionNote: No user authentication, the feed is authenticated by the
+// token of its URL (VisitorCreateAgendaICSToken).
+func (h *ApiHandler) GetAgendaICS(gc *gin.Context) {
+	apiRequest := grains_api.NewRequest(gc, "get-agenda-ics")
+	ctx := gc.Request.Context()
+
+	var userUuid string
+	query := fmt.Sprintf(
+		`SELECT uuid FROM %s.user WHERE agenda_ics_token_hash = $1 AND is_active`,
+		h.DbSchema)
+	err := h.DbPool.QueryRow(ctx, query, hashOrgKey(gc.Param("icsToken"))).Scan(&userUuid)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			apiRequest.NotFound("agenda feed not found")
+			return
+		}
+		debugf(err.Error())
+		apiRequest.DatabaseError()
+		return
+	}
+
+	rows, err := h.DbPool.Query(ctx, app.UranusInstance.SqlGetAgendaICS, userUuid)
+	if err != nil {
+		debugf("Error querying agenda: %v", err)
+		apiRequest.InternalServerError()
+		return
+	}
+	defer rows.Close()
+
+	w := newICSWriter(h.Config.IcsTimezone)
+	w.Begin("Agenda")
+
+	for rows.Next() {
+		e, err := h.scanICSEvent(rows)
+		if err != nil {
+			debugf("Error scanning agenda: %v", err)
+			apiRequest.InternalServerError()
+			return
+		}
+
+		w.WriteEvent(e)
+	}
+
+	if err := rows.Err(); err != nil {
+		debugf("Error reading agenda: %v", err)
+		apiRequest.InternalServerError()
+		return
+	}
+
+	gc.Header("Content-Type", "text/calendar; charset=utf-8")
+	gc.Header("Content-Disposition", `inline; filename="agenda.ics"`)
+	gc.Header("Cache-Control", "private, no-store")
+	gc.String(http.StatusOK, w.End())
+}
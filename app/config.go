@@ -9,40 +9,107 @@ import (
 
 // Config holds database configuration details
 type Config struct {
-	Verbose                     bool     `json:"verbose"`
-	DevMode                     bool     `json:"dev_mode"`
-	DebugLevel                  int      `json:"debug_level"`
-	Port                        int      `json:"port"`
-	BaseApiUrl                  string   `json:"base_api_url"`
-	IcsDomain                   string   `json:"ics_domain"`
-	Frontend                    string   `json:"frontend"`
-	UseRouterMiddleware         bool     `json:"use_router_middleware"`
-	SupportedLanguages          []string `json:"supported_languages"`
-	DbHost                      string   `json:"db_host"`
-	DbPort                      int      `json:"db_port"`
-	DbUser                      string   `json:"db_user"`
-	DbPassword                  string   `json:"db_password"`
-	DbName                      string   `json:"db_name"`
-	DbSchema                    string   `json:"db_schema"`
-	SSLMode                     string   `json:"ssl_mode"`
-	AllowOrigins                []string `json:"allow_origins"`
-	ProfileImageDir             string   `json:"profile_image_dir"`
-	ProfileImageQuality         float32  `json:"profile_image_quality"`
-	PlutoImageMaxFileSize       int      `json:"pluto_image_max_file_size"`
-	PlutoImageMaxPx             int      `json:"pluto_image_max_px"`
-	PlutoVerbose                bool     `json:"pluto_verbose"`
-	PlutoImageDir               string   `json:"pluto_image_dir"`
-	PlutoCacheDir               string   `json:"pluto_cache_dir"`
-	JwtSecret                   string   `json:"jwt_secret"`
-	SecretKey                   string   `json:"secret_key"`
-	AuthTokenExpirationTime     int      `json:"auth_token_expiration_time"`
-	AuthSmtpHost                string   `json:"auth_smtp_host"`
-	AuthSmtpPort                int      `json:"auth_smtp_port"`
-	AuthSmtpLogin               string   `json:"auth_smtp_login"`
-	AuthSmtpPassword            string   `json:"auth_smtp_password"`
-	AuthReplyEmail              string   `json:"auth_reply_email"`
-	AuthResetPasswordUrl        string   `json:"auth_reset_password_url"`
-	InvitationExpirationMinutes int      `json:"invitation_expiration_minutes"`
+	Verbose                     bool                 `json:"verbose"`
+	DevMode                     bool                 `json:"dev_mode"`
+	DebugLevel                  int                  `json:"debug_level"`
+	Port                        int                  `json:"port"`
+	BaseApiUrl                  string               `json:"base_api_url"`
+	IcsDomain                   string               `json:"ics_domain"`
+	IcsTimezone                 string               `json:"ics_timezone"`
+	Frontend                    string               `json:"frontend"`
+	UseRouterMiddleware         bool                 `json:"use_router_middleware"`
+	SupportedLanguages          []string             `json:"supported_languages"`
+	DbHost                      string               `json:"db_host"`
+	DbPort                      int                  `json:"db_port"`
+	DbUser                      string               `json:"db_user"`
+	DbPassword                  string               `json:"db_password"`
+	DbName                      string               `json:"db_name"`
+	DbSchema                    string               `json:"db_schema"`
+	SSLMode                     string               `json:"ssl_mode"`
+	AllowOrigins                []string             `json:"allow_origins"`
+	ProfileImageDir             string               `json:"profile_image_dir"`
+	ProfileImageQuality         float32              `json:"profile_image_quality"`
+	PlutoImageMaxFileSize       int                  `json:"pluto_image_max_file_size"`
+	PlutoImageMaxPx             int                  `json:"pluto_image_max_px"`
+	PlutoVerbose                bool                 `json:"pluto_verbose"`
+	PlutoImageDir               string               `json:"pluto_image_dir"`
+	PlutoCacheDir               string               `json:"pluto_cache_dir"`
+	JwtSecret                   string               `json:"jwt_secret"`
+	SecretKey                   string               `json:"secret_key"`
+	AuthTokenExpirationTime     int                  `json:"auth_token_expiration_time"`
+	AuthRefreshTokenDays        int                  `json:"auth_refresh_token_days"`
+	AuthSmtpHost                string               `json:"auth_smtp_host"`
+	AuthSmtpPort                int                  `json:"auth_smtp_port"`
+	AuthSmtpLogin               string               `json:"auth_smtp_login"`
+	AuthSmtpPassword            string               `json:"auth_smtp_password"`
+	AuthReplyEmail              string               `json:"auth_reply_email"`
+	AuthResetPasswordUrl        string               `json:"auth_reset_password_url"`
+	InvitationExpirationMinutes int                  `json:"invitation_expiration_minutes"`
+	RecurrenceHorizonDays       int                  `json:"recurrence_horizon_days"`
+	ImportMaxFileSize           int                  `json:"import_max_file_size"`
+	AuditLogRetentionDays       int                  `json:"audit_log_retention_days"`
+	TotpIssuer                  string               `json:"totp_issuer"`
+	RateLimitStore              string               `json:"rate_limit_store"`
+	RateLimits                  map[string]RateLimit `json:"rate_limits"`
+	LoginDelayAfter             int                  `json:"login_delay_after"`
+	LoginLockoutThreshold       int                  `json:"login_lockout_threshold"`
+	LoginLockoutMinutes         int                  `json:"login_lockout_minutes"`
+	OidcProviders               []OidcProvider       `json:"oidc_providers"`
+	MailTransport               string               `json:"mail_transport"`
+	MailDir                     string               `json:"mail_dir"`
+	NotificationDigestHour      int                  `json:"notification_digest_hour"`
+	NotificationLookaheadDays   int                  `json:"notification_lookahead_days"`
+	VapidPublicKey              string               `json:"vapid_public_key"`
+	VapidPrivateKey             string               `json:"vapid_private_key"`
+	VapidSubject                string               `json:"vapid_subject"`
+}
+
+// RateLimit configures the token bucket of a route group, see
+// Config.RateLimits. The buckets are kept in memory or, with
+// Config.RateLimitStore "postgres", shared by several instances.
+//
+// A bucket holds up to Burst tokens (default Requests) and is refilled by
+// Requests tokens per WindowSeconds, each request takes one. Key selects
+// whose requests share a bucket: "ip" (default) or "user", the user or API
+// key of the request, falling back to the client IP. With OnlyWithParam set,
+// only requests carrying this query or form parameter are limited.
+type RateLimit struct {
+	Requests      int    `json:"requests"`
+	WindowSeconds int    `json:"window_seconds"`
+	Burst         int    `json:"burst"`
+	Key           string `json:"key"`
+	OnlyWithParam string `json:"only_with_param"`
+}
+
+// OidcProvider configures an OpenID Connect identity provider users can log
+// in with, e.g. a Keycloak realm or Nextcloud. Name identifies the provider
+// in the login routes. The endpoints are discovered from Issuer.
+//
+// Users are linked by the subject of the provider and on their first login
+// by a verified email. Without an account with this email a user is only
+// created with AllowSignup. GroupsClaim names the claim holding the groups
+// of the user (default "groups"), which organizations can map onto
+// memberships.
+type OidcProvider struct {
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"display_name"`
+	Issuer       string   `json:"issuer"`
+	ClientId     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectUrl  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+	GroupsClaim  string   `json:"groups_claim"`
+	AllowSignup  bool     `json:"allow_signup"`
+}
+
+// OidcProvider returns the configured provider with the given name.
+func (config *Config) OidcProvider(name string) (OidcProvider, bool) {
+	for _, provider := range config.OidcProviders {
+		if provider.Name == name {
+			return provider, true
+		}
+	}
+	return OidcProvider{}, false
 }
 
 func (config Config) Print() {
@@ -65,6 +132,7 @@ func DefaultConfig() Config {
 		DebugLevel:                  1,
 		Port:                        9090,
 		BaseApiUrl:                  "http://localhost:9090",
+		IcsTimezone:                 "Europe/Berlin",
 		UseRouterMiddleware:         true,
 		SupportedLanguages:          []string{"en", "de", "da"},
 		DbHost:                      "localhost",
@@ -74,6 +142,29 @@ func DefaultConfig() Config {
 		PlutoImageMaxFileSize:       5_000_000,
 		PlutoImageMaxPx:             1920,
 		AuthTokenExpirationTime:     360,
+		AuthRefreshTokenDays:        30,
 		InvitationExpirationMinutes: 60,
+		RecurrenceHorizonDays:       365,
+		ImportMaxFileSize:           5_000_000,
+		AuditLogRetentionDays:       730,
+		TotpIssuer:                  "Uranus",
+		RateLimitStore:              "memory",
+		RateLimits: map[string]RateLimit{
+			"login":           {Requests: 10, WindowSeconds: 60, Burst: 20},
+			"signup":          {Requests: 5, WindowSeconds: 3600},
+			"forgot-password": {Requests: 5, WindowSeconds: 3600},
+			"events-search":   {Requests: 60, WindowSeconds: 60, Burst: 30, OnlyWithParam: "search"},
+			"admin":           {Requests: 600, WindowSeconds: 60, Burst: 120, Key: "user"},
+			"push":            {Requests: 30, WindowSeconds: 60},
+			"visitor":         {Requests: 300, WindowSeconds: 60, Burst: 60, Key: "user"},
+			"registration":    {Requests: 10, WindowSeconds: 600},
+			"tickets":         {Requests: 10, WindowSeconds: 600},
+		},
+		LoginDelayAfter:           3,
+		LoginLockoutThreshold:     10,
+		LoginLockoutMinutes:       15,
+		MailTransport:             "smtp",
+		NotificationDigestHour:    7,
+		NotificationLookaheadDays: 14,
 	}
 }
@@ -13,6 +13,35 @@ import (
 // TODO: Review code
 
 func JWTMiddleware(gc *gin.Context) {
+	claims, accountType, errMsg := authenticate(gc)
+	if errMsg != "" {
+		gc.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+		return
+	}
+
+	// Store claims for downstream handlers
+	gc.Set("user-uuid", claims.UserUuid)
+	gc.Set("session-uuid", claims.SessionUuid)
+	gc.Set("account-type", accountType)
+
+	gc.Next()
+}
+
+// OptionalUserUuid returns the user of a request with a valid access token
+// of an active session, "" for anonymous requests. The token is checked like
+// in JWTMiddleware, but the request is not rejected without one.
+func OptionalUserUuid(gc *gin.Context) string {
+	claims, _, errMsg := authenticate(gc)
+	if errMsg != "" {
+		return ""
+	}
+	return claims.UserUuid
+}
+
+// authenticate returns the claims of the access token of a request and the
+// account type of its user, or the error message if there is no valid access
+// token of an active session.
+func authenticate(gc *gin.Context) (*Claims, string, string) {
 	var tokenStr string
 
 	// 1. First try Authorization header
@@ -30,8 +59,7 @@ func JWTMiddleware(gc *gin.Context) {
 	}
 
 	if tokenStr == "" {
-		gc.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
-		return
+		return nil, "", "missing token"
 	}
 
 	// 3. Parse and validate
@@ -40,16 +68,32 @@ func JWTMiddleware(gc *gin.Context) {
 		return UranusInstance.JwtKey, nil
 	})
 	if err != nil || !token.Valid {
-		gc.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-		return
+		return nil, "", "invalid token"
 	}
 	if claims.UserUuid == "" {
-		gc.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid user Id"})
+		return nil, "", "invalid user Id"
+	}
+	if claims.Purpose != "" {
+		return nil, "", "invalid token"
 	}
 
-	// 4. Store claims for downstream handlers
-	gc.Set("user-uuid", claims.UserUuid)
+	// 4. Reject tokens of revoked or expired sessions
+	accountType, ok := sessionActive(gc, claims.UserUuid, claims.SessionUuid)
+	if !ok {
+		return nil, "", "session expired"
+	}
+
+	return claims, accountType, ""
+}
 
+// MemberOnlyMiddleware rejects requests of visitor accounts, it must run
+// after JWTMiddleware. Requests without user, e.g. with an API key of an
+// organization, pass.
+func MemberOnlyMiddleware(gc *gin.Context) {
+	if gc.GetString("account-type") == AccountTypeVisitor {
+		gc.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not available for visitor accounts"})
+		return
+	}
 	gc.Next()
 }
 
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MigrationsDir holds the schema migrations, applied in the order of their
+// file names (NNNN_name.sql)
+const MigrationsDir = "sql/migrations"
+
+// Migrate applies the migrations in dir which are not recorded in the
+// schema_migration table yet and returns their names. Each migration runs
+// in a transaction of its own together with its record, so a failing
+// migration leaves no trace and the ones before it stay applied.
+// Concurrent runs are serialized by an advisory lock.
+func (app *Uranus) Migrate(ctx context.Context, dir string) ([]string, error) {
+	schema := app.Config.DbSchema
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+
+	conn, err := app.MainDbPool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock(hashtext('uranus-migrate'))`)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtext('uranus-migrate'))`)
+
+	query := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s.schema_migration (
+			name text PRIMARY KEY,
+			applied_at timestamptz NOT NULL DEFAULT NOW()
+		)`,
+		schema)
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, fmt.Sprintf(`SELECT name FROM %s.schema_migration`, schema))
+	if err != nil {
+		return nil, err
+	}
+	applied, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	var done []string
+	for _, name := range names {
+		if slices.Contains(applied, name) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return done, err
+		}
+		sql := strings.ReplaceAll(string(content), "{{schema}}", schema)
+
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			// Without arguments the statements of the file are sent as one
+			// simple query
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx,
+				fmt.Sprintf(`INSERT INTO %s.schema_migration (name) VALUES ($1)`, schema),
+				name)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s: %w", name, err)
+		}
+		done = append(done, name)
+	}
+
+	return done, nil
+}
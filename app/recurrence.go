@@ -0,0 +1,512 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecurrenceRule is the subset of an RFC 5545 RRULE supported by Uranus.
+// Supported parts: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT,
+// UNTIL, BYDAY (with ordinals for MONTHLY and YEARLY), BYMONTHDAY, BYMONTH
+// and WKST. Rules are expanded on date granularity, the time of day is
+// defined by the event date itself.
+type RecurrenceRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []RecurrenceWeekday
+	ByMonthDay []int
+	ByMonth    []int
+	WeekStart  time.Weekday
+}
+
+// RecurrenceWeekday is a BYDAY entry, e.g. "TU" or "-1FR".
+// Ordinal is 0 if no ordinal is given.
+type RecurrenceWeekday struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+const (
+	recurrenceMaxPeriods     = 10000
+	recurrenceMaxOccurrences = 5000
+)
+
+var recurrenceWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseRecurrenceRule parses an RRULE value like "FREQ=WEEKLY;BYDAY=TU,TH".
+// A leading "RRULE:" is accepted.
+func ParseRecurrenceRule(s string) (*RecurrenceRule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("empty recurrence rule")
+	}
+
+	rule := &RecurrenceRule{
+		Interval:  1,
+		WeekStart: time.Monday,
+	}
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid rule part: %s", part)
+		}
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				rule.Freq = value
+			default:
+				return nil, fmt.Errorf("unsupported FREQ: %s", value)
+			}
+
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL: %s", value)
+			}
+			rule.Interval = n
+
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT: %s", value)
+			}
+			rule.Count = n
+
+		case "UNTIL":
+			until, err := parseRecurrenceUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				wd, err := parseRecurrenceWeekday(v)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY: %s", v)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("invalid BYMONTH: %s", v)
+				}
+				rule.ByMonth = append(rule.ByMonth, n)
+			}
+
+		case "WKST":
+			wd, ok := recurrenceWeekdays[value]
+			if !ok {
+				return nil, fmt.Errorf("invalid WKST: %s", value)
+			}
+			rule.WeekStart = wd
+
+		default:
+			return nil, fmt.Errorf("unsupported rule part: %s", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL must not be used together")
+	}
+
+	for _, wd := range rule.ByDay {
+		if wd.Ordinal != 0 && rule.Freq != "MONTHLY" && rule.Freq != "YEARLY" {
+			return nil, fmt.Errorf("BYDAY ordinals are only supported for MONTHLY and YEARLY")
+		}
+	}
+
+	if rule.Freq == "YEARLY" && len(rule.ByDay) > 0 && len(rule.ByMonth) == 0 {
+		return nil, fmt.Errorf("BYDAY in YEARLY rules requires BYMONTH")
+	}
+
+	return rule, nil
+}
+
+// String returns the rule in RRULE value notation (without "RRULE:").
+// UNTIL is written as DATE, which matches a DTSTART of value type DATE.
+func (r *RecurrenceRule) String() string {
+	if r.Until == nil {
+		return r.StringWithUntil("")
+	}
+	return r.StringWithUntil(r.Until.Format("20060102"))
+}
+
+// StringWithUntil works like String but writes the given UNTIL value, e.g.
+// a UTC DATE-TIME for series whose DTSTART is a DATE-TIME with time zone
+// (RFC 5545, 3.3.10). UNTIL is left out if until is empty.
+func (r *RecurrenceRule) StringWithUntil(until string) string {
+	parts := []string{"FREQ=" + r.Freq}
+
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if until != "" {
+		parts = append(parts, "UNTIL="+until)
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = wd.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+func (wd RecurrenceWeekday) String() string {
+	if wd.Ordinal != 0 {
+		return strconv.Itoa(wd.Ordinal) + weekdayCode(wd.Weekday)
+	}
+	return weekdayCode(wd.Weekday)
+}
+
+// Occurrences expands the rule starting at dtStart and returns all dates
+// within [from, to] (inclusive, date granularity). COUNT is always applied
+// relative to dtStart, so the result is independent of the window.
+// rDates are added, exDates are removed.
+func (r *RecurrenceRule) Occurrences(
+	dtStart time.Time,
+	from time.Time,
+	to time.Time,
+	rDates []time.Time,
+	exDates []time.Time,
+) []time.Time {
+	dtStart = truncateDay(dtStart)
+	from = truncateDay(from)
+	to = truncateDay(to)
+
+	excluded := make(map[string]struct{}, len(exDates))
+	for _, d := range exDates {
+		excluded[d.Format("2006-01-02")] = struct{}{}
+	}
+
+	seen := make(map[string]struct{})
+	var result []time.Time
+
+	add := func(d time.Time) {
+		key := d.Format("2006-01-02")
+		if _, ok := excluded[key]; ok {
+			return
+		}
+		if _, ok := seen[key]; ok {
+			return
+		}
+		if d.Before(from) || d.After(to) {
+			return
+		}
+		seen[key] = struct{}{}
+		result = append(result, d)
+	}
+
+	count := 0
+	done := false
+
+	for period := 0; period < recurrenceMaxPeriods && !done; period++ {
+		periodStart := r.periodStart(dtStart, period)
+		if periodStart.After(to) {
+			break
+		}
+		if r.Until != nil && periodStart.After(*r.Until) {
+			break
+		}
+
+		for _, d := range r.periodCandidates(dtStart, periodStart) {
+			if d.Before(dtStart) {
+				continue
+			}
+			if r.Until != nil && d.After(*r.Until) {
+				done = true
+				break
+			}
+			if d.After(to) && r.Count == 0 {
+				done = true
+				break
+			}
+
+			count++
+			add(d)
+
+			if r.Count > 0 && count >= r.Count {
+				done = true
+				break
+			}
+			if count >= recurrenceMaxOccurrences {
+				done = true
+				break
+			}
+		}
+	}
+
+	for _, d := range rDates {
+		add(truncateDay(d))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Before(result[j])
+	})
+
+	return result
+}
+
+func (r *RecurrenceRule) periodStart(dtStart time.Time, period int) time.Time {
+	n := period * r.Interval
+	switch r.Freq {
+	case "DAILY":
+		return dtStart.AddDate(0, 0, n)
+	case "WEEKLY":
+		offset := (int(dtStart.Weekday()) - int(r.WeekStart) + 7) % 7
+		return dtStart.AddDate(0, 0, -offset+7*n)
+	case "MONTHLY":
+		first := time.Date(dtStart.Year(), dtStart.Month(), 1, 0, 0, 0, 0, dtStart.Location())
+		return first.AddDate(0, n, 0)
+	default: // YEARLY
+		return time.Date(dtStart.Year()+n, time.January, 1, 0, 0, 0, 0, dtStart.Location())
+	}
+}
+
+// periodCandidates returns the sorted candidate dates of one period.
+func (r *RecurrenceRule) periodCandidates(dtStart time.Time, periodStart time.Time) []time.Time {
+	var candidates []time.Time
+
+	switch r.Freq {
+	case "DAILY":
+		if r.matchesDay(periodStart) {
+			candidates = append(candidates, periodStart)
+		}
+
+	case "WEEKLY":
+		for i := 0; i < 7; i++ {
+			d := periodStart.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 {
+				if d.Weekday() != dtStart.Weekday() {
+					continue
+				}
+			} else if !r.matchesWeekday(d) {
+				continue
+			}
+			if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(d.Month())) {
+				continue
+			}
+			candidates = append(candidates, d)
+		}
+
+	case "MONTHLY":
+		if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(periodStart.Month())) {
+			return nil
+		}
+		candidates = r.monthCandidates(dtStart, periodStart.Year(), periodStart.Month())
+
+	case "YEARLY":
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []int{int(dtStart.Month())}
+		}
+		for _, m := range months {
+			candidates = append(candidates, r.monthCandidates(dtStart, periodStart.Year(), time.Month(m))...)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+
+	return candidates
+}
+
+func (r *RecurrenceRule) monthCandidates(dtStart time.Time, year int, month time.Month) []time.Time {
+	loc := dtStart.Location()
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+
+	var candidates []time.Time
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			day := md
+			if md < 0 {
+				day = daysInMonth + md + 1
+			}
+			if day < 1 || day > daysInMonth {
+				continue
+			}
+			d := time.Date(year, month, day, 0, 0, 0, 0, loc)
+			if len(r.ByDay) > 0 && !r.matchesWeekday(d) {
+				continue
+			}
+			candidates = append(candidates, d)
+		}
+
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			var matches []time.Time
+			for day := 1; day <= daysInMonth; day++ {
+				d := time.Date(year, month, day, 0, 0, 0, 0, loc)
+				if d.Weekday() == wd.Weekday {
+					matches = append(matches, d)
+				}
+			}
+			switch {
+			case wd.Ordinal == 0:
+				candidates = append(candidates, matches...)
+			case wd.Ordinal > 0 && wd.Ordinal <= len(matches):
+				candidates = append(candidates, matches[wd.Ordinal-1])
+			case wd.Ordinal < 0 && -wd.Ordinal <= len(matches):
+				candidates = append(candidates, matches[len(matches)+wd.Ordinal])
+			}
+		}
+
+	default:
+		if dtStart.Day() <= daysInMonth {
+			candidates = append(candidates, time.Date(year, month, dtStart.Day(), 0, 0, 0, 0, loc))
+		}
+	}
+
+	return candidates
+}
+
+func (r *RecurrenceRule) matchesDay(d time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(d.Month())) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 {
+		daysInMonth := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, d.Location()).Day()
+		match := false
+		for _, md := range r.ByMonthDay {
+			if md == d.Day() || (md < 0 && daysInMonth+md+1 == d.Day()) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(r.ByDay) > 0 && !r.matchesWeekday(d) {
+		return false
+	}
+	return true
+}
+
+func (r *RecurrenceRule) matchesWeekday(d time.Time) bool {
+	for _, wd := range r.ByDay {
+		if wd.Weekday == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRecurrenceWeekday(s string) (RecurrenceWeekday, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return RecurrenceWeekday{}, fmt.Errorf("invalid BYDAY: %s", s)
+	}
+
+	code := s[len(s)-2:]
+	wd, ok := recurrenceWeekdays[code]
+	if !ok {
+		return RecurrenceWeekday{}, fmt.Errorf("invalid BYDAY: %s", s)
+	}
+
+	result := RecurrenceWeekday{Weekday: wd}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return RecurrenceWeekday{}, fmt.Errorf("invalid BYDAY: %s", s)
+		}
+		result.Ordinal = n
+	}
+
+	return result, nil
+}
+
+func parseRecurrenceUntil(value string) (time.Time, error) {
+	layouts := []string{"20060102", "20060102T150405", "20060102T150405Z"}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return truncateDay(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL: %s", value)
+}
+
+func weekdayCode(wd time.Weekday) string {
+	for code, d := range recurrenceWeekdays {
+		if d == wd {
+			return code
+		}
+	}
+	return ""
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,446 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustDate(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatalf("invalid test date %q: %v", s, err)
+	}
+	return d
+}
+
+func formatDates(dates []time.Time) string {
+	values := make([]string, len(dates))
+	for i, d := range dates {
+		values[i] = d.Format("2006-01-02")
+	}
+	return strings.Join(values, ",")
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    string
+		wantErr string
+	}{
+		{name: "weekly", rule: "FREQ=WEEKLY;BYDAY=TU,TH", want: "FREQ=WEEKLY;BYDAY=TU,TH"},
+		{name: "rrule prefix and lower case", rule: " rrule:freq=daily;interval=2 ", want: "FREQ=DAILY;INTERVAL=2"},
+		{name: "interval 1 omitted", rule: "FREQ=DAILY;INTERVAL=1", want: "FREQ=DAILY"},
+		{name: "count", rule: "FREQ=MONTHLY;COUNT=5", want: "FREQ=MONTHLY;COUNT=5"},
+		{name: "until date", rule: "FREQ=WEEKLY;UNTIL=20261231", want: "FREQ=WEEKLY;UNTIL=20261231"},
+		{name: "until utc date-time", rule: "FREQ=WEEKLY;UNTIL=20261231T183000Z", want: "FREQ=WEEKLY;UNTIL=20261231"},
+		{name: "until floating date-time", rule: "FREQ=WEEKLY;UNTIL=20261231T183000", want: "FREQ=WEEKLY;UNTIL=20261231"},
+		{name: "monthly ordinals", rule: "FREQ=MONTHLY;BYDAY=1MO,-1FR", want: "FREQ=MONTHLY;BYDAY=1MO,-1FR"},
+		{name: "last day of month", rule: "FREQ=MONTHLY;BYMONTHDAY=-1", want: "FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{name: "yearly by month", rule: "FREQ=YEARLY;BYMONTH=3,10;BYDAY=-1SU", want: "FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3,10"},
+		{name: "week start", rule: "FREQ=WEEKLY;INTERVAL=2;WKST=SU", want: "FREQ=WEEKLY;INTERVAL=2;WKST=SU"},
+
+		{name: "empty", rule: "", wantErr: "empty recurrence rule"},
+		{name: "prefix only", rule: "RRULE:", wantErr: "empty recurrence rule"},
+		{name: "missing freq", rule: "INTERVAL=2", wantErr: "FREQ is required"},
+		{name: "unsupported freq", rule: "FREQ=HOURLY", wantErr: "unsupported FREQ"},
+		{name: "part without value", rule: "FREQ=DAILY;COUNT=", wantErr: "invalid rule part"},
+		{name: "part without equals", rule: "FREQ=DAILY;COUNT", wantErr: "invalid rule part"},
+		{name: "zero interval", rule: "FREQ=DAILY;INTERVAL=0", wantErr: "invalid INTERVAL"},
+		{name: "negative count", rule: "FREQ=DAILY;COUNT=-1", wantErr: "invalid COUNT"},
+		{name: "invalid until", rule: "FREQ=DAILY;UNTIL=2026-12-31", wantErr: "invalid UNTIL"},
+		{name: "count and until", rule: "FREQ=DAILY;COUNT=3;UNTIL=20261231", wantErr: "must not be used together"},
+		{name: "invalid weekday", rule: "FREQ=WEEKLY;BYDAY=XX", wantErr: "invalid BYDAY"},
+		{name: "ordinal out of range", rule: "FREQ=MONTHLY;BYDAY=6MO", wantErr: "invalid BYDAY"},
+		{name: "zero ordinal", rule: "FREQ=MONTHLY;BYDAY=0MO", wantErr: "invalid BYDAY"},
+		{name: "ordinal in weekly rule", rule: "FREQ=WEEKLY;BYDAY=1MO", wantErr: "only supported for MONTHLY and YEARLY"},
+		{name: "zero month day", rule: "FREQ=MONTHLY;BYMONTHDAY=0", wantErr: "invalid BYMONTHDAY"},
+		{name: "month day out of range", rule: "FREQ=MONTHLY;BYMONTHDAY=-32", wantErr: "invalid BYMONTHDAY"},
+		{name: "month out of range", rule: "FREQ=YEARLY;BYMONTH=13", wantErr: "invalid BYMONTH"},
+		{name: "invalid week start", rule: "FREQ=WEEKLY;WKST=XX", wantErr: "invalid WKST"},
+		{name: "unsupported part", rule: "FREQ=DAILY;BYHOUR=10", wantErr: "unsupported rule part"},
+		{name: "yearly byday without bymonth", rule: "FREQ=YEARLY;BYDAY=1MO", wantErr: "requires BYMONTH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRecurrenceRule(tt.rule)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("ParseRecurrenceRule(%q) = %q, want error containing %q", tt.rule, rule.String(), tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseRecurrenceRule(%q) error = %q, want error containing %q", tt.rule, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRecurrenceRule(%q) error = %v", tt.rule, err)
+			}
+			if got := rule.String(); got != tt.want {
+				t.Errorf("ParseRecurrenceRule(%q).String() = %q, want %q", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceRuleStringWithUntil(t *testing.T) {
+	rule, err := ParseRecurrenceRule("FREQ=WEEKLY;UNTIL=20261231;BYDAY=TU")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := rule.StringWithUntil("20261231T183000Z"), "FREQ=WEEKLY;UNTIL=20261231T183000Z;BYDAY=TU"; got != want {
+		t.Errorf("StringWithUntil() = %q, want %q", got, want)
+	}
+	if got, want := rule.StringWithUntil(""), "FREQ=WEEKLY;BYDAY=TU"; got != want {
+		t.Errorf("StringWithUntil(\"\") = %q, want %q", got, want)
+	}
+}
+
+func TestRecurrenceRuleOccurrences(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtStart string
+		from    string
+		to      string
+		rDates  []string
+		exDates []string
+		want    string
+	}{
+		{
+			name:    "daily",
+			rule:    "FREQ=DAILY",
+			dtStart: "2026-01-30",
+			from:    "2026-01-30",
+			to:      "2026-02-02",
+			want:    "2026-01-30,2026-01-31,2026-02-01,2026-02-02",
+		},
+		{
+			name:    "daily interval",
+			rule:    "FREQ=DAILY;INTERVAL=3",
+			dtStart: "2026-01-01",
+			from:    "2026-01-01",
+			to:      "2026-01-10",
+			want:    "2026-01-01,2026-01-04,2026-01-07,2026-01-10",
+		},
+		{
+			name:    "weekly defaults to weekday of start",
+			rule:    "FREQ=WEEKLY",
+			dtStart: "2026-01-06", // Tuesday
+			from:    "2026-01-01",
+			to:      "2026-01-31",
+			want:    "2026-01-06,2026-01-13,2026-01-20,2026-01-27",
+		},
+		{
+			name:    "weekly by day skips days before start",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,TH",
+			dtStart: "2026-01-07", // Wednesday
+			from:    "2026-01-01",
+			to:      "2026-01-15",
+			want:    "2026-01-08,2026-01-12,2026-01-15",
+		},
+		{
+			name:    "biweekly with week start sunday",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=SU,MO;WKST=SU",
+			dtStart: "2026-01-04", // Sunday
+			from:    "2026-01-01",
+			to:      "2026-01-31",
+			want:    "2026-01-04,2026-01-05,2026-01-18,2026-01-19",
+		},
+		{
+			name:    "monthly first monday and last friday",
+			rule:    "FREQ=MONTHLY;BYDAY=1MO,-1FR",
+			dtStart: "2026-01-01",
+			from:    "2026-01-01",
+			to:      "2026-03-31",
+			want:    "2026-01-05,2026-01-30,2026-02-02,2026-02-27,2026-03-02,2026-03-27",
+		},
+		{
+			name:    "monthly fifth friday only in months having one",
+			rule:    "FREQ=MONTHLY;BYDAY=5FR",
+			dtStart: "2026-01-01",
+			from:    "2026-01-01",
+			to:      "2026-06-30",
+			want:    "2026-01-30,2026-05-29",
+		},
+		{
+			name:    "monthly last day of month",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtStart: "2028-01-31",
+			from:    "2028-01-01",
+			to:      "2028-04-30",
+			want:    "2028-01-31,2028-02-29,2028-03-31,2028-04-30",
+		},
+		{
+			name:    "monthly skips months without the day",
+			rule:    "FREQ=MONTHLY",
+			dtStart: "2026-01-31",
+			from:    "2026-01-01",
+			to:      "2026-05-31",
+			want:    "2026-01-31,2026-03-31,2026-05-31",
+		},
+		{
+			name:    "monthly friday the 13th",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=13;BYDAY=FR",
+			dtStart: "2026-01-01",
+			from:    "2026-01-01",
+			to:      "2026-12-31",
+			want:    "2026-02-13,2026-03-13,2026-11-13",
+		},
+		{
+			name:    "yearly last sunday of march and october",
+			rule:    "FREQ=YEARLY;BYMONTH=3,10;BYDAY=-1SU",
+			dtStart: "2026-01-01",
+			from:    "2026-01-01",
+			to:      "2027-12-31",
+			want:    "2026-03-29,2026-10-25,2027-03-28,2027-10-31",
+		},
+		{
+			name:    "yearly on start date",
+			rule:    "FREQ=YEARLY",
+			dtStart: "2026-07-15",
+			from:    "2026-01-01",
+			to:      "2028-12-31",
+			want:    "2026-07-15,2027-07-15,2028-07-15",
+		},
+		{
+			name:    "count",
+			rule:    "FREQ=WEEKLY;COUNT=3",
+			dtStart: "2026-01-05",
+			from:    "2026-01-01",
+			to:      "2026-12-31",
+			want:    "2026-01-05,2026-01-12,2026-01-19",
+		},
+		{
+			name:    "count is relative to start, not to the window",
+			rule:    "FREQ=WEEKLY;COUNT=3",
+			dtStart: "2026-01-05",
+			from:    "2026-01-10",
+			to:      "2026-12-31",
+			want:    "2026-01-12,2026-01-19",
+		},
+		{
+			name:    "count ends beyond the window",
+			rule:    "FREQ=DAILY;COUNT=10",
+			dtStart: "2026-01-01",
+			from:    "2026-01-01",
+			to:      "2026-01-03",
+			want:    "2026-01-01,2026-01-02,2026-01-03",
+		},
+		{
+			name:    "until is inclusive",
+			rule:    "FREQ=WEEKLY;UNTIL=20260119",
+			dtStart: "2026-01-05",
+			from:    "2026-01-01",
+			to:      "2026-12-31",
+			want:    "2026-01-05,2026-01-12,2026-01-19",
+		},
+		{
+			name:    "until date-time uses its date",
+			rule:    "FREQ=DAILY;UNTIL=20260103T120000Z",
+			dtStart: "2026-01-01",
+			from:    "2026-01-01",
+			to:      "2026-12-31",
+			want:    "2026-01-01,2026-01-02,2026-01-03",
+		},
+		{
+			name:    "until before start",
+			rule:    "FREQ=DAILY;UNTIL=20251231",
+			dtStart: "2026-01-01",
+			from:    "2025-01-01",
+			to:      "2026-12-31",
+			want:    "",
+		},
+		{
+			name:    "rdates and exdates",
+			rule:    "FREQ=WEEKLY",
+			dtStart: "2026-01-05",
+			from:    "2026-01-01",
+			to:      "2026-01-31",
+			rDates:  []string{"2026-01-07", "2026-01-12", "2026-02-20"},
+			exDates: []string{"2026-01-19"},
+			want:    "2026-01-05,2026-01-07,2026-01-12,2026-01-26",
+		},
+		{
+			name:    "exdates win over rdates",
+			rule:    "FREQ=WEEKLY;COUNT=1",
+			dtStart: "2026-01-05",
+			from:    "2026-01-01",
+			to:      "2026-01-31",
+			rDates:  []string{"2026-01-08"},
+			exDates: []string{"2026-01-05", "2026-01-08"},
+			want:    "",
+		},
+		{
+			name:    "daily across spring dst transition",
+			rule:    "FREQ=DAILY",
+			dtStart: "2026-03-28",
+			from:    "2026-03-28",
+			to:      "2026-03-31",
+			want:    "2026-03-28,2026-03-29,2026-03-30,2026-03-31",
+		},
+		{
+			name:    "weekly across autumn dst transition",
+			rule:    "FREQ=WEEKLY;BYDAY=SA,SU",
+			dtStart: "2026-10-24",
+			from:    "2026-10-24",
+			to:      "2026-11-01",
+			want:    "2026-10-24,2026-10-25,2026-10-31,2026-11-01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRecurrenceRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRecurrenceRule(%q) error = %v", tt.rule, err)
+			}
+
+			var rDates, exDates []time.Time
+			for _, d := range tt.rDates {
+				rDates = append(rDates, mustDate(t, d))
+			}
+			for _, d := range tt.exDates {
+				exDates = append(exDates, mustDate(t, d))
+			}
+
+			got := rule.Occurrences(mustDate(t, tt.dtStart), mustDate(t, tt.from), mustDate(t, tt.to), rDates, exDates)
+			if formatDates(got) != tt.want {
+				t.Errorf("Occurrences() = %q, want %q", formatDates(got), tt.want)
+			}
+		})
+	}
+}
+
+// Event dates are local, the expansion must neither skip nor repeat a day
+// when the start is given as a local time near midnight across DST changes.
+func TestRecurrenceRuleOccurrencesLocalTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtStart time.Time
+		to      time.Time
+		want    string
+	}{
+		{
+			name:    "daily late evening across spring transition",
+			rule:    "FREQ=DAILY",
+			dtStart: time.Date(2026, time.March, 28, 23, 30, 0, 0, berlin),
+			to:      time.Date(2026, time.March, 31, 23, 30, 0, 0, berlin),
+			want:    "2026-03-28,2026-03-29,2026-03-30,2026-03-31",
+		},
+		{
+			name:    "daily after midnight across autumn transition",
+			rule:    "FREQ=DAILY",
+			dtStart: time.Date(2026, time.October, 24, 0, 30, 0, 0, berlin),
+			to:      time.Date(2026, time.October, 27, 0, 30, 0, 0, berlin),
+			want:    "2026-10-24,2026-10-25,2026-10-26,2026-10-27",
+		},
+		{
+			name:    "monthly last sunday on transition days",
+			rule:    "FREQ=MONTHLY;BYDAY=-1SU",
+			dtStart: time.Date(2026, time.March, 1, 2, 30, 0, 0, berlin),
+			to:      time.Date(2026, time.October, 31, 0, 0, 0, 0, berlin),
+			want:    "2026-03-29,2026-04-26,2026-05-31,2026-06-28,2026-07-26,2026-08-30,2026-09-27,2026-10-25",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRecurrenceRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRecurrenceRule(%q) error = %v", tt.rule, err)
+			}
+
+			got := rule.Occurrences(tt.dtStart, tt.dtStart, tt.to, nil, nil)
+			if formatDates(got) != tt.want {
+				t.Errorf("Occurrences() = %q, want %q", formatDates(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceRuleMonthCandidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtStart string
+		year    int
+		month   time.Month
+		want    string
+	}{
+		{name: "day of start", rule: "FREQ=MONTHLY", dtStart: "2026-01-15", year: 2026, month: time.February, want: "2026-02-15"},
+		{name: "day of start missing", rule: "FREQ=MONTHLY", dtStart: "2026-01-30", year: 2026, month: time.February, want: ""},
+		{name: "last day in leap year", rule: "FREQ=MONTHLY;BYMONTHDAY=-1", dtStart: "2028-01-01", year: 2028, month: time.February, want: "2028-02-29"},
+		{name: "last day", rule: "FREQ=MONTHLY;BYMONTHDAY=-1", dtStart: "2026-01-01", year: 2026, month: time.February, want: "2026-02-28"},
+		{name: "second to last day", rule: "FREQ=MONTHLY;BYMONTHDAY=-2", dtStart: "2026-01-01", year: 2026, month: time.April, want: "2026-04-29"},
+		{name: "day 31 missing", rule: "FREQ=MONTHLY;BYMONTHDAY=31", dtStart: "2026-01-01", year: 2026, month: time.April, want: ""},
+		{name: "day -31 missing", rule: "FREQ=MONTHLY;BYMONTHDAY=-31", dtStart: "2026-01-01", year: 2026, month: time.February, want: ""},
+		{name: "all tuesdays", rule: "FREQ=MONTHLY;BYDAY=TU", dtStart: "2026-01-01", year: 2026, month: time.September, want: "2026-09-01,2026-09-08,2026-09-15,2026-09-22,2026-09-29"},
+		{name: "second wednesday", rule: "FREQ=MONTHLY;BYDAY=2WE", dtStart: "2026-01-01", year: 2026, month: time.September, want: "2026-09-09"},
+		{name: "last sunday", rule: "FREQ=MONTHLY;BYDAY=-1SU", dtStart: "2026-01-01", year: 2026, month: time.March, want: "2026-03-29"},
+		{name: "second to last monday", rule: "FREQ=MONTHLY;BYDAY=-2MO", dtStart: "2026-01-01", year: 2026, month: time.June, want: "2026-06-22"},
+		{name: "fifth monday missing", rule: "FREQ=MONTHLY;BYDAY=5MO", dtStart: "2026-01-01", year: 2026, month: time.February, want: ""},
+		{name: "month day filtered by weekday", rule: "FREQ=MONTHLY;BYMONTHDAY=1,15;BYDAY=TU", dtStart: "2026-01-01", year: 2026, month: time.September, want: "2026-09-01,2026-09-15"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRecurrenceRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRecurrenceRule(%q) error = %v", tt.rule, err)
+			}
+
+			got := rule.monthCandidates(mustDate(t, tt.dtStart), tt.year, tt.month)
+			if formatDates(got) != tt.want {
+				t.Errorf("monthCandidates() = %q, want %q", formatDates(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceRulePeriodCandidates(t *testing.T) {
+	tests := []struct {
+		name        string
+		rule        string
+		dtStart     string
+		periodStart string
+		want        string
+	}{
+		{name: "daily", rule: "FREQ=DAILY", dtStart: "2026-01-01", periodStart: "2026-01-05", want: "2026-01-05"},
+		{name: "daily filtered by month", rule: "FREQ=DAILY;BYMONTH=2", dtStart: "2026-01-01", periodStart: "2026-01-05", want: ""},
+		{name: "daily filtered by weekday", rule: "FREQ=DAILY;BYDAY=SA,SU", dtStart: "2026-01-01", periodStart: "2026-01-10", want: "2026-01-10"},
+		{name: "daily last day of month", rule: "FREQ=DAILY;BYMONTHDAY=-1", dtStart: "2026-01-01", periodStart: "2026-01-31", want: "2026-01-31"},
+		{name: "weekly without byday", rule: "FREQ=WEEKLY", dtStart: "2026-01-07", periodStart: "2026-01-12", want: "2026-01-14"},
+		{name: "weekly sorted by date", rule: "FREQ=WEEKLY;BYDAY=FR,MO,WE", dtStart: "2026-01-05", periodStart: "2026-01-12", want: "2026-01-12,2026-01-14,2026-01-16"},
+		{name: "weekly across month filtered by month", rule: "FREQ=WEEKLY;BYDAY=MO,SU;BYMONTH=2", dtStart: "2026-01-05", periodStart: "2026-01-26", want: "2026-02-01"},
+		{name: "monthly filtered by month", rule: "FREQ=MONTHLY;BYMONTH=3", dtStart: "2026-01-15", periodStart: "2026-02-01", want: ""},
+		{name: "monthly sorted by date", rule: "FREQ=MONTHLY;BYDAY=-1FR,1MO", dtStart: "2026-01-01", periodStart: "2026-02-01", want: "2026-02-02,2026-02-27"},
+		{name: "yearly month of start", rule: "FREQ=YEARLY", dtStart: "2026-07-15", periodStart: "2027-01-01", want: "2027-07-15"},
+		{name: "yearly leap day", rule: "FREQ=YEARLY", dtStart: "2028-02-29", periodStart: "2029-01-01", want: ""},
+		{name: "yearly months sorted by date", rule: "FREQ=YEARLY;BYMONTH=10,3;BYDAY=-1SU", dtStart: "2026-01-01", periodStart: "2026-01-01", want: "2026-03-29,2026-10-25"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRecurrenceRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRecurrenceRule(%q) error = %v", tt.rule, err)
+			}
+
+			got := rule.periodCandidates(mustDate(t, tt.dtStart), mustDate(t, tt.periodStart))
+			if formatDates(got) != tt.want {
+				t.Errorf("periodCandidates() = %q, want %q", formatDates(got), tt.want)
+			}
+		})
+	}
+}
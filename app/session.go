@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Interval in which the last seen time of a session is updated, requests in
+// between only read the session
+const sessionTouchInterval = 5 * time.Minute
+
+// Account types of users. Members work in organizations, visitors only
+// keep a personal agenda and have no access to the admin API.
+const (
+	AccountTypeMember  = "member"
+	AccountTypeVisitor = "visitor"
+)
+
+// sessionActive reports whether the session of an access token is neither
+// revoked nor expired and its user is still active, and returns the account
+// type of the user. The last seen time and client IP of the session are
+// updated on the way, at most once per sessionTouchInterval.
+func sessionActive(gc *gin.Context, userUuid string, sessionUuid string) (string, bool) {
+	if sessionUuid == "" || UranusInstance.MainDbPool == nil {
+		return "", false
+	}
+
+	ctx := gc.Request.Context()
+	schema := UranusInstance.Config.DbSchema
+
+	query := fmt.Sprintf(`
+		SELECT s.last_seen_at, u.account_type
+		FROM %[1]s.user_session s
+		JOIN %[1]s.user u ON u.uuid = s.user_uuid
+		WHERE s.uuid = $1::uuid
+			AND s.user_uuid = $2::uuid
+			AND s.revoked_at IS NULL
+			AND s.expires_at > NOW()
+			AND u.is_active`,
+		schema)
+	var lastSeenAt time.Time
+	var accountType string
+	err := UranusInstance.MainDbPool.QueryRow(ctx, query, sessionUuid, userUuid).Scan(&lastSeenAt, &accountType)
+	if err != nil {
+		return "", false
+	}
+
+	// Concurrent requests of a session check the interval again in SQL, so
+	// only one of them writes
+	if time.Since(lastSeenAt) > sessionTouchInterval {
+		query = fmt.Sprintf(`
+			UPDATE %s.user_session
+			SET last_seen_at = NOW(), client_ip = $2
+			WHERE uuid = $1::uuid AND last_seen_at < NOW() - make_interval(secs => $3)`,
+			schema)
+		_, err = UranusInstance.MainDbPool.Exec(ctx, query, sessionUuid, gc.ClientIP(), sessionTouchInterval.Seconds())
+		if err != nil {
+			UranusInstance.Log("touch session failed: " + err.Error())
+		}
+	}
+
+	return accountType, true
+}
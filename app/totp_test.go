@@ -0,0 +1,101 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+// Secret of the SHA1 test vectors of RFC 6238 Appendix B, the ASCII string
+// "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCodeRfc6238(t *testing.T) {
+	// The RFC lists 8 digit codes, the last 6 digits are the 6 digit codes
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		step := TotpStep(time.Unix(tt.unix, 0))
+		got, err := TotpCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("%d: got %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTotpCodeLowerCaseSecret(t *testing.T) {
+	got, err := TotpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "287082" {
+		t.Errorf("got %s, want 287082", got)
+	}
+}
+
+func TestTotpCodeInvalidSecret(t *testing.T) {
+	if _, err := TotpCode("not base32!", 1); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestVerifyTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TotpStep(now)
+
+	code := func(step int64) string {
+		t.Helper()
+		c, err := TotpCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		code      string
+		afterStep int64
+		wantStep  int64
+		wantOk    bool
+	}{
+		{name: "current step", code: code(current), wantStep: current, wantOk: true},
+		{name: "spaces are ignored", code: " " + code(current)[:3] + " " + code(current)[3:] + " ", wantStep: current, wantOk: true},
+		{name: "previous step within skew", code: code(current - 1), wantStep: current - 1, wantOk: true},
+		{name: "next step within skew", code: code(current + 1), wantStep: current + 1, wantOk: true},
+		{name: "too old", code: code(current - 2)},
+		{name: "too new", code: code(current + 2)},
+		{name: "wrong code", code: "000000"},
+		{name: "too short", code: code(current)[:5]},
+		{name: "too long", code: code(current) + "0"},
+		{name: "empty", code: ""},
+
+		{name: "replay of the last used step", code: code(current), afterStep: current},
+		{name: "replay of an earlier step", code: code(current - 1), afterStep: current - 1},
+		{name: "later step after use", code: code(current + 1), afterStep: current, wantStep: current + 1, wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTotp(rfc6238Secret, tt.code, now, tt.afterStep)
+			if ok != tt.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOk)
+			}
+			if ok && step != tt.wantStep {
+				t.Errorf("got step %d, want %d", step, tt.wantStep)
+			}
+		})
+	}
+}
@@ -33,6 +33,8 @@ type Uranus struct {
 	SqlGetEventDateICS                         string
 	SqlGetEventDates                           string
 	SqlGetEventsProjected                      string
+	SqlGetEventsICS                            string
+	SqlGetAgendaICS                            string
 	SqlGetEventsProjectedWeek                  string
 	SqlGetEventsGeoJSON                        string
 	SqlGetPortal                               string
@@ -58,8 +60,17 @@ type Uranus struct {
 	SqlAdminGetEventLinks                      string
 	SqlAdminGetEventDates                      string
 	SqlAdminInsertEventDate                    string
+	SqlAdminInsertEvent                        string
+	SqlSyncUpdateEvent                         string
+	SqlAdminGetOrgScheduledTransitions         string
+	SqlWebhookEnqueue                          string
+	SqlPushEnqueue                             string
 	SqlAdminGetPortal                          string
 	SqlAdminUpdateEventDate                    string
+	SqlAdminGetEventRecurrence                 string
+	SqlAdminUpsertEventRecurrence              string
+	SqlAdminInsertRecurrenceEventDate          string
+	SqlAdminUpdateRecurrenceEventDate          string
 	SqlEventTypeGenreLookup                    string
 	SqlChoosableOrgVenues                      string
 	SqlChoosableVenueSpaces                    string
@@ -86,8 +97,8 @@ type Uranus struct {
 	SqlGetSystemEmailTemplate                  string
 	SqlAdminGetPermissionList                  string
 	SqlQueryUserOrgEventsOverview              string
+	SqlNotificationEventChecks                 string
 	SqlAdminGetUserEventNotifications          string
-	SqlAdminGetUserPartnerNotifications        string
 	SqlAdminChoosableOrgs                      string
 	SqlAdminChoosableUserEventVenues           string
 	SqlAdminEvent                              string
@@ -271,6 +282,8 @@ func (app *Uranus) PrepareSql() error {
 		{"sql/get-event-dates.sql", &app.SqlGetEventDates, nil},
 		{"sql/get-event-date-ics.sql", &app.SqlGetEventDateICS, nil},
 		{"sql/get-events-projected.sql", &app.SqlGetEventsProjected, nil},
+		{"sql/get-events-ics.sql", &app.SqlGetEventsICS, nil},
+		{"sql/get-agenda-ics.sql", &app.SqlGetAgendaICS, nil},
 		{"sql/get-events-projected-week.sql", &app.SqlGetEventsProjectedWeek, nil},
 		{"sql/get-events-geojson.sql", &app.SqlGetEventsGeoJSON, nil},
 
@@ -329,11 +342,21 @@ func (app *Uranus) PrepareSql() error {
 
 		{"sql/admin-update-event-date.sql", &app.SqlAdminUpdateEventDate, nil},
 		{"sql/admin-insert-event-date.sql", &app.SqlAdminInsertEventDate, nil},
+		{"sql/admin-insert-event.sql", &app.SqlAdminInsertEvent, nil},
+		{"sql/sync-update-event.sql", &app.SqlSyncUpdateEvent, nil},
+		{"sql/admin-get-org-scheduled-transitions.sql", &app.SqlAdminGetOrgScheduledTransitions, nil},
+		{"sql/webhook-enqueue.sql", &app.SqlWebhookEnqueue, nil},
+		{"sql/push-enqueue.sql", &app.SqlPushEnqueue, nil},
+
+		{"sql/admin-get-event-recurrence.sql", &app.SqlAdminGetEventRecurrence, nil},
+		{"sql/admin-upsert-event-recurrence.sql", &app.SqlAdminUpsertEventRecurrence, nil},
+		{"sql/admin-insert-recurrence-event-date.sql", &app.SqlAdminInsertRecurrenceEventDate, nil},
+		{"sql/admin-update-recurrence-event-date.sql", &app.SqlAdminUpdateRecurrenceEventDate, nil},
 
 		{"sql/admin-get-portal.sql", &app.SqlAdminGetPortal, nil},
 
+		{"sql/notification-event-checks.sql", &app.SqlNotificationEventChecks, nil},
 		{"sql/admin-get-user-event-notifications.sql", &app.SqlAdminGetUserEventNotifications, nil},
-		{"sql/admin-get-user-partner-notifications.sql", &app.SqlAdminGetUserPartnerNotifications, nil},
 
 		{"sql/admin-user-spaces-for-event.sql", &app.SqlAdminSpacesForEvent, nil},
 
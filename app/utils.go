@@ -8,7 +8,11 @@ import (
 
 // Claims struct for JWT
 type Claims struct {
-	UserUuid string `json:"user_uuid"`
+	UserUuid    string `json:"user_uuid"`
+	SessionUuid string `json:"sid,omitempty"`
+	// Purpose is set for tokens which are no access tokens, e.g. the interim
+	// token of a login waiting for the second factor
+	Purpose string `json:"pur,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// AgendaItem is an event date saved in the personal agenda of a user.
+// ReleaseStatus is the status visitors see, the own status of the date or
+// the status of the event. RemindBeforeHours 0 means no reminder.
+type AgendaItem struct {
+	EventDateUuid     string     `json:"event_date_uuid"`
+	EventUuid         string     `json:"event_uuid"`
+	Title             *string    `json:"title"`
+	StartDate         *string    `json:"start_date"`
+	StartTime         *string    `json:"start_time"`
+	EndDate           *string    `json:"end_date"`
+	EndTime           *string    `json:"end_time"`
+	VenueName         *string    `json:"venue_name"`
+	VenueCity         *string    `json:"venue_city"`
+	ReleaseStatus     *string    `json:"release_status"`
+	StatusReason      *string    `json:"status_reason"`
+	RescheduledToUuid *string    `json:"rescheduled_to_uuid"`
+	RemindBeforeHours int        `json:"remind_before_hours"`
+	RemindedAt        *time.Time `json:"reminded_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditLogEntry struct {
+	Id              int64           `json:"id"`
+	ActorUserUuid   *string         `json:"actor_user_uuid"`
+	ActorApiKeyUuid *string         `json:"actor_api_key_uuid"`
+	ActorName       *string         `json:"actor_name"`
+	EntityType      string          `json:"entity_type"`
+	EntityUuid      *string         `json:"entity_uuid"`
+	Action          string          `json:"action"`
+	Before          json.RawMessage `json:"before"`
+	After           json.RawMessage `json:"after"`
+	ClientIp        *string         `json:"client_ip"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
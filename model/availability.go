@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// AvailabilityRule holds the thresholds of an organization from which few
+// seats of an event date are left: FewLeftPercent of the capacity or
+// FewLeftSeats, whichever is higher.
+type AvailabilityRule struct {
+	FewLeftPercent int `json:"few_left_percent"`
+	FewLeftSeats   int `json:"few_left_seats"`
+}
+
+// EventDateTicketCount is the latest ticket count of an event date pushed by
+// an external ticketing system. Capacity is optional, Waitlist tells whether
+// visitors can join a waiting list when no tickets are left.
+type EventDateTicketCount struct {
+	EventDateUuid        string    `json:"event_date_uuid"`
+	Available            int       `json:"available"`
+	Capacity             *int      `json:"capacity"`
+	Waitlist             bool      `json:"waitlist"`
+	Source               *string   `json:"source"`
+	ReportedAt           time.Time `json:"reported_at"`
+	AvailabilityStatusId *int      `json:"availability_status_id"`
+}
+
+// EventDateTicketCountPayload pushes the ticket count of an event date.
+type EventDateTicketCountPayload struct {
+	Available *int    `json:"available" binding:"required"`
+	Capacity  *int    `json:"capacity"`
+	Waitlist  bool    `json:"waitlist"`
+	Source    *string `json:"source"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// EmailMessage is a queued mail without its bodies, which may contain tokens.
+type EmailMessage struct {
+	Id              int64      `json:"id"`
+	MessageId       string     `json:"message_id"`
+	ToEmail         string     `json:"to_email"`
+	Subject         string     `json:"subject"`
+	TemplateContext *string    `json:"template_context"`
+	Lang            *string    `json:"lang"`
+	UserUuid        *string    `json:"user_uuid"`
+	OrgUuid         *string    `json:"org_uuid"`
+	Status          string     `json:"status"`
+	AttemptCount    int        `json:"attempt_count"`
+	NextAttemptAt   *time.Time `json:"next_attempt_at"`
+	LastAttemptAt   *time.Time `json:"last_attempt_at"`
+	Error           *string    `json:"error"`
+	CreatedAt       time.Time  `json:"created_at"`
+	SentAt          *time.Time `json:"sent_at"`
+	BouncedAt       *time.Time `json:"bounced_at"`
+}
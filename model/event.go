@@ -1,7 +1,5 @@
 package model
 
-import "time"
-
 type EventTicketFlag string
 
 const (
@@ -46,6 +44,32 @@ type EventDatePayload struct {
 	Custom               *string `json:"custom,omitempty"`
 }
 
+// EventRecurrencePayload describes a recurring series of event dates.
+// Rule is an RFC 5545 RRULE value (e.g. "FREQ=WEEKLY;BYDAY=TU"), RDates and
+// ExDates are additional and excluded dates (YYYY-MM-DD). StartDate is the
+// first date of the series (DTSTART), the times apply to all dates.
+type EventRecurrencePayload struct {
+	Rule      string   `json:"rrule" binding:"required"`
+	RDates    []string `json:"rdates,omitempty"`
+	ExDates   []string `json:"exdates,omitempty"`
+	StartDate string   `json:"start_date" binding:"required"`
+	StartTime string   `json:"start_time" binding:"required"`
+	EndTime   *string  `json:"end_time,omitempty"`
+	EntryTime *string  `json:"entry_time,omitempty"`
+	AllDay    *bool    `json:"all_day,omitempty"`
+	Duration  *int     `json:"duration,omitempty"`
+	VenueUuid *string  `json:"venue_uuid,omitempty"`
+	SpaceUuid *string  `json:"space_uuid,omitempty"`
+}
+
+// EventRecurrence is a stored recurrence rule of an event.
+// MaterializedUntil is the last date up to which event dates were generated.
+type EventRecurrence struct {
+	EventUuid string `json:"event_uuid"`
+	EventRecurrencePayload
+	MaterializedUntil *string `json:"materialized_until,omitempty"`
+}
+
 type EventType struct {
 	Type      int     `json:"type_id"`
 	TypeName  *string `json:"type_name,omitempty"`
@@ -96,6 +120,10 @@ type EventDate struct {
 	AccessibilitySummary *string         `json:"accessibility_summary,omitempty"`
 	AccessibilityInfo    *string         `json:"accessibility_info,omitempty"`
 	AccessibilityLabels  []string        `json:"accessibility_labels,omitempty"`
+	StatusReason         *string         `json:"status_reason,omitempty"`
+	RescheduledToUuid    *string         `json:"rescheduled_to_uuid,omitempty"`
+	RescheduledFromUuid  *string         `json:"rescheduled_from_uuid,omitempty"`
+	AvailabilityStatusId *int            `json:"availability_status_id,omitempty"`
 }
 
 type EventDetails struct {
@@ -137,6 +165,7 @@ type EventDetails struct {
 	RegistrationPhone    *string          `json:"registration_phone,omitempty"`
 	RegistrationDeadline *string          `json:"registration_deadline,omitempty"`
 	LogoMode             int              `json:"logo_mode,omitempty"`
+	JsonLD               map[string]any   `json:"json_ld,omitempty"`
 }
 
 type AdminEvent struct {
@@ -202,6 +231,8 @@ type AdminEventDate struct {
 	Uuid                 string   `json:"uuid"`
 	EventUuid            string   `json:"event_uuid"`
 	ReleaseStatus        *string  `json:"release_status"`
+	StatusReason         *string  `json:"status_reason,omitempty"`
+	RescheduledToUuid    *string  `json:"rescheduled_to_uuid,omitempty"`
 	StartDate            *string  `json:"start_date,omitempty"`
 	StartTime            *string  `json:"start_time,omitempty"`
 	EndDate              *string  `json:"end_date,omitempty"`
@@ -262,30 +293,3 @@ type AdminListEvent struct {
 	UpcomingDatesCount   int         `json:"upcoming_dates_count,omitempty"`
 	NextDate             *string     `json:"next_date,omitempty"`
 }
-
-// UserEventNotification contains a single event notification
-type UserEventNotification struct {
-	// Event core
-	EventUuid  string `db:"uuid" json:"event_uuid"`
-	EventTitle string `db:"title" json:"event_title"`
-	OrgUuid    string `db:"org_uuid" json:"org_uuid"`
-	OrgName    string `db:"org_name" json:"org_name"`
-
-	// Venue (nullable)
-	VenueUuid *string `db:"venue_uuid" json:"venue_uuid"`
-	VenueName *string `db:"venue_name" json:"venue_name"`
-	VenueCity *string `db:"venue_city" json:"venue_city"`
-
-	// Release / schedule
-	ReleaseStatus      *string    `db:"release_status" json:"release_status"`
-	FirstDate          *time.Time `db:"first_date" json:"first_date"`
-	DaysUntilFirstDate *int       `db:"days_until_first_date" json:"days_until_first_date"`
-
-	// QA flags
-	NoImage             bool `db:"no_image" json:"no_image"`
-	NoEventDates        bool `db:"no_event_dates" json:"no_event_dates"`
-	NoVenueOrOnlineLink bool `db:"no_venue_or_online_link" json:"no_venue_or_online_link"`
-	NoEventType         bool `db:"no_event_type" json:"no_event_type"`
-	NoTitle             bool `db:"no_title" json:"no_title"`
-	NoUpcomingDate      bool `db:"no_upcoming_date" json:"no_upcoming_date"`
-}
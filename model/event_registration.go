@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventRegistrationConfig holds the registration settings of an event,
+// valid for all of its dates.
+type EventRegistrationConfig struct {
+	EventUuid               string                 `json:"event_uuid"`
+	Enabled                 bool                   `json:"enabled"`
+	Questions               []RegistrationQuestion `json:"questions"`
+	WaitlistEnabled         bool                   `json:"waitlist_enabled"`
+	MaxSeatsPerRegistration int                    `json:"max_seats_per_registration"`
+}
+
+// RegistrationQuestion is a custom question of a registration form. Type is
+// "text", "textarea", "checkbox" or "select", selects offer Options.
+type RegistrationQuestion struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// EventDateRegistrationState is the registration state of an event date as
+// shown to visitors. Capacity and SeatsLeft are nil without a limit.
+type EventDateRegistrationState struct {
+	EventUuid               string                 `json:"event_uuid"`
+	EventDateUuid           string                 `json:"event_date_uuid"`
+	Open                    bool                   `json:"open"`
+	Questions               []RegistrationQuestion `json:"questions"`
+	WaitlistEnabled         bool                   `json:"waitlist_enabled"`
+	MaxSeatsPerRegistration int                    `json:"max_seats_per_registration"`
+	Capacity                *int                   `json:"capacity"`
+	SeatsLeft               *int                   `json:"seats_left"`
+	WaitlistCount           int                    `json:"waitlist_count"`
+}
+
+// EventRegistration is a registration for an event date as listed for the
+// organization.
+type EventRegistration struct {
+	Uuid         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	Email        string          `json:"email"`
+	Seats        int             `json:"seats"`
+	Answers      json.RawMessage `json:"answers"`
+	Lang         string          `json:"lang"`
+	Status       string          `json:"status"`
+	CreatedAt    time.Time       `json:"created_at"`
+	ConfirmedAt  *time.Time      `json:"confirmed_at"`
+	WaitlistedAt *time.Time      `json:"waitlisted_at"`
+	PromotedAt   *time.Time      `json:"promoted_at"`
+	CancelledAt  *time.Time      `json:"cancelled_at"`
+	CancelledBy  *string         `json:"cancelled_by"`
+}
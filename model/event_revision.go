@@ -0,0 +1,24 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventRevisionChange is the change of a single field between two revisions
+type EventRevisionChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+type EventRevision struct {
+	Revision      int                            `json:"revision"`
+	Action        string                         `json:"action"`
+	UserUuid      *string                        `json:"user_uuid"`
+	UserName      *string                        `json:"user_name"`
+	RestoredFrom  *int                           `json:"restored_from,omitempty"`
+	CreatedAt     time.Time                      `json:"created_at"`
+	ChangedFields []string                       `json:"changed_fields"`
+	Diff          map[string]EventRevisionChange `json:"diff,omitempty"`
+	Snapshot      json.RawMessage                `json:"snapshot,omitempty"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Notification struct {
+	Uuid       string          `json:"uuid"`
+	Type       string          `json:"type"`
+	OrgUuid    *string         `json:"org_uuid"`
+	EntityType *string         `json:"entity_type"`
+	EntityUuid *string         `json:"entity_uuid"`
+	Title      string          `json:"title"`
+	Body       string          `json:"body"`
+	Link       *string         `json:"link"`
+	Data       json.RawMessage `json:"data"`
+	Channel    string          `json:"channel"`
+	CreatedBy  *string         `json:"created_by"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ReadAt     *time.Time      `json:"read_at"`
+	EmailedAt  *time.Time      `json:"emailed_at"`
+}
+
+type NotificationPreference struct {
+	Type           string `json:"type"`
+	Channel        string `json:"channel"`
+	DefaultChannel string `json:"default_channel"`
+	Mandatory      bool   `json:"mandatory"`
+}
+
+// UserEventNotification contains the event notifications of an event in the
+// shape of the deprecated dashboard endpoint.
+type UserEventNotification struct {
+	// Event core
+	EventUuid  string `db:"uuid" json:"event_uuid"`
+	EventTitle string `db:"title" json:"event_title"`
+	OrgUuid    string `db:"org_uuid" json:"org_uuid"`
+	OrgName    string `db:"org_name" json:"org_name"`
+
+	// Venue (nullable)
+	VenueUuid *string `db:"venue_uuid" json:"venue_uuid"`
+	VenueName *string `db:"venue_name" json:"venue_name"`
+	VenueCity *string `db:"venue_city" json:"venue_city"`
+
+	// Release / schedule
+	ReleaseStatus      *string    `db:"release_status" json:"release_status"`
+	FirstDate          *time.Time `db:"first_date" json:"first_date"`
+	DaysUntilFirstDate *int       `db:"days_until_first_date" json:"days_until_first_date"`
+
+	// QA flags
+	NoImage             bool `db:"no_image" json:"no_image"`
+	NoEventDates        bool `db:"no_event_dates" json:"no_event_dates"`
+	NoVenueOrOnlineLink bool `db:"no_venue_or_online_link" json:"no_venue_or_online_link"`
+	NoEventType         bool `db:"no_event_type" json:"no_event_type"`
+	NoTitle             bool `db:"no_title" json:"no_title"`
+	NoUpcomingDate      bool `db:"no_upcoming_date" json:"no_upcoming_date"`
+}
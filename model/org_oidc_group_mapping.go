@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type OrgOidcGroupMapping struct {
+	Uuid        string    `json:"uuid"`
+	Provider    string    `json:"provider"`
+	GroupName   string    `json:"group_name"`
+	Permissions int64     `json:"permissions"`
+	CreatedBy   *string   `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
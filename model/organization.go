@@ -5,13 +5,16 @@ import (
 )
 
 type OrgMember struct {
-	UserUuid     string     `json:"user_uuid"`
-	Email        string     `json:"email"`
-	Username     *string    `json:"username"`
-	DisplayName  *string    `json:"display_name"`
-	AvatarUrl    *string    `json:"avatar_url"`
-	LastActiveAt *time.Time `json:"last_active_at"`
-	JoinedAt     time.Time  `json:"joined_at"`
+	UserUuid         string     `json:"user_uuid"`
+	Email            string     `json:"email"`
+	Username         *string    `json:"username"`
+	DisplayName      *string    `json:"display_name"`
+	AvatarUrl        *string    `json:"avatar_url"`
+	LastActiveAt     *time.Time `json:"last_active_at"`
+	JoinedAt         time.Time  `json:"joined_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	RoleUuid         *string    `json:"role_uuid"`
+	RoleName         *string    `json:"role_name"`
 }
 
 type InvitedOrgMember struct {
@@ -24,9 +27,13 @@ type InvitedOrgMember struct {
 }
 
 type OrgMemberRole struct {
-	Uuid        string `json:"uuid"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Uuid        string    `json:"uuid"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions int64     `json:"permissions"`
+	MemberCount int64     `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	ModifiedAt  time.Time `json:"modified_at"`
 }
 
 type OrgMemberLink struct {
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// PushSubscription is the Web Push subscription of a browser without its
+// keys. QuietStart and QuietEnd ("15:04") are in TimeZone.
+type PushSubscription struct {
+	Uuid          string       `json:"uuid"`
+	Endpoint      string       `json:"endpoint"`
+	QuietStart    *string      `json:"quiet_start"`
+	QuietEnd      *string      `json:"quiet_end"`
+	TimeZone      string       `json:"time_zone"`
+	CreatedAt     time.Time    `json:"created_at"`
+	ModifiedAt    time.Time    `json:"modified_at"`
+	LastSuccessAt *time.Time   `json:"last_success_at"`
+	Follows       []PushFollow `json:"follows"`
+}
+
+// PushFollow is an organization, venue, event type or portal followed by a
+// push subscription. TargetName is the name in the requested language.
+type PushFollow struct {
+	TargetType string    `json:"target_type"`
+	TargetId   string    `json:"target_id"`
+	TargetName *string   `json:"target_name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,89 @@
+package model
+
+import "time"
+
+// EventPriceTier is a price of an event. EventDateUuid limits it to a single
+// date, Quota limits the tickets per date, nil means unlimited.
+type EventPriceTier struct {
+	Uuid          string    `json:"uuid"`
+	EventUuid     string    `json:"event_uuid"`
+	EventDateUuid *string   `json:"event_date_uuid"`
+	Name          string    `json:"name"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	Quota         *int      `json:"quota"`
+	SortOrder     int       `json:"sort_order"`
+	CreatedAt     time.Time `json:"created_at"`
+	ModifiedAt    time.Time `json:"modified_at"`
+}
+
+// EventPriceTierPayload creates or changes a price tier.
+type EventPriceTierPayload struct {
+	EventDateUuid *string `json:"event_date_uuid"`
+	Name          string  `json:"name" binding:"required"`
+	Amount        float64 `json:"amount"`
+	Currency      *string `json:"currency"`
+	Quota         *int    `json:"quota"`
+	SortOrder     int     `json:"sort_order"`
+}
+
+// EventDateTicketTier is a price tier as offered for an event date.
+// Remaining is nil without quota.
+type EventDateTicketTier struct {
+	Uuid      string  `json:"uuid"`
+	Name      string  `json:"name"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	Payment   string  `json:"payment"`
+	Quota     *int    `json:"quota"`
+	Sold      int     `json:"sold"`
+	Remaining *int    `json:"remaining"`
+}
+
+// EventTicket is an issued ticket. Code is the signed content of its QR
+// code.
+type EventTicket struct {
+	Uuid            string     `json:"uuid"`
+	OrderUuid       string     `json:"order_uuid"`
+	EventDateUuid   string     `json:"event_date_uuid"`
+	PriceTierUuid   string     `json:"price_tier_uuid"`
+	PriceTierName   string     `json:"price_tier_name"`
+	HolderName      string     `json:"holder_name"`
+	HolderEmail     *string    `json:"holder_email,omitempty"`
+	Amount          float64    `json:"amount"`
+	Currency        string     `json:"currency"`
+	Payment         string     `json:"payment"`
+	Status          string     `json:"status"`
+	IssuedAt        time.Time  `json:"issued_at"`
+	CheckedInAt     *time.Time `json:"checked_in_at,omitempty"`
+	CheckedInDevice *string    `json:"checked_in_device,omitempty"`
+	CancelledAt     *time.Time `json:"cancelled_at,omitempty"`
+	Code            string     `json:"code,omitempty"`
+}
+
+// TicketScan is a scanned ticket code, ScannedAt is the time of the scan on
+// the device, the time of the request if missing.
+type TicketScan struct {
+	Code      string     `json:"code" binding:"required"`
+	ScannedAt *time.Time `json:"scanned_at"`
+	DeviceId  string     `json:"device_id"`
+}
+
+// TicketScanResult is the result of a scan. Result is "checked_in",
+// "already_used", "cancelled", "wrong_date" or "invalid". Conflict marks
+// tickets which were checked in before by another scan, Replayed scans
+// which were already synced.
+type TicketScanResult struct {
+	Code            string     `json:"code"`
+	TicketUuid      *string    `json:"ticket_uuid"`
+	Result          string     `json:"result"`
+	Conflict        bool       `json:"conflict"`
+	Replayed        bool       `json:"replayed"`
+	HolderName      *string    `json:"holder_name,omitempty"`
+	PriceTierName   *string    `json:"price_tier_name,omitempty"`
+	Payment         *string    `json:"payment,omitempty"`
+	Amount          *float64   `json:"amount,omitempty"`
+	Currency        *string    `json:"currency,omitempty"`
+	CheckedInAt     *time.Time `json:"checked_in_at,omitempty"`
+	CheckedInDevice *string    `json:"checked_in_device,omitempty"`
+}
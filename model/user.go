@@ -11,6 +11,7 @@ type User struct {
 	Locale       *string `json:"locale"`
 	Theme        *string `json:"theme"`
 	IsActive     bool    `json:"is_active"`
+	AccountType  string  `json:"account_type"`
 	AvatarUrl    *string `json:"avatar_url"`
 }
 
@@ -28,5 +29,6 @@ type UserProfileResponse struct {
 	LastName    *string `json:"last_name"`
 	Locale      *string `json:"locale"`
 	Theme       *string `json:"theme"`
+	AccountType string  `json:"account_type"`
 	AvatarUrl   *string `json:"avatar_url"`
 }
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+type UserSession struct {
+	Uuid       string    `json:"uuid"`
+	Device     string    `json:"device"`
+	UserAgent  *string   `json:"user_agent"`
+	ClientIp   *string   `json:"client_ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+type UserTwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at"`
+	SetupPending      bool       `json:"setup_pending"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	RequiredByOrgs    []string   `json:"required_by_orgs"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookSubscription struct {
+	Uuid        string    `json:"uuid"`
+	OrgUuid     string    `json:"org_uuid"`
+	PortalUuid  *string   `json:"portal_uuid"`
+	Url         string    `json:"url"`
+	EventTypes  []string  `json:"event_types"`
+	Description *string   `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	ModifiedAt  time.Time `json:"modified_at"`
+}
+
+type WebhookDelivery struct {
+	Id             int64           `json:"id"`
+	EventType      string          `json:"event_type"`
+	EntityUuid     *string         `json:"entity_uuid"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	AttemptCount   int             `json:"attempt_count"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	ResponseBody   *string         `json:"response_body"`
+	Error          *string         `json:"error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
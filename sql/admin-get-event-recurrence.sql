@@ -0,0 +1,16 @@
+SELECT
+    er.event_uuid,
+    er.rrule,
+    ARRAY(SELECT TO_CHAR(d, 'YYYY-MM-DD') FROM unnest(er.rdates) AS d ORDER BY d) AS rdates,
+    ARRAY(SELECT TO_CHAR(d, 'YYYY-MM-DD') FROM unnest(er.exdates) AS d ORDER BY d) AS exdates,
+    TO_CHAR(er.start_date, 'YYYY-MM-DD') AS start_date,
+    TO_CHAR(er.start_time, 'HH24:MI') AS start_time,
+    TO_CHAR(er.end_time, 'HH24:MI') AS end_time,
+    TO_CHAR(er.entry_time, 'HH24:MI') AS entry_time,
+    er.all_day,
+    er.duration,
+    er.venue_uuid,
+    er.space_uuid,
+    TO_CHAR(er.materialized_until, 'YYYY-MM-DD') AS materialized_until
+FROM {{schema}}.event_recurrence er
+WHERE er.event_uuid = $1::uuid
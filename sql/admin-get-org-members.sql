@@ -4,8 +4,18 @@ SELECT
     u.username,
     COALESCE(u.display_name, u.first_name || ' ' || u.last_name, u.email) AS display_name,
     u.modified_at AS last_active_at,
-    oml.created_at AS joined_at
+    oml.created_at AS joined_at,
+    EXISTS (
+        SELECT 1
+        FROM {{schema}}.user_totp t
+        WHERE t.user_uuid = u.uuid
+        AND t.enabled_at IS NOT NULL
+    ) AS two_factor_enabled,
+    uol.role_uuid::text AS role_uuid,
+    r.name AS role_name
 FROM {{schema}}.organization_member_link oml
 JOIN {{schema}}.user u ON u.uuid = oml.user_uuid
+LEFT JOIN {{schema}}.user_organization_link uol ON uol.org_uuid = oml.org_uuid AND uol.user_uuid = oml.user_uuid
+LEFT JOIN {{schema}}.org_member_role r ON r.uuid = uol.role_uuid
 WHERE oml.org_uuid = $1 AND oml.has_joined = TRUE
 ORDER BY display_name
\ No newline at end of file
@@ -0,0 +1,48 @@
+SELECT
+    t.event_uuid,
+    t.title,
+    t.release_status,
+    t.transition,
+    t.target_status,
+    TO_CHAR(t.due_date, 'YYYY-MM-DD') AS due_date,
+    t.due_date <= CURRENT_DATE AS overdue
+FROM (
+    -- Scheduled events are released at their release date
+    SELECT
+        e.uuid AS event_uuid,
+        e.title,
+        e.release_status,
+        'release' AS transition,
+        'released' AS target_status,
+        e.release_date AS due_date
+    FROM {{schema}}.event e
+    WHERE e.org_uuid = $1::uuid
+        AND e.release_status = 'scheduled'
+        AND e.release_date IS NOT NULL
+
+    UNION ALL
+
+    -- Released events are archived the day after their last date
+    SELECT
+        e.uuid,
+        e.title,
+        e.release_status,
+        'archive',
+        'archived',
+        (dates.last_date + 1)::date
+    FROM {{schema}}.event e
+    JOIN LATERAL (
+        SELECT MAX(COALESCE(ed.end_date, ed.start_date)) AS last_date
+        FROM {{schema}}.event_date ed
+        WHERE ed.event_uuid = e.uuid
+    ) dates ON dates.last_date IS NOT NULL
+    WHERE e.org_uuid = $1::uuid
+        AND e.release_status = 'released'
+        AND dates.last_date < CURRENT_DATE + $2::int
+        AND NOT EXISTS (
+            SELECT 1 FROM {{schema}}.event_recurrence r
+            WHERE r.event_uuid = e.uuid
+                AND r.rrule !~* '(UNTIL|COUNT)='
+        )
+) t
+ORDER BY t.due_date ASC, t.title ASC
\ No newline at end of file
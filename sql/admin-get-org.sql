@@ -17,6 +17,7 @@ SELECT
     o.address_addition,
     ST_X(o.point) AS lon,
     ST_Y(o.point) AS lat,
+    o.two_factor_permissions,
     img.images
 FROM {{schema}}.organization o
 JOIN {{schema}}.user_organization_link uol
@@ -11,9 +11,21 @@ SELECT
     |
     COALESCE(
         (
-            SELECT uol.permissions
+            SELECT
+                CASE
+                    WHEN uol.permissions & o.two_factor_permissions <> 0
+                        AND NOT EXISTS (
+                            SELECT 1
+                            FROM {{schema}}.user_totp t
+                            WHERE t.user_uuid = uol.user_uuid
+                            AND t.enabled_at IS NOT NULL
+                        )
+                    THEN uol.permissions & ~o.two_factor_permissions
+                    ELSE uol.permissions
+                END
             FROM {{schema}}.user_organization_link uol
             JOIN {{schema}}.venue v ON v.org_uuid = uol.org_uuid
+            JOIN {{schema}}.organization o ON o.uuid = uol.org_uuid
             WHERE uol.user_uuid = $1
             AND v.uuid = $2
             LIMIT 1
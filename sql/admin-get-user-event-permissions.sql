@@ -1,5 +1,15 @@
 SELECT
-    uol.permissions
+    CASE
+        WHEN uol.permissions & o.two_factor_permissions <> 0
+            AND NOT EXISTS (
+                SELECT 1
+                FROM {{schema}}.user_totp t
+                WHERE t.user_uuid = u.uuid
+                AND t.enabled_at IS NOT NULL
+            )
+        THEN uol.permissions & ~o.two_factor_permissions
+        ELSE uol.permissions
+    END AS permissions
 FROM {{schema}}.event e
 JOIN {{schema}}.user u ON u.uuid = $1::uuid
 JOIN {{schema}}.organization o ON o.uuid = e.org_uuid
@@ -1,8 +1,21 @@
-SELECT uol.permissions::bigint AS perm
+SELECT
+    CASE
+        WHEN uol.permissions & o.two_factor_permissions <> 0
+            AND NOT EXISTS (
+                SELECT 1
+                FROM {{schema}}.user_totp t
+                WHERE t.user_uuid = uol.user_uuid
+                AND t.enabled_at IS NOT NULL
+            )
+        THEN uol.permissions & ~o.two_factor_permissions
+        ELSE uol.permissions
+    END::bigint AS perm
 FROM {{schema}}.user_organization_link uol
 JOIN {{schema}}.organization_member_link oml
 ON uol.user_uuid = oml.user_uuid
 AND uol.org_uuid = oml.org_uuid
+JOIN {{schema}}.organization o
+ON o.uuid = uol.org_uuid
 WHERE uol.user_uuid = $1
 AND uol.org_uuid = $2
 AND oml.has_joined = TRUE
@@ -26,5 +26,5 @@ VALUES (
     $10,
     $11,
     $12,
-    $13::uuid
+    NULLIF($13::text, '')::uuid
 )
\ No newline at end of file
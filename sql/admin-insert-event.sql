@@ -0,0 +1,62 @@
+INSERT INTO {{schema}}.event (
+    uuid,
+    org_uuid,
+    venue_uuid,
+    space_uuid,
+    external_id,
+    release_status,
+    release_date,
+    categories,
+    title,
+    subtitle,
+    description,
+    summary,
+    languages,
+    tags,
+    source_link,
+    online_link,
+    occasion_type_id,
+    participation_info,
+    min_age,
+    max_age,
+    meeting_point,
+    max_attendees,
+    price_type,
+    currency,
+    ticket_link,
+    ticket_flags,
+    custom,
+    style,
+    created_by
+)
+VALUES (
+    $1::uuid,
+    $2::uuid,
+    $3::uuid,
+    $4::uuid,
+    $5,
+    $6,
+    $7::date,
+    $8,
+    $9,
+    $10,
+    $11,
+    $12,
+    $13,
+    $14,
+    $15,
+    $16,
+    $17,
+    $18,
+    $19,
+    $20,
+    $21,
+    $22,
+    $23,
+    $24,
+    $25,
+    $26,
+    $27,
+    $28,
+    NULLIF($29::text, '')::uuid
+)
\ No newline at end of file
@@ -0,0 +1,32 @@
+INSERT INTO {{schema}}.event_date (
+    uuid,
+    event_uuid,
+    release_status,
+    venue_uuid,
+    space_uuid,
+    start_date,
+    start_time,
+    end_date,
+    end_time,
+    entry_time,
+    duration,
+    all_day,
+    recurrence_generated,
+    created_by
+)
+VALUES (
+    $1::uuid,
+    $2::uuid,
+    'inherited',
+    $3::uuid,
+    $4::uuid,
+    $5::date,
+    $6::time,
+    $7::date,
+    $8::time,
+    $9::time,
+    $10,
+    COALESCE($11, false),
+    true,
+    COALESCE($12::uuid, (SELECT er.modified_by FROM {{schema}}.event_recurrence er WHERE er.event_uuid = $2::uuid))
+)
@@ -19,6 +19,6 @@ INSERT INTO {{schema}}.venue (
 VALUES (
     $1::uuid, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
     ST_SetSRID(ST_MakePoint($15, $16),4326),
-    $17::uuid
+    NULLIF($17::text, '')::uuid
 )
 RETURNING id
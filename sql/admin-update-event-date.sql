@@ -10,6 +10,6 @@ SET
     entry_time = $10,
     duration = $11,
     all_day = COALESCE($12, all_day),
-    modified_by = $13::uuid
+    modified_by = NULLIF($13::text, '')::uuid
 WHERE uuid = $1::uuid
     AND event_uuid = $2::uuid
\ No newline at end of file
@@ -0,0 +1,18 @@
+UPDATE {{schema}}.event_date
+SET
+    venue_uuid = $2::uuid,
+    space_uuid = $3::uuid,
+    start_time = $4::time,
+    end_date = $5::date,
+    end_time = $6::time,
+    entry_time = $7::time,
+    duration = $8,
+    all_day = COALESCE($9, all_day),
+    modified_by = COALESCE($10::uuid, modified_by),
+    -- Dates cancelled because the rule dropped their day take place again
+    release_status = CASE WHEN release_status = 'cancelled' THEN 'inherited' ELSE release_status END,
+    status_reason = CASE WHEN release_status = 'cancelled' THEN NULL ELSE status_reason END,
+    status_changed_at = CASE WHEN release_status = 'cancelled' THEN NULL ELSE status_changed_at END,
+    status_changed_by = CASE WHEN release_status = 'cancelled' THEN NULL ELSE status_changed_by END
+WHERE uuid = $1::uuid
+    AND recurrence_generated
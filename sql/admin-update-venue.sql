@@ -14,5 +14,5 @@ SET
     country = $13,
     state = $14,
     point = ST_SetSRID(ST_MakePoint($15, $16), 4326),
-    modified_by = $17
+    modified_by = NULLIF($17::text, '')::uuid
 WHERE uuid = $1
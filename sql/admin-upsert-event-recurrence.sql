@@ -0,0 +1,46 @@
+INSERT INTO {{schema}}.event_recurrence (
+    event_uuid,
+    rrule,
+    rdates,
+    exdates,
+    start_date,
+    start_time,
+    end_time,
+    entry_time,
+    all_day,
+    duration,
+    venue_uuid,
+    space_uuid,
+    created_by,
+    modified_by
+)
+VALUES (
+    $1::uuid,
+    $2,
+    $3::date[],
+    $4::date[],
+    $5::date,
+    $6::time,
+    $7::time,
+    $8::time,
+    $9,
+    $10,
+    $11::uuid,
+    $12::uuid,
+    NULLIF($13::text, '')::uuid,
+    NULLIF($13::text, '')::uuid
+)
+ON CONFLICT (event_uuid) DO UPDATE SET
+    rrule = EXCLUDED.rrule,
+    rdates = EXCLUDED.rdates,
+    exdates = EXCLUDED.exdates,
+    start_date = EXCLUDED.start_date,
+    start_time = EXCLUDED.start_time,
+    end_time = EXCLUDED.end_time,
+    entry_time = EXCLUDED.entry_time,
+    all_day = EXCLUDED.all_day,
+    duration = EXCLUDED.duration,
+    venue_uuid = EXCLUDED.venue_uuid,
+    space_uuid = EXCLUDED.space_uuid,
+    modified_by = EXCLUDED.modified_by,
+    modified_at = NOW()
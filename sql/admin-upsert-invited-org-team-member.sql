@@ -2,13 +2,15 @@ INSERT INTO {{schema}}.organization_member_link AS oml (
     org_uuid,
     user_uuid,
     accept_token,
-    invited_by_user_uuid
+    invited_by_user_uuid,
+    role_uuid
 )
-VALUES ($1::uuid, $2::uuid, $3, $4::uuid)
+VALUES ($1::uuid, $2::uuid, $3, $4::uuid, NULLIF($5, '')::uuid)
 ON CONFLICT (org_uuid, user_uuid)
     DO UPDATE
     SET
         accept_token = EXCLUDED.accept_token,
         invited_by_user_uuid = EXCLUDED.invited_by_user_uuid,
+        role_uuid = EXCLUDED.role_uuid,
         invited_at = NOW()
     WHERE NOT oml.has_joined
\ No newline at end of file
@@ -1,18 +1,35 @@
 SELECT
     edp.event_date_uuid,
+    edp.event_uuid,
+    COALESCE(ed.recurrence_generated, false) AS recurrence_generated,
     COALESCE(edp.venue_name, ep.venue_name),
     COALESCE(edp.venue_street, ep.venue_street),
     COALESCE(edp.venue_house_number, ep.venue_house_number),
+    COALESCE(edp.venue_postal_code, ep.venue_postal_code),
     COALESCE(edp.venue_city, ep.venue_city),
     TO_CHAR(edp.start_date, 'YYYY-MM-DD') AS start_date,
     TO_CHAR(edp.start_time, 'HH24:MI') AS start_time,
     TO_CHAR(edp.end_date, 'YYYY-MM-DD') AS end_date,
     TO_CHAR(edp.end_time, 'HH24:MI') AS end_time,
+    COALESCE(edp.all_day, false) AS all_day,
     ep.title,
     ep.subtitle,
     ep.description AS description,
     ep.org_name,
-    ep.org_contact_email
+    ep.org_contact_email,
+
+    CASE
+        WHEN edp.release_status IS NULL OR edp.release_status = 'inherited'
+            THEN ep.release_status
+            ELSE edp.release_status
+        END AS release_status,
+
+    edp.status_reason,
+    edp.rescheduled_to_uuid,
+    edp.availability_status_id,
+
+    GREATEST(edp.modified_at, ep.modified_at) AS modified_at
 FROM {{schema}}.event_date_projection edp
 JOIN {{schema}}.event_projection ep ON ep.event_uuid = edp.event_uuid
+LEFT JOIN {{schema}}.event_date ed ON ed.uuid = edp.event_date_uuid
 WHERE edp.event_date_uuid = $1::uuid
\ No newline at end of file
@@ -44,7 +44,19 @@ SELECT
     s.web_link AS space_link,
     s.accessibility_flags::text AS accessibility_flags,
     s.accessibility_summary AS accessibility_summary,
-    ed.accessibility_info AS accessibility_info
+    ed.accessibility_info AS accessibility_info,
+
+    -- Cancellation, postponement and rescheduling
+    ed.status_reason,
+    ed.rescheduled_to_uuid,
+    (
+        SELECT prev.uuid
+        FROM {{schema}}.event_date prev
+        WHERE prev.rescheduled_to_uuid = ed.uuid
+        ORDER BY prev.start_date DESC
+        LIMIT 1
+    ) AS rescheduled_from_uuid,
+    ed.availability_status_id
 
 FROM {{schema}}.event_date ed
 
@@ -22,7 +22,7 @@ LEFT JOIN LATERAL (
 WHERE {{date_conditions}}
 {{conditions}}
 AND v.venue_uuid IS NOT NULL
-AND ep.release_status NOT IN ('review', 'draft')
+AND ep.release_status NOT IN ('review', 'draft', 'scheduled')
 
 GROUP BY
     v.venue_uuid,
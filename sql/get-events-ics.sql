@@ -0,0 +1,47 @@
+SELECT
+    {{search_rank}},
+    edp.event_date_uuid,
+    edp.event_uuid,
+    TO_CHAR(edp.start_date, 'YYYY-MM-DD') AS start_date,
+    TO_CHAR(edp.start_time, 'HH24:MI') AS start_time,
+    TO_CHAR(edp.end_date, 'YYYY-MM-DD') AS end_date,
+    TO_CHAR(edp.end_time, 'HH24:MI') AS end_time,
+    COALESCE(edp.all_day, false) AS all_day,
+
+    CASE
+        WHEN edp.release_status IS NULL OR edp.release_status = 'inherited'
+            THEN ep.release_status
+            ELSE edp.release_status
+        END AS release_status,
+
+    edp.status_reason,
+    edp.rescheduled_to_uuid,
+    edp.availability_status_id,
+
+    ep.title,
+    ep.subtitle,
+    ep.description,
+    ep.org_name,
+    ep.org_contact_email,
+    COALESCE(edp.venue_name, ep.venue_name) AS venue_name,
+    COALESCE(edp.venue_street, ep.venue_street) AS venue_street,
+    COALESCE(edp.venue_house_number, ep.venue_house_number) AS venue_house_number,
+    COALESCE(edp.venue_postal_code, ep.venue_postal_code) AS venue_postal_code,
+    COALESCE(edp.venue_city, ep.venue_city) AS venue_city,
+    GREATEST(edp.modified_at, ep.modified_at) AS modified_at
+
+FROM {{schema}}.event_date_projection edp
+JOIN {{schema}}.event_projection ep
+    ON ep.event_uuid = edp.event_uuid
+
+{{portal_join}}
+
+WHERE ep.release_status IN ('released', 'cancelled', 'deferred', 'rescheduled')
+    AND {{date_conditions}}
+
+{{conditions}}
+{{portal_conditions}}
+
+ORDER BY edp.event_start_at ASC, edp.event_date_uuid ASC
+
+{{limit}}
\ No newline at end of file
@@ -32,6 +32,9 @@ base AS (
             ELSE edp.release_status
         END AS release_status,
 
+        edp.status_reason,
+        edp.rescheduled_to_uuid,
+
         COALESCE(edp.venue_name, ep.venue_name) AS venue_name,
         COALESCE(edp.venue_city, ep.venue_city) AS venue_city,
 
@@ -76,6 +79,8 @@ events_agg AS (
                 'image_uuid', image_uuid,
                 'categories', categories,
                 'release_status', release_status,
+                'status_reason', status_reason,
+                'rescheduled_to_uuid', rescheduled_to_uuid,
                 'venue_name', venue_name,
                 'venue_city', venue_city
             )
@@ -19,7 +19,11 @@ SELECT
             ELSE edp.release_status
         END AS release_status,
 
+    edp.status_reason,
+    edp.rescheduled_to_uuid,
+
     edp.ticket_link,
+    edp.availability_status_id,
     ep.title,
     ep.subtitle,
     ep.summary,
@@ -0,0 +1,30 @@
+-- Recurring event series (RRULE/RDATE/EXDATE).
+-- Event dates generated from a rule are flagged with recurrence_generated,
+-- so regenerating a series never touches explicitly entered dates.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.event_recurrence (
+    event_uuid uuid PRIMARY KEY REFERENCES {{schema}}.event (uuid) ON DELETE CASCADE,
+    rrule text NOT NULL,
+    rdates date[] NOT NULL DEFAULT '{}',
+    exdates date[] NOT NULL DEFAULT '{}',
+    start_date date NOT NULL,
+    start_time time NOT NULL,
+    end_time time,
+    entry_time time,
+    duration integer,
+    all_day boolean,
+    venue_uuid uuid REFERENCES {{schema}}.venue (uuid) ON DELETE SET NULL,
+    space_uuid uuid REFERENCES {{schema}}.space (uuid) ON DELETE SET NULL,
+    materialized_until date,
+    created_by uuid,
+    modified_by uuid,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    modified_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE {{schema}}.event_date
+    ADD COLUMN IF NOT EXISTS recurrence_generated boolean NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS event_date_recurrence_generated_idx
+    ON {{schema}}.event_date (event_uuid, start_date)
+    WHERE recurrence_generated;
@@ -0,0 +1,15 @@
+-- Event sync for external systems (ticketing, aggregators).
+-- Organizations authenticate with a key of which only the SHA-256 hash is
+-- stored. Events are matched by (org_uuid, external_id), sync_hash holds
+-- the hash of the last synced payload to detect unchanged events.
+
+ALTER TABLE {{schema}}.organization
+    ADD COLUMN IF NOT EXISTS sync_key_hash text,
+    ADD COLUMN IF NOT EXISTS sync_key_created_at timestamptz;
+
+ALTER TABLE {{schema}}.event
+    ADD COLUMN IF NOT EXISTS sync_hash text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS event_org_external_id_idx
+    ON {{schema}}.event (org_uuid, external_id)
+    WHERE external_id IS NOT NULL;
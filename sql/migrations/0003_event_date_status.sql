@@ -0,0 +1,19 @@
+-- Lifecycle of single event dates. A date can be cancelled, postponed
+-- (release_status 'deferred') or rescheduled to another date, which is
+-- linked from the original date. Dates are no longer deleted, so links
+-- already shared keep working.
+
+ALTER TABLE {{schema}}.event_date
+    ADD COLUMN IF NOT EXISTS status_reason text,
+    ADD COLUMN IF NOT EXISTS rescheduled_to_uuid uuid
+        REFERENCES {{schema}}.event_date (uuid) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS status_changed_at timestamptz,
+    ADD COLUMN IF NOT EXISTS status_changed_by uuid;
+
+CREATE INDEX IF NOT EXISTS event_date_rescheduled_to_idx
+    ON {{schema}}.event_date (rescheduled_to_uuid)
+    WHERE rescheduled_to_uuid IS NOT NULL;
+
+ALTER TABLE {{schema}}.event_date_projection
+    ADD COLUMN IF NOT EXISTS status_reason text,
+    ADD COLUMN IF NOT EXISTS rescheduled_to_uuid uuid;
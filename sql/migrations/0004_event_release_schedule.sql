@@ -0,0 +1,11 @@
+-- Scheduled publishing. Events with release_status 'scheduled' are
+-- released by the release schedule worker at their release_date. Released
+-- events whose last date has passed are set to 'archived'.
+
+ALTER TABLE {{schema}}.event
+    ADD COLUMN IF NOT EXISTS released_at timestamptz,
+    ADD COLUMN IF NOT EXISTS archived_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS event_scheduled_release_idx
+    ON {{schema}}.event (release_date)
+    WHERE release_status = 'scheduled';
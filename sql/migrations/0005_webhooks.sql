@@ -0,0 +1,47 @@
+-- Outbound webhooks. Organizations register endpoints, optionally scoped to
+-- one of their portals, with a filter of event types (empty = all).
+-- RefreshEventProjections writes deliveries into webhook_delivery within the
+-- transaction of the change (transactional outbox), the webhook worker sends
+-- them after commit, signed with the secret of the subscription.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.webhook_subscription (
+    uuid uuid PRIMARY KEY,
+    org_uuid uuid NOT NULL REFERENCES {{schema}}.organization (uuid) ON DELETE CASCADE,
+    portal_uuid uuid REFERENCES {{schema}}.portal2 (uuid) ON DELETE CASCADE,
+    url text NOT NULL,
+    secret text NOT NULL,
+    event_types text[] NOT NULL DEFAULT '{}',
+    description text,
+    active boolean NOT NULL DEFAULT true,
+    created_by uuid,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    modified_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_subscription_org_idx
+    ON {{schema}}.webhook_subscription (org_uuid)
+    WHERE active;
+
+CREATE TABLE IF NOT EXISTS {{schema}}.webhook_delivery (
+    id bigserial PRIMARY KEY,
+    subscription_uuid uuid NOT NULL REFERENCES {{schema}}.webhook_subscription (uuid) ON DELETE CASCADE,
+    event_type text NOT NULL,
+    entity_uuid uuid,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending', -- pending, delivered, failed
+    attempt_count integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT NOW(),
+    last_attempt_at timestamptz,
+    response_status integer,
+    response_body text,
+    error text,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    delivered_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_pending_idx
+    ON {{schema}}.webhook_delivery (next_attempt_at)
+    WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_subscription_idx
+    ON {{schema}}.webhook_delivery (subscription_uuid, id DESC);
@@ -0,0 +1,16 @@
+-- Revision history of events. Every change of an event stores a snapshot of
+-- the event (including type links, explicitly entered dates and the
+-- recurrence rule) together with a field-level diff to the previous state.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.event_revision (
+    id bigserial PRIMARY KEY,
+    event_uuid uuid NOT NULL REFERENCES {{schema}}.event (uuid) ON DELETE CASCADE,
+    revision integer NOT NULL,
+    action text NOT NULL,
+    user_uuid uuid,
+    snapshot jsonb NOT NULL,
+    diff jsonb,
+    restored_from integer,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (event_uuid, revision)
+);
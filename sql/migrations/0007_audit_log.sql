@@ -0,0 +1,41 @@
+-- Audit log of administrative actions within organizations. Entries are
+-- append-only: updates are rejected, deletes are only allowed to the
+-- retention worker, which sets uranus.audit_log_retention for its
+-- transaction. org_uuid has no foreign key, so the log of a deleted
+-- organization is kept until it expires.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.audit_log (
+    id bigserial PRIMARY KEY,
+    org_uuid uuid NOT NULL,
+    actor_user_uuid uuid,
+    entity_type text NOT NULL,
+    entity_uuid uuid,
+    action text NOT NULL,
+    before jsonb,
+    after jsonb,
+    client_ip text,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_org_idx
+    ON {{schema}}.audit_log (org_uuid, id DESC);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx
+    ON {{schema}}.audit_log (entity_uuid);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx
+    ON {{schema}}.audit_log (created_at);
+
+CREATE OR REPLACE FUNCTION {{schema}}.audit_log_protect() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('uranus.audit_log_retention', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_protect ON {{schema}}.audit_log;
+CREATE TRIGGER audit_log_protect
+    BEFORE UPDATE OR DELETE ON {{schema}}.audit_log
+    FOR EACH ROW EXECUTE FUNCTION {{schema}}.audit_log_protect();
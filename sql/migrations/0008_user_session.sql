@@ -0,0 +1,48 @@
+-- Login sessions. A session is created at login and kept alive by rotating
+-- refresh tokens, of which only the SHA-256 hash is stored. Access tokens
+-- carry the session uuid and are rejected once the session is revoked or
+-- expired. Presenting a refresh token which was already rotated revokes the
+-- session (reuse detection).
+
+CREATE TABLE IF NOT EXISTS {{schema}}.user_session (
+    uuid uuid PRIMARY KEY,
+    user_uuid uuid NOT NULL REFERENCES {{schema}}.user (uuid) ON DELETE CASCADE,
+    user_agent text,
+    client_ip text,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    last_seen_at timestamptz NOT NULL DEFAULT NOW(),
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    revoke_reason text
+);
+
+CREATE INDEX IF NOT EXISTS user_session_user_idx
+    ON {{schema}}.user_session (user_uuid)
+    WHERE revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS {{schema}}.user_session_token (
+    token_hash text PRIMARY KEY,
+    session_uuid uuid NOT NULL REFERENCES {{schema}}.user_session (uuid) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    rotated_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS user_session_token_session_idx
+    ON {{schema}}.user_session_token (session_uuid);
+
+-- Deactivating a user ends all sessions
+CREATE OR REPLACE FUNCTION {{schema}}.user_revoke_sessions() RETURNS trigger AS $$
+BEGIN
+    UPDATE {{schema}}.user_session
+    SET revoked_at = NOW(), revoke_reason = 'deactivated'
+    WHERE user_uuid = NEW.uuid AND revoked_at IS NULL;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS user_revoke_sessions ON {{schema}}.user;
+CREATE TRIGGER user_revoke_sessions
+    AFTER UPDATE OF is_active ON {{schema}}.user
+    FOR EACH ROW
+    WHEN (OLD.is_active AND NOT NEW.is_active)
+    EXECUTE FUNCTION {{schema}}.user_revoke_sessions();
@@ -0,0 +1,26 @@
+-- API keys of organizations for machine clients. A key acts as its
+-- organization with the permission bits stored in permissions, independent
+-- of the user who created it. Only the SHA-256 hash of a key is stored,
+-- key_prefix identifies the key in listings.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.org_api_key (
+    uuid uuid PRIMARY KEY,
+    org_uuid uuid NOT NULL REFERENCES {{schema}}.organization (uuid) ON DELETE CASCADE,
+    name text NOT NULL,
+    key_prefix text NOT NULL,
+    key_hash text NOT NULL UNIQUE,
+    permissions bigint NOT NULL DEFAULT 0,
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    last_used_ip text,
+    created_by uuid,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    revoked_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS org_api_key_org_idx
+    ON {{schema}}.org_api_key (org_uuid);
+
+-- Changes made with an API key are logged with the key as actor
+ALTER TABLE {{schema}}.audit_log
+    ADD COLUMN IF NOT EXISTS actor_api_key_uuid uuid;
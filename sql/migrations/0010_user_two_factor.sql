@@ -0,0 +1,26 @@
+-- TOTP two-factor authentication (RFC 6238). A secret is stored at setup
+-- and becomes active with enabled_at once the user has confirmed a code.
+-- last_used_step holds the time step of the last accepted code, so that a
+-- code cannot be used twice. Recovery codes are stored as SHA-256 hashes and
+-- can be used once each.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.user_totp (
+    user_uuid uuid PRIMARY KEY REFERENCES {{schema}}.user (uuid) ON DELETE CASCADE,
+    secret text NOT NULL,
+    enabled_at timestamptz,
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}.user_recovery_code (
+    user_uuid uuid NOT NULL REFERENCES {{schema}}.user (uuid) ON DELETE CASCADE,
+    code_hash text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    used_at timestamptz,
+    PRIMARY KEY (user_uuid, code_hash)
+);
+
+-- Permission bits which members of an organization only hold while they
+-- have two-factor authentication enabled
+ALTER TABLE {{schema}}.organization
+    ADD COLUMN IF NOT EXISTS two_factor_permissions bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,20 @@
+-- Token buckets of the rate limiter when it is configured to share its
+-- state between instances (rate_limit_store "postgres"). Buckets which have
+-- not been used for a day are deleted.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.rate_limit_bucket (
+    key text PRIMARY KEY,
+    tokens double precision NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_bucket_updated_idx
+    ON {{schema}}.rate_limit_bucket (updated_at);
+
+-- Failed logins of an account. After a few failures each further attempt
+-- must wait for a growing delay, too many failures lock the login for a
+-- while. Both are reset by a successful login.
+ALTER TABLE {{schema}}.user
+    ADD COLUMN IF NOT EXISTS failed_login_count integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_failed_login_at timestamptz,
+    ADD COLUMN IF NOT EXISTS login_locked_until timestamptz;
@@ -0,0 +1,48 @@
+-- OpenID Connect login. oidc_login_state keeps the state, PKCE verifier and
+-- nonce of a login between its start and the callback of the provider,
+-- entries older than an hour are deleted.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.oidc_login_state (
+    state text PRIMARY KEY,
+    provider text NOT NULL,
+    code_verifier text NOT NULL,
+    nonce text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- Accounts of providers linked to users, identified by the subject (sub)
+-- of the provider
+CREATE TABLE IF NOT EXISTS {{schema}}.user_identity (
+    provider text NOT NULL,
+    subject text NOT NULL,
+    user_uuid uuid NOT NULL REFERENCES {{schema}}.user (uuid) ON DELETE CASCADE,
+    email text,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    last_login_at timestamptz,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identity_user_idx
+    ON {{schema}}.user_identity (user_uuid);
+
+-- Groups of a provider mapped onto the membership of an organization. Users
+-- in a mapped group become members with the permissions of their groups when
+-- logging in with the provider and leave when they are no longer in any.
+CREATE TABLE IF NOT EXISTS {{schema}}.org_oidc_group_mapping (
+    uuid uuid PRIMARY KEY,
+    org_uuid uuid NOT NULL REFERENCES {{schema}}.organization (uuid) ON DELETE CASCADE,
+    provider text NOT NULL,
+    group_name text NOT NULL,
+    permissions bigint NOT NULL DEFAULT 0,
+    created_by uuid,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (org_uuid, provider, group_name)
+);
+
+CREATE INDEX IF NOT EXISTS org_oidc_group_mapping_provider_idx
+    ON {{schema}}.org_oidc_group_mapping (provider, group_name);
+
+-- Memberships created by a group mapping are managed by the provider,
+-- memberships added by invitation are never changed by a mapping
+ALTER TABLE {{schema}}.organization_member_link
+    ADD COLUMN IF NOT EXISTS oidc_provider text;
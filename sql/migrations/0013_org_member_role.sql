@@ -0,0 +1,33 @@
+-- Named roles of an organization bundling permission bits, e.g. "Editor" or
+-- "Venue Manager". Members are assigned a role and optionally extra bits.
+-- user_organization_link.permissions stays the effective mask read by the
+-- permission checks, it is the role's permissions combined with
+-- extra_permissions and updated whenever either changes.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.org_member_role (
+    uuid uuid PRIMARY KEY,
+    org_uuid uuid NOT NULL REFERENCES {{schema}}.organization (uuid) ON DELETE CASCADE,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    permissions bigint NOT NULL DEFAULT 0,
+    created_by uuid,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    modified_at timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (org_uuid, name)
+);
+
+ALTER TABLE {{schema}}.user_organization_link
+    ADD COLUMN IF NOT EXISTS role_uuid uuid REFERENCES {{schema}}.org_member_role (uuid) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS extra_permissions bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS user_organization_link_role_idx
+    ON {{schema}}.user_organization_link (role_uuid);
+
+-- Members without role keep their permissions as extra bits
+UPDATE {{schema}}.user_organization_link
+SET extra_permissions = permissions
+WHERE role_uuid IS NULL AND extra_permissions = 0;
+
+-- Role preassigned by an invitation, applied when it is accepted
+ALTER TABLE {{schema}}.organization_member_link
+    ADD COLUMN IF NOT EXISTS role_uuid uuid REFERENCES {{schema}}.org_member_role (uuid) ON DELETE SET NULL;
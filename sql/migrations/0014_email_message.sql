@@ -0,0 +1,38 @@
+-- Outbound email queue. Mails are written into email_message, usually within
+-- the transaction of the change causing them, and sent by the email worker
+-- with retries and exponential backoff. Permanent rejections and bounces
+-- reported later by the mail server are kept as status. Messages are deleted
+-- 90 days after their last attempt.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.email_message (
+    id bigserial PRIMARY KEY,
+    message_id text NOT NULL UNIQUE,
+    to_email text NOT NULL,
+    subject text NOT NULL,
+    html_body text NOT NULL,
+    text_body text NOT NULL,
+    template_context text,
+    lang text,
+    user_uuid uuid,
+    org_uuid uuid,
+    status text NOT NULL DEFAULT 'pending', -- pending, sent, failed, bounced
+    attempt_count integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT NOW(),
+    last_attempt_at timestamptz,
+    error text,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    sent_at timestamptz,
+    bounced_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS email_message_pending_idx
+    ON {{schema}}.email_message (next_attempt_at)
+    WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS email_message_to_idx
+    ON {{schema}}.email_message (LOWER(to_email), id DESC);
+
+-- Plain text version of the templates for multipart mails. Without it the
+-- text is derived from the HTML template.
+ALTER TABLE {{schema}}.system_email_template
+    ADD COLUMN IF NOT EXISTS text_template text;
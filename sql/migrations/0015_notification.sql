@@ -0,0 +1,91 @@
+-- Notification center. Notifications are written per recipient, typed and
+-- linked to the entity they are about. The channel is taken from the
+-- preference of the user for the type when the notification is created:
+-- 'in-app' only, 'immediate' mail, or collected into a 'daily' or 'weekly'
+-- digest mail. emailed_at is set once a notification was mailed or its
+-- digest is done. Notifications found by the notification worker carry a
+-- dedup_key, so they are created once per recipient.
+--
+-- Mails use the system email templates 'notification' (title, body, link)
+-- and 'notification-digest' (count, items), a built-in layout is used where
+-- they are missing.
+--
+-- Replaces the message table, existing messages are copied. The table is
+-- kept until the copy was verified, a later migration drops it:
+--
+--   SELECT COUNT(*) FROM {{schema}}.message m
+--   WHERE NOT EXISTS (
+--       SELECT 1 FROM {{schema}}.notification n
+--       WHERE n.type = 'message' AND n.user_uuid::text = m.to_user_id::text
+--           AND n.created_at = m.created_at AND n.title = m.subject
+--   );
+
+CREATE TABLE IF NOT EXISTS {{schema}}.notification (
+    uuid uuid PRIMARY KEY,
+    user_uuid uuid NOT NULL REFERENCES {{schema}}.user (uuid) ON DELETE CASCADE,
+    type text NOT NULL,
+    org_uuid uuid REFERENCES {{schema}}.organization (uuid) ON DELETE CASCADE,
+    entity_type text,
+    entity_uuid uuid,
+    title text NOT NULL,
+    body text NOT NULL DEFAULT '',
+    link text,
+    data jsonb NOT NULL DEFAULT '{}',
+    dedup_key text,
+    channel text NOT NULL DEFAULT 'in-app', -- in-app, immediate, daily, weekly
+    created_by uuid,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    read_at timestamptz,
+    emailed_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS notification_user_idx
+    ON {{schema}}.notification (user_uuid, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS notification_unread_idx
+    ON {{schema}}.notification (user_uuid)
+    WHERE read_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS notification_digest_idx
+    ON {{schema}}.notification (channel, created_at)
+    WHERE emailed_at IS NULL AND channel IN ('daily', 'weekly');
+
+CREATE UNIQUE INDEX IF NOT EXISTS notification_dedup_idx
+    ON {{schema}}.notification (user_uuid, dedup_key)
+    WHERE dedup_key IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS {{schema}}.notification_preference (
+    user_uuid uuid NOT NULL REFERENCES {{schema}}.user (uuid) ON DELETE CASCADE,
+    type text NOT NULL,
+    channel text NOT NULL, -- in-app, immediate, daily, weekly
+    modified_at timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_uuid, type)
+);
+
+-- Last digest mail per user and frequency
+CREATE TABLE IF NOT EXISTS {{schema}}.notification_digest (
+    user_uuid uuid NOT NULL REFERENCES {{schema}}.user (uuid) ON DELETE CASCADE,
+    frequency text NOT NULL, -- daily, weekly
+    sent_at timestamptz NOT NULL,
+    PRIMARY KEY (user_uuid, frequency)
+);
+
+DO $$
+BEGIN
+    IF to_regclass('{{schema}}.message') IS NOT NULL THEN
+        INSERT INTO {{schema}}.notification
+            (uuid, user_uuid, type, entity_type, entity_uuid, title, body, created_by, created_at, read_at, emailed_at)
+        SELECT
+            gen_random_uuid(), u.uuid, 'message', 'user', sender.uuid,
+            m.subject, COALESCE(m.message, ''), sender.uuid, m.created_at,
+            CASE WHEN m.is_read THEN m.created_at END, m.created_at
+        FROM {{schema}}.message m
+        JOIN {{schema}}.user u ON u.uuid::text = m.to_user_id::text
+        LEFT JOIN {{schema}}.user sender ON sender.uuid::text = m.from_user_id::text
+        WHERE NOT EXISTS (
+            SELECT 1 FROM {{schema}}.notification n
+            WHERE n.type = 'message' AND n.user_uuid = u.uuid
+                AND n.created_at = m.created_at AND n.title = m.subject
+        );
+    END IF;
+END $$;
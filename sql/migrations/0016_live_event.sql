@@ -0,0 +1,35 @@
+-- Live events for the Server-Sent Events stream of the admin dashboard.
+-- Rows are written within the transaction of the change, for a user (e.g. a
+-- new notification) or for the members of an organization (e.g. an event
+-- modified by another editor). The trigger announces each row on the
+-- channel <schema>_live_event after commit, every API instance listens and
+-- forwards it to its connected clients. Clients resume after a reconnect
+-- with the id of the last received event (Last-Event-ID). Rows are deleted
+-- after a day.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.live_event (
+    id bigserial PRIMARY KEY,
+    user_uuid uuid,
+    org_uuid uuid,
+    type text NOT NULL,
+    payload jsonb NOT NULL DEFAULT '{}',
+    created_by uuid,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    CHECK (user_uuid IS NOT NULL OR org_uuid IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS live_event_created_at_idx
+    ON {{schema}}.live_event (created_at);
+
+CREATE OR REPLACE FUNCTION {{schema}}.live_event_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify(TG_TABLE_SCHEMA || '_live_event', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS live_event_notify ON {{schema}}.live_event;
+
+CREATE TRIGGER live_event_notify
+    AFTER INSERT ON {{schema}}.live_event
+    FOR EACH ROW EXECUTE FUNCTION {{schema}}.live_event_notify();
@@ -0,0 +1,65 @@
+-- Web Push for visitors. A browser registers its push subscription (endpoint
+-- and keys of the Push API) anonymously or as logged in user, and follows
+-- organizations, venues, event types or portals. When an event date matching
+-- a follow is released or cancelled, a push message is written to the
+-- outbox within the transaction of the projection refresh and sent by the
+-- push worker, encrypted (RFC 8291) and signed with the VAPID key of the
+-- server (RFC 8292). Messages due within the quiet hours of a subscription
+-- are held back until the quiet hours end. Subscriptions the push service
+-- reports as gone (404, 410) are deleted.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.push_subscription (
+    uuid uuid PRIMARY KEY,
+    endpoint text NOT NULL UNIQUE,
+    p256dh text NOT NULL,
+    auth text NOT NULL,
+    user_uuid uuid REFERENCES {{schema}}.user (uuid) ON DELETE SET NULL,
+    quiet_start time,
+    quiet_end time,
+    time_zone text NOT NULL DEFAULT 'UTC',
+    user_agent text,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    modified_at timestamptz NOT NULL DEFAULT NOW(),
+    last_success_at timestamptz,
+    CHECK ((quiet_start IS NULL) = (quiet_end IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS push_subscription_user_idx
+    ON {{schema}}.push_subscription (user_uuid)
+    WHERE user_uuid IS NOT NULL;
+
+-- target_id is the uuid of the organization, venue or portal, or the
+-- type_id of the event type
+CREATE TABLE IF NOT EXISTS {{schema}}.push_follow (
+    subscription_uuid uuid NOT NULL REFERENCES {{schema}}.push_subscription (uuid) ON DELETE CASCADE,
+    target_type text NOT NULL CHECK (target_type IN ('org', 'venue', 'event_type', 'portal')),
+    target_id text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subscription_uuid, target_type, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS push_follow_target_idx
+    ON {{schema}}.push_follow (target_type, target_id);
+
+CREATE TABLE IF NOT EXISTS {{schema}}.push_message (
+    id bigserial PRIMARY KEY,
+    subscription_uuid uuid NOT NULL REFERENCES {{schema}}.push_subscription (uuid) ON DELETE CASCADE,
+    event_date_uuid uuid NOT NULL,
+    type text NOT NULL, -- event_date.released, event_date.cancelled
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending', -- pending, sent, failed
+    attempt_count integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT NOW(),
+    last_attempt_at timestamptz,
+    response_status integer,
+    error text,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    sent_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS push_message_pending_idx
+    ON {{schema}}.push_message (next_attempt_at)
+    WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS push_message_subscription_idx
+    ON {{schema}}.push_message (subscription_uuid);
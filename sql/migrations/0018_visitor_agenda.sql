@@ -0,0 +1,42 @@
+-- Visitor accounts. Visitors sign up like team members but have no access
+-- to the admin API, they keep a personal agenda of saved event dates. A
+-- reminder is mailed remind_before_hours before a saved date starts (0
+-- turns the reminder off). When a saved date is cancelled, postponed,
+-- rescheduled or moved to another time or place, the projection refresh
+-- marks the agenda entry with a pending change within its transaction and
+-- the agenda worker notifies the visitor. Moved dates are reminded again.
+--
+-- The agenda can be subscribed to as private iCalendar feed, the URL holds
+-- a token of which only the hash is stored.
+
+ALTER TABLE {{schema}}.user
+    ADD COLUMN IF NOT EXISTS account_type text NOT NULL DEFAULT 'member'
+        CHECK (account_type IN ('member', 'visitor')),
+    ADD COLUMN IF NOT EXISTS agenda_ics_token_hash text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_agenda_ics_token_hash_idx
+    ON {{schema}}.user (agenda_ics_token_hash)
+    WHERE agenda_ics_token_hash IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS {{schema}}.user_agenda (
+    user_uuid uuid NOT NULL REFERENCES {{schema}}.user (uuid) ON DELETE CASCADE,
+    event_date_uuid uuid NOT NULL REFERENCES {{schema}}.event_date (uuid) ON DELETE CASCADE,
+    remind_before_hours integer NOT NULL DEFAULT 24
+        CHECK (remind_before_hours BETWEEN 0 AND 336),
+    reminded_at timestamptz,
+    pending_change text, -- cancelled, deferred, rescheduled, moved
+    changed_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_uuid, event_date_uuid)
+);
+
+CREATE INDEX IF NOT EXISTS user_agenda_event_date_idx
+    ON {{schema}}.user_agenda (event_date_uuid);
+
+CREATE INDEX IF NOT EXISTS user_agenda_pending_change_idx
+    ON {{schema}}.user_agenda (changed_at)
+    WHERE pending_change IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS user_agenda_reminder_idx
+    ON {{schema}}.user_agenda (event_date_uuid)
+    WHERE reminded_at IS NULL AND remind_before_hours > 0;
@@ -0,0 +1,55 @@
+-- Registration (RSVP) for event dates. Organizations enable registration per
+-- event, optionally with custom questions. Visitors register for a single
+-- date with name, email and answers and confirm the registration with the
+-- link of a mail (double opt-in), unconfirmed registrations expire. The
+-- capacity of a date is the max_attendees of the event or the total
+-- capacity of its space, without either there is no limit. Confirmations
+-- beyond the capacity go onto the waitlist, which is promoted in order when
+-- seats become free. Registrations are cancelled with the link of the mails
+-- or by the organization.
+--
+-- Mails use the system email templates 'event-registration-confirm',
+-- '-confirmed', '-waitlisted', '-promoted' and '-cancelled', a built-in
+-- layout is used where they are missing.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.event_registration_config (
+    event_uuid uuid PRIMARY KEY REFERENCES {{schema}}.event (uuid) ON DELETE CASCADE,
+    enabled boolean NOT NULL DEFAULT false,
+    questions jsonb NOT NULL DEFAULT '[]',
+    waitlist_enabled boolean NOT NULL DEFAULT true,
+    max_seats_per_registration integer NOT NULL DEFAULT 1
+        CHECK (max_seats_per_registration BETWEEN 1 AND 20),
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    modified_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}.event_registration (
+    uuid uuid PRIMARY KEY,
+    event_date_uuid uuid NOT NULL REFERENCES {{schema}}.event_date (uuid) ON DELETE CASCADE,
+    name text NOT NULL,
+    email text NOT NULL,
+    seats integer NOT NULL DEFAULT 1 CHECK (seats > 0),
+    answers jsonb NOT NULL DEFAULT '{}',
+    lang text NOT NULL DEFAULT 'en',
+    status text NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'confirmed', 'waitlisted', 'cancelled')),
+    token_hash text NOT NULL UNIQUE,
+    confirm_expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    confirmed_at timestamptz,
+    waitlisted_at timestamptz,
+    promoted_at timestamptz,
+    cancelled_at timestamptz,
+    cancelled_by text -- attendee, organizer
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS event_registration_email_idx
+    ON {{schema}}.event_registration (event_date_uuid, lower(email))
+    WHERE status <> 'cancelled';
+
+CREATE INDEX IF NOT EXISTS event_registration_date_status_idx
+    ON {{schema}}.event_registration (event_date_uuid, status);
+
+CREATE INDEX IF NOT EXISTS event_registration_pending_idx
+    ON {{schema}}.event_registration (confirm_expires_at)
+    WHERE status = 'pending';
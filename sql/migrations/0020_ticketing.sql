@@ -0,0 +1,69 @@
+-- Ticketing for event dates. Organizations define price tiers per event,
+-- optionally limited to a single date, with an amount in a currency and an
+-- optional quota of tickets per date. Tickets are free or paid at the door,
+-- no payment is handled. Each ticket carries a signed code shown as QR code,
+-- which is checked in at the door. Scans of offline devices are synced later,
+-- a scan is recorded once per device and time, so repeated syncs are
+-- harmless and double entries are reported as conflicts.
+--
+-- Ticket sales set availability_status_id of dates with a limit (quotas of
+-- all tiers or the capacity of the date): 1 available, 2 few tickets left,
+-- 3 sold out.
+--
+-- Tickets are mailed with the system email template 'event-tickets-issued',
+-- a built-in layout is used where it is missing.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.event_price_tier (
+    uuid uuid PRIMARY KEY,
+    event_uuid uuid NOT NULL REFERENCES {{schema}}.event (uuid) ON DELETE CASCADE,
+    event_date_uuid uuid REFERENCES {{schema}}.event_date (uuid) ON DELETE CASCADE,
+    name text NOT NULL,
+    amount numeric(10, 2) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    currency text NOT NULL DEFAULT 'EUR',
+    quota integer CHECK (quota >= 0),
+    sort_order integer NOT NULL DEFAULT 0,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    modified_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS event_price_tier_event_idx
+    ON {{schema}}.event_price_tier (event_uuid, sort_order);
+
+CREATE TABLE IF NOT EXISTS {{schema}}.event_ticket (
+    uuid uuid PRIMARY KEY,
+    order_uuid uuid NOT NULL,
+    event_date_uuid uuid NOT NULL REFERENCES {{schema}}.event_date (uuid) ON DELETE CASCADE,
+    price_tier_uuid uuid NOT NULL REFERENCES {{schema}}.event_price_tier (uuid),
+    holder_name text NOT NULL,
+    holder_email text,
+    amount numeric(10, 2) NOT NULL,
+    currency text NOT NULL,
+    payment text NOT NULL CHECK (payment IN ('free', 'at_door')),
+    status text NOT NULL DEFAULT 'valid' CHECK (status IN ('valid', 'used', 'cancelled')),
+    issued_at timestamptz NOT NULL DEFAULT NOW(),
+    issued_by uuid, -- user of the box office, NULL for visitors
+    checked_in_at timestamptz,
+    checked_in_by uuid,
+    checked_in_device text,
+    cancelled_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS event_ticket_date_idx
+    ON {{schema}}.event_ticket (event_date_uuid, status);
+
+CREATE INDEX IF NOT EXISTS event_ticket_tier_idx
+    ON {{schema}}.event_ticket (price_tier_uuid, event_date_uuid);
+
+CREATE INDEX IF NOT EXISTS event_ticket_email_idx
+    ON {{schema}}.event_ticket (event_date_uuid, lower(holder_email));
+
+CREATE TABLE IF NOT EXISTS {{schema}}.event_ticket_scan (
+    id bigserial PRIMARY KEY,
+    ticket_uuid uuid NOT NULL REFERENCES {{schema}}.event_ticket (uuid) ON DELETE CASCADE,
+    device_id text NOT NULL DEFAULT '',
+    scanned_at timestamptz NOT NULL,
+    received_at timestamptz NOT NULL DEFAULT NOW(),
+    user_uuid uuid,
+    result text NOT NULL, -- checked_in, already_used, cancelled
+    UNIQUE (ticket_uuid, device_id, scanned_at)
+);
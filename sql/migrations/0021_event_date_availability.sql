@@ -0,0 +1,31 @@
+-- Availability of event dates derived from real data. availability_status_id
+-- of an event date is set from the first source which applies:
+--
+--   1. Ticket counts pushed by an external ticketing system
+--   2. Tickets issued for the price tiers of the event, if limited
+--   3. Registrations, if enabled and the date has a capacity
+--
+-- Dates without a source keep the status set by hand. Values are 1 available,
+-- 2 few left, 3 sold out and 4 waitlist (no seats left, visitors can join the
+-- waiting list). Few are left when the free seats drop to a percentage of the
+-- capacity or a number of seats, whichever is higher. Organizations set both
+-- thresholds, without a rule 10 percent and 1 seat apply.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.org_availability_rule (
+    org_uuid uuid PRIMARY KEY REFERENCES {{schema}}.organization (uuid) ON DELETE CASCADE,
+    few_left_percent integer NOT NULL DEFAULT 10 CHECK (few_left_percent BETWEEN 0 AND 100),
+    few_left_seats integer NOT NULL DEFAULT 1 CHECK (few_left_seats >= 0),
+    modified_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- Latest counts of an external ticketing system, replaced by every push.
+-- capacity is optional, without it only few_left_seats applies.
+CREATE TABLE IF NOT EXISTS {{schema}}.event_date_ticket_count (
+    event_date_uuid uuid PRIMARY KEY REFERENCES {{schema}}.event_date (uuid) ON DELETE CASCADE,
+    available integer NOT NULL CHECK (available >= 0),
+    capacity integer CHECK (capacity >= 0),
+    waitlist boolean NOT NULL DEFAULT false,
+    source text,
+    reported_at timestamptz NOT NULL DEFAULT NOW(),
+    reported_by uuid
+);
@@ -0,0 +1,23 @@
+-- At most one generated date per day and recurring event. Duplicates left
+-- by concurrent materializations are detached from the recurrence and
+-- cancelled, the oldest date of the day stays generated.
+
+UPDATE {{schema}}.event_date ed
+SET recurrence_generated = false,
+    release_status = 'cancelled',
+    status_reason = 'Duplicate of a generated date',
+    status_changed_at = NOW()
+WHERE ed.recurrence_generated
+    AND EXISTS (
+        SELECT 1 FROM {{schema}}.event_date o
+        WHERE o.event_uuid = ed.event_uuid
+            AND o.start_date = ed.start_date
+            AND o.recurrence_generated
+            AND o.uuid < ed.uuid
+    );
+
+DROP INDEX IF EXISTS {{schema}}.event_date_recurrence_generated_idx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS event_date_recurrence_generated_uidx
+    ON {{schema}}.event_date (event_uuid, start_date)
+    WHERE recurrence_generated;
@@ -0,0 +1,14 @@
+-- Failed logins with email addresses which belong to no account, keyed by
+-- the SHA-256 hash of the lower-cased address. They are throttled like the
+-- failed logins of accounts, so the response does not tell whether an
+-- account exists. Entries are deleted a day after their last failure.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.login_failure (
+    email_hash text PRIMARY KEY,
+    failed_login_count integer NOT NULL DEFAULT 0,
+    last_failed_login_at timestamptz NOT NULL DEFAULT NOW(),
+    login_locked_until timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS login_failure_last_failed_idx
+    ON {{schema}}.login_failure (last_failed_login_at);
@@ -0,0 +1,77 @@
+-- Events starting within $1 days, with the flags reported by
+-- CheckEventNotifications
+WITH event_check AS (
+    SELECT
+        e.uuid,
+        e.title,
+        e.org_uuid,
+        e.release_status,
+        e.online_link,
+
+        -- First upcoming date
+        MIN(ed.start_date) FILTER (
+            WHERE ed.start_date >= CURRENT_DATE
+        ) AS first_date,
+
+        -- Image exists?
+        EXISTS (
+            SELECT 1
+            FROM {{schema}}.pluto_image_link pil
+            WHERE pil.context = 'event'
+                AND pil.context_uuid = e.uuid
+                AND pil.identifier = 'main'
+        ) AS has_image,
+
+        -- Venue exists on event OR any date
+        (
+            e.venue_uuid IS NOT NULL
+                OR BOOL_OR(ed.venue_uuid IS NOT NULL)
+        ) AS has_venue
+
+    FROM {{schema}}.event e
+
+    JOIN {{schema}}.event_date ed
+        ON ed.event_uuid = e.uuid
+
+    WHERE e.release_status IN ('draft', 'review', 'scheduled', 'released')
+
+    GROUP BY
+        e.uuid,
+        e.title,
+        e.org_uuid,
+        e.release_status,
+        e.online_link,
+        e.venue_uuid
+)
+
+SELECT
+    ec.uuid,
+    COALESCE(ec.title, '') AS title,
+    ec.org_uuid,
+    ec.release_status,
+    ec.first_date,
+    (ec.first_date - CURRENT_DATE) AS days_until_first_date,
+
+    NOT ec.has_image AS no_image,
+
+    (
+        NOT ec.has_venue
+            AND COALESCE(NULLIF(TRIM(ec.online_link), ''), '') = ''
+    ) AS no_venue_or_online_link,
+
+    NOT EXISTS (
+        SELECT 1
+        FROM {{schema}}.event_type_link etl
+        WHERE etl.event_uuid = ec.uuid
+    ) AS no_event_type,
+
+    COALESCE(TRIM(ec.title), '') = '' AS no_title
+
+FROM event_check ec
+
+WHERE ec.first_date IS NOT NULL
+    AND ec.first_date <= CURRENT_DATE + ($1 * interval '1 day')
+
+ORDER BY
+    ec.first_date,
+    ec.uuid
@@ -0,0 +1,70 @@
+WITH changed AS (
+    SELECT
+        w.event_date_uuid,
+        w.type,
+        ep.org_uuid,
+        COALESCE(edp.venue_uuid, ep.venue_uuid) AS venue_uuid,
+        ep.types
+    FROM unnest($1::uuid[], $2::text[]) AS w(event_date_uuid, type)
+    JOIN {{schema}}.event_date_projection edp
+        ON edp.event_date_uuid = w.event_date_uuid
+    JOIN {{schema}}.event_projection ep
+        ON ep.event_uuid = edp.event_uuid
+    WHERE edp.start_date >= CURRENT_DATE
+),
+matched AS (
+    SELECT f.subscription_uuid, c.event_date_uuid, c.type
+    FROM changed c
+    JOIN {{schema}}.push_follow f
+        ON f.target_type = 'org' AND f.target_id = c.org_uuid::text
+
+    UNION
+
+    SELECT f.subscription_uuid, c.event_date_uuid, c.type
+    FROM changed c
+    JOIN {{schema}}.push_follow f
+        ON f.target_type = 'venue' AND f.target_id = c.venue_uuid::text
+
+    UNION
+
+    SELECT f.subscription_uuid, c.event_date_uuid, c.type
+    FROM changed c
+    CROSS JOIN LATERAL jsonb_array_elements(COALESCE(c.types, '[]'::jsonb)) t
+    JOIN {{schema}}.push_follow f
+        ON f.target_type = 'event_type' AND f.target_id = t->>0
+
+    UNION
+
+    -- Portals follow the filter of the portal
+    SELECT f.subscription_uuid, c.event_date_uuid, c.type
+    FROM changed c
+    JOIN {{schema}}.push_follow f
+        ON f.target_type = 'portal'
+    JOIN {{schema}}.portal2 p
+        ON p.uuid::text = f.target_id
+    JOIN {{schema}}.event_date_projection edp
+        ON edp.event_date_uuid = c.event_date_uuid
+    JOIN {{schema}}.event_projection ep
+        ON ep.event_uuid = edp.event_uuid
+    WHERE TRUE
+    {{portal_conditions}}
+)
+INSERT INTO {{schema}}.push_message (subscription_uuid, event_date_uuid, type, payload)
+SELECT
+    m.subscription_uuid,
+    m.event_date_uuid,
+    m.type,
+    jsonb_build_object(
+        'event_uuid', ep.event_uuid,
+        'event_date_uuid', edp.event_date_uuid,
+        'title', ep.title,
+        'start_date', edp.start_date,
+        'start_time', edp.start_time,
+        'venue_name', COALESCE(edp.venue_name, ep.venue_name),
+        'status_reason', edp.status_reason
+    )
+FROM matched m
+JOIN {{schema}}.event_date_projection edp
+    ON edp.event_date_uuid = m.event_date_uuid
+JOIN {{schema}}.event_projection ep
+    ON ep.event_uuid = edp.event_uuid
@@ -0,0 +1,29 @@
+UPDATE {{schema}}.event
+SET
+    venue_uuid = $2::uuid,
+    space_uuid = $3::uuid,
+    release_status = $4,
+    release_date = $5::date,
+    categories = $6,
+    title = $7,
+    subtitle = $8,
+    description = $9,
+    summary = $10,
+    languages = $11,
+    tags = $12,
+    source_link = $13,
+    online_link = $14,
+    occasion_type_id = $15,
+    participation_info = $16,
+    min_age = $17,
+    max_age = $18,
+    meeting_point = $19,
+    max_attendees = $20,
+    price_type = $21,
+    currency = $22,
+    ticket_link = $23,
+    ticket_flags = $24,
+    custom = $25,
+    style = $26,
+    sync_hash = $27
+WHERE uuid = $1::uuid
\ No newline at end of file
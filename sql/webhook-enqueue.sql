@@ -0,0 +1,27 @@
+INSERT INTO {{schema}}.webhook_delivery (subscription_uuid, event_type, entity_uuid, payload)
+SELECT s.uuid, w.event_type, w.entity_uuid, w.payload
+FROM unnest($1::text[], $2::uuid[], $3::uuid[], $4::uuid[], $5::jsonb[])
+    AS w(event_type, org_uuid, entity_uuid, event_uuid, payload)
+JOIN {{schema}}.webhook_subscription s
+    ON s.active
+    AND (cardinality(s.event_types) = 0 OR w.event_type = ANY(s.event_types))
+WHERE
+    -- Organization subscriptions receive all changes of the organization
+    (s.portal_uuid IS NULL AND s.org_uuid = w.org_uuid)
+
+    OR
+    -- Portal subscriptions receive changes of events shown in the portal
+    (
+        s.portal_uuid IS NOT NULL
+        AND w.event_uuid IS NOT NULL
+        AND EXISTS (
+            SELECT 1
+            FROM {{schema}}.event_projection ep
+            LEFT JOIN {{schema}}.event_date_projection edp
+                ON edp.event_uuid = ep.event_uuid
+            JOIN {{schema}}.portal2 p
+                ON p.uuid = s.portal_uuid
+            WHERE ep.event_uuid = w.event_uuid
+            {{portal_conditions}}
+        )
+    )
\ No newline at end of file
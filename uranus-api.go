@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"html/template"
 
@@ -23,8 +24,19 @@ import (
 func main() {
 	configFileName := flag.String("config", "config.json", "Path to config file")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging")
+	generateVapidKeys := flag.Bool("generate-vapid-keys", false, "Print a new VAPID key pair for web push and exit")
+	migrate := flag.Bool("migrate", false, "Apply pending database migrations and exit")
 	flag.Parse()
 
+	if *generateVapidKeys {
+		publicKey, privateKey, err := api.GenerateVapidKeys()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("\"vapid_public_key\": %q,\n\"vapid_private_key\": %q\n", publicKey, privateKey)
+		return
+	}
+
 	grains_api.Init(grains_api.Config{
 		ServiceName: "Uranus API",
 		APIVersion:  "1.0",
@@ -39,6 +51,18 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if *migrate {
+		applied, err := app.UranusInstance.Migrate(context.Background(), app.MigrationsDir)
+		for _, name := range applied {
+			fmt.Println("Applied migration", name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d migrations applied\n", len(applied))
+		return
+	}
+
 	err = app.UranusInstance.CheckAllDatabaseConsistency(context.Background())
 	if err != nil {
 		fmt.Println("Uranus database not consistent")
@@ -71,14 +95,72 @@ func main() {
 
 	eventTemplate := template.Must(template.ParseFiles("templates/event.html"))
 
+	rateLimitStore, err := api.NewRateLimitStore(&app.UranusInstance.Config, app.UranusInstance.MainDbPool)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	emailTransport, err := api.NewEmailTransport(&app.UranusInstance.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	webPush, err := api.NewWebPushSender(&app.UranusInstance.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if webPush == nil {
+		fmt.Println("Web push disabled")
+	}
+
 	apiHandler := &api.ApiHandler{
-		Config:        &app.UranusInstance.Config,
-		DbPool:        app.UranusInstance.MainDbPool,
-		DbSchema:      app.UranusInstance.Config.DbSchema,
-		EventTemplate: eventTemplate,
-		Accessibility: accessibilityLookup,
+		Config:         &app.UranusInstance.Config,
+		DbPool:         app.UranusInstance.MainDbPool,
+		DbSchema:       app.UranusInstance.Config.DbSchema,
+		EventTemplate:  eventTemplate,
+		Accessibility:  accessibilityLookup,
+		RateLimits:     rateLimitStore,
+		EmailTransport: emailTransport,
+		LiveEvents:     api.NewLiveEventHub(app.UranusInstance.MainDbPool, app.UranusInstance.Config.DbSchema),
+		WebPush:        webPush,
 	}
 
+	// Keep generated dates of recurring events up to the rolling horizon
+	go apiHandler.RunEventRecurrenceWorker(context.Background(), 6*time.Hour)
+
+	// Release scheduled events and archive past ones
+	go apiHandler.RunReleaseScheduleWorker(context.Background(), 5*time.Minute)
+
+	// Send queued webhook deliveries
+	go apiHandler.RunWebhookWorker(context.Background(), 30*time.Second)
+
+	// Delete audit log entries past the retention period
+	go apiHandler.RunAuditLogRetentionWorker(context.Background(), 24*time.Hour)
+
+	// Delete revoked and expired login sessions
+	go apiHandler.RunUserSessionCleanupWorker(context.Background(), 24*time.Hour)
+
+	// Drop unused rate limit buckets
+	go apiHandler.RunRateLimitCleanupWorker(context.Background(), time.Hour)
+
+	// Send queued emails
+	go apiHandler.RunEmailWorker(context.Background(), 15*time.Second)
+
+	// Check events for notifications and send notification digests
+	go apiHandler.RunNotificationWorker(context.Background(), 15*time.Minute)
+
+	// Send push messages to visitors following released and cancelled event dates
+	go apiHandler.RunPushWorker(context.Background(), 15*time.Second)
+
+	// Notify visitors about changes of saved event dates and send reminders
+	go apiHandler.RunAgendaWorker(context.Background(), time.Minute)
+
+	// Expire unconfirmed event registrations and promote waitlists
+	go apiHandler.RunRegistrationWorker(context.Background(), 5*time.Minute)
+
+	// Pass on live events of all instances to the event streams
+	go apiHandler.LiveEvents.Run(context.Background())
+
 	_, err = pluto.Initialize(*configFileName, app.UranusInstance.MainDbPool, true)
 	if err != nil {
 		panic(err)
@@ -95,6 +177,7 @@ func main() {
 	router.Use(gzip.Gzip(
 		gzip.DefaultCompression,
 		gzip.WithExcludedExtensions([]string{".png", ".jpg", ".jpeg", ".webp"}),
+		gzip.WithExcludedPaths([]string{"/api/admin/user/events"}),
 	))
 
 	/*
@@ -144,8 +227,9 @@ func main() {
 
 	publicRoute.GET("/event/release-status-i18n", apiHandler.GetEventReleaseStatusI18n)
 
-	publicRoute.GET("/events", apiHandler.GetEvents)
-	publicRoute.POST("/events/filter", apiHandler.GetEvents)
+	publicRoute.GET("/events", apiHandler.RateLimit("events-search"), apiHandler.GetEvents)
+	publicRoute.GET("/events.ics", apiHandler.RateLimit("events-search"), apiHandler.GetEventsICS)
+	publicRoute.POST("/events/filter", apiHandler.RateLimit("events-search"), apiHandler.GetEvents)
 	publicRoute.GET("/events/week", apiHandler.GetEventsWeek)
 	publicRoute.GET("/events/type-summary", apiHandler.GetEventTypeSummary)
 	publicRoute.GET("/events/venue-summary", apiHandler.GetEventVenueSummary) // TODO: check!
@@ -155,9 +239,21 @@ func main() {
 	publicRoute.GET("/event/:eventUuid/date/:dateIdentifier", apiHandler.GetEventByDate)
 	publicRoute.GET("/event/:eventUuid/date/:dateIdentifier/ics", apiHandler.GetEventDateICS)
 
+	// Registration for event dates, confirmed and cancelled by the token of the mailed links
+	publicRoute.GET("/event/:eventUuid/date/:dateIdentifier/registration", apiHandler.GetEventDateRegistration)
+	publicRoute.POST("/event/:eventUuid/date/:dateIdentifier/registration", apiHandler.RateLimit("registration"), apiHandler.CreateEventRegistration)
+	publicRoute.POST("/registration/confirm", apiHandler.RateLimit("registration"), apiHandler.ConfirmEventRegistration)
+	publicRoute.POST("/registration/cancel", apiHandler.RateLimit("registration"), apiHandler.CancelEventRegistration)
+
+	// Free and pay-at-door tickets, a ticket is shown by the signed code of its QR code
+	publicRoute.GET("/event/:eventUuid/date/:dateIdentifier/tickets", apiHandler.GetEventDateTickets)
+	publicRoute.POST("/event/:eventUuid/date/:dateIdentifier/tickets", apiHandler.RateLimit("tickets"), apiHandler.CreateEventDateTickets)
+	publicRoute.GET("/ticket", apiHandler.GetTicket)
+
 	publicRoute.GET("/portal/:uuid", apiHandler.GetPortal)               // TODO: Evt. wieder herausnehmen
 	publicRoute.GET("/portal2/:portalIdentifier", apiHandler.GetPortal2) // TODO: Neue Version
 	publicRoute.GET("/portal/:uuid/geojson", apiHandler.GetPortalGeoJSON)
+	publicRoute.GET("/portal/:uuid/events.ics", apiHandler.GetPortalEventsICS)
 
 	publicRoute.GET("/display-preset/:uuid", apiHandler.GetDisplayPreset)
 
@@ -166,9 +262,12 @@ func main() {
 	publicRoute.GET("/venues/geojson", apiHandler.GetVenuesGeoJSON)
 
 	publicRoute.GET("/org/:orgUuid", apiHandler.GetOrg)
+	publicRoute.GET("/org/:orgUuid/events.ics", apiHandler.GetOrgEventsICS)
+	publicRoute.POST("/org/:orgUuid/events/sync", apiHandler.SyncOrgEvents) // Authenticated by org key
 	publicRoute.GET("/orgs", apiHandler.GetOrgs)
 
 	publicRoute.GET("/venue/:venueIdentifier", apiHandler.GetVenue)
+	publicRoute.GET("/venue/:venueIdentifier/events.ics", apiHandler.GetVenueEventsICS)
 	publicRoute.GET("/venue/slug/:slug/uuid", apiHandler.GetVenueUuidBySlug)
 	publicRoute.GET("/venue/:venueIdentifier/space/:spaceUuid/label", apiHandler.GetVenueSpaceLabel)
 
@@ -201,15 +300,34 @@ func main() {
 	publicRoute.GET("/accessibility/flags", apiHandler.GetAccessibilityFlags) // TODO: check!
 
 	// Inject app middleware into Pluto's image routes
-	pluto.PlutoInstance.RegisterRoutes(publicRoute, app.JWTMiddleware) // TODO: check!
+	pluto.PlutoInstance.RegisterRoutes(publicRoute, app.JWTMiddleware, app.MemberOnlyMiddleware) // TODO: check!
 
-	publicRoute.POST("/signup", apiHandler.Signup)
-	publicRoute.POST("/login", apiHandler.Login)
+	publicRoute.POST("/signup", apiHandler.RateLimit("signup"), apiHandler.Signup)
+	publicRoute.POST("/login", apiHandler.RateLimit("login"), apiHandler.Login)
+	publicRoute.POST("/login/two-factor", apiHandler.RateLimit("login"), apiHandler.LoginTwoFactor) // Authenticated by interim token
+	publicRoute.POST("/refresh", apiHandler.Refresh)                                                // Authenticated by refresh token
 	publicRoute.POST("/activate", apiHandler.Activate)
 	publicRoute.POST("/org/team/invite/accept", apiHandler.OrgTeamInviteAccept)
-	publicRoute.POST("/forgot-password", apiHandler.ForgotPassword)
+	publicRoute.POST("/forgot-password", apiHandler.RateLimit("forgot-password"), apiHandler.ForgotPassword)
 	publicRoute.POST("/reset-password", apiHandler.ResetPassword)
 
+	// Single sign-on with the OpenID Connect providers of the config
+	publicRoute.GET("/login/oidc/providers", apiHandler.GetOidcProviders)
+	publicRoute.POST("/login/oidc/:provider/start", apiHandler.RateLimit("login"), apiHandler.LoginOidcStart)
+	publicRoute.POST("/login/oidc/:provider/callback", apiHandler.RateLimit("login"), apiHandler.LoginOidcCallback)
+
+	// Web Push for visitors following organizations, venues, event types and
+	// portals, authenticated by the auth secret of the subscription
+	publicRoute.GET("/push/vapid-key", apiHandler.GetPushVapidKey)
+	publicRoute.POST("/push/subscription", apiHandler.RateLimit("push"), apiHandler.UpsertPushSubscription)
+	publicRoute.GET("/push/subscription/:subscriptionUuid", apiHandler.GetPushSubscription)
+	publicRoute.DELETE("/push/subscription/:subscriptionUuid", apiHandler.RateLimit("push"), apiHandler.DeletePushSubscription)
+	publicRoute.POST("/push/subscription/:subscriptionUuid/follow", apiHandler.RateLimit("push"), apiHandler.AddPushFollow)
+	publicRoute.DELETE("/push/subscription/:subscriptionUuid/follow/:targetType/:targetId", apiHandler.RateLimit("push"), apiHandler.DeletePushFollow)
+
+	// Private iCalendar feed of the agenda of a visitor, authenticated by the token of the URL
+	publicRoute.GET("/agenda/:icsToken/events.ics", apiHandler.GetAgendaICS)
+
 	publicRoute.GET("/sitemap", apiHandler.Sitemap)
 
 	publicRoute.GET("/geolist/countries", apiHandler.GetGeoCountries)
@@ -218,127 +336,368 @@ func main() {
 	publicRoute.GET("/geolist/region/:country_slug/:state_slug/:region_slug", apiHandler.GetGeoRegion)
 
 	//
-	// Authorized endpoints, user must be logged in
+	// Authorized endpoints, user must be logged in or use an API key of an
+	// organization
 	//
 
 	adminRoute := router.Group("/api/admin")
-	adminRoute.Use(app.JWTMiddleware)
+	adminRoute.Use(apiHandler.AuthMiddleware, app.MemberOnlyMiddleware, apiHandler.RateLimit("admin"))
 
-	adminRoute.GET("/event/:eventUuid/date/:dateIdentifier", apiHandler.GetEventByDate) // TODO: Permission check
-	adminRoute.GET("/permissions/list", apiHandler.AdminGetPermissionsList)             // TODO: Permission check
-	adminRoute.POST("/refresh", apiHandler.Refresh)                                     // TODO: Permission check
+	// Routes addressing an org owned entity declare their required permissions
+	// via RequireOrgPermissions/RequireAnyOrgPermission. Routes without such a
+	// declaration are user scoped or check the org given in the request body.
+	// Routes registered via apiKeyRoute accept API keys of organizations as
+	// well, all others reject them.
+	apiKeyRoute := apiHandler.ApiKeyRoutes(adminRoute)
 
-	// User
+	adminRoute.GET("/event/:eventUuid/date/:dateIdentifier", apiHandler.GetEventByDate) // User scoped, filtered by event permissions
+	adminRoute.GET("/permissions/list", apiHandler.AdminGetPermissionsList)             // User scoped
+	adminRoute.POST("/logout", apiHandler.AdminLogout)                                  // User scoped
 
-	adminRoute.GET("/user/profile", apiHandler.AdminGetUserProfile)             // TODO: Permission check
-	adminRoute.PUT("/user/profile", apiHandler.AdminUpdateUserProfile)          // TODO: Permission check
-	adminRoute.PUT("/user/settings", apiHandler.AdminUpdateUserProfileSettings) // TODO: Permission check
-	adminRoute.POST("/user/avatar", apiHandler.AdminUploadUserAvatar)           // TODO: Permission check
-	adminRoute.DELETE("/user/avatar", apiHandler.AdminDeleteUserAvatar)         // TODO: Permission check
-
-	adminRoute.GET("/user/todos", apiHandler.AdminUserGetTodos)         // TODO: Permission check
-	adminRoute.GET("/user/todo/:todoId", apiHandler.AdminGetTodo)       // TODO: Permission check
-	adminRoute.PUT("/user/todo", apiHandler.AdminUpsertTodo)            // TODO: Permission check
-	adminRoute.DELETE("/user/todo/:todoId", apiHandler.AdminDeleteTodo) // TODO: Permission check
-
-	adminRoute.GET("/user/messages", apiHandler.AdminGetMessages)      // TODO: Permission check
-	adminRoute.POST("/user/send-message", apiHandler.AdminSendMessage) // TODO: Permission check
+	// User
 
-	adminRoute.GET("/user/org/:orgUuid/event/notifications", apiHandler.AdminGetUserEventNotifications)
-	adminRoute.GET("/user/choosable-orgs", apiHandler.AdminGetChoosableOrgs)                    // TODO: Permission check
+	adminRoute.GET("/user/profile", apiHandler.AdminGetUserProfile)             // User scoped
+	adminRoute.PUT("/user/profile", apiHandler.AdminUpdateUserProfile)          // User scoped
+	adminRoute.PUT("/user/settings", apiHandler.AdminUpdateUserProfileSettings) // User scoped
+	adminRoute.POST("/user/avatar", apiHandler.AdminUploadUserAvatar)           // User scoped
+	adminRoute.DELETE("/user/avatar", apiHandler.AdminDeleteUserAvatar)         // User scoped
+
+	adminRoute.GET("/user/sessions", apiHandler.AdminGetUserSessions)                  // User scoped
+	adminRoute.DELETE("/user/sessions", apiHandler.AdminDeleteUserSessions)            // User scoped
+	adminRoute.DELETE("/user/session/:sessionUuid", apiHandler.AdminDeleteUserSession) // User scoped
+
+	adminRoute.GET("/user/two-factor", apiHandler.AdminGetUserTwoFactor)                        // User scoped
+	adminRoute.POST("/user/two-factor/setup", apiHandler.AdminSetupUserTwoFactor)               // User scoped
+	adminRoute.POST("/user/two-factor/enable", apiHandler.AdminEnableUserTwoFactor)             // User scoped
+	adminRoute.DELETE("/user/two-factor", apiHandler.AdminDisableUserTwoFactor)                 // User scoped
+	adminRoute.POST("/user/two-factor/recovery-codes", apiHandler.AdminCreateUserRecoveryCodes) // User scoped
+
+	adminRoute.GET("/user/todos", apiHandler.AdminUserGetTodos)         // User scoped
+	adminRoute.GET("/user/todo/:todoId", apiHandler.AdminGetTodo)       // User scoped
+	adminRoute.PUT("/user/todo", apiHandler.AdminUpsertTodo)            // User scoped
+	adminRoute.DELETE("/user/todo/:todoId", apiHandler.AdminDeleteTodo) // User scoped
+
+	adminRoute.POST("/user/send-message", apiHandler.AdminSendMessage) // User scoped
+
+	// Deprecated, read from notifications for older dashboards
+	adminRoute.GET("/user/messages", apiHandler.AdminGetMessages)                                       // User scoped
+	adminRoute.GET("/user/org/:orgUuid/event/notifications", apiHandler.AdminGetUserEventNotifications) // User scoped
+
+	adminRoute.GET("/user/events", apiHandler.AdminStreamUserEvents)                                    // User scoped, Server-Sent Events
+	adminRoute.GET("/user/notifications", apiHandler.AdminGetUserNotifications)                         // User scoped
+	adminRoute.POST("/user/notifications/read", apiHandler.AdminReadUserNotifications)                  // User scoped
+	adminRoute.PUT("/user/notification/:notificationUuid", apiHandler.AdminUpdateUserNotification)      // User scoped
+	adminRoute.DELETE("/user/notification/:notificationUuid", apiHandler.AdminDeleteUserNotification)   // User scoped
+	adminRoute.GET("/user/notification-preferences", apiHandler.AdminGetUserNotificationPreferences)    // User scoped
+	adminRoute.PUT("/user/notification-preferences", apiHandler.AdminUpdateUserNotificationPreferences) // User scoped
+
+	adminRoute.GET("/user/choosable-orgs", apiHandler.AdminGetChoosableOrgs)                    // User scoped
 	adminRoute.GET("/user/choosable-event-venues", apiHandler.AdminGetChoosableUserEventVenues) // TODO: Unused, can be removed!
 
 	// Organization
 
-	adminRoute.GET("/org/:orgUuid/member/:memberUuid/permissions", apiHandler.AdminGetOrgMemberPermissions)    // TODO: Permission check
-	adminRoute.PUT("/org/:orgUuid/member/:memberUuid/permissions", apiHandler.AdminUpdateOrgMemberPermissions) // TODO: Permission check
-
-	adminRoute.POST("/org/create", apiHandler.AdminCreateOrg)          // TODO: Permission check
-	adminRoute.GET("/org/:orgUuid", apiHandler.AdminGetOrg)            // TODO: Permission check
-	adminRoute.PUT("/org/:orgUuid/fields", apiHandler.UpdateOrgFields) // TODO: Permission check
-	adminRoute.DELETE("/org/:orgUuid", apiHandler.AdminDeleteOrg)      // TODO: Permission check
-
-	adminRoute.GET("/org/list", apiHandler.AdminGetOrgList) // TODO: Permission check
-	adminRoute.GET("/org/:orgUuid/venues", apiHandler.AdminGetOrgVenues)
-	adminRoute.GET("/org/:orgUuid/events", apiHandler.AdminGetOrgEvents)   // TODO: Permission check
-	adminRoute.GET("/org/:orgUuid/portals", apiHandler.AdminGetOrgPortals) // TODO: Permission check
-
-	adminRoute.GET("/org/:orgUuid/team", apiHandler.AdminGetOrgTeam)            // TODO: Permission check
-	adminRoute.POST("/org/:orgUuid/team/invite", apiHandler.AdminOrgTeamInvite) // TODO: Permission check
-	adminRoute.DELETE("/org/:orgUuid/team/member/:memberUuid", apiHandler.AdminDeleteOrgTeamMember)
-	adminRoute.GET("/org/:orgUuid/choosable-venues", apiHandler.AdminGetOrgChoosableVenues)
+	adminRoute.GET("/org/:orgUuid/member/:memberUuid/permissions",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminGetOrgMemberPermissions)
+	adminRoute.PUT("/org/:orgUuid/member/:memberUuid/permissions",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminUpdateOrgMemberPermissions)
+	adminRoute.PUT("/org/:orgUuid/member/:memberUuid/role",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminUpdateOrgMemberRole)
+	adminRoute.GET("/org/:orgUuid/roles",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg, app.UserPermManagePermissions|app.UserPermManageTeam),
+		apiHandler.AdminGetOrgRoles)
+	adminRoute.POST("/org/:orgUuid/role",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminCreateOrgRole)
+	adminRoute.PUT("/org/:orgUuid/role/:roleUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminUpdateOrgRole)
+	adminRoute.DELETE("/org/:orgUuid/role/:roleUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminDeleteOrgRole)
+	adminRoute.GET("/org/:orgUuid/audit",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminGetOrgAuditLog)
+	adminRoute.PUT("/org/:orgUuid/two-factor-policy",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminUpdateOrgTwoFactorPolicy)
+	adminRoute.GET("/org/:orgUuid/availability-rule",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminGetOrgAvailabilityRule)
+	adminRoute.PUT("/org/:orgUuid/availability-rule",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminUpdateOrgAvailabilityRule)
+
+	adminRoute.POST("/org/create", apiHandler.AdminCreateOrg) // User scoped
+	adminRoute.GET("/org/:orgUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminGetOrg)
+	adminRoute.PUT("/org/:orgUuid/fields",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.UpdateOrgFields)
+	adminRoute.DELETE("/org/:orgUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermDeleteOrg),
+		apiHandler.AdminDeleteOrg)
+	adminRoute.POST("/org/:orgUuid/sync-key",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminCreateOrgSyncKey)
+	adminRoute.DELETE("/org/:orgUuid/sync-key",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminDeleteOrgSyncKey)
+	adminRoute.GET("/org/:orgUuid/webhooks",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminGetOrgWebhooks)
+	adminRoute.POST("/org/:orgUuid/webhook",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminCreateOrgWebhook)
+	adminRoute.PUT("/org/:orgUuid/webhook/:webhookUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminUpdateOrgWebhook)
+	adminRoute.DELETE("/org/:orgUuid/webhook/:webhookUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminDeleteOrgWebhook)
+	adminRoute.GET("/org/:orgUuid/webhook/:webhookUuid/deliveries",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminGetOrgWebhookDeliveries)
+	adminRoute.POST("/org/:orgUuid/webhook/:webhookUuid/delivery/:deliveryId/retry",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditOrg),
+		apiHandler.AdminRetryOrgWebhookDelivery)
+	adminRoute.GET("/org/:orgUuid/api-keys",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminGetOrgApiKeys)
+	adminRoute.POST("/org/:orgUuid/api-key",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminCreateOrgApiKey)
+	adminRoute.DELETE("/org/:orgUuid/api-key/:apiKeyUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminDeleteOrgApiKey)
+	adminRoute.GET("/org/:orgUuid/oidc-group-mappings",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminGetOrgOidcGroupMappings)
+	adminRoute.POST("/org/:orgUuid/oidc-group-mapping",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminCreateOrgOidcGroupMapping)
+	adminRoute.DELETE("/org/:orgUuid/oidc-group-mapping/:mappingUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManagePermissions),
+		apiHandler.AdminDeleteOrgOidcGroupMapping)
+
+	adminRoute.GET("/org/list", apiHandler.AdminGetOrgList) // User scoped
+	apiKeyRoute.GET("/org/:orgUuid/venues",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg,
+			app.UserPermEditOrg|app.UserPermAddVenue|app.UserPermEditVenue|app.UserPermDeleteVenue|app.UserPermAddSpace|app.UserPermEditSpace),
+		apiHandler.AdminGetOrgVenues)
+	apiKeyRoute.GET("/org/:orgUuid/events",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg,
+			app.UserPermAddEvent|app.UserPermEditEvent|app.UserPermDeleteEvent|app.UserPermReleaseEvent|app.UserPermViewEventInsights),
+		apiHandler.AdminGetOrgEvents)
+	apiKeyRoute.POST("/org/:orgUuid/events/import",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermChooseAsEventOrg|app.UserPermAddEvent),
+		apiHandler.AdminImportOrgEvents)
+	apiKeyRoute.GET("/org/:orgUuid/events/scheduled-transitions",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg, app.UserPermEditEvent|app.UserPermReleaseEvent),
+		apiHandler.AdminGetOrgScheduledTransitions)
+	apiKeyRoute.GET("/org/:orgUuid/portals",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg, app.UserPermAddPortal|app.UserPermEditPortal|app.UserPermDeletePortal),
+		apiHandler.AdminGetOrgPortals)
+
+	adminRoute.GET("/org/:orgUuid/team",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManageTeam),
+		apiHandler.AdminGetOrgTeam)
+	adminRoute.POST("/org/:orgUuid/team/invite",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManageTeam),
+		apiHandler.AdminOrgTeamInvite)
+	adminRoute.DELETE("/org/:orgUuid/team/member/:memberUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermManageTeam),
+		apiHandler.AdminDeleteOrgTeamMember)
+	apiKeyRoute.GET("/org/:orgUuid/choosable-venues",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg, app.UserPermAddEvent|app.UserPermEditEvent),
+		apiHandler.AdminGetOrgChoosableVenues)
 
 	// Partner
 
-	adminRoute.GET("/org/:orgUuid/partnership-connections", apiHandler.AdminOrgPartnershipConnections)           // TODO: Permission check
-	adminRoute.GET("/org/partnership-connections-by-user", apiHandler.AdminOrgPartnershipConnectionsByUser)      // TODO: Permission check
-	adminRoute.GET("/org/:orgUuid/partner/grants", apiHandler.AdminGetOrgPartnerGrants)                          // TODO: Permission check
-	adminRoute.GET("/org/:orgUuid/partner/requests", apiHandler.AdminGetOrgPartnerRequest)                       // TODO: Permission check
-	adminRoute.POST("/org/:orgUuid/partner/:partnerUuid/grants", apiHandler.AdminUpdateOrgPartnerGrants)         // TODO: Permission check
-	adminRoute.POST("/org/:orgUuid/partner/request", apiHandler.AdminInsertOrgPartnerRequest)                    // TODO: Permission check
-	adminRoute.POST("/org/:orgUuid/partner/request/:partnerUuid/accept", apiHandler.AdminInsertOrgPartnerAccept) // TODO: Permission check
-	adminRoute.POST("/org/:orgUuid/partner/request/:partnerUuid/reject", apiHandler.AdminOrgPartnerReject)       // TODO: Permission check
+	adminRoute.GET("/org/:orgUuid/partnership-connections",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg, app.UserPermRequestPartner|app.UserPermAnswerPartnerRequest|app.UserPermEditPartnerRights),
+		apiHandler.AdminOrgPartnershipConnections)
+	adminRoute.GET("/org/partnership-connections-by-user", apiHandler.AdminOrgPartnershipConnectionsByUser) // User scoped
+	adminRoute.GET("/org/:orgUuid/partner/grants",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg, app.UserPermAnswerPartnerRequest|app.UserPermEditPartnerRights),
+		apiHandler.AdminGetOrgPartnerGrants)
+	adminRoute.GET("/org/:orgUuid/partner/requests",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg, app.UserPermRequestPartner|app.UserPermAnswerPartnerRequest),
+		apiHandler.AdminGetOrgPartnerRequest)
+	adminRoute.POST("/org/:orgUuid/partner/:partnerUuid/grants",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermEditPartnerRights),
+		apiHandler.AdminUpdateOrgPartnerGrants)
+	adminRoute.POST("/org/:orgUuid/partner/request",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermRequestPartner),
+		apiHandler.AdminInsertOrgPartnerRequest)
+	adminRoute.POST("/org/:orgUuid/partner/request/:partnerUuid/accept",
+		apiHandler.RequireOrgPermissions(api.OrgEntityOrg, app.UserPermAnswerPartnerRequest),
+		apiHandler.AdminInsertOrgPartnerAccept)
+	adminRoute.POST("/org/:orgUuid/partner/request/:partnerUuid/reject",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityOrg, app.UserPermAnswerPartnerRequest|app.UserPermDeletePartnership),
+		apiHandler.AdminOrgPartnerReject)
 
 	// Venue
 
-	adminRoute.GET("/venue/:venueUuid", apiHandler.AdminGetVenue) // TODO: Permission check
-	adminRoute.POST("/venue/create", apiHandler.AdminCreateVenue) // TODO: Permission check
+	apiKeyRoute.GET("/venue/:venueUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityVenue, app.UserPermEditVenue),
+		apiHandler.AdminGetVenue)
+	adminRoute.POST("/venue/create", apiHandler.AdminCreateVenue) // Checked in handler, org is part of the payload
 	// adminRoute.PUT("/venue", apiHandler.AdminUpsertVenue) // TODO: refactor to be create with complete data set
-	adminRoute.PUT("/venue/:venueUuid/fields", apiHandler.AdminUpdateVenueFields) // TODO: Permission check
-	adminRoute.DELETE("/venue/:venueUuid", apiHandler.AdminDeleteVenue)           // TODO: Permission check
+	apiKeyRoute.PUT("/venue/:venueUuid/fields",
+		apiHandler.RequireOrgPermissions(api.OrgEntityVenue, app.UserPermEditVenue),
+		apiHandler.AdminUpdateVenueFields)
+	apiKeyRoute.DELETE("/venue/:venueUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityVenue, app.UserPermDeleteVenue),
+		apiHandler.AdminDeleteVenue)
 
 	// Space
 
 	adminRoute.GET("/space/:spaceUuid", apiHandler.AdminGetSpace) // Permission check ok
-	adminRoute.POST("/space/create", apiHandler.AdminCreateSpace) // TODO: Permission check
+	adminRoute.POST("/space/create", apiHandler.AdminCreateSpace) // Checked in handler, venue is part of the payload
 	// adminRoute.PUT("/space", apiHandler.AdminUpsertSpace) // TODO: refactor to be create with complete data set
-	adminRoute.PUT("/space/:spaceUuid/fields", apiHandler.AdminUpdateSpaceFields) // Permission check ok
-	adminRoute.DELETE("/space/:spaceUuid", apiHandler.AdminDeleteSpace)           // Permission check ok
+	apiKeyRoute.PUT("/space/:spaceUuid/fields",
+		apiHandler.RequireOrgPermissions(api.OrgEntitySpace, app.UserPermEditSpace),
+		apiHandler.AdminUpdateSpaceFields)
+	apiKeyRoute.DELETE("/space/:spaceUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntitySpace, app.UserPermDeleteSpace),
+		apiHandler.AdminDeleteSpace)
 
 	// Event
 
-	adminRoute.GET("/event/:eventUuid", apiHandler.AdminGetEvent)                          // TODO: Permission check
-	adminRoute.POST("/event/:eventUuid/date", apiHandler.AdminUpsertEventDate)             // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/date/:dateUuid", apiHandler.AdminUpsertEventDate)    // TODO: Permission check
-	adminRoute.DELETE("/event/:eventUuid", apiHandler.AdminDeleteEvent)                    // Permission check ok
-	adminRoute.DELETE("/event/:eventUuid/date/:dateUuid", apiHandler.AdminDeleteEventDate) // Permission check ok
-
-	adminRoute.POST("/event/initial", apiHandler.AdminInitialEvent)                     // TODO: Permission check
-	adminRoute.POST("/event/create", apiHandler.AdminCreateEvent)                       // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/dates", apiHandler.AdminUpdateEventDates)         // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/types", apiHandler.AdminUpdateEventTypes)         // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/languages", apiHandler.AdminUpdateEventLanguages) // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/links", apiHandler.AdminUpdateEventLinks)         // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/venue", apiHandler.AdminUpdateEventVenue)         // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/fields", apiHandler.AdminUpdateEventFields)       // TODO: Permission check
-
-	adminRoute.PUT("/event/:eventUuid/release-status", apiHandler.AdminUpdateEventReleaseStatus)           // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/header", apiHandler.AdminUpdateEventHeader)                          // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/description", apiHandler.AdminUpdateEventDescription)                // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/summary", apiHandler.AdminUpdateEventSummary)                        // TODO: Permission check
-	adminRoute.PUT("/event/:eventUuid/participation-infos", apiHandler.AdminUpdateEventParticipationInfos) // TODO: Permission check
+	apiKeyRoute.GET("/event/:eventUuid",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityEvent, app.UserPermEditEvent|app.UserPermViewEventInsights),
+		apiHandler.AdminGetEvent)
+	apiKeyRoute.POST("/event/:eventUuid/date",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityEvent, app.UserPermAddEvent|app.UserPermEditEvent),
+		apiHandler.AdminUpsertEventDate)
+	apiKeyRoute.PUT("/event/:eventUuid/date/:dateUuid",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityEvent, app.UserPermAddEvent|app.UserPermEditEvent),
+		apiHandler.AdminUpsertEventDate)
+	apiKeyRoute.DELETE("/event/:eventUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityEvent, app.UserPermDeleteEvent),
+		apiHandler.AdminDeleteEvent)
+	apiKeyRoute.DELETE("/event/:eventUuid/date/:dateUuid",
+		apiHandler.RequireOrgPermissions(api.OrgEntityEvent, app.UserPermDeleteEvent),
+		apiHandler.AdminDeleteEventDate)
+	apiKeyRoute.PUT("/event/:eventUuid/date/:dateUuid/status",
+		apiHandler.RequireOrgPermissions(api.OrgEntityEvent, app.UserPermEditEvent|app.UserPermReleaseEvent),
+		apiHandler.AdminUpdateEventDateStatus)
+
+	adminRoute.POST("/event/initial", apiHandler.AdminInitialEvent) // Checked in handler, org is part of the payload
+	adminRoute.POST("/event/create", apiHandler.AdminCreateEvent)   // Checked in handler, org is part of the payload
+
+	requireEditEvent := apiHandler.RequireOrgPermissions(api.OrgEntityEvent, app.UserPermEditEvent)
+	apiKeyRoute.PUT("/event/:eventUuid/dates", requireEditEvent, apiHandler.AdminUpdateEventDates)
+	apiKeyRoute.PUT("/event/:eventUuid/types", requireEditEvent, apiHandler.AdminUpdateEventTypes)
+	apiKeyRoute.PUT("/event/:eventUuid/languages", requireEditEvent, apiHandler.AdminUpdateEventLanguages)
+	apiKeyRoute.PUT("/event/:eventUuid/links", requireEditEvent, apiHandler.AdminUpdateEventLinks)
+	apiKeyRoute.PUT("/event/:eventUuid/venue", requireEditEvent, apiHandler.AdminUpdateEventVenue)
+	apiKeyRoute.PUT("/event/:eventUuid/fields", requireEditEvent, apiHandler.AdminUpdateEventFields)
+
+	apiKeyRoute.GET("/event/:eventUuid/recurrence", requireEditEvent, apiHandler.AdminGetEventRecurrence)
+	apiKeyRoute.PUT("/event/:eventUuid/recurrence", requireEditEvent, apiHandler.AdminUpdateEventRecurrence)
+	apiKeyRoute.DELETE("/event/:eventUuid/recurrence", requireEditEvent, apiHandler.AdminDeleteEventRecurrence)
+
+	apiKeyRoute.PUT("/event/:eventUuid/release-status",
+		apiHandler.RequireOrgPermissions(api.OrgEntityEvent, app.UserPermReleaseEvent),
+		apiHandler.AdminUpdateEventReleaseStatus)
+	apiKeyRoute.PUT("/event/:eventUuid/header", requireEditEvent, apiHandler.AdminUpdateEventHeader)
+	apiKeyRoute.PUT("/event/:eventUuid/description", requireEditEvent, apiHandler.AdminUpdateEventDescription)
+	apiKeyRoute.PUT("/event/:eventUuid/summary", requireEditEvent, apiHandler.AdminUpdateEventSummary)
+	apiKeyRoute.PUT("/event/:eventUuid/participation-infos", requireEditEvent, apiHandler.AdminUpdateEventParticipationInfos)
+
+	apiKeyRoute.GET("/event/:eventUuid/revisions", requireEditEvent, apiHandler.AdminGetEventRevisions)
+	apiKeyRoute.GET("/event/:eventUuid/revisions/diff", requireEditEvent, apiHandler.AdminGetEventRevisionDiff)
+	apiKeyRoute.GET("/event/:eventUuid/revision/:revision", requireEditEvent, apiHandler.AdminGetEventRevision)
+	apiKeyRoute.POST("/event/:eventUuid/revision/:revision/restore", requireEditEvent, apiHandler.AdminRestoreEventRevision)
+
+	apiKeyRoute.GET("/event/:eventUuid/registration", requireEditEvent, apiHandler.AdminGetEventRegistrationConfig)
+	apiKeyRoute.PUT("/event/:eventUuid/registration", requireEditEvent, apiHandler.AdminUpdateEventRegistrationConfig)
+	apiKeyRoute.GET("/event/:eventUuid/date/:dateIdentifier/registrations",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityEvent, app.UserPermEditEvent|app.UserPermViewEventInsights),
+		apiHandler.AdminGetEventDateRegistrations)
+	apiKeyRoute.GET("/event/:eventUuid/date/:dateIdentifier/registrations.csv",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityEvent, app.UserPermEditEvent|app.UserPermViewEventInsights),
+		apiHandler.AdminExportEventDateRegistrations)
+	apiKeyRoute.POST("/event/:eventUuid/date/:dateUuid/registration/:registrationUuid/cancel", requireEditEvent, apiHandler.AdminCancelEventRegistration)
+
+	apiKeyRoute.GET("/event/:eventUuid/price-tiers",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityEvent, app.UserPermEditEvent|app.UserPermViewEventInsights),
+		apiHandler.AdminGetEventPriceTiers)
+	apiKeyRoute.POST("/event/:eventUuid/price-tier", requireEditEvent, apiHandler.AdminCreateEventPriceTier)
+	apiKeyRoute.PUT("/event/:eventUuid/price-tier/:tierUuid", requireEditEvent, apiHandler.AdminUpdateEventPriceTier)
+	apiKeyRoute.DELETE("/event/:eventUuid/price-tier/:tierUuid", requireEditEvent, apiHandler.AdminDeleteEventPriceTier)
+	apiKeyRoute.GET("/event/:eventUuid/date/:dateIdentifier/tickets",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityEvent, app.UserPermEditEvent|app.UserPermViewEventInsights),
+		apiHandler.AdminGetEventDateTickets)
+	apiKeyRoute.POST("/event/:eventUuid/date/:dateUuid/tickets", requireEditEvent, apiHandler.AdminIssueEventDateTickets)
+	apiKeyRoute.POST("/event/:eventUuid/date/:dateUuid/ticket/:ticketUuid/cancel", requireEditEvent, apiHandler.AdminCancelEventTicket)
+	apiKeyRoute.POST("/event/:eventUuid/date/:dateUuid/check-in", requireEditEvent, apiHandler.AdminCheckInTickets)
+	apiKeyRoute.GET("/event/:eventUuid/date/:dateIdentifier/ticket-count",
+		apiHandler.RequireAnyOrgPermission(api.OrgEntityEvent, app.UserPermEditEvent|app.UserPermViewEventInsights),
+		apiHandler.AdminGetEventDateTicketCount)
+	apiKeyRoute.PUT("/event/:eventUuid/date/:dateUuid/ticket-count", requireEditEvent, apiHandler.AdminPushEventDateTicketCount)
+	apiKeyRoute.DELETE("/event/:eventUuid/date/:dateUuid/ticket-count", requireEditEvent, apiHandler.AdminDeleteEventDateTicketCount)
 
 	// Portal
 
-	adminRoute.GET("/portal/:portalUuid", apiHandler.AdminGetPortal)                 // TODO: Permission check
-	adminRoute.POST("/portal/create", apiHandler.AdminCreatePortal)                  // TODO: Permission check
-	adminRoute.PUT("/portal/:portalUuid/fields", apiHandler.AdminUpdatePortalFields) // TODO: Permission check
-	adminRoute.PUT("/portal/:portalUuid/filter", apiHandler.AdminUpdatePortalFilter) // TODO: Permission check
-	adminRoute.PUT("/portal/:portalUuid/style", apiHandler.AdminUpdatePortalStyle)   // TODO: Permission check
-	adminRoute.PUT("/portal/:portalUuid/header", apiHandler.AdminUpdatePortalHeader) // TODO: Permission check
-	adminRoute.PUT("/portal/:portalUuid/footer", apiHandler.AdminUpdatePortalFooter) // TODO: Permission check
+	requireEditPortal := apiHandler.RequireOrgPermissions(api.OrgEntityPortal, app.UserPermEditPortal)
+	apiKeyRoute.GET("/portal/:portalUuid", requireEditPortal, apiHandler.AdminGetPortal)
+	adminRoute.POST("/portal/create", apiHandler.AdminCreatePortal) // Checked in handler, org is part of the payload
+	apiKeyRoute.PUT("/portal/:portalUuid/fields", requireEditPortal, apiHandler.AdminUpdatePortalFields)
+	apiKeyRoute.PUT("/portal/:portalUuid/filter", requireEditPortal, apiHandler.AdminUpdatePortalFilter)
+	apiKeyRoute.PUT("/portal/:portalUuid/style", requireEditPortal, apiHandler.AdminUpdatePortalStyle)
+	apiKeyRoute.PUT("/portal/:portalUuid/header", requireEditPortal, apiHandler.AdminUpdatePortalHeader)
+	apiKeyRoute.PUT("/portal/:portalUuid/footer", requireEditPortal, apiHandler.AdminUpdatePortalFooter)
 
 	// Favorites
 
-	adminRoute.GET("/org/:orgUuid/favorite-lists", apiHandler.AdminGetFavoriteLists)               // TODO: Permission check
-	adminRoute.POST("/favorite-list/create", apiHandler.AdminCreateFavoriteList)                   // TODO: Permission check
-	adminRoute.POST("/favorite-list/toggle-event-date", apiHandler.AdminToggleFavoriteEventDate)   // TODO: Permission check
-	adminRoute.POST("/favorite-list/check-event-date", apiHandler.AdminCheckFavoriteListEventDate) // TODO: Permission check
+	adminRoute.GET("/org/:orgUuid/favorite-lists", apiHandler.AdminGetFavoriteLists)               // Permission check in SQL
+	adminRoute.POST("/favorite-list/create", apiHandler.AdminCreateFavoriteList)                   // Checked in handler, org is part of the payload
+	adminRoute.POST("/favorite-list/toggle-event-date", apiHandler.AdminToggleFavoriteEventDate)   // Checked in handler, list is part of the payload
+	adminRoute.POST("/favorite-list/check-event-date", apiHandler.AdminCheckFavoriteListEventDate) // Checked in handler, list is part of the payload
 
 	// Pluto Image
 
-	adminRoute.POST("/image/:context/:contextUuid/:identifier", apiHandler.AdminUpsertPlutoImage)   // TODO: Permission check
-	adminRoute.DELETE("/image/:context/:contextUuid/:identifier", apiHandler.AdminDeletePlutoImage) // TODO: Permission check
+	requireEditImage := apiHandler.RequireContextOrgPermissions(map[api.OrgEntity]app.Permissions{
+		api.OrgEntityOrg:    app.UserPermEditOrg,
+		api.OrgEntityVenue:  app.UserPermEditVenue,
+		api.OrgEntityEvent:  app.UserPermEditEvent,
+		api.OrgEntityPortal: app.UserPermEditPortal,
+	})
+	apiKeyRoute.POST("/image/:context/:contextUuid/:identifier", requireEditImage, apiHandler.AdminUpsertPlutoImage)
+	apiKeyRoute.DELETE("/image/:context/:contextUuid/:identifier", requireEditImage, apiHandler.AdminDeletePlutoImage)
+
+	//
+	// Visitor endpoints, user must be logged in. Visitor accounts have no
+	// access to the admin API, members can use these endpoints as well.
+	//
+
+	visitorRoute := router.Group("/api/visitor")
+	visitorRoute.Use(app.JWTMiddleware, apiHandler.RateLimit("visitor"))
+
+	visitorRoute.POST("/logout", apiHandler.AdminLogout)                      // User scoped
+	visitorRoute.GET("/user/profile", apiHandler.AdminGetUserProfile)         // User scoped
+	visitorRoute.PUT("/user/profile", apiHandler.AdminUpdateUserProfile)      // User scoped
+	visitorRoute.GET("/user/sessions", apiHandler.AdminGetUserSessions)       // User scoped
+	visitorRoute.DELETE("/user/sessions", apiHandler.AdminDeleteUserSessions) // User scoped
+
+	visitorRoute.GET("/user/notifications", apiHandler.AdminGetUserNotifications)                         // User scoped
+	visitorRoute.POST("/user/notifications/read", apiHandler.AdminReadUserNotifications)                  // User scoped
+	visitorRoute.PUT("/user/notification/:notificationUuid", apiHandler.AdminUpdateUserNotification)      // User scoped
+	visitorRoute.DELETE("/user/notification/:notificationUuid", apiHandler.AdminDeleteUserNotification)   // User scoped
+	visitorRoute.GET("/user/notification-preferences", apiHandler.AdminGetUserNotificationPreferences)    // User scoped
+	visitorRoute.PUT("/user/notification-preferences", apiHandler.AdminUpdateUserNotificationPreferences) // User scoped
+
+	visitorRoute.GET("/agenda", apiHandler.VisitorGetAgenda)                                     // User scoped
+	visitorRoute.PUT("/agenda/event-date/:eventDateUuid", apiHandler.VisitorUpsertAgendaItem)    // User scoped
+	visitorRoute.DELETE("/agenda/event-date/:eventDateUuid", apiHandler.VisitorDeleteAgendaItem) // User scoped
+	visitorRoute.POST("/agenda/ics-token", apiHandler.VisitorCreateAgendaICSToken)               // User scoped
+	visitorRoute.DELETE("/agenda/ics-token", apiHandler.VisitorDeleteAgendaICSToken)             // User scoped
 
 	//
 	// Internal endpoints, callable only from localhost
@@ -351,6 +710,18 @@ func main() {
 	internalRoute.GET("/test", apiHandler.InternalTest)                                                  // TODO: Check!
 	internalRoute.GET("/migrate-venues", apiHandler.InternalMigrateVenues)                               // TODO: Check!
 
+	// Outbound email queue
+	internalRoute.GET("/emails", apiHandler.InternalGetEmailMessages)
+	internalRoute.POST("/email/bounce", apiHandler.InternalBounceEmailMessage)
+	internalRoute.POST("/email/:emailId/retry", apiHandler.InternalRetryEmailMessage)
+
+	// Local push endpoints for testing web push, reachable by the push worker in dev mode
+	internalRoute.POST("/push/stub", apiHandler.InternalCreatePushStub)
+	internalRoute.GET("/push/stub/:stubId", apiHandler.InternalGetPushStub)
+	internalRoute.PUT("/push/stub/:stubId", apiHandler.InternalUpdatePushStub)
+	internalRoute.POST("/push/stub/:stubId", apiHandler.InternalReceivePushStub)
+	internalRoute.POST("/push/send", apiHandler.InternalSendPushMessages)
+
 	fmt.Println("Gin mode:", gin.Mode())
 	fmt.Println("Total routes:", len(router.Routes()))
 	// Print all registered routes
@@ -404,11 +775,11 @@ func CORSMiddleware() gin.HandlerFunc {
 			c.Header("Access-Control-Allow-Origin", "*")
 			c.Header(
 				"Access-Control-Allow-Headers",
-				"Accept, Content-Type, Authorization",
+				"Accept, Content-Type, Authorization, X-Push-Auth",
 			)
 			c.Header(
 				"Access-Control-Allow-Methods",
-				"GET, POST, OPTIONS",
+				"GET, POST, PUT, DELETE, OPTIONS",
 			)
 		}
 